@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	transaction "github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+	"github.com/spf13/cobra"
+)
+
+var (
+	anchorFiles     []string
+	anchorRecipient string
+	anchorAmount    int64
+)
+
+var anchorDataCmd = &cobra.Command{
+	Use:   "anchor [hex|sha256|merkle] [fee-rate] [data...]",
+	Short: "Commit data or document hashes to the chain in an OP_RETURN output",
+	Long: `Anchor up to 80 bytes in an OP_RETURN output and record the payload with the txid.
+	hex anchors raw hex data, sha256 a single document digest and merkle the merkle root of several digests.
+	Documents passed with --file are hashed locally and added as digests. Without --to the transaction
+	only pays the fee and sends the change back to the wallet.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		mode, feeRate, items := args[0], args[1], args[2:]
+		if _, err := strconv.Atoi(feeRate); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid fee rate: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, path := range anchorFiles {
+			digest, err := transaction.HashDocument(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "%s  %s\n", digest, path)
+			items = append(items, digest)
+		}
+
+		// Validate locally so an oversized payload never reaches the wallet
+		if _, _, err := transaction.BuildAnchorPayload(mode, items); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		amount := ""
+		if anchorRecipient != "" {
+			amount = strconv.FormatInt(anchorAmount, 10)
+		}
+		ipcArgs := append([]string{mode, feeRate, strings.Join(items, ","), anchorRecipient, amount}, spendInputs...)
+		result := sendIPCCommand("anchor-data", ipcArgs, "Error anchoring data")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var listAnchorsCmd = &cobra.Command{
+	Use:   "list-anchors",
+	Short: "List anchored payloads and their transactions",
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("list-anchors", args, "Error listing anchors")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+func init() {
+	anchorDataCmd.Flags().StringSliceVar(&anchorFiles, "file", nil, "documents to hash and anchor")
+	anchorDataCmd.Flags().StringVar(&anchorRecipient, "to", "", "optional recipient address paid alongside the anchor")
+	anchorDataCmd.Flags().Int64Var(&anchorAmount, "amount", 0, "amount in satoshis for --to")
+	anchorDataCmd.Flags().StringSliceVar(&spendInputs, "inputs", nil, "comma separated txid:vout outpoints to spend instead of automatic coin selection")
+}
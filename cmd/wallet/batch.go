@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	transaction "github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+	"github.com/spf13/cobra"
+)
+
+var batchOpReturn string
+
+var batchTransactionCmd = &cobra.Command{
+	Use:   "batch-transaction [recipients-file] [fee-rate]",
+	Short: "Pay several recipients in one transaction",
+	Long: `Pay every recipient listed in a CSV (address,amount) or JSON ([{"address","amount"}]) file
+	in a single transaction with one change output. Amounts are in satoshis and the fee rate in sat/vB.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		recipientsJSON, feeRate := readBatchArgs(args)
+
+		ipcArgs := append([]string{recipientsJSON, feeRate, batchOpReturn}, spendInputs...)
+		result := sendIPCCommand("batch-transaction", ipcArgs, "Error creating batch transaction")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var estimateBatchTransactionSizeCmd = &cobra.Command{
+	Use:   "estimate-batch-tx-size [recipients-file] [fee-rate]",
+	Short: "Estimate the size and fee of a batch transaction",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		recipientsJSON, feeRate := readBatchArgs(args)
+
+		result := sendIPCCommand("estimate-batch-transaction-size", []string{recipientsJSON, feeRate, batchOpReturn}, "Error estimating batch transaction size")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+func init() {
+	batchTransactionCmd.Flags().StringVar(&batchOpReturn, "op-return", "", "hex encoded OP_RETURN payload (max 80 bytes)")
+	estimateBatchTransactionSizeCmd.Flags().StringVar(&batchOpReturn, "op-return", "", "hex encoded OP_RETURN payload (max 80 bytes)")
+}
+
+// readBatchArgs loads the recipients file and validates the fee rate.
+func readBatchArgs(args []string) (string, string) {
+	recipients, err := transaction.ParseRecipientsFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid recipients file: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := strconv.Atoi(args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid fee rate: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := transaction.DecodeOpReturnHex(batchOpReturn); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	recipientsJSON, err := json.Marshal(recipients)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding recipients: %v\n", err)
+		os.Exit(1)
+	}
+	return string(recipientsJSON), args[1]
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/operations"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchOnlyAddressType string
+	watchOnlyFingerprint string
+)
+
+var exportDescriptorsCmd = &cobra.Command{
+	Use:   "export-descriptors",
+	Short: "Export the wallet's receive and change output descriptors",
+	Long: `Print wpkh([fingerprint/84h/0h/0h]xpub/0/*)#checksum style descriptors for every
+	account the open wallet holds, receive and change branch separately.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("export-descriptors", args, "Error exporting descriptors")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var importDescriptorCmd = &cobra.Command{
+	Use:   "import-descriptor [wallet-name] [descriptor] [password] [birthdate] [pubKey] [apiKey]",
+	Short: "Create a wallet from an output descriptor",
+	Long: `Create a wallet from a wpkh() or tr() descriptor. A descriptor over the master xprv
+	gives a full wallet; one over an account xpub gives a watch-only wallet.
+	Provide the wallet's birthdate in YYYY-MM-DD format.
+	Optionally provide a pubKey and apiKey for panel integration.`,
+	Args: cobra.RangeArgs(4, 6),
+	Run: func(cmd *cobra.Command, args []string) {
+		walletName := args[0]
+		desc := args[1]
+		password := args[2]
+		birthdate := args[3]
+		pubKey := ""
+		apiKey := ""
+		if len(args) > 4 {
+			pubKey = args[4]
+		}
+		if len(args) > 5 {
+			apiKey = args[5]
+		}
+
+		err := operations.ImportDescriptorWallet(walletName, desc, password, birthdate, pubKey, apiKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing descriptor: %v\n", err)
+			os.Exit(1)
+		}
+
+		result := struct {
+			WalletName string `json:"walletName"`
+			Message    string `json:"message"`
+		}{
+			WalletName: walletName,
+			Message:    "Wallet imported successfully",
+		}
+
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var importWatchOnlyCmd = &cobra.Command{
+	Use:   "import-watch-only [wallet-name] [xpub|zpub] [password] [birthdate] [pubKey] [apiKey]",
+	Short: "Create a watch-only wallet from an account extended public key",
+	Long: `Create a wallet that tracks an account-level xpub or zpub without ever holding the seed.
+	It syncs, generates addresses and reports to the backend like any other wallet, but cannot sign:
+	sends return an unsigned PSBT for the machine that holds the seed.
+	Provide the wallet's birthdate in YYYY-MM-DD format.
+	Optionally provide a pubKey and apiKey for panel integration.`,
+	Args: cobra.RangeArgs(4, 6),
+	Run: func(cmd *cobra.Command, args []string) {
+		walletName := args[0]
+		extendedKey := args[1]
+		password := args[2]
+		birthdate := args[3]
+		pubKey := ""
+		apiKey := ""
+		if len(args) > 4 {
+			pubKey = args[4]
+		}
+		if len(args) > 5 {
+			apiKey = args[5]
+		}
+
+		err := operations.ImportWatchOnlyWallet(walletName, extendedKey, watchOnlyAddressType, watchOnlyFingerprint, password, birthdate, pubKey, apiKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing watch-only wallet: %v\n", err)
+			os.Exit(1)
+		}
+
+		result := struct {
+			WalletName string `json:"walletName"`
+			Message    string `json:"message"`
+		}{
+			WalletName: walletName,
+			Message:    "Watch-only wallet imported successfully",
+		}
+
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+func init() {
+	importWatchOnlyCmd.Flags().StringVar(&watchOnlyAddressType, "address-type", "p2wpkh", "address type an xpub is read as: p2wpkh or p2tr")
+	importWatchOnlyCmd.Flags().StringVar(&watchOnlyFingerprint, "fingerprint", "", "master key fingerprint (8 hex characters) for exported descriptors and PSBTs")
+}
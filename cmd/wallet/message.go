@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	transaction "github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/spf13/cobra"
+)
+
+var messageFormat string
+
+var signMessageCmd = &cobra.Command{
+	Use:   "sign-message [address] [message]",
+	Short: "Sign a message with the key of a wallet address",
+	Long: `Prove ownership of a wallet address by signing a message with its key.
+	P2WPKH and P2TR addresses sign BIP322 simple signatures by default; P2PKH and nested segwit
+	addresses sign in the legacy Bitcoin Signed Message format. Use --format to choose.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		switch messageFormat {
+		case "", transaction.MessageFormatBIP322, transaction.MessageFormatLegacy:
+		default:
+			fmt.Fprintf(os.Stderr, "Invalid format %q, use %s or %s\n", messageFormat, transaction.MessageFormatBIP322, transaction.MessageFormatLegacy)
+			os.Exit(1)
+		}
+
+		result := sendIPCCommand("sign-message", []string{args[0], args[1], messageFormat}, "Error signing message")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var verifyMessageCmd = &cobra.Command{
+	Use:   "verify-message [address] [signature] [message]",
+	Short: "Verify a BIP322 or legacy message signature",
+	Long: `Check that the base64 signature of the message was made by the address.
+	Verification needs no wallet, so it works without a running wallet server.`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		valid, format, err := transaction.VerifyMessage(args[0], args[1], args[2], &chaincfg.MainNetParams)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error verifying message: %v\n", err)
+			os.Exit(1)
+		}
+
+		json.NewEncoder(os.Stdout).Encode(map[string]interface{}{
+			"address": args[0],
+			"format":  format,
+			"valid":   valid,
+		})
+		if !valid {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	signMessageCmd.Flags().StringVar(&messageFormat, "format", "", "signature format, bip322 or legacy (default by address type)")
+}
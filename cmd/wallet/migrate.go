@@ -3,31 +3,66 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/operations"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 // migrateCmd represents the migrate command
 var migrateCmd = &cobra.Command{
 	Use:   "migrate",
-	Short: "Migrate database (no longer needed)",
-	Long:  `This command is kept for backwards compatibility but is no longer needed as all wallets now use SQLite.`,
+	Short: "Convert .env wallet files to keystores",
+	Long: `Convert wallets still kept in .env files to versioned, authenticated keystores.
+	Each wallet is re-encrypted with its existing password and its .env file is kept as a .env.bak backup.
+	Without --wallet every wallet still in a .env file is migrated, asking for each password in turn.
+	Wallets are also migrated the first time they are opened.`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		// Get command line flags or defaults
 		walletName, _ := cmd.Flags().GetString("wallet")
-		baseDir, _ := cmd.Flags().GetString("dir")
+		password, _ := cmd.Flags().GetString("password")
+		validate, _ := cmd.Flags().GetBool("validate")
 
-		if baseDir == "" {
-			baseDir = "./wallets" // Default directory
+		wallets := []string{walletName}
+		if walletName == "" {
+			legacy, err := operations.LegacyWallets()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error listing wallets: %v\n", err)
+				os.Exit(1)
+			}
+			if len(legacy) == 0 {
+				fmt.Println("No wallets need migrating.")
+				return
+			}
+			wallets = legacy
 		}
 
-		// Skip any actual migration since SQLite is now the only database
-		fmt.Printf("Migration complete for wallet '%s'.\n", walletName)
-		fmt.Println("All wallets now use SQLite database backend.")
-		fmt.Printf("Database located at: %s/%s_wallet.db\n", baseDir, walletName)
+		for _, name := range wallets {
+			walletPassword := password
+			if walletPassword == "" {
+				fmt.Printf("Enter the password of wallet '%s': ", name)
+				passwordBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
+				fmt.Println() // Add newline after password input
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
+					os.Exit(1)
+				}
+				walletPassword = strings.TrimSpace(string(passwordBytes))
+			}
 
-		// Explicitly exit to prevent any further processing
-		os.Exit(0)
+			if err := operations.MigrateWallet(name, walletPassword); err != nil {
+				fmt.Fprintf(os.Stderr, "Error migrating wallet '%s': %v\n", name, err)
+				os.Exit(1)
+			}
+			if validate {
+				if _, err := operations.ReadSeedPhrase(name, walletPassword); err != nil {
+					fmt.Fprintf(os.Stderr, "Error validating wallet '%s': %v\n", name, err)
+					os.Exit(1)
+				}
+			}
+			fmt.Printf("Wallet '%s' migrated to a keystore; the .env file was kept as %s.env.bak.\n", name, name)
+		}
 	},
 }
 
@@ -35,9 +70,9 @@ func init() {
 	rootCmd.AddCommand(migrateCmd)
 
 	// Add flags specific to this command
-	migrateCmd.Flags().StringP("wallet", "w", "", "Wallet name to migrate")
-	migrateCmd.Flags().StringP("dir", "d", "", "Base directory containing the wallet data")
+	migrateCmd.Flags().StringP("wallet", "w", "", "Wallet name to migrate (default: every .env wallet)")
+	migrateCmd.Flags().StringP("password", "p", "", "Wallet password (asked for when omitted)")
 
 	// Optional: validate
-	migrateCmd.Flags().BoolP("validate", "v", false, "Validate data after migration")
+	migrateCmd.Flags().BoolP("validate", "v", false, "Open the new keystore with the password after migration")
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/operations"
+	"github.com/spf13/cobra"
+)
+
+var multisigCosigners []string
+
+var createMultisigCmd = &cobra.Command{
+	Use:   "create-multisig [wallet-name] [threshold] [mnemonic] [password] [birthdate] [pubKey] [apiKey]",
+	Short: "Create a k-of-n P2WSH multisig wallet with cosigner xpubs",
+	Long: `Create a wsh(sortedmulti()) wallet from the BIP48 account key of your mnemonic and
+	one --cosigner account key per cosigner, given as [fingerprint/48h/0h/0h/2h]xpub or a bare xpub/Zpub.
+	The wallet signs every spend with its own key and returns a PSBT for the cosigners to complete.
+	Provide the wallet's birthdate in YYYY-MM-DD format.
+	Optionally provide a pubKey and apiKey for panel integration.`,
+	Args: cobra.RangeArgs(5, 7),
+	Run: func(cmd *cobra.Command, args []string) {
+		walletName := args[0]
+		threshold, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid threshold: %v\n", err)
+			os.Exit(1)
+		}
+		mnemonic := args[2]
+		password := args[3]
+		birthdate := args[4]
+		pubKey := ""
+		apiKey := ""
+		if len(args) > 5 {
+			pubKey = args[5]
+		}
+		if len(args) > 6 {
+			apiKey = args[6]
+		}
+
+		err = operations.CreateMultisigWallet(walletName, threshold, mnemonic, bip39Passphrase, multisigCosigners, password, birthdate, pubKey, apiKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating multisig wallet: %v\n", err)
+			os.Exit(1)
+		}
+
+		result := struct {
+			WalletName string `json:"walletName"`
+			Message    string `json:"message"`
+		}{
+			WalletName: walletName,
+			Message:    fmt.Sprintf("%d-of-%d multisig wallet created successfully", threshold, len(multisigCosigners)+1),
+		}
+
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var multisigKeyCmd = &cobra.Command{
+	Use:   "multisig-key [mnemonic]",
+	Short: "Print the account key to share with multisig cosigners",
+	Long: `Print the BIP48 P2WSH account key of a mnemonic as [fingerprint/48h/0h/0h/2h]xpub.
+	Each cosigner passes the others' keys to create-multisig with --cosigner.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := operations.MultisigCosignerKey(args[0], bip39Passphrase)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error deriving multisig key: %v\n", err)
+			os.Exit(1)
+		}
+
+		result := struct {
+			Key string `json:"key"`
+		}{
+			Key: key,
+		}
+
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+func init() {
+	createMultisigCmd.Flags().StringSliceVar(&multisigCosigners, "cosigner", nil, "cosigner account key, repeat once per cosigner")
+	for _, cmd := range []*cobra.Command{createMultisigCmd, multisigKeyCmd} {
+		cmd.Flags().StringVar(&bip39Passphrase, "passphrase", "", "BIP39 passphrase (25th word) protecting the seed")
+	}
+}
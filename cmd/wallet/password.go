@@ -0,0 +1,20 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var changePasswordCmd = &cobra.Command{
+	Use:   "change-password [wallet-name] [old-password] [new-password]",
+	Short: "Change the password that encrypts a wallet",
+	Long: `Re-encrypt the wallet's seed, passphrases and birthdate under a new password.
+	The wallet must be open in the running wallet server, which also rotates its internal private passphrase.`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("change-password", args, "Error changing password")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	paymentExpiry string
+	paymentMemo   string
+)
+
+var paymentRequestCmd = &cobra.Command{
+	Use:   "payment-request",
+	Short: "Create and track requests for payment to fresh addresses",
+	Long: `Payment requests ask for an amount to be paid to a newly allocated address before an expiry.
+	The wallet matches incoming payments on every rescan and marks each request unpaid, pending,
+	underpaid, paid, overpaid or expired. Payments count once they have payment_min_confirmations
+	confirmations; until then the request is pending.`,
+}
+
+var paymentRequestCreateCmd = &cobra.Command{
+	Use:   "create [amount]",
+	Short: "Request an amount in satoshis, 0 for any amount",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid amount: %v\n", err)
+			os.Exit(1)
+		}
+		validatePaymentExpiry()
+
+		result := sendIPCCommand("create-payment-request", []string{args[0], paymentExpiry, paymentMemo}, "Error creating payment request")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var paymentRequestListCmd = &cobra.Command{
+	Use:   "list [status]",
+	Short: "List payment requests, optionally by status",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("list-payment-requests", args, "Error listing payment requests")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var paymentRequestGetCmd = &cobra.Command{
+	Use:   "get [id]",
+	Short: "Show a payment request and its URI",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("get-payment-request", args, "Error getting payment request")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var paymentRequestUpdateCmd = &cobra.Command{
+	Use:   "update [id]",
+	Short: "Change the memo or extend the expiry of an unpaid request",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		validatePaymentExpiry()
+
+		ipcArgs := []string{args[0], paymentExpiry}
+		if cmd.Flags().Changed("memo") {
+			ipcArgs = append(ipcArgs, paymentMemo)
+		}
+		result := sendIPCCommand("update-payment-request", ipcArgs, "Error updating payment request")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var paymentRequestDeleteCmd = &cobra.Command{
+	Use:   "delete [id]",
+	Short: "Delete a payment request; its address is not reused",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("delete-payment-request", args, "Error deleting payment request")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+func validatePaymentExpiry() {
+	if paymentExpiry == "" {
+		return
+	}
+	if _, err := time.ParseDuration(paymentExpiry); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid expiry: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	paymentRequestCreateCmd.Flags().StringVar(&paymentExpiry, "expiry", "", "how long the request stays open, e.g. 30m or 24h (default 1h)")
+	paymentRequestCreateCmd.Flags().StringVar(&paymentMemo, "memo", "", "description shown to the payer")
+	paymentRequestUpdateCmd.Flags().StringVar(&paymentExpiry, "expiry", "", "new expiry from now, e.g. 30m or 24h")
+	paymentRequestUpdateCmd.Flags().StringVar(&paymentMemo, "memo", "", "new description")
+
+	paymentRequestCmd.AddCommand(paymentRequestCreateCmd, paymentRequestListCmd, paymentRequestGetCmd, paymentRequestUpdateCmd, paymentRequestDeleteCmd)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var spendingPolicyCmd = &cobra.Command{
+	Use:   "spending-policy",
+	Short: "Show the spending policy and how much of its limits is used",
+	Long: `Show the rules in spending_policy_file and the amounts signed for in the last 24 hours and 30 days.
+	Every send, sweep, bump and PSBT signature is checked against the policy before the wallet signs it.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("spending-policy", args, "Error reading spending policy")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	transaction "github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+	"github.com/spf13/cobra"
+)
+
+var (
+	psbtOutFile string
+	psbtBinary  bool
+)
+
+var psbtCmd = &cobra.Command{
+	Use:   "psbt",
+	Short: "Create, sign, finalize and broadcast PSBTs",
+	Long: `Work with partially signed bitcoin transactions (BIP174).
+	PSBTs can be passed as base64 strings or as paths to base64 or binary files.`,
+}
+
+var psbtCreateCmd = &cobra.Command{
+	Use:   "create [recipient] [amount] [fee-rate]",
+	Short: "Create an unsigned PSBT",
+	Long:  `Create an unsigned PSBT paying the recipient the amount (in satoshis) at the fee rate (in sat/vB).`,
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := strconv.ParseInt(args[1], 10, 64); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid amount: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := strconv.Atoi(args[2]); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid fee rate: %v\n", err)
+			os.Exit(1)
+		}
+
+		result := sendIPCCommand("psbt-create", append(args, spendInputs...), "Error creating PSBT")
+		outputPSBTResult(result)
+	},
+}
+
+var psbtSignCmd = &cobra.Command{
+	Use:   "sign [psbt-or-file]",
+	Short: "Sign a PSBT with the wallet keys",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("psbt-sign", []string{readPSBTArg(args[0])}, "Error signing PSBT")
+		outputPSBTResult(result)
+	},
+}
+
+var psbtFinalizeCmd = &cobra.Command{
+	Use:   "finalize [psbt-or-file]",
+	Short: "Finalize a PSBT and extract the transaction",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("psbt-finalize", []string{readPSBTArg(args[0])}, "Error finalizing PSBT")
+		outputPSBTResult(result)
+	},
+}
+
+var psbtBroadcastCmd = &cobra.Command{
+	Use:   "broadcast [psbt-or-file]",
+	Short: "Finalize and broadcast a PSBT",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("psbt-broadcast", []string{readPSBTArg(args[0])}, "Error broadcasting PSBT")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{psbtCreateCmd, psbtSignCmd, psbtFinalizeCmd} {
+		c.Flags().StringVarP(&psbtOutFile, "out", "o", "", "write the resulting PSBT to this file")
+		c.Flags().BoolVar(&psbtBinary, "binary", false, "write the output file in binary instead of base64")
+	}
+	psbtCmd.AddCommand(psbtCreateCmd, psbtSignCmd, psbtFinalizeCmd, psbtBroadcastCmd)
+}
+
+// readPSBTArg returns the base64 PSBT held in arg, reading it from a file when
+// arg is a path.
+func readPSBTArg(arg string) string {
+	if _, err := os.Stat(arg); err != nil {
+		return arg
+	}
+
+	packet, err := transaction.ReadPSBTFile(arg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading PSBT: %v\n", err)
+		os.Exit(1)
+	}
+	encoded, err := transaction.EncodePSBT(packet)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding PSBT: %v\n", err)
+		os.Exit(1)
+	}
+	return encoded
+}
+
+// outputPSBTResult prints the server result and, when --out is set, writes the
+// returned PSBT to a file as well.
+func outputPSBTResult(result map[string]interface{}) {
+	if psbtOutFile != "" {
+		encoded, _ := result["psbt"].(string)
+		packet, err := transaction.DecodePSBT([]byte(encoded))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error decoding PSBT from wallet server: %v\n", err)
+			os.Exit(1)
+		}
+		if err := transaction.WritePSBTFile(psbtOutFile, packet, psbtBinary); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing PSBT file: %v\n", err)
+			os.Exit(1)
+		}
+		result["file"] = psbtOutFile
+	}
+
+	json.NewEncoder(os.Stdout).Encode(result)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var scheduleTransactionCmd = &cobra.Command{
+	Use:   "schedule-transaction [recipient] [amount] [fee-rate] [locktime]",
+	Short: "Sign a payment now and broadcast it once a locktime is reached",
+	Long: `Create a transaction with an absolute nLockTime and keep it in the wallet until the chain reaches it.
+	A locktime below 500000000 is a block height, anything above is a unix timestamp.`,
+	Args: cobra.ExactArgs(4),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("schedule-transaction", append(args, spendInputs...), "Error scheduling transaction")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var listScheduledTransactionsCmd = &cobra.Command{
+	Use:   "list-scheduled [status]",
+	Short: "List scheduled transactions, optionally by status (scheduled, broadcast, cancelled)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("list-scheduled-transactions", args, "Error listing scheduled transactions")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var cancelScheduledTransactionCmd = &cobra.Command{
+	Use:   "cancel-scheduled [txid]",
+	Short: "Cancel a scheduled transaction before it is broadcast",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("cancel-scheduled-transaction", args, "Error cancelling scheduled transaction")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+func init() {
+	scheduleTransactionCmd.Flags().StringSliceVar(&spendInputs, "inputs", nil, "comma separated txid:vout outpoints to spend instead of automatic coin selection")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var unlockCmd = &cobra.Command{
+	Use:   "unlock [timeout]",
+	Short: "Unlock the wallet for signing",
+	Long: `Unlock the running wallet so it can sign transactions and messages until the timeout passes.
+	The timeout is a duration such as 10m or a number of seconds; it defaults to unlock_timeout and may not exceed max_unlock_timeout.
+	Only used when the server runs with unlock_sessions enabled.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		password, _ := cmd.Flags().GetString("password")
+		if password == "" {
+			fmt.Print("Enter wallet password: ")
+			passwordBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
+			fmt.Println() // Add newline after password input
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
+				os.Exit(1)
+			}
+			password = strings.TrimSpace(string(passwordBytes))
+		}
+
+		result := sendIPCCommand("unlock", append([]string{password}, args...), "Error unlocking wallet")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Lock the wallet, ending the unlock session",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("lock", args, "Error locking wallet")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+func init() {
+	unlockCmd.Flags().StringP("password", "p", "", "Wallet password (asked for when omitted)")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/creation"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/operations"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/slip39"
+	"github.com/spf13/cobra"
+)
+
+var (
+	shareGroupThreshold  int
+	shareGroups          []string
+	sharePassphrase      string
+	shareIterationExp    int
+	importShareMnemonics []string
+)
+
+var backupSharesCmd = &cobra.Command{
+	Use:   "backup-shares [wallet-name] [password]",
+	Short: "Split the wallet's seed into SLIP-39 Shamir shares",
+	Long: `Split the wallet's mnemonic into SLIP-39 share groups for distributed custody.
+	Each --group is written as threshold-of-count, e.g. --group 2-of-3 --group 3-of-5, and
+	--group-threshold sets how many groups must take part in a recovery.
+	An optional --share-passphrase encrypts the shares; it is needed again by import-shares.
+	A BIP39 passphrase used by the wallet is not part of the shares.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		walletName := args[0]
+		password := args[1]
+
+		var groups []slip39.Group
+		for _, spec := range shareGroups {
+			var group slip39.Group
+			if _, err := fmt.Sscanf(spec, "%d-of-%d", &group.Threshold, &group.Count); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid group %q, expected threshold-of-count\n", spec)
+				os.Exit(1)
+			}
+			groups = append(groups, group)
+		}
+
+		shares, err := operations.BackupShares(walletName, password, shareGroupThreshold, groups, sharePassphrase, shareIterationExp)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating shares: %v\n", err)
+			os.Exit(1)
+		}
+
+		passphraseMode, err := operations.WalletPassphraseMode(walletName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading passphrase mode: %v\n", err)
+			os.Exit(1)
+		}
+
+		type shareGroup struct {
+			Threshold int      `json:"threshold"`
+			Shares    []string `json:"shares"`
+		}
+		result := struct {
+			WalletName      string       `json:"walletName"`
+			GroupThreshold  int          `json:"groupThreshold"`
+			Groups          []shareGroup `json:"groups"`
+			PassphraseInUse bool         `json:"passphraseInUse"`
+		}{
+			WalletName:      walletName,
+			GroupThreshold:  shareGroupThreshold,
+			PassphraseInUse: passphraseMode != operations.PassphraseNone,
+		}
+		for i, group := range groups {
+			result.Groups = append(result.Groups, shareGroup{Threshold: group.Threshold, Shares: shares[i]})
+		}
+
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var importSharesCmd = &cobra.Command{
+	Use:   "import-shares [wallet-name] [password] [birthdate] [pubKey] [apiKey]",
+	Short: "Restore a wallet from SLIP-39 Shamir shares",
+	Long: `Restore a wallet from enough SLIP-39 shares, each given with --share, to meet the
+	group threshold and every used group's member threshold.
+	Give the --share-passphrase the shares were made with; a wrong one restores a different wallet.
+	Provide the wallet's birthdate in YYYY-MM-DD format.
+	Optionally provide a pubKey and apiKey for panel integration.`,
+	Args: cobra.RangeArgs(3, 5),
+	Run: func(cmd *cobra.Command, args []string) {
+		walletName := args[0]
+		password := args[1]
+		birthdate := args[2]
+		pubKey := ""
+		apiKey := ""
+		if len(args) > 3 {
+			pubKey = args[3]
+		}
+		if len(args) > 4 {
+			apiKey = args[4]
+		}
+
+		err := creation.ImportSharesAPI(walletName, importShareMnemonics, sharePassphrase, password, bip39Passphrase, storePassphrase, birthdate, pubKey, apiKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing shares: %v\n", err)
+			os.Exit(1)
+		}
+
+		result := struct {
+			WalletName string `json:"walletName"`
+			Message    string `json:"message"`
+		}{
+			WalletName: walletName,
+			Message:    "Wallet imported successfully",
+		}
+
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+func init() {
+	backupSharesCmd.Flags().IntVar(&shareGroupThreshold, "group-threshold", 1, "number of groups needed to recover")
+	backupSharesCmd.Flags().StringArrayVar(&shareGroups, "group", []string{"2-of-3"}, "member threshold and count of a group, repeat once per group")
+	backupSharesCmd.Flags().IntVar(&shareIterationExp, "iteration-exponent", 1, "PBKDF2 work factor protecting the share passphrase (0-15)")
+	importSharesCmd.Flags().StringArrayVar(&importShareMnemonics, "share", nil, "share mnemonic, repeat once per share")
+	importSharesCmd.Flags().StringVar(&bip39Passphrase, "passphrase", "", "BIP39 passphrase (25th word) protecting the seed")
+	importSharesCmd.Flags().BoolVar(&storePassphrase, "store-passphrase", true, "store the passphrase encrypted in the wallet file")
+	for _, cmd := range []*cobra.Command{backupSharesCmd, importSharesCmd} {
+		cmd.Flags().StringVar(&sharePassphrase, "share-passphrase", "", "SLIP-39 passphrase encrypting the shares")
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var sweepTransactionCmd = &cobra.Command{
+	Use:   "sweep [recipient] [fee-rate] [outpoint...]",
+	Short: "Send the whole balance, or selected outpoints, with no change",
+	Long: `Spend every confirmed UTXO, or only the given txid:vout outpoints, to the recipient.
+	The amount sent is the total minus the fee at the given rate (in sat/vB); no change output is created.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("sweep-transaction", args, "Error creating sweep transaction")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var maxSendableCmd = &cobra.Command{
+	Use:   "max-sendable [recipient-address] [fee-rate] [outpoint...]",
+	Short: "Show the most that can be sent at a fee rate",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("max-sendable", args, "Error calculating max sendable amount")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
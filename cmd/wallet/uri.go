@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	uriAmount  int64
+	uriLabel   string
+	uriMessage string
+	uriPayJoin string
+)
+
+var paymentURICmd = &cobra.Command{
+	Use:   "payment-uri",
+	Short: "Allocate a receive address and print a bitcoin: payment URI for it",
+	Long: `Allocate the next receive address and build a BIP21 URI for it.
+	The amount is in satoshis and shown in BTC in the URI; without one the payer chooses.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		amount := ""
+		if uriAmount > 0 {
+			amount = strconv.FormatInt(uriAmount, 10)
+		}
+		result := sendIPCCommand("payment-uri", []string{amount, uriLabel, uriMessage, uriPayJoin}, "Error creating payment URI")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+func init() {
+	paymentURICmd.Flags().Int64Var(&uriAmount, "amount", 0, "requested amount in satoshis")
+	paymentURICmd.Flags().StringVar(&uriLabel, "label", "", "label for the recipient")
+	paymentURICmd.Flags().StringVar(&uriMessage, "message", "", "message describing the payment")
+	paymentURICmd.Flags().StringVar(&uriPayJoin, "pj", "", "BIP78 payjoin endpoint")
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -21,7 +20,7 @@ import (
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/creation"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/operations"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
-	"github.com/joho/godotenv"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/bip21"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -90,6 +89,8 @@ func init() {
 	rootCmd.AddCommand(openWalletCmd)
 	rootCmd.AddCommand(newTransactionCmd)
 	rootCmd.AddCommand(rbfTransactionCmd)
+	rootCmd.AddCommand(cpfpTransactionCmd)
+	rootCmd.AddCommand(cancelTransactionCmd)
 	rootCmd.AddCommand(getWalletBalanceCmd)
 	rootCmd.AddCommand(estimateTransactionSizeCmd)
 	rootCmd.AddCommand(getTransactionHistoryCmd)
@@ -97,6 +98,42 @@ func init() {
 	rootCmd.AddCommand(exitWalletCmd)
 	rootCmd.AddCommand(deleteWalletCmd)
 	rootCmd.AddCommand(viewSeedCmd)
+	rootCmd.AddCommand(psbtCmd)
+	rootCmd.AddCommand(batchTransactionCmd)
+	rootCmd.AddCommand(estimateBatchTransactionSizeCmd)
+	rootCmd.AddCommand(sweepTransactionCmd)
+	rootCmd.AddCommand(maxSendableCmd)
+	rootCmd.AddCommand(listUTXOsCmd)
+	rootCmd.AddCommand(freezeUTXOCmd)
+	rootCmd.AddCommand(unfreezeUTXOCmd)
+	rootCmd.AddCommand(labelUTXOCmd)
+	rootCmd.AddCommand(scheduleTransactionCmd)
+	rootCmd.AddCommand(listScheduledTransactionsCmd)
+	rootCmd.AddCommand(cancelScheduledTransactionCmd)
+	rootCmd.AddCommand(anchorDataCmd)
+	rootCmd.AddCommand(listAnchorsCmd)
+	rootCmd.AddCommand(exportDescriptorsCmd)
+	rootCmd.AddCommand(importDescriptorCmd)
+	rootCmd.AddCommand(importWatchOnlyCmd)
+	rootCmd.AddCommand(createMultisigCmd)
+	rootCmd.AddCommand(multisigKeyCmd)
+	rootCmd.AddCommand(backupSharesCmd)
+	rootCmd.AddCommand(importSharesCmd)
+	rootCmd.AddCommand(signMessageCmd)
+	rootCmd.AddCommand(verifyMessageCmd)
+	rootCmd.AddCommand(paymentURICmd)
+	rootCmd.AddCommand(paymentRequestCmd)
+	rootCmd.AddCommand(changePasswordCmd)
+	rootCmd.AddCommand(unlockCmd)
+	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(spendingPolicyCmd)
+
+	for _, cmd := range []*cobra.Command{createWalletCmd, importWalletCmd, openWalletCmd} {
+		cmd.Flags().StringVar(&bip39Passphrase, "passphrase", "", "BIP39 passphrase (25th word) protecting the seed")
+	}
+	for _, cmd := range []*cobra.Command{createWalletCmd, importWalletCmd} {
+		cmd.Flags().BoolVar(&storePassphrase, "store-passphrase", true, "store the passphrase encrypted in the wallet file")
+	}
 }
 
 func initConfig() {
@@ -130,10 +167,17 @@ func initConfig() {
 	}
 }
 
+var (
+	bip39Passphrase string
+	storePassphrase bool
+)
+
 var createWalletCmd = &cobra.Command{
 	Use:   "create [wallet-name] [password] [pubKey] [apiKey]",
 	Short: "Create a new wallet",
 	Long: `Create a new wallet with the given name and password. 
+	Optionally protect the seed with a BIP39 passphrase using --passphrase; add --store-passphrase=false
+	to keep it out of the wallet file, in which case it must be given to open.
 	Optionally provide a pubKey and apiKey for panel integration.`,
 	Args: cobra.RangeArgs(2, 4),
 	Run: func(cmd *cobra.Command, args []string) {
@@ -148,7 +192,7 @@ var createWalletCmd = &cobra.Command{
 			apiKey = args[3]
 		}
 
-		mnemonic, err := creation.CreateWalletAPI(walletName, password, pubKey, apiKey)
+		mnemonic, err := creation.CreateWalletAPI(walletName, password, bip39Passphrase, storePassphrase, pubKey, apiKey)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error creating wallet: %v\n", err)
 			os.Exit(1)
@@ -171,6 +215,8 @@ var importWalletCmd = &cobra.Command{
 	Short: "Import an existing wallet",
 	Long: `Import an existing wallet with the given name, mnemonic, and password. 
 	Provide the wallet's birthdate in YYYY-MM-DD format.
+	Give the seed's BIP39 passphrase, if any, with --passphrase; add --store-passphrase=false
+	to keep it out of the wallet file, in which case it must be given to open.
 	Optionally provide a pubKey and apiKey for panel integration.`,
 	Args: cobra.RangeArgs(4, 6),
 	Run: func(cmd *cobra.Command, args []string) {
@@ -187,7 +233,7 @@ var importWalletCmd = &cobra.Command{
 			apiKey = args[5]
 		}
 
-		err := creation.ImportWalletAPI(walletName, mnemonic, password, birthdate, pubKey, apiKey)
+		err := creation.ImportWalletAPI(walletName, mnemonic, password, bip39Passphrase, storePassphrase, birthdate, pubKey, apiKey)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error importing wallet: %v\n", err)
 			os.Exit(1)
@@ -208,8 +254,9 @@ var importWalletCmd = &cobra.Command{
 var openWalletCmd = &cobra.Command{
 	Use:   "open [wallet-name] [password]",
 	Short: "Open and load a wallet",
-	Long:  `Open and load a wallet with the given name and password.`,
-	Args:  cobra.ExactArgs(2),
+	Long: `Open and load a wallet with the given name and password.
+	Wallets that do not store their BIP39 passphrase need it with --passphrase.`,
+	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		walletName := args[0]
 		password := args[1]
@@ -222,7 +269,7 @@ var openWalletCmd = &cobra.Command{
 
 		logger.Info("Starting wallet open operation for: ", walletName)
 
-		err = auth.OpenAndLoadWalletAPI(walletName, password, baseDir)
+		err = auth.OpenAndLoadWalletAPI(walletName, password, bip39Passphrase, baseDir)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error opening wallet: %v\n", err)
 			os.Exit(1)
@@ -243,25 +290,42 @@ var openWalletCmd = &cobra.Command{
 var newTransactionCmd = &cobra.Command{
 	Use:   "new-transaction [recipient] [amount] [fee-rate]",
 	Short: "Create a new transaction",
-	Long:  `Create a new transaction with the specified recipient, amount (in satoshis), and fee rate (in sat/vB).`,
-	Args:  cobra.ExactArgs(3),
+	Long: `Create a new transaction with the specified recipient, amount (in satoshis), and fee rate (in sat/vB).
+	The recipient may be a bitcoin: payment URI; when the URI requests an amount the amount argument can be left out.`,
+	Args: cobra.RangeArgs(2, 3),
 	Run: func(cmd *cobra.Command, args []string) {
-		// Early address verification
-		recipientAddr, err := btcutil.DecodeAddress(args[0], &chaincfg.MainNetParams)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Invalid recipient address: %v\n", err)
-			os.Exit(1)
+		recipient, amountArg, feeRateArg := args[0], "0", args[len(args)-1]
+		if len(args) == 3 {
+			amountArg = args[1]
 		}
 
 		// Verify amount is a valid integer
-		_, err = strconv.ParseInt(args[1], 10, 64)
+		amount, err := strconv.ParseInt(amountArg, 10, 64)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Invalid amount: %v\n", err)
 			os.Exit(1)
 		}
 
+		// A payment URI is passed on as is: the server resolves it against
+		// the open wallet's network and takes the amount from it when given
+		if !bip21.IsURI(recipient) {
+			if amount <= 0 {
+				fmt.Fprintf(os.Stderr, "An amount is required unless the payment URI requests one\n")
+				os.Exit(1)
+			}
+
+			// Early address verification
+			recipientAddr, err := btcutil.DecodeAddress(recipient, &chaincfg.MainNetParams)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid recipient address: %v\n", err)
+				os.Exit(1)
+			}
+			// Use the string representation of the verified address
+			recipient = recipientAddr.String()
+		}
+
 		// Verify fee rate is a valid integer
-		_, err = strconv.ParseInt(args[2], 10, 64)
+		_, err = strconv.ParseInt(feeRateArg, 10, 64)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Invalid fee rate: %v\n", err)
 			os.Exit(1)
@@ -274,10 +338,9 @@ var newTransactionCmd = &cobra.Command{
 		}
 		defer client.Close()
 
-		// Use the string representation of the verified address
-		args[0] = recipientAddr.String()
+		args = []string{recipient, strconv.FormatInt(amount, 10), feeRateArg}
 
-		result, err := client.SendCommand("new-transaction", args)
+		result, err := client.SendCommand("new-transaction", append(args, spendInputs...))
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error communicating with wallet server: %v\n", err)
 			os.Exit(1)
@@ -368,6 +431,30 @@ var rbfTransactionCmd = &cobra.Command{
 	},
 }
 
+var cancelTransactionCmd = &cobra.Command{
+	Use:   "cancel-transaction [txid] [fee-rate]",
+	Short: "Cancel an unconfirmed transaction",
+	Long: `Replace an unconfirmed transaction that signals RBF with one sending the same inputs back to
+	a fresh change address. The fee rate (in sat/vB) is optional; by default the BIP125 minimum is paid.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("cancel-transaction", args, "Error cancelling transaction")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var cpfpTransactionCmd = &cobra.Command{
+	Use:   "cpfp-transaction [txid] [target-fee-rate]",
+	Short: "Speed up an incoming or unconfirmed transaction with a child",
+	Long: `Spend an unconfirmed output we own from the given transaction in a new child transaction
+	whose fee brings the parent and child together up to the target fee rate (in sat/vB).`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("cpfp-transaction", args, "Error in CPFP transaction")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
 var getWalletBalanceCmd = &cobra.Command{
 	Use:   "balance",
 	Short: "Get the current wallet balance",
@@ -514,26 +601,10 @@ var deleteWalletCmd = &cobra.Command{
 		walletName := args[0]
 		password := args[1]
 
-		// Load the wallet's .env file
-		walletDir := viper.GetString("wallet_dir")
-		envFile := filepath.Join(walletDir, walletName+".env")
-		err := godotenv.Load(envFile)
+		// Check the password against the wallet's keystore
+		_, err := operations.ReadSeedPhrase(walletName, password)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading wallet file: %v\n", err)
-			os.Exit(1)
-		}
-
-		// Retrieve the encrypted seed phrase
-		encryptedSeedPhrase := os.Getenv("ENCRYPTED_SEED_PHRASE")
-		if encryptedSeedPhrase == "" {
-			fmt.Fprintf(os.Stderr, "Encrypted seed phrase not found\n")
-			os.Exit(1)
-		}
-
-		// Decrypt the seed phrase with the provided password
-		_, err = utils.Decrypt(encryptedSeedPhrase, password)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error decrypting seed phrase: incorrect password or decryption failed\n")
+			fmt.Fprintf(os.Stderr, "Error verifying wallet password: %v\n", err)
 			os.Exit(1)
 		}
 
@@ -565,32 +636,28 @@ var viewSeedCmd = &cobra.Command{
 		walletName := args[0]
 		password := args[1]
 
-		walletDir := viper.GetString("wallet_dir")
-		envFile := filepath.Join(walletDir, walletName+".env")
-		err := godotenv.Load(envFile)
+		seedPhrase, err := operations.ReadSeedPhrase(walletName, password)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading wallet file: %v\n", err)
-			os.Exit(1)
-		}
-
-		encryptedSeedPhrase := os.Getenv("ENCRYPTED_SEED_PHRASE")
-		if encryptedSeedPhrase == "" {
-			fmt.Fprintf(os.Stderr, "Encrypted seed phrase not found\n")
+			fmt.Fprintf(os.Stderr, "Error reading seed phrase: %v\n", err)
 			os.Exit(1)
 		}
 
-		seedPhrase, err := utils.Decrypt(encryptedSeedPhrase, password)
+		passphraseMode, err := operations.WalletPassphraseMode(walletName)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error decrypting seed phrase: incorrect password or decryption failed\n")
+			fmt.Fprintf(os.Stderr, "Error reading passphrase mode: %v\n", err)
 			os.Exit(1)
 		}
 
 		result := struct {
-			WalletName string `json:"walletName"`
-			SeedPhrase string `json:"seedPhrase"`
+			WalletName       string `json:"walletName"`
+			SeedPhrase       string `json:"seedPhrase"`
+			PassphraseInUse  bool   `json:"passphraseInUse"`
+			PassphraseStored bool   `json:"passphraseStored"`
 		}{
-			WalletName: walletName,
-			SeedPhrase: seedPhrase,
+			WalletName:       walletName,
+			SeedPhrase:       seedPhrase,
+			PassphraseInUse:  passphraseMode != operations.PassphraseNone,
+			PassphraseStored: passphraseMode == operations.PassphraseStored,
 		}
 
 		err = json.NewEncoder(os.Stdout).Encode(result)
@@ -600,3 +667,39 @@ var viewSeedCmd = &cobra.Command{
 		}
 	},
 }
+
+// sendIPCCommand sends a command to the running wallet server and returns its
+// result map, exiting with errPrefix when the server reports an error.
+func sendIPCCommand(command string, args []string, errPrefix string) map[string]interface{} {
+	client, err := ipc.NewClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error connecting to wallet server: %v\n", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	result, err := client.SendCommand(command, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error communicating with wallet server: %v\n", err)
+		os.Exit(1)
+	}
+
+	var resultMap map[string]interface{}
+	switch v := result.(type) {
+	case map[string]interface{}:
+		resultMap = v
+	case string:
+		if err := json.Unmarshal([]byte(v), &resultMap); err != nil {
+			fmt.Fprintf(os.Stderr, "Error unmarshaling response: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		resultMap = map[string]interface{}{"result": v}
+	}
+
+	if errorMsg, ok := resultMap["error"]; ok {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", errPrefix, errorMsg)
+		os.Exit(1)
+	}
+	return resultMap
+}
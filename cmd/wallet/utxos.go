@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// spendInputs holds the --inputs flag shared by the send commands. When set,
+// exactly these outpoints are spent instead of running coin selection.
+var spendInputs []string
+
+var listUTXOsCmd = &cobra.Command{
+	Use:   "list-utxos",
+	Short: "List wallet UTXOs with their labels and frozen state",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("list-utxos", args, "Error listing UTXOs")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var freezeUTXOCmd = &cobra.Command{
+	Use:   "freeze-utxo [outpoint...]",
+	Short: "Stop coin selection from spending the given txid:vout outpoints",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("freeze-utxo", args, "Error freezing UTXO")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var unfreezeUTXOCmd = &cobra.Command{
+	Use:   "unfreeze-utxo [outpoint...]",
+	Short: "Make frozen txid:vout outpoints spendable again",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("unfreeze-utxo", args, "Error unfreezing UTXO")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+var labelUTXOCmd = &cobra.Command{
+	Use:   "label-utxo [outpoint] [label]",
+	Short: "Attach a label to a txid:vout outpoint",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := sendIPCCommand("label-utxo", args, "Error labelling UTXO")
+		json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{newTransactionCmd, batchTransactionCmd, psbtCreateCmd} {
+		c.Flags().StringSliceVar(&spendInputs, "inputs", nil, "comma separated txid:vout outpoints to spend instead of automatic coin selection")
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+)
+
+// HandleAnchorData commits hex data or document digests to the chain in an
+// OP_RETURN output. The recipient is optional.
+func (s *API) HandleAnchorData(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AnchorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	payload, leaves, err := transaction.BuildAnchorPayload(req.Mode, req.Data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	inputs, err := transaction.ParseOutpoints(req.Inputs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var recipients []transaction.Recipient
+	if req.RecipientAddress != "" {
+		recipients = append(recipients, transaction.Recipient{Address: req.RecipientAddress, Amount: req.SpendAmount})
+	}
+
+	txid, verified, err := transaction.CreateAnchorTransaction(s.Wallet, s.ChainClient.CS, req.EnableRBF, req.Mode, payload, leaves, recipients, s.PrivPass, req.PriorityRate, inputs)
+	if err != nil {
+		if writePolicyViolation(w, err) {
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to anchor data: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AnchorResponse{
+		TxID:     txid.String(),
+		Mode:     req.Mode,
+		Payload:  hex.EncodeToString(payload),
+		Verified: verified,
+		Status:   "anchored",
+	})
+}
+
+func (s *API) HandleListAnchors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	anchors, err := walletstatedb.GetAnchors()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list anchors: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"anchors": anchors})
+}
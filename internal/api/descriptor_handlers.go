@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
+)
+
+// HandleExportDescriptors returns the wallet's receive and change output
+// descriptors for every account it holds.
+func (s *API) HandleExportDescriptors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fingerprint, err := walletstatedb.GetMasterFingerprint()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read master key fingerprint: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	descriptors := multisig.Descriptors(s.Wallet, fingerprint)
+	if len(descriptors) == 0 {
+		http.Error(w, "Wallet has no accounts to export", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"fingerprint": fmt.Sprintf("%08x", fingerprint),
+		"addressType": utils.AddressType(),
+		"descriptors": descriptors,
+	})
+}
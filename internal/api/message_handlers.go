@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+)
+
+// HandleSignMessage signs a message with the key of one of the wallet's
+// addresses, proving the wallet controls it.
+func (s *API) HandleSignMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req MessageSignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	signature, format, err := transaction.SignMessage(s.Wallet, s.PrivPass, req.Address, req.Message, req.Format)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to sign message: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MessageResponse{
+		Address:   req.Address,
+		Signature: signature,
+		Format:    format,
+	})
+}
+
+// HandleVerifyMessage checks a BIP322 or legacy signature of a message by any
+// address.
+func (s *API) HandleVerifyMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req MessageVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	valid, format, err := transaction.VerifyMessage(req.Address, req.Signature, req.Message, s.Wallet.ChainParams())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to verify message: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MessageResponse{
+		Address:   req.Address,
+		Signature: req.Signature,
+		Format:    format,
+		Valid:     &valid,
+	})
+}
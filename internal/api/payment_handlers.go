@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/payments"
+)
+
+// HandlePaymentRequests lists payment requests on GET, optionally filtered by
+// a status query parameter, and creates one on POST.
+func (s *API) HandlePaymentRequests(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		requests, err := walletstatedb.GetPaymentRequests(r.URL.Query().Get("status"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to list payment requests: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"requests": requests})
+
+	case http.MethodPost:
+		var req PaymentRequestCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		expiry := payments.DefaultExpiry
+		if req.Expiry != "" {
+			var err error
+			if expiry, err = time.ParseDuration(req.Expiry); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid expiry: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		request, err := payments.CreateRequest(s.Wallet, req.Amount, req.Memo, expiry)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to create payment request: %v", err), http.StatusBadRequest)
+			return
+		}
+		s.writePaymentRequest(w, request)
+
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *API) HandleGetPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	request, err := walletstatedb.GetPaymentRequest(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	s.writePaymentRequest(w, request)
+}
+
+func (s *API) HandleUpdatePaymentRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req PaymentRequestUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var expiry time.Duration
+	if req.Expiry != "" {
+		var err error
+		if expiry, err = time.ParseDuration(req.Expiry); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid expiry: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	request, err := payments.UpdateRequest(req.ID, req.Memo, expiry)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update payment request: %v", err), http.StatusBadRequest)
+		return
+	}
+	s.writePaymentRequest(w, request)
+}
+
+func (s *API) HandleDeletePaymentRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req PaymentRequestDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := walletstatedb.DeletePaymentRequest(req.ID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete payment request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":      req.ID,
+		"message": "Payment request deleted",
+	})
+}
+
+func (s *API) writePaymentRequest(w http.ResponseWriter, request *walletstatedb.PaymentRequest) {
+	uri, err := payments.URI(request, s.Wallet)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PaymentRequestResponse{Request: request, URI: uri})
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+)
+
+// HandleSpendingPolicy returns the active spending policy and how much of its
+// rolling limits has been used.
+func (s *API) HandleSpendingPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	policy, err := transaction.LoadSpendingPolicy()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	usage, err := transaction.GetSpendingUsage()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read spending usage: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": policy != nil,
+		"policy":  policy,
+		"usage":   usage,
+	})
+}
+
+// writePolicyViolation answers a spend the spending policy refused with 403
+// Forbidden and the rule that refused it. It reports whether err was such a
+// refusal.
+func writePolicyViolation(w http.ResponseWriter, err error) bool {
+	var violation *transaction.PolicyViolation
+	if !errors.As(err, &violation) {
+		return false
+	}
+
+	log.Printf("Spend refused by spending policy rule %s", violation.Rule)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: violation.Error(), Code: ErrorCodePolicyViolation, Violation: violation})
+	return true
+}
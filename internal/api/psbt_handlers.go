@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+)
+
+// maxPSBTBodySize bounds the request body accepted by the PSBT endpoints.
+const maxPSBTBodySize = 4 << 20
+
+func (s *API) HandlePSBTCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req PSBTRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	inputs, err := transaction.ParseOutpoints(req.Inputs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	packet, err := transaction.CreatePSBT(s.Wallet, req.EnableRBF, req.SpendAmount, req.RecipientAddress, req.PriorityRate, inputs)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create PSBT: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writePSBTResponse(w, packet, PSBTResponse{Status: "created"})
+}
+
+func (s *API) HandlePSBTSign(w http.ResponseWriter, r *http.Request) {
+	packet, ok := readPSBTRequest(w, r)
+	if !ok {
+		return
+	}
+
+	signed, err := transaction.SignPSBT(s.Wallet, packet, s.PrivPass)
+	if err != nil {
+		if writePolicyViolation(w, err) {
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to sign PSBT: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writePSBTResponse(w, packet, PSBTResponse{Status: "signed", SignedInputs: signed})
+}
+
+func (s *API) HandlePSBTFinalize(w http.ResponseWriter, r *http.Request) {
+	packet, ok := readPSBTRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := transaction.FinalizePSBT(packet)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to finalize PSBT: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	txHex, err := transaction.SerializeTransactionHex(tx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writePSBTResponse(w, packet, PSBTResponse{Status: "finalized", TxID: tx.TxHash().String(), TxHex: txHex})
+}
+
+func (s *API) HandlePSBTBroadcast(w http.ResponseWriter, r *http.Request) {
+	packet, ok := readPSBTRequest(w, r)
+	if !ok {
+		return
+	}
+
+	resp := PSBTResponse{}
+	txid, verified, err := transaction.BroadcastPSBT(s.Wallet, s.ChainClient.CS, packet)
+	if err != nil {
+		resp.Status = "failed"
+		resp.Message = fmt.Sprintf("Error broadcasting PSBT: %v", err)
+	} else if verified {
+		resp.Status = "success"
+		resp.Message = "Transaction successfully broadcasted and verified in the mempool"
+	} else {
+		resp.Status = "pending"
+		resp.Message = "Transaction broadcasted. Please check the mempool in a few seconds to see if it is confirmed."
+	}
+	resp.TxID = txid.String()
+	resp.Complete = packet.IsComplete()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// readPSBTRequest accepts either a JSON body carrying a base64 PSBT or a raw
+// binary PSBT sent as application/octet-stream.
+func readPSBTRequest(w http.ResponseWriter, r *http.Request) (*psbt.Packet, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPSBTBodySize))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+
+	data := body
+	if r.Header.Get("Content-Type") != "application/octet-stream" {
+		var req PSBTRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return nil, false
+		}
+		data = []byte(req.PSBT)
+	}
+
+	packet, err := transaction.DecodePSBT(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return packet, true
+}
+
+func writePSBTResponse(w http.ResponseWriter, packet *psbt.Packet, resp PSBTResponse) {
+	encoded, err := transaction.EncodePSBT(packet)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode PSBT: %v", err), http.StatusInternalServerError)
+		return
+	}
+	resp.PSBT = encoded
+	resp.Complete = packet.IsComplete()
+	if fee, err := packet.GetTxFee(); err == nil {
+		resp.Fee = int64(fee)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+)
+
+// HandleListScheduledTransactions lists timelocked transactions. An optional
+// status query parameter filters the list.
+func (s *API) HandleListScheduledTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	scheduled, err := walletstatedb.GetScheduledTransactions(r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list scheduled transactions: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"transactions": scheduled})
+}
+
+func (s *API) HandleCancelScheduledTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ScheduledTransactionCancelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := transaction.CancelScheduledTransaction(req.TxID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to cancel scheduled transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TransactionResponse{
+		TxID:   req.TxID,
+		Status: walletstatedb.ScheduledStatusCancelled,
+	})
+}
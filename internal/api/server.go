@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcwallet/chain"
 	"github.com/btcsuite/btcwallet/wallet"
@@ -184,6 +185,22 @@ func (a *API) WalletAPIMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// UnlockedMiddleware refuses signing requests with 423 Locked while the wallet
+// needs an unlock session and none is open, so callers can tell the wallet is
+// locked from other failures.
+func (a *API) UnlockedMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := transaction.CheckUnlocked(a.Wallet, a.PrivPass); err != nil {
+			log.Println("Signing request refused: wallet locked")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusLocked)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error(), Code: ErrorCodeWalletLocked})
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
 type HealthStatus struct {
 	Status       string `json:"status"`
 	Timestamp    string `json:"timestamp"`
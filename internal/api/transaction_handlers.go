@@ -2,23 +2,55 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/bip21"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
-func (s *API) PerformHttpTransaction(req TransactionRequest) (chainhash.Hash, string, string) {
+// PerformHttpTransaction carries out req and reports the txid, a status and a
+// message for the caller. The error behind a failed status is returned too,
+// so refusals by the spending policy can be told apart.
+func (s *API) PerformHttpTransaction(req TransactionRequest) (chainhash.Hash, string, string, error) {
 	enableRBF := req.EnableRBF
 	var txid chainhash.Hash
 	var status, message string
 
+	inputs, err := transaction.ParseOutpoints(req.Inputs)
+	if err != nil {
+		return chainhash.Hash{}, "failed", err.Error(), err
+	}
+
 	switch req.Choice {
 	case 1:
+		if req.Sweep {
+			// Send the whole balance, or the chosen outpoints, without change
+			outpoints, err := transaction.ParseOutpoints(req.Outpoints)
+			if err != nil {
+				return chainhash.Hash{}, "failed", err.Error(), err
+			}
+			txid, verified, err := transaction.SweepTransaction(s.Wallet, s.ChainClient.CS, enableRBF, req.RecipientAddress, outpoints, s.PrivPass, req.PriorityRate)
+			if err != nil {
+				message = fmt.Sprintf("Error creating or broadcasting sweep transaction: %v", err)
+				status = "failed"
+			} else if verified {
+				message = "Sweep transaction successfully broadcasted and verified in the mempool"
+				status = "success"
+			} else {
+				message = "Sweep transaction broadcasted. Please check the mempool in a few seconds to see if it is confirmed."
+				status = "pending"
+			}
+			return txid, status, message, err
+		}
+
 		// New transaction
-		txid, verified, err := transaction.HttpCheckBalanceAndCreateTransaction(s.Wallet, s.ChainClient.CS, enableRBF, req.SpendAmount, req.RecipientAddress, s.PrivPass, req.PriorityRate)
+		txid, verified, err := transaction.HttpCheckBalanceAndCreateTransaction(s.Wallet, s.ChainClient.CS, enableRBF, req.SpendAmount, req.RecipientAddress, s.PrivPass, req.PriorityRate, inputs)
 		if err != nil {
 			message = fmt.Sprintf("Error creating or broadcasting transaction: %v", err)
 			status = "failed"
@@ -30,7 +62,7 @@ func (s *API) PerformHttpTransaction(req TransactionRequest) (chainhash.Hash, st
 			status = "pending"
 		}
 
-		return txid, status, message
+		return txid, status, message, err
 
 	case 2:
 		// RBF (Replace-By-Fee) transaction
@@ -40,8 +72,9 @@ func (s *API) PerformHttpTransaction(req TransactionRequest) (chainhash.Hash, st
 		}
 		client, err := transaction.CreateElectrumClient(mempoolSpaceConfig)
 		if err != nil {
-			return chainhash.Hash{}, "failed", fmt.Sprintf("Failed to create Electrum client: %v", err)
+			return chainhash.Hash{}, "failed", fmt.Sprintf("Failed to create Electrum client: %v", err), err
 		}
+		defer client.Shutdown()
 		txid, verified, err := transaction.ReplaceTransactionWithHigherFee(s.Wallet, s.ChainClient.CS, req.OriginalTxID, req.NewFeeRate, client, s.PrivPass)
 		if err != nil {
 			message = fmt.Sprintf("Error performing RBF transaction: %v", err)
@@ -53,14 +86,91 @@ func (s *API) PerformHttpTransaction(req TransactionRequest) (chainhash.Hash, st
 			message = "RBF transaction broadcasted. Please check the mempool in a few seconds."
 			status = "pending"
 		}
-		return txid, status, message
+		return txid, status, message, err
+
+	case 3:
+		// Batch transaction paying several recipients at once
+		opReturn, err := transaction.DecodeOpReturnHex(req.OpReturn)
+		if err != nil {
+			return chainhash.Hash{}, "failed", err.Error(), err
+		}
+		txid, verified, err := transaction.CreateBatchTransaction(s.Wallet, s.ChainClient.CS, enableRBF, req.Recipients, opReturn, s.PrivPass, req.PriorityRate, inputs)
+		if err != nil {
+			message = fmt.Sprintf("Error creating or broadcasting batch transaction: %v", err)
+			status = "failed"
+		} else if verified {
+			message = "Batch transaction successfully broadcasted and verified in the mempool"
+			status = "success"
+		} else {
+			message = "Batch transaction broadcasted. Please check the mempool in a few seconds to see if it is confirmed."
+			status = "pending"
+		}
+		return txid, status, message, err
+
+	case 4:
+		// CPFP (Child-Pays-For-Parent) transaction
+		client, err := transaction.CreateElectrumClient(transaction.ElectrumConfig{
+			ServerAddr: "electrum.blockstream.info:50002",
+			UseSSL:     true,
+		})
+		if err != nil {
+			return chainhash.Hash{}, "failed", fmt.Sprintf("Failed to create Electrum client: %v", err), err
+		}
+		defer client.Shutdown()
+		txid, verified, err := transaction.CreateCPFPTransaction(s.Wallet, s.ChainClient.CS, req.ParentTxID, req.TargetFeeRate, client, s.PrivPass)
+		if err != nil {
+			message = fmt.Sprintf("Error performing CPFP transaction: %v", err)
+			status = "failed"
+		} else if verified {
+			message = "CPFP transaction successfully broadcasted and verified in the mempool"
+			status = "success"
+		} else {
+			message = "CPFP transaction broadcasted. Please check the mempool in a few seconds."
+			status = "pending"
+		}
+		return txid, status, message, err
+
+	case 5:
+		// Cancel an unconfirmed transaction by double-spending it to ourselves
+		client, err := transaction.CreateElectrumClient(transaction.ElectrumConfig{
+			ServerAddr: "electrum.blockstream.info:50002",
+			UseSSL:     true,
+		})
+		if err != nil {
+			return chainhash.Hash{}, "failed", fmt.Sprintf("Failed to create Electrum client: %v", err), err
+		}
+		defer client.Shutdown()
+		txid, verified, err := transaction.CancelTransaction(s.Wallet, s.ChainClient.CS, req.OriginalTxID, req.NewFeeRate, client, s.PrivPass)
+		if err != nil {
+			message = fmt.Sprintf("Error cancelling transaction: %v", err)
+			status = "failed"
+		} else if verified {
+			message = "Cancellation transaction successfully broadcasted and verified in the mempool"
+			status = "success"
+		} else {
+			message = "Cancellation transaction broadcasted. Please check the mempool in a few seconds."
+			status = "pending"
+		}
+		return txid, status, message, err
+
+	case 6:
+		// Sign now, broadcast once the chain reaches the locktime
+		scheduled, err := transaction.CreateScheduledTransaction(s.Wallet, req.SpendAmount, req.RecipientAddress, req.LockTime, s.PrivPass, req.PriorityRate, inputs)
+		if err != nil {
+			return chainhash.Hash{}, "failed", fmt.Sprintf("Error scheduling transaction: %v", err), err
+		}
+		hash, err := chainhash.NewHashFromStr(scheduled.TxID)
+		if err != nil {
+			return chainhash.Hash{}, "failed", fmt.Sprintf("Invalid scheduled txid: %v", err), err
+		}
+		return *hash, scheduled.Status, fmt.Sprintf("Transaction scheduled for broadcast at %s", transaction.DescribeLockTime(scheduled.LockTime)), nil
 
 	default:
 		message = "Invalid transaction choice"
 		status = "failed"
 	}
 
-	return txid, status, message
+	return txid, status, message, nil
 }
 
 func (s *API) TransactionHandler(w http.ResponseWriter, r *http.Request) {
@@ -76,12 +186,35 @@ func (s *API) TransactionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	txid, status, message := s.performHttpTransaction(req)
+	// The recipient may be a BIP21 URI carrying the amount
+	if bip21.IsURI(req.RecipientAddress) {
+		address, amount, err := bip21.Resolve(req.RecipientAddress, req.SpendAmount, s.Wallet.ChainParams())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid payment URI: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.Sweep && amount != 0 {
+			http.Error(w, "A sweep sends everything and cannot pay a URI that requests an amount", http.StatusBadRequest)
+			return
+		}
+		req.RecipientAddress, req.SpendAmount = address, amount
+	}
 
-	resp := TransactionResponse{
-		TxID:    txid.String(),
-		Status:  status,
-		Message: message,
+	var resp TransactionResponse
+	if transaction.IsWatchOnly(s.Wallet) && (req.Choice == 1 && !req.Sweep || req.Choice == 2 || req.Choice == 3) {
+		resp = s.performWatchOnlySpend(req)
+	} else {
+		txid, status, message, err := s.PerformHttpTransaction(req)
+		resp = TransactionResponse{
+			TxID:    txid.String(),
+			Status:  status,
+			Message: message,
+		}
+		var violation *transaction.PolicyViolation
+		if errors.As(err, &violation) {
+			resp.Status = "rejected"
+			resp.Violation = violation
+		}
 	}
 
 	// Convert the response struct to a JSON string for logging
@@ -105,70 +238,98 @@ func (s *API) HandleTransactionSizeEstimate(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Call the transaction size estimator function
-	txSize, err := transaction.HttpCalculateTransactionSize(s.Wallet, req.SpendAmount, req.RecipientAddress, req.PriorityRate)
+	// Call the transaction size estimator function, batching when several
+	// recipients are given
+	var txSize int
+	var maxSendable int64
+	switch {
+	case len(req.Recipients) > 0:
+		opReturn, decodeErr := transaction.DecodeOpReturnHex(req.OpReturn)
+		if decodeErr != nil {
+			http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		txSize, err = transaction.HttpCalculateBatchTransactionSize(s.Wallet, req.Recipients, opReturn, req.PriorityRate)
+	case req.Sweep:
+		// Sweeping only the chosen outpoints, or everything when none are given
+		outpoints, parseErr := transaction.ParseOutpoints(req.Outpoints)
+		if parseErr != nil {
+			http.Error(w, parseErr.Error(), http.StatusBadRequest)
+			return
+		}
+		var amount btcutil.Amount
+		amount, txSize, err = transaction.CalculateMaxSendable(s.Wallet, req.RecipientAddress, outpoints, req.PriorityRate)
+		maxSendable = int64(amount)
+	default:
+		txSize, maxSendable, err = transaction.HttpCalculateTransactionSize(s.Wallet, req.SpendAmount, req.RecipientAddress, req.PriorityRate)
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to estimate transaction size: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Send back the transaction size
-	resp := map[string]int{
-		"txSize": txSize,
+	// Send back the transaction size and the most that could be sent
+	resp := map[string]int64{
+		"txSize":      int64(txSize),
+		"maxSendable": maxSendable,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-func (s *API) performHttpTransaction(req TransactionRequest) (chainhash.Hash, string, string) {
-	enableRBF := req.EnableRBF
-	var txid chainhash.Hash
-	var status, message string
+// performWatchOnlySpend answers a send, RBF or batch request on a watch-only
+// wallet with an unsigned PSBT, which the machine holding the seed signs.
+// Multisig wallets sign their part first and leave the rest to the cosigners.
+func (s *API) performWatchOnlySpend(req TransactionRequest) TransactionResponse {
+	inputs, err := transaction.ParseOutpoints(req.Inputs)
+	if err != nil {
+		return TransactionResponse{Status: "failed", Message: err.Error()}
+	}
 
+	var packet *psbt.Packet
 	switch req.Choice {
-	case 1:
-		// New transaction
-		txid, verified, err := transaction.HttpCheckBalanceAndCreateTransaction(s.Wallet, s.ChainClient.CS, enableRBF, req.SpendAmount, req.RecipientAddress, s.PrivPass, req.PriorityRate)
-		if err != nil {
-			message = fmt.Sprintf("Error creating or broadcasting transaction: %v", err)
-			status = "failed"
-		} else if verified {
-			message = "Transaction successfully broadcasted and verified in the mempool"
-			status = "success"
-		} else {
-			message = "Transaction broadcasted. Please check the mempool in a few seconds to see if it is confirmed."
-			status = "pending"
-		}
-
-		return txid, status, message
-
 	case 2:
-		// RBF (Replace-By-Fee) transaction
-		var mempoolSpaceConfig = transaction.ElectrumConfig{
+		client, clientErr := transaction.CreateElectrumClient(transaction.ElectrumConfig{
 			ServerAddr: "electrum.blockstream.info:50002",
 			UseSSL:     true,
+		})
+		if clientErr != nil {
+			return TransactionResponse{Status: "failed", Message: fmt.Sprintf("Failed to create Electrum client: %v", clientErr)}
 		}
-		client, err := transaction.CreateElectrumClient(mempoolSpaceConfig)
-		if err != nil {
-			return chainhash.Hash{}, "failed", fmt.Sprintf("Failed to create Electrum client: %v", err)
+		defer client.Shutdown()
+		packet, err = transaction.BumpFeePSBT(s.Wallet, req.OriginalTxID, req.NewFeeRate, client)
+	case 3:
+		opReturn, decodeErr := transaction.DecodeOpReturnHex(req.OpReturn)
+		if decodeErr != nil {
+			return TransactionResponse{Status: "failed", Message: decodeErr.Error()}
 		}
-		txid, verified, err := transaction.ReplaceTransactionWithHigherFee(s.Wallet, s.ChainClient.CS, req.OriginalTxID, req.NewFeeRate, client, s.PrivPass)
-		if err != nil {
-			message = fmt.Sprintf("Error performing RBF transaction: %v", err)
-			status = "failed"
-		} else if verified {
-			message = "RBF transaction successfully broadcasted and verified in the mempool"
-			status = "success"
-		} else {
-			message = "RBF transaction broadcasted. Please check the mempool in a few seconds."
-			status = "pending"
+		packet, err = transaction.CreateBatchPSBT(s.Wallet, req.EnableRBF, req.Recipients, opReturn, req.PriorityRate, inputs)
+	default:
+		packet, err = transaction.CreatePSBT(s.Wallet, req.EnableRBF, req.SpendAmount, req.RecipientAddress, req.PriorityRate, inputs)
+	}
+	if err != nil {
+		return TransactionResponse{Status: "failed", Message: fmt.Sprintf("Error creating PSBT: %v", err)}
+	}
+
+	status := "unsigned"
+	message := "Watch-only wallet: sign the PSBT with the seed and submit it to /psbt/finalize and /psbt/broadcast"
+	if transaction.IsMultisig() {
+		if _, err := transaction.AddMultisigSignature(s.Wallet, packet); err != nil {
+			return TransactionResponse{Status: "failed", Message: fmt.Sprintf("Error signing PSBT: %v", err)}
 		}
-		return txid, status, message
+		status = "partially_signed"
+		message = "Multisig wallet: pass the PSBT to the cosigners to sign and submit it to /psbt/broadcast"
+	}
 
-	default:
-		message = "Invalid transaction choice"
-		status = "failed"
+	encoded, err := transaction.EncodePSBT(packet)
+	if err != nil {
+		return TransactionResponse{Status: "failed", Message: fmt.Sprintf("Error encoding PSBT: %v", err)}
 	}
 
-	return txid, status, message
+	return TransactionResponse{
+		TxID:    packet.UnsignedTx.TxHash().String(),
+		Status:  status,
+		Message: message,
+		PSBT:    encoded,
+	}
 }
@@ -1,6 +1,8 @@
 package api
 
 import (
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcwallet/chain"
 	"github.com/btcsuite/btcwallet/wallet"
@@ -21,19 +23,174 @@ type API struct {
 
 type TransactionRequest struct {
 	Choice           int    `json:"choice"`
-	RecipientAddress string `json:"recipient_address"`
+	RecipientAddress string `json:"recipient_address"` // address or BIP21 URI
 	SpendAmount      int64  `json:"spend_amount"`
 	PriorityRate     int    `json:"priority_rate"`
 	FilePath         string `json:"file_path,omitempty"`
-	OriginalTxID     string `json:"original_tx_id,omitempty"`
+	OriginalTxID     string `json:"original_tx_id,omitempty"` // RBF (choice 2) and cancel (choice 5)
 	NewFeeRate       int64  `json:"new_fee_rate,omitempty"`
 	EnableRBF        bool   `json:"enable_rbf"` // New field for enabling RBF
+
+	// Sweep sends everything spendable, or only Outpoints (txid:vout) when
+	// set, to RecipientAddress with no change. SpendAmount is ignored.
+	Sweep     bool     `json:"sweep,omitempty"`
+	Outpoints []string `json:"outpoints,omitempty"`
+
+	// Inputs (txid:vout) to spend instead of running coin selection
+	Inputs []string `json:"inputs,omitempty"`
+
+	// Batch payments (choice 3)
+	Recipients []transaction.Recipient `json:"recipients,omitempty"`
+	OpReturn   string                  `json:"op_return,omitempty"` // hex encoded
+
+	// Absolute locktime (block height or unix time) for scheduled
+	// transactions (choice 6)
+	LockTime uint32 `json:"lock_time,omitempty"`
+
+	// Child-pays-for-parent (choice 4)
+	ParentTxID    string `json:"parent_tx_id,omitempty"`
+	TargetFeeRate int64  `json:"target_fee_rate,omitempty"`
 }
 
 type TransactionResponse struct {
 	TxID    string `json:"txid"`
 	Status  string `json:"status"`
 	Message string `json:"message,omitempty"`
+	PSBT    string `json:"psbt,omitempty"` // unsigned spend from a watch-only wallet
+
+	// Violation is the rule that refused a spend with status rejected
+	Violation *transaction.PolicyViolation `json:"violation,omitempty"`
+}
+
+type ScheduledTransactionCancelRequest struct {
+	TxID string `json:"txid"`
+}
+
+type UTXOFreezeRequest struct {
+	Outpoints []string `json:"outpoints"` // txid:vout
+	Frozen    bool     `json:"frozen"`
+}
+
+type UTXOLabelRequest struct {
+	Outpoint string `json:"outpoint"` // txid:vout
+	Label    string `json:"label"`
+}
+
+type AnchorRequest struct {
+	Mode             string   `json:"mode"` // hex, sha256 or merkle
+	Data             []string `json:"data"` // hex payload or SHA-256 digests
+	RecipientAddress string   `json:"recipient_address,omitempty"`
+	SpendAmount      int64    `json:"spend_amount,omitempty"`
+	PriorityRate     int      `json:"priority_rate"`
+	EnableRBF        bool     `json:"enable_rbf"`
+	Inputs           []string `json:"inputs,omitempty"` // txid:vout, overrides coin selection
+}
+
+type AnchorResponse struct {
+	TxID     string `json:"txid"`
+	Mode     string `json:"mode"`
+	Payload  string `json:"payload"`
+	Verified bool   `json:"verified"`
+	Status   string `json:"status"`
+}
+
+type DescriptorImportRequest struct {
+	WalletName string `json:"wallet_name"`
+	Descriptor string `json:"descriptor"`
+	Password   string `json:"password"`
+	Birthdate  string `json:"birthdate"` // YYYY-MM-DD
+}
+
+type PSBTRequest struct {
+	RecipientAddress string   `json:"recipient_address,omitempty"`
+	SpendAmount      int64    `json:"spend_amount,omitempty"`
+	PriorityRate     int      `json:"priority_rate,omitempty"`
+	EnableRBF        bool     `json:"enable_rbf"`
+	Inputs           []string `json:"inputs,omitempty"` // txid:vout, overrides coin selection
+	PSBT             string   `json:"psbt,omitempty"`   // base64 encoded
+}
+
+type PSBTResponse struct {
+	PSBT         string `json:"psbt,omitempty"`
+	Complete     bool   `json:"complete"`
+	Fee          int64  `json:"fee,omitempty"`
+	SignedInputs int    `json:"signed_inputs,omitempty"`
+	TxID         string `json:"txid,omitempty"`
+	TxHex        string `json:"tx_hex,omitempty"`
+	Status       string `json:"status"`
+	Message      string `json:"message,omitempty"`
+}
+
+type MessageSignRequest struct {
+	Address string `json:"address"`
+	Message string `json:"message"`
+	Format  string `json:"format,omitempty"` // bip322 or legacy, by address type when empty
+}
+
+type MessageVerifyRequest struct {
+	Address   string `json:"address"`
+	Signature string `json:"signature"` // base64 encoded
+	Message   string `json:"message"`
+}
+
+type MessageResponse struct {
+	Address   string `json:"address"`
+	Signature string `json:"signature"`
+	Format    string `json:"format"`
+	Valid     *bool  `json:"valid,omitempty"`
+}
+
+type PaymentURIRequest struct {
+	Amount  int64  `json:"amount,omitempty"` // satoshis, chosen by the payer when zero
+	Label   string `json:"label,omitempty"`
+	Message string `json:"message,omitempty"`
+	PayJoin string `json:"pj,omitempty"` // BIP78 endpoint
+}
+
+type PaymentURIResponse struct {
+	Address string `json:"address"`
+	Amount  int64  `json:"amount,omitempty"`
+	URI     string `json:"uri"`
+}
+
+type PaymentRequestCreateRequest struct {
+	Amount int64  `json:"amount"`           // satoshis, any amount when zero
+	Expiry string `json:"expiry,omitempty"` // duration such as 30m or 24h
+	Memo   string `json:"memo,omitempty"`
+}
+
+type PaymentRequestUpdateRequest struct {
+	ID     string  `json:"id"`
+	Expiry string  `json:"expiry,omitempty"` // new expiry from now
+	Memo   *string `json:"memo,omitempty"`
+}
+
+type PaymentRequestDeleteRequest struct {
+	ID string `json:"id"`
+}
+
+type PaymentRequestResponse struct {
+	Request *walletstatedb.PaymentRequest `json:"request"`
+	URI     string                        `json:"uri"`
+}
+
+type UnlockRequest struct {
+	Password string `json:"password"`
+	Timeout  string `json:"timeout,omitempty"` // duration such as 10m, or seconds
+}
+
+// ErrorCodeWalletLocked marks a signing request refused because no unlock
+// session is open.
+const ErrorCodeWalletLocked = "wallet_locked"
+
+// ErrorCodePolicyViolation marks a spend the spending policy refused to sign.
+const ErrorCodePolicyViolation = "policy_violation"
+
+// ErrorResponse is a refusal callers can act on by its code.
+type ErrorResponse struct {
+	Error     string                       `json:"error"`
+	Code      string                       `json:"code"`
+	Violation *transaction.PolicyViolation `json:"violation,omitempty"`
 }
 
 type contextKey string
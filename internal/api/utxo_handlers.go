@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+)
+
+func (s *API) HandleListUTXOs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	utxos, err := transaction.ListWalletUTXOs(s.Wallet)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"utxos": utxos})
+}
+
+func (s *API) HandleFreezeUTXOs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req UTXOFreezeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	outpoints, err := transaction.ParseOutpoints(req.Outpoints)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(outpoints) == 0 {
+		http.Error(w, "No outpoints given", http.StatusBadRequest)
+		return
+	}
+
+	if err := transaction.FreezeOutpoints(outpoints, req.Frozen); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+func (s *API) HandleLabelUTXO(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req UTXOLabelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	outpoint, err := transaction.ParseOutpoint(req.Outpoint)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := transaction.LabelOutpoint(outpoint, req.Label); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to label UTXO: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
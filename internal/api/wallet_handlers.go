@@ -68,6 +68,34 @@ func (s *API) HandleAddressGeneration(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// HandlePaymentURI allocates a receive address and returns a BIP21 URI for it
+// with the requested amount, label, message and payjoin endpoint.
+func (s *API) HandlePaymentURI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req PaymentURIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	uri, err := addresses.AllocatePaymentURI(s.Wallet, req.Amount, req.Label, req.Message, req.PayJoin)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create payment URI: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PaymentURIResponse{
+		Address: uri.Address.EncodeAddress(),
+		Amount:  int64(uri.Amount),
+		URI:     uri.String(),
+	})
+}
+
 func (s *API) HandleChallengeRequest(w http.ResponseWriter, _ *http.Request) {
 	log.Println("Challenge requested...")
 	// User's public key from Viper config (as this wallet has one primary user)
@@ -62,14 +62,25 @@ func setDefaults() {
 	viper.SetDefault("use_https", false)
 	viper.SetDefault("cert_file", "server.crt")
 	viper.SetDefault("key_file", "server.key")
-	viper.SetDefault("fee_per_kb", 1000)    // in satoshis
-	viper.SetDefault("dust_limit", 546)     // in satoshis
-	viper.SetDefault("tx_max_size", 100000) // in bytes
+	viper.SetDefault("fee_per_kb", 1000)       // in satoshis
+	viper.SetDefault("dust_limit", 546)        // in satoshis
+	viper.SetDefault("coin_selection", "auto") // auto, bnb, knapsack or largest-first
+	viper.SetDefault("tx_max_size", 100000)    // in bytes
+	viper.SetDefault("anti_fee_sniping", true)
+	viper.SetDefault("tx_ordering", "random")  // random, bip69 or none
+	viper.SetDefault("address_type", "p2wpkh") // p2wpkh or p2tr
 	viper.SetDefault("address_gap_limit", 20)
 	viper.SetDefault("sync_interval", "10m")
 	viper.SetDefault("backup_interval", "24h")
 	viper.SetDefault("backup_path", "./wallet_backup")
 	viper.SetDefault("wallet_dir", "./wallets")
+	viper.SetDefault("wallet_kdf", "scrypt")       // scrypt or argon2id
+	viper.SetDefault("wallet_kdf_target", "500ms") // time one key derivation should take
+	viper.SetDefault("unlock_sessions", false)     // sign only inside unlock sessions
+	viper.SetDefault("unlock_timeout", "5m")       // session length when unlock is given none
+	viper.SetDefault("max_unlock_timeout", "1h")
+	viper.SetDefault("spending_policy_file", "")     // JSON spending limits; none when empty
+	viper.SetDefault("payment_min_confirmations", 1) // before a payment settles a request
 	viper.SetDefault("jwt_keys_dir", "./jwtkeys")
 	viper.SetDefault("wallet_api_key", "")
 	viper.SetDefault("server_mode", true)
@@ -1,6 +1,8 @@
 package walletstatedb
 
 import (
+	"time"
+
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
@@ -9,12 +11,24 @@ import (
 
 const (
 	LastScannedBlockKey    = "last_scanned_block_height"
+	MasterFingerprintKey   = "master_key_fingerprint"
 	AddressStatusAvailable = "available"
 	AddressStatusAllocated = "allocated"
 	AddressStatusUsed      = "used"
 	MinAvailableAddresses  = 10
 	UnsentTransactionsTree = "unsent_transactions"
 	ChallengeTreeName      = "challenges"
+
+	ScheduledStatusScheduled = "scheduled"
+	ScheduledStatusBroadcast = "broadcast"
+	ScheduledStatusCancelled = "cancelled"
+
+	PaymentStatusUnpaid    = "unpaid"
+	PaymentStatusPending   = "pending"
+	PaymentStatusUnderpaid = "underpaid"
+	PaymentStatusPaid      = "paid"
+	PaymentStatusOverpaid  = "overpaid"
+	PaymentStatusExpired   = "expired"
 )
 
 // Helper wrapper functions that redirect to SQLite implementations
@@ -27,6 +41,12 @@ func PrintAndCopyReceiveAddresses() (Address, error) {
 	return PrintAndCopyReceiveAddressesFromSQLite()
 }
 
+// AllocateReceiveAddress marks the next available receive address as
+// allocated and returns it.
+func AllocateReceiveAddress() (*Address, error) {
+	return AllocateAddressFromSQLite("receive")
+}
+
 func SetLastScannedBlockHeight(height int32) error {
 	return SetLastScannedBlockHeightInSQLite(height)
 }
@@ -39,6 +59,14 @@ func UpdateLastScannedBlockHeight(height int32) error {
 	return SetLastScannedBlockHeightInSQLite(height)
 }
 
+func SetMasterFingerprint(fingerprint uint32) error {
+	return SetMasterFingerprintInSQLite(fingerprint)
+}
+
+func GetMasterFingerprint() (uint32, error) {
+	return GetMasterFingerprintFromSQLite()
+}
+
 func GenerateNewAddresses(w *wallet.Wallet, count int) error {
 	return GenerateNewAddressesInSQLite(w, count)
 }
@@ -95,3 +123,91 @@ func MarkChallengeAsUsed(hash string) error {
 func ExpireOldChallenges() error {
 	return ExpireOldChallengesInSQLite()
 }
+
+func SetOutpointFrozen(outpoint string, frozen bool) error {
+	return SetOutpointFrozenInSQLite(outpoint, frozen)
+}
+
+func SetOutpointLabel(outpoint, label string) error {
+	return SetOutpointLabelInSQLite(outpoint, label)
+}
+
+func GetOutpointTags() (map[string]OutpointTag, error) {
+	return GetOutpointTagsFromSQLite()
+}
+
+func GetFrozenOutpoints() (map[string]bool, error) {
+	return GetFrozenOutpointsFromSQLite()
+}
+
+func SaveScheduledTransaction(tx *ScheduledTransaction) error {
+	return SaveScheduledTransactionToSQLite(tx)
+}
+
+func GetScheduledTransactions(status string) ([]ScheduledTransaction, error) {
+	return GetScheduledTransactionsFromSQLite(status)
+}
+
+func GetScheduledTransaction(txID string) (*ScheduledTransaction, error) {
+	return GetScheduledTransactionFromSQLite(txID)
+}
+
+func UpdateScheduledTransactionStatus(txID, status, lastError string) error {
+	return UpdateScheduledTransactionStatusInSQLite(txID, status, lastError)
+}
+
+func SaveAnchor(anchor *Anchor) error {
+	return SaveAnchorToSQLite(anchor)
+}
+
+func GetAnchors() ([]Anchor, error) {
+	return GetAnchorsFromSQLite()
+}
+
+func GetAnchor(txID string) (*Anchor, error) {
+	return GetAnchorFromSQLite(txID)
+}
+
+func SavePaymentRequest(request *PaymentRequest) error {
+	return SavePaymentRequestToSQLite(request)
+}
+
+func GetPaymentRequests(status string) ([]PaymentRequest, error) {
+	return GetPaymentRequestsFromSQLite(status)
+}
+
+func GetPaymentRequest(id string) (*PaymentRequest, error) {
+	return GetPaymentRequestFromSQLite(id)
+}
+
+func UpdatePaymentRequest(id string, updates map[string]interface{}) error {
+	return UpdatePaymentRequestInSQLite(id, updates)
+}
+
+func DeletePaymentRequest(id string) error {
+	return DeletePaymentRequestFromSQLite(id)
+}
+
+func GetUnsentPaymentRequests() ([]PaymentRequest, error) {
+	return GetUnsentPaymentRequestsFromSQLite()
+}
+
+func MarkPaymentRequestsAsSent(ids []string) error {
+	return MarkPaymentRequestsAsSentInSQLite(ids)
+}
+
+func SaveSpend(spend *Spend) error {
+	return SaveSpendToSQLite(spend)
+}
+
+func MarkSpendBroadcast(txID string) error {
+	return MarkSpendBroadcastInSQLite(txID, time.Now())
+}
+
+func DeleteSpend(txID string) error {
+	return DeleteSpendFromSQLite(txID)
+}
+
+func GetSpendsSince(since time.Time) ([]Spend, error) {
+	return GetSpendsSinceFromSQLite(since)
+}
@@ -80,6 +80,12 @@ func GetAddressesWithType(addrType string) ([]Address, error) {
 	return GetAddressesFromSQLite(addrType)
 }
 
+// GetAddressType returns whether an address is a receive or change address
+// and its entry
+func GetAddressType(address string) (string, *Address, error) {
+	return GetAddressTypeFromSQLite(address)
+}
+
 // GetLastAddressIndexWithType gets the last address index for a specific type
 func GetLastAddressIndexWithType(addrType string) (int, error) {
 	return GetLastAddressIndexFromSQLite(addrType)
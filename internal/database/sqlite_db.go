@@ -7,12 +7,14 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
-	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/wallet"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -53,6 +55,11 @@ func InitSQLiteDB(dbPath string) error {
 		&SQLiteChallenge{},
 		&SQLiteMetadata{},
 		&SQLiteUnsentTransaction{},
+		&SQLiteOutpoint{},
+		&SQLiteScheduledTransaction{},
+		&SQLiteAnchor{},
+		&SQLitePaymentRequest{},
+		&SQLiteSpend{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %v", err)
@@ -78,6 +85,7 @@ func SaveAddressToSQLite(addrType string, address Address) error {
 		UsedAt:        address.UsedAt,
 		BlockHeight:   address.BlockHeight,
 		AddrType:      addrType,
+		ScriptType:    address.ScriptType,
 		SentToBackend: address.SentToBackend,
 	}
 
@@ -102,6 +110,7 @@ func GetAddressesFromSQLite(addrType string) ([]Address, error) {
 			AllocatedAt:   addr.AllocatedAt,
 			UsedAt:        addr.UsedAt,
 			BlockHeight:   addr.BlockHeight,
+			ScriptType:    addr.ScriptType,
 			SentToBackend: addr.SentToBackend,
 		}
 	}
@@ -109,6 +118,30 @@ func GetAddressesFromSQLite(addrType string) ([]Address, error) {
 	return addresses, nil
 }
 
+// GetAddressTypeFromSQLite returns the pool an address belongs to, receive or
+// change, and its entry.
+func GetAddressTypeFromSQLite(address string) (string, *Address, error) {
+	var sqliteAddr SQLiteAddress
+
+	if err := DB.Where("address = ?", address).First(&sqliteAddr).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return "", nil, fmt.Errorf("address %s not found", address)
+		}
+		return "", nil, err
+	}
+
+	return sqliteAddr.AddrType, &Address{
+		Index:         sqliteAddr.Index,
+		Address:       sqliteAddr.Address,
+		Status:        sqliteAddr.Status,
+		AllocatedAt:   sqliteAddr.AllocatedAt,
+		UsedAt:        sqliteAddr.UsedAt,
+		BlockHeight:   sqliteAddr.BlockHeight,
+		ScriptType:    sqliteAddr.ScriptType,
+		SentToBackend: sqliteAddr.SentToBackend,
+	}, nil
+}
+
 // GetUnusedAddressFromSQLite retrieves an unused address of a specific type from SQLite
 func GetUnusedAddressFromSQLite(addrType string) (*Address, error) {
 	var sqliteAddr SQLiteAddress
@@ -125,6 +158,7 @@ func GetUnusedAddressFromSQLite(addrType string) (*Address, error) {
 		AllocatedAt:   sqliteAddr.AllocatedAt,
 		UsedAt:        sqliteAddr.UsedAt,
 		BlockHeight:   sqliteAddr.BlockHeight,
+		ScriptType:    sqliteAddr.ScriptType,
 		SentToBackend: sqliteAddr.SentToBackend,
 	}
 
@@ -195,6 +229,7 @@ func AllocateAddressFromSQLite(addrType string) (*Address, error) {
 		AllocatedAt:   &now,
 		UsedAt:        sqliteAddr.UsedAt,
 		BlockHeight:   sqliteAddr.BlockHeight,
+		ScriptType:    sqliteAddr.ScriptType,
 		SentToBackend: sqliteAddr.SentToBackend,
 	}
 
@@ -241,6 +276,44 @@ func GetLastScannedBlockHeightFromSQLite() (int32, error) {
 	return int32(height), nil
 }
 
+// SetMasterFingerprintInSQLite records the fingerprint of the wallet's master key
+func SetMasterFingerprintInSQLite(fingerprint uint32) error {
+	var metadata SQLiteMetadata
+	value := fmt.Sprintf("%08x", fingerprint)
+
+	result := DB.Where("key = ?", MasterFingerprintKey).First(&metadata)
+	if result.Error == nil {
+		return DB.Model(&metadata).Update("value", value).Error
+	}
+
+	metadata = SQLiteMetadata{
+		Key:   MasterFingerprintKey,
+		Value: value,
+	}
+	return DB.Create(&metadata).Error
+}
+
+// GetMasterFingerprintFromSQLite returns the recorded master key fingerprint, or
+// 0 if none was recorded
+func GetMasterFingerprintFromSQLite() (uint32, error) {
+	var metadata SQLiteMetadata
+
+	result := DB.Where("key = ?", MasterFingerprintKey).First(&metadata)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return 0, nil
+		}
+		return 0, result.Error
+	}
+
+	fingerprint, err := strconv.ParseUint(metadata.Value, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse master key fingerprint: %v", err)
+	}
+
+	return uint32(fingerprint), nil
+}
+
 // UpdateLastScannedBlockHeightInSQLite updates the last scanned block height in SQLite
 func UpdateLastScannedBlockHeightInSQLite(height int32) error {
 	return SetLastScannedBlockHeightInSQLite(height)
@@ -300,6 +373,7 @@ func PrintAndCopyReceiveAddressesFromSQLite() (Address, error) {
 		AllocatedAt:   sqliteAddr.AllocatedAt,
 		UsedAt:        sqliteAddr.UsedAt,
 		BlockHeight:   sqliteAddr.BlockHeight,
+		ScriptType:    sqliteAddr.ScriptType,
 		SentToBackend: sqliteAddr.SentToBackend,
 	}
 
@@ -330,7 +404,12 @@ func GenerateNewAddressesInSQLite(w *wallet.Wallet, count int) error {
 
 	// Generate and save new addresses
 	for i := 0; i < count; i++ {
-		newAddr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+		var newAddr btcutil.Address
+		if multisig.Active() != nil {
+			newAddr, err = multisig.PoolAddress(w, multisig.BranchReceive, uint(lastIndex+i+1))
+		} else {
+			newAddr, err = w.NewAddress(0, utils.AddressKeyScope())
+		}
 		if err != nil {
 			return fmt.Errorf("failed to generate new address: %v", err)
 		}
@@ -339,6 +418,7 @@ func GenerateNewAddressesInSQLite(w *wallet.Wallet, count int) error {
 			Index:         uint(lastIndex + i + 1),
 			Address:       newAddr.EncodeAddress(),
 			Status:        AddressStatusAvailable,
+			ScriptType:    utils.AddressScriptType(newAddr),
 			SentToBackend: false,
 		}
 
@@ -698,6 +778,7 @@ func GetUnsentAddressesFromSQLite() ([]Address, error) {
 			AllocatedAt:   addr.AllocatedAt,
 			UsedAt:        addr.UsedAt,
 			BlockHeight:   addr.BlockHeight,
+			ScriptType:    addr.ScriptType,
 			SentToBackend: addr.SentToBackend,
 		}
 	}
@@ -720,3 +801,440 @@ func MarkAddressesAsSentInSQLite() error {
 	log.Printf("Marked %d addresses as sent to backend", result.RowsAffected)
 	return nil
 }
+
+// SetOutpointFrozenInSQLite freezes or unfreezes a UTXO so coin selection
+// skips it
+func SetOutpointFrozenInSQLite(outpoint string, frozen bool) error {
+	var record SQLiteOutpoint
+	return DB.Where(SQLiteOutpoint{Outpoint: outpoint}).
+		Assign(map[string]interface{}{"frozen": frozen}).
+		FirstOrCreate(&record).Error
+}
+
+// SetOutpointLabelInSQLite attaches a label to a UTXO
+func SetOutpointLabelInSQLite(outpoint, label string) error {
+	var record SQLiteOutpoint
+	return DB.Where(SQLiteOutpoint{Outpoint: outpoint}).
+		Assign(map[string]interface{}{"label": label}).
+		FirstOrCreate(&record).Error
+}
+
+// GetOutpointTagsFromSQLite returns the label and frozen flag of every tagged
+// UTXO, keyed by outpoint
+func GetOutpointTagsFromSQLite() (map[string]OutpointTag, error) {
+	var records []SQLiteOutpoint
+	if err := DB.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get outpoints: %v", err)
+	}
+
+	tags := make(map[string]OutpointTag, len(records))
+	for _, record := range records {
+		tags[record.Outpoint] = OutpointTag{
+			Outpoint: record.Outpoint,
+			Label:    record.Label,
+			Frozen:   record.Frozen,
+		}
+	}
+	return tags, nil
+}
+
+// GetFrozenOutpointsFromSQLite returns the set of frozen outpoints
+func GetFrozenOutpointsFromSQLite() (map[string]bool, error) {
+	var records []SQLiteOutpoint
+	if err := DB.Where("frozen = ?", true).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get frozen outpoints: %v", err)
+	}
+
+	frozen := make(map[string]bool, len(records))
+	for _, record := range records {
+		frozen[record.Outpoint] = true
+	}
+	return frozen, nil
+}
+
+// SaveScheduledTransactionToSQLite stores a signed timelocked transaction
+func SaveScheduledTransactionToSQLite(tx *ScheduledTransaction) error {
+	record := SQLiteScheduledTransaction{
+		TxID:      tx.TxID,
+		RawTx:     tx.RawTx,
+		LockTime:  tx.LockTime,
+		Recipient: tx.Recipient,
+		Amount:    tx.Amount,
+		Fee:       tx.Fee,
+		Inputs:    strings.Join(tx.Inputs, ","),
+		Status:    tx.Status,
+	}
+
+	return DB.Create(&record).Error
+}
+
+// GetScheduledTransactionsFromSQLite lists scheduled transactions, optionally
+// filtered by status, soonest locktime first
+func GetScheduledTransactionsFromSQLite(status string) ([]ScheduledTransaction, error) {
+	var records []SQLiteScheduledTransaction
+
+	query := DB.Order("lock_time asc")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get scheduled transactions: %v", err)
+	}
+
+	transactions := make([]ScheduledTransaction, 0, len(records))
+	for _, record := range records {
+		transactions = append(transactions, scheduledTransactionFromRecord(record))
+	}
+	return transactions, nil
+}
+
+// GetScheduledTransactionFromSQLite retrieves a scheduled transaction by txid
+func GetScheduledTransactionFromSQLite(txID string) (*ScheduledTransaction, error) {
+	var record SQLiteScheduledTransaction
+
+	if err := DB.Where("tx_id = ?", txID).First(&record).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("scheduled transaction %s not found", txID)
+		}
+		return nil, err
+	}
+
+	tx := scheduledTransactionFromRecord(record)
+	return &tx, nil
+}
+
+// UpdateScheduledTransactionStatusInSQLite records a status change. Cancelled
+// transactions have their signed bytes wiped so they cannot be broadcast later.
+func UpdateScheduledTransactionStatusInSQLite(txID, status, lastError string) error {
+	updates := map[string]interface{}{
+		"status":     status,
+		"last_error": lastError,
+	}
+	switch status {
+	case ScheduledStatusBroadcast:
+		updates["broadcast_at"] = time.Now()
+	case ScheduledStatusCancelled:
+		updates["raw_tx"] = nil
+	}
+
+	result := DB.Model(&SQLiteScheduledTransaction{}).
+		Where("tx_id = ?", txID).
+		Updates(updates)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("scheduled transaction %s not found", txID)
+	}
+
+	return nil
+}
+
+func scheduledTransactionFromRecord(record SQLiteScheduledTransaction) ScheduledTransaction {
+	var inputs []string
+	if record.Inputs != "" {
+		inputs = strings.Split(record.Inputs, ",")
+	}
+	return ScheduledTransaction{
+		TxID:        record.TxID,
+		RawTx:       record.RawTx,
+		LockTime:    record.LockTime,
+		Recipient:   record.Recipient,
+		Amount:      record.Amount,
+		Fee:         record.Fee,
+		Inputs:      inputs,
+		Status:      record.Status,
+		LastError:   record.LastError,
+		CreatedAt:   record.CreatedAt,
+		BroadcastAt: record.BroadcastAt,
+	}
+}
+
+// SaveAnchorToSQLite records the payload anchored by a transaction
+func SaveAnchorToSQLite(anchor *Anchor) error {
+	record := SQLiteAnchor{
+		TxID:    anchor.TxID,
+		Mode:    anchor.Mode,
+		Payload: anchor.Payload,
+		Leaves:  strings.Join(anchor.Leaves, ","),
+	}
+
+	return DB.Create(&record).Error
+}
+
+// GetAnchorsFromSQLite lists anchored payloads, newest first
+func GetAnchorsFromSQLite() ([]Anchor, error) {
+	var records []SQLiteAnchor
+
+	if err := DB.Order("created_at desc").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get anchors: %v", err)
+	}
+
+	anchors := make([]Anchor, 0, len(records))
+	for _, record := range records {
+		anchors = append(anchors, anchorFromRecord(record))
+	}
+	return anchors, nil
+}
+
+// GetAnchorFromSQLite retrieves the payload anchored by a transaction
+func GetAnchorFromSQLite(txID string) (*Anchor, error) {
+	var record SQLiteAnchor
+
+	if err := DB.Where("tx_id = ?", txID).First(&record).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("anchor for transaction %s not found", txID)
+		}
+		return nil, err
+	}
+
+	anchor := anchorFromRecord(record)
+	return &anchor, nil
+}
+
+func anchorFromRecord(record SQLiteAnchor) Anchor {
+	var leaves []string
+	if record.Leaves != "" {
+		leaves = strings.Split(record.Leaves, ",")
+	}
+	return Anchor{
+		TxID:      record.TxID,
+		Mode:      record.Mode,
+		Payload:   record.Payload,
+		Leaves:    leaves,
+		CreatedAt: record.CreatedAt,
+	}
+}
+
+// SavePaymentRequestToSQLite stores a new payment request
+func SavePaymentRequestToSQLite(request *PaymentRequest) error {
+	record := SQLitePaymentRequest{
+		RequestID: request.ID,
+		Address:   request.Address,
+		Amount:    request.Amount,
+		Memo:      request.Memo,
+		ExpiresAt: request.ExpiresAt,
+		Status:    request.Status,
+	}
+
+	return DB.Create(&record).Error
+}
+
+// GetPaymentRequestsFromSQLite lists payment requests, optionally filtered by
+// status, newest first
+func GetPaymentRequestsFromSQLite(status string) ([]PaymentRequest, error) {
+	var records []SQLitePaymentRequest
+
+	query := DB.Order("created_at desc")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get payment requests: %v", err)
+	}
+
+	requests := make([]PaymentRequest, 0, len(records))
+	for _, record := range records {
+		requests = append(requests, paymentRequestFromRecord(record))
+	}
+	return requests, nil
+}
+
+// GetPaymentRequestFromSQLite retrieves a payment request by id
+func GetPaymentRequestFromSQLite(id string) (*PaymentRequest, error) {
+	var record SQLitePaymentRequest
+
+	if err := DB.Where("request_id = ?", id).First(&record).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("payment request %s not found", id)
+		}
+		return nil, err
+	}
+
+	request := paymentRequestFromRecord(record)
+	return &request, nil
+}
+
+// UpdatePaymentRequestInSQLite applies updates to a payment request. Any
+// change is queued to be pushed to the backend again.
+func UpdatePaymentRequestInSQLite(id string, updates map[string]interface{}) error {
+	updates["sent_to_backend"] = false
+
+	result := DB.Model(&SQLitePaymentRequest{}).
+		Where("request_id = ?", id).
+		Updates(updates)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("payment request %s not found", id)
+	}
+
+	return nil
+}
+
+// DeletePaymentRequestFromSQLite removes a payment request. Its address stays
+// allocated so it is never handed out again.
+func DeletePaymentRequestFromSQLite(id string) error {
+	result := DB.Where("request_id = ?", id).Delete(&SQLitePaymentRequest{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("payment request %s not found", id)
+	}
+
+	return nil
+}
+
+// GetUnsentPaymentRequestsFromSQLite lists payment requests whose latest
+// state has not reached the backend
+func GetUnsentPaymentRequestsFromSQLite() ([]PaymentRequest, error) {
+	var records []SQLitePaymentRequest
+
+	if err := DB.Where("sent_to_backend = ?", false).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get unsent payment requests: %v", err)
+	}
+
+	requests := make([]PaymentRequest, 0, len(records))
+	for _, record := range records {
+		requests = append(requests, paymentRequestFromRecord(record))
+	}
+	return requests, nil
+}
+
+// MarkPaymentRequestsAsSentInSQLite marks the given payment requests as sent
+// to the backend
+func MarkPaymentRequestsAsSentInSQLite(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return DB.Model(&SQLitePaymentRequest{}).
+		Where("request_id IN ?", ids).
+		Update("sent_to_backend", true).Error
+}
+
+func paymentRequestFromRecord(record SQLitePaymentRequest) PaymentRequest {
+	var txids []string
+	if record.TxIDs != "" {
+		txids = strings.Split(record.TxIDs, ",")
+	}
+	return PaymentRequest{
+		ID:        record.RequestID,
+		Address:   record.Address,
+		Amount:    record.Amount,
+		Memo:      record.Memo,
+		ExpiresAt: record.ExpiresAt,
+		Status:    record.Status,
+		Received:  record.Received,
+		TxIDs:     txids,
+		PaidAt:    record.PaidAt,
+		CreatedAt: record.CreatedAt,
+	}
+}
+
+// SaveSpendToSQLite records a signed transaction, replacing any earlier
+// record of the same txid.
+func SaveSpendToSQLite(spend *Spend) error {
+	record := SQLiteSpend{
+		TxID:       spend.TxID,
+		Amount:     spend.Amount,
+		Fee:        spend.Fee,
+		Recipients: strings.Join(spend.Recipients, ","),
+		Inputs:     strings.Join(spend.Inputs, ","),
+		Pending:    spend.Pending,
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		// Hard delete, so a packet signed again can reuse its txid
+		if err := tx.Unscoped().Where("tx_id = ?", spend.TxID).Delete(&SQLiteSpend{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&record).Error
+	})
+}
+
+// MarkSpendBroadcastInSQLite records that the pending spend txID went out at
+// broadcastAt. The spends it shares an input with can no longer confirm, so
+// they are removed in the same database transaction. A txID that was never
+// recorded is ignored.
+func MarkSpendBroadcastInSQLite(txID string, broadcastAt time.Time) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var record SQLiteSpend
+		err := tx.Where("tx_id = ?", txID).First(&record).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		spent := make(map[string]bool)
+		for _, input := range strings.Split(record.Inputs, ",") {
+			spent[input] = true
+		}
+		var others []SQLiteSpend
+		if err := tx.Where("tx_id <> ?", txID).Find(&others).Error; err != nil {
+			return err
+		}
+		var replaced []string
+		for _, other := range others {
+			for _, input := range strings.Split(other.Inputs, ",") {
+				if spent[input] {
+					replaced = append(replaced, other.TxID)
+					break
+				}
+			}
+		}
+		if len(replaced) > 0 {
+			if err := tx.Unscoped().Where("tx_id IN ?", replaced).Delete(&SQLiteSpend{}).Error; err != nil {
+				return err
+			}
+		}
+
+		// The rolling limits count the spend from when it left the wallet
+		return tx.Model(&record).Updates(map[string]interface{}{"pending": false, "created_at": broadcastAt}).Error
+	})
+}
+
+// DeleteSpendFromSQLite forgets a signed transaction that will never be
+// broadcast
+func DeleteSpendFromSQLite(txID string) error {
+	return DB.Unscoped().Where("tx_id = ?", txID).Delete(&SQLiteSpend{}).Error
+}
+
+// GetSpendsSinceFromSQLite lists the transactions signed after since, newest
+// first
+func GetSpendsSinceFromSQLite(since time.Time) ([]Spend, error) {
+	var records []SQLiteSpend
+
+	if err := DB.Where("created_at > ?", since).Order("created_at desc").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get spends: %v", err)
+	}
+
+	spends := make([]Spend, 0, len(records))
+	for _, record := range records {
+		var recipients, inputs []string
+		if record.Recipients != "" {
+			recipients = strings.Split(record.Recipients, ",")
+		}
+		if record.Inputs != "" {
+			inputs = strings.Split(record.Inputs, ",")
+		}
+		spends = append(spends, Spend{
+			TxID:       record.TxID,
+			Amount:     record.Amount,
+			Fee:        record.Fee,
+			Recipients: recipients,
+			Inputs:     inputs,
+			Pending:    record.Pending,
+			CreatedAt:  record.CreatedAt,
+		})
+	}
+	return spends, nil
+}
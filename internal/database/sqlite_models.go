@@ -16,6 +16,7 @@ type SQLiteAddress struct {
 	UsedAt        *time.Time
 	BlockHeight   *uint32
 	AddrType      string `gorm:"index;uniqueIndex:idx_addr_type_index"` // receive or change
+	ScriptType    string // p2wpkh or p2tr
 	SentToBackend bool   `gorm:"index;default:false"`
 }
 
@@ -65,3 +66,67 @@ type SQLiteUnsentTransaction struct {
 	gorm.Model
 	TransactionID uint `gorm:"index"`
 }
+
+// SQLiteOutpoint holds coin control state for a wallet UTXO
+type SQLiteOutpoint struct {
+	gorm.Model
+	Outpoint string `gorm:"uniqueIndex"` // txid:vout
+	Label    string
+	Frozen   bool `gorm:"index;default:false"`
+}
+
+// SQLiteScheduledTransaction holds a signed, timelocked transaction waiting
+// for the chain to reach its locktime
+type SQLiteScheduledTransaction struct {
+	gorm.Model
+	TxID        string `gorm:"uniqueIndex"`
+	RawTx       []byte
+	LockTime    uint32 `gorm:"index"`
+	Recipient   string
+	Amount      int64
+	Fee         int64
+	Inputs      string // comma separated txid:vout
+	Status      string `gorm:"index"` // scheduled, broadcast, cancelled
+	LastError   string
+	BroadcastAt *time.Time
+}
+
+// SQLiteAnchor records data committed to the chain in an OP_RETURN output,
+// with the leaves it was built from so inclusion can be proven later.
+type SQLiteAnchor struct {
+	gorm.Model
+	TxID    string `gorm:"uniqueIndex"`
+	Mode    string // hex, sha256 or merkle
+	Payload string // hex encoded OP_RETURN data
+	Leaves  string // comma separated hex leaves for merkle anchors
+}
+
+// SQLitePaymentRequest asks for an amount to be paid to an allocated address
+// before an expiry time. Received and Status are kept up to date by the
+// rescan.
+type SQLitePaymentRequest struct {
+	gorm.Model
+	RequestID     string `gorm:"uniqueIndex"`
+	Address       string `gorm:"index"`
+	Amount        int64
+	Memo          string
+	ExpiresAt     time.Time `gorm:"index"`
+	Status        string    `gorm:"index"` // unpaid, pending, underpaid, paid, overpaid, expired
+	Received      int64
+	TxIDs         string // comma separated txids of the payments
+	PaidAt        *time.Time
+	SentToBackend bool `gorm:"index;default:false"`
+}
+
+// SQLiteSpend records a transaction the wallet signed, for the rolling limits
+// of the spending policy. Amount is what left the wallet, excluding the fee.
+// Pending spends are signed but not yet broadcast.
+type SQLiteSpend struct {
+	gorm.Model
+	TxID       string `gorm:"uniqueIndex"`
+	Amount     int64
+	Fee        int64
+	Recipients string // comma separated addresses paid
+	Inputs     string // comma separated txid:vout
+	Pending    bool
+}
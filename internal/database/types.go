@@ -19,6 +19,7 @@ type Address struct {
 	AllocatedAt   *time.Time
 	UsedAt        *time.Time
 	BlockHeight   *uint32
+	ScriptType    string
 	SentToBackend bool
 }
 
@@ -33,3 +34,54 @@ type Transaction struct {
 	Vout          uint32    `json:"vout"`
 	SentToBackend bool      `json:"sent_to_backend"`
 }
+
+type OutpointTag struct {
+	Outpoint string `json:"outpoint"`
+	Label    string `json:"label,omitempty"`
+	Frozen   bool   `json:"frozen"`
+}
+
+type ScheduledTransaction struct {
+	TxID        string     `json:"txid"`
+	RawTx       []byte     `json:"-"`
+	LockTime    uint32     `json:"lock_time"`
+	Recipient   string     `json:"recipient"`
+	Amount      int64      `json:"amount"`
+	Fee         int64      `json:"fee"`
+	Inputs      []string   `json:"inputs"`
+	Status      string     `json:"status"`
+	LastError   string     `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	BroadcastAt *time.Time `json:"broadcast_at,omitempty"`
+}
+
+type Anchor struct {
+	TxID      string    `json:"txid"`
+	Mode      string    `json:"mode"`
+	Payload   string    `json:"payload"`
+	Leaves    []string  `json:"leaves,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PaymentRequest struct {
+	ID        string     `json:"id"`
+	Address   string     `json:"address"`
+	Amount    int64      `json:"amount"`
+	Memo      string     `json:"memo,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	Status    string     `json:"status"`
+	Received  int64      `json:"received"`
+	TxIDs     []string   `json:"txids,omitempty"`
+	PaidAt    *time.Time `json:"paid_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type Spend struct {
+	TxID       string    `json:"txid"`
+	Amount     int64     `json:"amount"`
+	Fee        int64     `json:"fee"`
+	Recipients []string  `json:"recipients,omitempty"`
+	Inputs     []string  `json:"inputs"`
+	Pending    bool      `json:"pending,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -6,6 +6,9 @@ import (
 	"time"
 
 	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/bip21"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcwallet/chain"
 	"github.com/btcsuite/btcwallet/waddrmgr"
@@ -90,7 +93,7 @@ func PrintAddresses(addrType string, addresses []btcutil.Address) {
 
 func GenerateAndSaveAddresses(w *wallet.Wallet, count int) ([]btcutil.Address, []btcutil.Address, error) {
 	account := uint32(0)
-	scope := waddrmgr.KeyScopeBIP0084
+	scope := utils.AddressKeyScope()
 
 	// Get the last address index for receive and change addresses
 	lastReceiveIndex, err := walletstatedb.GetLastAddressIndexWithType("receive")
@@ -108,7 +111,7 @@ func GenerateAndSaveAddresses(w *wallet.Wallet, count int) ([]btcutil.Address, [
 
 	for i := 0; i < count; i++ {
 		// Generate and save receive address
-		receiveAddr, err := w.NewAddress(account, scope)
+		receiveAddr, err := nextAddress(w, account, scope, multisig.BranchReceive, uint(lastReceiveIndex+i+1))
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to generate receive address: %v", err)
 		}
@@ -119,6 +122,7 @@ func GenerateAndSaveAddresses(w *wallet.Wallet, count int) ([]btcutil.Address, [
 			Index:         uint(lastReceiveIndex + i + 1),
 			Address:       receiveAddr.String(),
 			Status:        walletstatedb.AddressStatusAvailable,
+			ScriptType:    utils.AddressScriptType(receiveAddr),
 			SentToBackend: false,
 		}
 
@@ -129,7 +133,7 @@ func GenerateAndSaveAddresses(w *wallet.Wallet, count int) ([]btcutil.Address, [
 		}
 
 		// Generate and save change address
-		changeAddr, err := w.NewChangeAddress(account, scope)
+		changeAddr, err := nextAddress(w, account, scope, multisig.BranchChange, uint(lastChangeIndex+i+1))
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to generate change address: %v", err)
 		}
@@ -139,6 +143,7 @@ func GenerateAndSaveAddresses(w *wallet.Wallet, count int) ([]btcutil.Address, [
 			Index:         uint(lastChangeIndex + i + 1),
 			Address:       changeAddr.String(),
 			Status:        walletstatedb.AddressStatusAvailable,
+			ScriptType:    utils.AddressScriptType(changeAddr),
 			SentToBackend: false,
 		})
 		if err != nil {
@@ -149,6 +154,19 @@ func GenerateAndSaveAddresses(w *wallet.Wallet, count int) ([]btcutil.Address, [
 	return newReceiveAddresses, newChangeAddresses, nil
 }
 
+// nextAddress returns the address for pool entry poolIndex on branch. Multisig
+// wallets derive it from their descriptor; the address manager only stores
+// their scripts.
+func nextAddress(w *wallet.Wallet, account uint32, scope waddrmgr.KeyScope, branch uint32, poolIndex uint) (btcutil.Address, error) {
+	if multisig.Active() != nil {
+		return multisig.PoolAddress(w, branch, poolIndex)
+	}
+	if branch == multisig.BranchChange {
+		return w.NewChangeAddress(account, scope)
+	}
+	return w.NewAddress(account, scope)
+}
+
 // GetUnsentAddresses retrieves all unsent addresses
 func GetUnsentAddresses() ([]walletstatedb.Address, error) {
 	return walletstatedb.GetUnsentAddressesFromSQLite()
@@ -158,3 +176,36 @@ func GetUnsentAddresses() ([]walletstatedb.Address, error) {
 func ClearUnsentAddresses() error {
 	return walletstatedb.MarkAddressesAsSentInSQLite()
 }
+
+// AllocatePaymentURI allocates the next available receive address and returns
+// a BIP21 URI for it. A zero amount lets the payer choose; payJoin is an
+// optional BIP78 endpoint.
+func AllocatePaymentURI(w *wallet.Wallet, amount int64, label, message, payJoin string) (*bip21.URI, error) {
+	if amount < 0 || amount > btcutil.MaxSatoshi {
+		return nil, fmt.Errorf("invalid amount %d", amount)
+	}
+
+	allocated, err := walletstatedb.AllocateReceiveAddress()
+	if err != nil {
+		return nil, fmt.Errorf("error allocating receive address: %v", err)
+	}
+
+	// Keep the pool topped up for the next request
+	if err := walletstatedb.EnsureMinimumAvailableAddresses(w); err != nil {
+		log.Printf("Error topping up address pool: %v", err)
+	}
+
+	addr, err := btcutil.DecodeAddress(allocated.Address, w.ChainParams())
+	if err != nil {
+		return nil, fmt.Errorf("error decoding allocated address: %v", err)
+	}
+
+	// Round trip through the parser so an invalid pj endpoint is refused
+	uri := &bip21.URI{Address: addr, Amount: btcutil.Amount(amount), Label: label, Message: message, PayJoin: payJoin}
+	if _, err := bip21.Parse(uri.String(), w.ChainParams()); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Allocated payment URI %s", uri)
+	return uri, nil
+}
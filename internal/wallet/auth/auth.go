@@ -64,7 +64,7 @@ func OpenAndloadWallet(reader *bufio.Reader, baseDir string) error {
 	walletName := wallets[choice-1]
 
 	// Load the wallet data
-	seedPhrase, publicPass, privatePass, birthdate, err := operations.LoadWallet(walletName)
+	seedPhrase, passphrase, publicPass, privatePass, birthdate, err := operations.LoadWallet(walletName)
 	if err != nil {
 		return fmt.Errorf("error loading wallet: %v", err)
 	}
@@ -123,7 +123,7 @@ func OpenAndloadWallet(reader *bufio.Reader, baseDir string) error {
 		log.Printf("Error setting wallet live state: %v", err)
 	}
 
-	err = operations.StartWallet(seedPhrase, pubPass, privPass, baseDir, walletName, birthdate, serverMode)
+	err = operations.StartWallet(seedPhrase, passphrase, pubPass, privPass, baseDir, walletName, birthdate, serverMode)
 	if err != nil {
 		return fmt.Errorf("failed to start wallet: %v", err)
 	}
@@ -132,13 +132,15 @@ func OpenAndloadWallet(reader *bufio.Reader, baseDir string) error {
 	return nil
 }
 
-func OpenAndLoadWalletAPI(walletName, password string, baseDir string) error {
+// OpenAndLoadWalletAPI opens walletName with password. passphrase is only
+// needed for wallets that do not store their BIP39 passphrase.
+func OpenAndLoadWalletAPI(walletName, password, passphrase string, baseDir string) error {
 	err := viper.ReadInConfig()
 	if err != nil {
 		log.Printf("Error reading viper config: %s", err.Error())
 	}
 	// Load the wallet data
-	seedPhrase, publicPass, privatePass, birthdate, err := operations.LoadWalletAPI(walletName, password)
+	seedPhrase, passphrase, publicPass, privatePass, birthdate, err := operations.LoadWalletAPI(walletName, password, passphrase)
 	if err != nil {
 		return fmt.Errorf("error loading wallet: %v", err)
 	}
@@ -167,7 +169,7 @@ func OpenAndLoadWalletAPI(walletName, password string, baseDir string) error {
 
 	logger.Info("Wallet opened successfully for: ", walletName)
 
-	err = operations.StartWallet(seedPhrase, pubPass, privPass, baseDir, walletName, birthdate, serverMode)
+	err = operations.StartWallet(seedPhrase, passphrase, pubPass, privPass, baseDir, walletName, birthdate, serverMode)
 	if err != nil {
 		return fmt.Errorf("failed to start wallet: %v", err)
 	}
@@ -13,10 +13,13 @@ import (
 	snWalletChain "github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/chain"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/formatter"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/descriptor"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/wallet"
 	"github.com/btcsuite/btcwallet/walletdb"
 	"github.com/lightninglabs/neutrino"
@@ -24,7 +27,7 @@ import (
 	"github.com/tyler-smith/go-bip39"
 )
 
-func InitializeWallet(seedPhrase string, pubPass []byte, privPass []byte, baseDir string, walletName string, birthdate time.Time) (*wallet.Wallet, *chaincfg.Params, *neutrino.ChainService, *chain.NeutrinoClient, walletdb.DB, error) {
+func InitializeWallet(seedPhrase, passphrase string, pubPass []byte, privPass []byte, baseDir string, walletName string, birthdate time.Time) (*wallet.Wallet, *chaincfg.Params, *neutrino.ChainService, *chain.NeutrinoClient, walletdb.DB, error) {
 	// Initialize the wallet and necessary components
 	// Initialize the SQLite database
 	walletDbName := fmt.Sprintf("%s_wallet.db", walletName)
@@ -34,19 +37,87 @@ func InitializeWallet(seedPhrase string, pubPass []byte, privPass []byte, baseDi
 		return nil, nil, nil, nil, nil, fmt.Errorf("error initializing database: %v", err)
 	}
 
-	log.Println("Generating BIP39 seed from seed phrase")
-	seed, err := bip39.NewSeedWithErrorChecking(seedPhrase, "")
-	if err != nil {
-		return nil, nil, nil, nil, nil, fmt.Errorf("error generating seed: %v", err)
-	}
+	chainParams := &chaincfg.MainNetParams
 
-	// Generating root key from seed
-	rootKey, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
-	if err != nil {
-		return nil, nil, nil, nil, nil, fmt.Errorf("error generating root key: %v", err)
+	// The wallet secret is either a BIP39 mnemonic or an output descriptor.
+	// Public descriptors give a watch-only wallet with no root key, and so do
+	// multisig descriptors, whose addresses are imported as witness scripts.
+	var rootKey *hdkeychain.ExtendedKey
+	var watchDescriptor *descriptor.Descriptor
+	var multisigDescriptor *descriptor.Multisig
+	multisig.SetActive(nil)
+	if descriptor.IsMultisig(seedPhrase) {
+		log.Println("Loading wallet keys from multisig descriptor")
+		m, err := descriptor.ParseMultisig(seedPhrase, chainParams)
+		if err != nil {
+			return nil, nil, nil, nil, nil, fmt.Errorf("error parsing multisig descriptor: %v", err)
+		}
+		if err := m.ValidateForWallet(); err != nil {
+			return nil, nil, nil, nil, nil, fmt.Errorf("error validating multisig descriptor: %v", err)
+		}
+		multisigDescriptor = m
+		if err := multisig.SetActive(m); err != nil {
+			return nil, nil, nil, nil, nil, fmt.Errorf("error loading multisig descriptor: %v", err)
+		}
+		// Like the wallet's private passphrase, the signing key stays loaded
+		// until the server locks it for unlock sessions
+		if m.SigningKey() != nil {
+			if err := multisig.Unlock(m, 0); err != nil {
+				return nil, nil, nil, nil, nil, fmt.Errorf("error loading multisig signing key: %v", err)
+			}
+		}
+	} else if descriptor.IsDescriptor(seedPhrase) {
+		log.Println("Loading wallet keys from output descriptor")
+		desc, err := descriptor.Parse(seedPhrase, chainParams)
+		if err != nil {
+			return nil, nil, nil, nil, nil, fmt.Errorf("error parsing descriptor: %v", err)
+		}
+		if err := desc.ValidateForWallet(); err != nil {
+			return nil, nil, nil, nil, nil, fmt.Errorf("error validating descriptor: %v", err)
+		}
+		if desc.Key.IsPrivate() {
+			rootKey = desc.Key
+		} else {
+			watchDescriptor = desc
+		}
+	} else {
+		log.Println("Generating BIP39 seed from seed phrase")
+		seed, err := bip39.NewSeedWithErrorChecking(seedPhrase, passphrase)
+		if err != nil {
+			return nil, nil, nil, nil, nil, fmt.Errorf("error generating seed: %v", err)
+		}
+
+		// Generating root key from seed
+		rootKey, err = hdkeychain.NewMaster(seed, chainParams)
+		if err != nil {
+			return nil, nil, nil, nil, nil, fmt.Errorf("error generating root key: %v", err)
+		}
 	}
 
-	chainParams := &chaincfg.MainNetParams
+	// Descriptor exports need the master key fingerprint, which the wallet
+	// database does not keep for seeded accounts
+	var fingerprint uint32
+	if rootKey != nil {
+		fingerprint, err = utils.GetMasterFingerprint(rootKey)
+		if err != nil {
+			return nil, nil, nil, nil, nil, fmt.Errorf("error computing master key fingerprint: %v", err)
+		}
+	} else if multisigDescriptor != nil {
+		// The wallet is known by the fingerprint of the key it signs with
+		key := multisigDescriptor.SigningKey()
+		if key == nil {
+			key = multisigDescriptor.Keys[0]
+		}
+		fingerprint, err = key.MasterFingerprint()
+		if err != nil {
+			return nil, nil, nil, nil, nil, fmt.Errorf("error computing master key fingerprint: %v", err)
+		}
+	} else {
+		fingerprint = watchDescriptor.Fingerprint
+	}
+	if err := walletstatedb.SetMasterFingerprint(fingerprint); err != nil {
+		log.Printf("Error recording master key fingerprint: %v", err)
+	}
 
 	log.Printf("Using base directory: %s", baseDir)
 
@@ -120,7 +191,13 @@ func InitializeWallet(seedPhrase string, pubPass []byte, privPass []byte, baseDi
 			formattedLastScanned := utils.FormatBlockHeight(lastScannedHeight)
 			log.Printf("Updated last scanned block height to %s", formattedLastScanned)
 		}
-		w, err = loader.CreateNewWalletExtendedKey(pubPass, privPass, rootKey, birthday)
+		if multisigDescriptor != nil {
+			w, err = createMultisigWallet(loader, pubPass, birthday)
+		} else if watchDescriptor != nil {
+			w, err = createWatchOnlyWallet(loader, pubPass, watchDescriptor, birthday)
+		} else {
+			w, err = loader.CreateNewWalletExtendedKey(pubPass, privPass, rootKey, birthday)
+		}
 		createDuration := time.Since(createStart)
 		if err != nil {
 			log.Printf("Error creating wallet after %v: %v", createDuration, err)
@@ -138,22 +215,24 @@ func InitializeWallet(seedPhrase string, pubPass []byte, privPass []byte, baseDi
 		}
 	}
 
-	// Derive the account key from m/84'/0'/0'
-	accountKeyPath := "84'/0'/0'"
-	accountKey, err := utils.DeriveKeyFromPath(rootKey, accountKeyPath)
-	if err != nil {
-		log.Fatalf("Error deriving account key: %v", err)
-	}
+	if rootKey != nil {
+		// Derive the account key from m/84'/0'/0'
+		accountKeyPath := "84'/0'/0'"
+		accountKey, err := utils.DeriveKeyFromPath(rootKey, accountKeyPath)
+		if err != nil {
+			log.Fatalf("Error deriving account key: %v", err)
+		}
 
-	// Get xpub and zpub
-	_, err = utils.GetExtendedPubKey(accountKey, []byte{0x04, 0x88, 0xB2, 0x1E})
-	if err != nil {
-		log.Fatalf("Error getting xpub: %v", err)
-	}
+		// Get xpub and zpub
+		_, err = utils.GetExtendedPubKey(accountKey, []byte{0x04, 0x88, 0xB2, 0x1E})
+		if err != nil {
+			log.Fatalf("Error getting xpub: %v", err)
+		}
 
-	_, err = utils.GetExtendedPubKey(accountKey, []byte{0x04, 0xB2, 0x47, 0x46})
-	if err != nil {
-		log.Fatalf("Error getting zpub: %v", err)
+		_, err = utils.GetExtendedPubKey(accountKey, []byte{0x04, 0xB2, 0x47, 0x46})
+		if err != nil {
+			log.Fatalf("Error getting zpub: %v", err)
+		}
 	}
 
 	log.Println("Initializing Neutrino chain service")
@@ -248,6 +327,45 @@ func InitializeWallet(seedPhrase string, pubPass []byte, privPass []byte, baseDi
 	return w, chainParams, chainService, chainClient, db, nil
 }
 
+// createWatchOnlyWallet creates a wallet without private keys and imports the
+// descriptor's account key as account 0 of its key scope.
+func createWatchOnlyWallet(loader *wallet.Loader, pubPass []byte, desc *descriptor.Descriptor, birthday time.Time) (*wallet.Wallet, error) {
+	scope, err := desc.Scope()
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := loader.CreateNewWatchingOnlyWallet(pubPass, birthday)
+	if err != nil {
+		return nil, fmt.Errorf("error creating watch-only wallet: %v", err)
+	}
+
+	props, err := w.ImportAccountWithScope("default", desc.Key, desc.Fingerprint, scope, waddrmgr.ScopeAddrMap[scope])
+	if err != nil {
+		return nil, fmt.Errorf("error importing account key: %v", err)
+	}
+	if props.AccountNumber != 0 {
+		return nil, fmt.Errorf("account key was imported as account %d, expected 0", props.AccountNumber)
+	}
+	log.Printf("Imported watch-only account into scope %s", scope)
+	return w, nil
+}
+
+// createMultisigWallet creates a wallet without private keys holding the
+// multisig key scope. Its addresses are imported as they enter the address
+// pool, and the wallet's own key, if any, signs from the descriptor.
+func createMultisigWallet(loader *wallet.Loader, pubPass []byte, birthday time.Time) (*wallet.Wallet, error) {
+	w, err := loader.CreateNewWatchingOnlyWallet(pubPass, birthday)
+	if err != nil {
+		return nil, fmt.Errorf("error creating multisig wallet: %v", err)
+	}
+	if err := multisig.CreateScope(w); err != nil {
+		return nil, err
+	}
+	log.Println("Created multisig wallet")
+	return w, nil
+}
+
 func isBirthdayToday(birthday time.Time) bool {
 	today := time.Now()
 	return birthday.Month() == today.Month() &&
@@ -2,8 +2,6 @@ package creation
 
 import (
 	"bufio"
-	"crypto/rand"
-	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
@@ -12,6 +10,7 @@ import (
 
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/operations"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/slip39"
 	"github.com/spf13/viper"
 	"github.com/tyler-smith/go-bip39"
 	"golang.org/x/term"
@@ -54,7 +53,10 @@ func CreateNewWallet(reader *bufio.Reader) error {
 	password := strings.TrimSpace(string(passwordBytes))
 	fmt.Println() // Add newline after password input
 
-	encryptedMnemonic := utils.Encrypt(mnemonic, password)
+	passphrase, storePassphrase, err := readPassphrase(reader)
+	if err != nil {
+		return err
+	}
 
 	pubKey := ""
 	apiKey := ""
@@ -94,26 +96,24 @@ func CreateNewWallet(reader *bufio.Reader) error {
 	}
 
 	// Generate public passphrase
-	pubPass, err := generateRandomPassphrase(16)
+	pubPass, err := utils.GenerateRandomPassphrase(16)
 	if err != nil {
 		return fmt.Errorf("error generating public passphrase: %v", err)
 	}
 
 	// Generate private passphrase
-	privPass, err := generateRandomPassphrase(32)
+	privPass, err := utils.GenerateRandomPassphrase(32)
 	if err != nil {
 		return fmt.Errorf("error generating private passphrase: %v", err)
 	}
 
-	encryptedPubPass := utils.Encrypt(pubPass, password)
-	encryptedPrivPass := utils.Encrypt(privPass, password)
-
 	// Set birthdate to current date and time
 	birthdate := time.Now().UTC()
-	encryptedBirthdate := utils.Encrypt(birthdate.Format(timeFormat), password)
 
 	// Save wallet data along with panel-specific info (if applicable)
-	operations.SaveWalletData(walletName, encryptedMnemonic, encryptedPubPass, encryptedPrivPass, encryptedBirthdate)
+	if err := operations.SaveWalletData(walletName, password, mnemonic, passphrase, pubPass, privPass, storePassphrase, birthdate); err != nil {
+		return err
+	}
 
 	fmt.Printf("Wallet '%s' created and encrypted successfully.\n", walletName)
 	fmt.Printf("Wallet birthdate: %s\n", birthdate.Format("2006-01-02"))
@@ -121,7 +121,10 @@ func CreateNewWallet(reader *bufio.Reader) error {
 	return nil
 }
 
-func CreateWalletAPI(walletName, password, pubKey, apiKey string) (string, error) {
+// CreateWalletAPI creates a wallet with a new mnemonic and returns it. A
+// non-empty passphrase is used as the BIP39 passphrase and, when
+// storePassphrase is set, saved encrypted alongside the seed.
+func CreateWalletAPI(walletName, password, passphrase string, storePassphrase bool, pubKey, apiKey string) (string, error) {
 	log.Printf("Creating new wallet: %s", walletName)
 
 	err := viper.ReadInConfig()
@@ -139,8 +142,6 @@ func CreateWalletAPI(walletName, password, pubKey, apiKey string) (string, error
 		return "", fmt.Errorf("error generating mnemonic: %v", err)
 	}
 
-	encryptedMnemonic := utils.Encrypt(mnemonic, password)
-
 	// Set panel wallet configuration if pubKey and apiKey are provided
 	if pubKey != "" && apiKey != "" {
 		viper.Set("relay_wallet_set", true)
@@ -159,26 +160,24 @@ func CreateWalletAPI(walletName, password, pubKey, apiKey string) (string, error
 	}
 
 	// Generate public passphrase
-	pubPass, err := generateRandomPassphrase(16)
+	pubPass, err := utils.GenerateRandomPassphrase(16)
 	if err != nil {
 		return "", fmt.Errorf("error generating public passphrase: %v", err)
 	}
 
 	// Generate private passphrase
-	privPass, err := generateRandomPassphrase(32)
+	privPass, err := utils.GenerateRandomPassphrase(32)
 	if err != nil {
 		return "", fmt.Errorf("error generating private passphrase: %v", err)
 	}
 
-	encryptedPubPass := utils.Encrypt(pubPass, password)
-	encryptedPrivPass := utils.Encrypt(privPass, password)
-
 	// Set birthdate to current date and time
 	birthdate := time.Now().UTC()
-	encryptedBirthdate := utils.Encrypt(birthdate.Format(timeFormat), password)
 
 	// Save wallet data
-	operations.SaveWalletData(walletName, encryptedMnemonic, encryptedPubPass, encryptedPrivPass, encryptedBirthdate)
+	if err := operations.SaveWalletData(walletName, password, mnemonic, passphrase, pubPass, privPass, storePassphrase, birthdate); err != nil {
+		return "", err
+	}
 
 	log.Printf("Wallet '%s' created and encrypted successfully.", walletName)
 	log.Printf("Wallet birthdate: %s", birthdate.Format("2006-01-02"))
@@ -221,8 +220,10 @@ func ExistingWallet(reader *bufio.Reader) error {
 	password := strings.TrimSpace(string(passwordBytes))
 	fmt.Println() // Add newline after password input
 
-	encryptedMnemonic := utils.Encrypt(mnemonic, password)
-	encryptedBirthdate := utils.Encrypt(birthdate.Format(timeFormat), password)
+	passphrase, storePassphrase, err := readPassphrase(reader)
+	if err != nil {
+		return err
+	}
 
 	pubKey := ""
 	apiKey := ""
@@ -254,6 +255,7 @@ func ExistingWallet(reader *bufio.Reader) error {
 
 	// Set the newly imported wallet flag to true
 	viper.Set("is_newly_imported", true)
+	viper.Set(utils.WalletSettingKey(walletName, "history_scopes"), []string{})
 	fmt.Println("Setting wallet as newly imported for extended initial sync timeouts")
 
 	err = viper.WriteConfig()
@@ -262,28 +264,30 @@ func ExistingWallet(reader *bufio.Reader) error {
 	}
 
 	// Generate public passphrase
-	pubPass, err := generateRandomPassphrase(16)
+	pubPass, err := utils.GenerateRandomPassphrase(16)
 	if err != nil {
 		return fmt.Errorf("error generating public passphrase: %v", err)
 	}
 
 	// Generate private passphrase
-	privPass, err := generateRandomPassphrase(32)
+	privPass, err := utils.GenerateRandomPassphrase(32)
 	if err != nil {
 		return fmt.Errorf("error generating private passphrase: %v", err)
 	}
 
-	encryptedPubPass := utils.Encrypt(pubPass, password)
-	encryptedPrivPass := utils.Encrypt(privPass, password)
-
-	operations.SaveWalletData(walletName, encryptedMnemonic, encryptedPubPass, encryptedPrivPass, encryptedBirthdate)
+	if err := operations.SaveWalletData(walletName, password, mnemonic, passphrase, pubPass, privPass, storePassphrase, birthdate); err != nil {
+		return err
+	}
 
 	fmt.Printf("Existing wallet '%s' encrypted and saved successfully.\n", walletName)
 
 	return nil
 }
 
-func ImportWalletAPI(walletName, mnemonic, password, birthdate, pubKey, apiKey string) error {
+// ImportWalletAPI restores a wallet from mnemonic. A non-empty passphrase is
+// used as the BIP39 passphrase and, when storePassphrase is set, saved
+// encrypted alongside the seed.
+func ImportWalletAPI(walletName, mnemonic, password, passphrase string, storePassphrase bool, birthdate, pubKey, apiKey string) error {
 	// Validate the mnemonic
 	if !isValidMnemonic(mnemonic) {
 		return fmt.Errorf("invalid mnemonic provided")
@@ -295,9 +299,6 @@ func ImportWalletAPI(walletName, mnemonic, password, birthdate, pubKey, apiKey s
 		return fmt.Errorf("invalid date format: %v", err)
 	}
 
-	encryptedMnemonic := utils.Encrypt(mnemonic, password)
-	encryptedBirthdate := utils.Encrypt(parsedBirthdate.Format(timeFormat), password)
-
 	if pubKey != "" && apiKey != "" {
 		viper.Set("relay_wallet_set", false)
 		viper.Set("wallet_name", walletName)
@@ -307,6 +308,7 @@ func ImportWalletAPI(walletName, mnemonic, password, birthdate, pubKey, apiKey s
 
 	// Set the newly imported wallet flag to true
 	viper.Set("is_newly_imported", true)
+	viper.Set(utils.WalletSettingKey(walletName, "history_scopes"), []string{})
 	log.Printf("Setting is_newly_imported flag to true for wallet: %s", walletName)
 
 	err = viper.WriteConfig()
@@ -315,33 +317,62 @@ func ImportWalletAPI(walletName, mnemonic, password, birthdate, pubKey, apiKey s
 	}
 
 	// Generate public passphrase
-	pubPass, err := generateRandomPassphrase(16)
+	pubPass, err := utils.GenerateRandomPassphrase(16)
 	if err != nil {
 		return fmt.Errorf("error generating public passphrase: %v", err)
 	}
 
 	// Generate private passphrase
-	privPass, err := generateRandomPassphrase(32)
+	privPass, err := utils.GenerateRandomPassphrase(32)
 	if err != nil {
 		return fmt.Errorf("error generating private passphrase: %v", err)
 	}
 
-	encryptedPubPass := utils.Encrypt(pubPass, password)
-	encryptedPrivPass := utils.Encrypt(privPass, password)
-
-	operations.SaveWalletData(walletName, encryptedMnemonic, encryptedPubPass, encryptedPrivPass, encryptedBirthdate)
+	if err := operations.SaveWalletData(walletName, password, mnemonic, passphrase, pubPass, privPass, storePassphrase, parsedBirthdate); err != nil {
+		return err
+	}
 
 	return nil
 }
 
-func isValidMnemonic(mnemonic string) bool {
-	return bip39.IsMnemonicValid(mnemonic)
+// ImportSharesAPI restores a wallet from a quorum of SLIP-39 shares made by
+// backup-shares. sharePassphrase must be the one the shares were made with: a
+// wrong one restores a different, empty wallet rather than failing.
+func ImportSharesAPI(walletName string, shares []string, sharePassphrase, password, passphrase string, storePassphrase bool, birthdate, pubKey, apiKey string) error {
+	entropy, err := slip39.Combine(shares, sharePassphrase)
+	if err != nil {
+		return fmt.Errorf("error combining shares: %v", err)
+	}
+
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return fmt.Errorf("shares do not hold a mnemonic: %v", err)
+	}
+
+	return ImportWalletAPI(walletName, mnemonic, password, passphrase, storePassphrase, birthdate, pubKey, apiKey)
 }
 
-func generateRandomPassphrase(length int) (string, error) {
-	bytes := make([]byte, length)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
+// readPassphrase asks for an optional BIP39 passphrase and whether to store
+// it with the wallet.
+func readPassphrase(reader *bufio.Reader) (string, bool, error) {
+	fmt.Print("Enter a BIP39 passphrase (leave empty for none): ")
+	passphraseBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", false, fmt.Errorf("error reading passphrase: %v", err)
+	}
+	passphrase := string(passphraseBytes)
+	fmt.Println() // Add newline after passphrase input
+
+	if passphrase == "" {
+		return "", false, nil
 	}
-	return hex.EncodeToString(bytes), nil
+
+	fmt.Print("Store the passphrase encrypted with the wallet? Otherwise it is asked for at every login. (yes/no): ")
+	storeChoice, _ := reader.ReadString('\n')
+	storeChoice = strings.TrimSpace(strings.ToLower(storeChoice))
+	return passphrase, storeChoice == "yes", nil
+}
+
+func isValidMnemonic(mnemonic string) bool {
+	return bip39.IsMnemonicValid(mnemonic)
 }
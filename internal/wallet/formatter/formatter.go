@@ -19,6 +19,7 @@ import (
 	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/ipc"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/addresses"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/payments"
 	utils "github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/rescanner"
 	"github.com/btcsuite/btcd/chaincfg"
@@ -85,6 +86,7 @@ func PerformRescanAndProcessTransactions(w *wallet.Wallet, chainClient *chain.Ne
 		ChainParams:      chainParams,
 		StartBlock:       lastScannedBlockHeight,
 		Wallet:           w,
+		WalletName:       walletName,
 		IsImportedWallet: isImportedWallet,
 	}
 
@@ -120,6 +122,10 @@ func PerformRescanAndProcessTransactions(w *wallet.Wallet, chainClient *chain.Ne
 	// Also output to stdout for CLI clients
 	outputProgressToStdout(completeUpdate)
 
+	if _, err := payments.UpdateStatuses(w); err != nil {
+		log.Printf("Error matching payment requests: %v", err)
+	}
+
 	if viper.GetBool("relay_wallet_set") && viper.GetString("wallet_name") == walletName {
 
 		if viper.GetBool("server_mode") {
@@ -133,6 +139,11 @@ func PerformRescanAndProcessTransactions(w *wallet.Wallet, chainClient *chain.Ne
 			if err != nil {
 				return fmt.Errorf("error sending receive addresses: %v", err)
 			}
+
+			err = SendPaymentRequestsToBackend(walletName)
+			if err != nil {
+				return fmt.Errorf("error sending payment requests: %v", err)
+			}
 		}
 	}
 
@@ -411,6 +422,65 @@ func SendReceiveAddressesToBackend(walletName string) error {
 	return nil
 }
 
+// SendPaymentRequestsToBackend pushes payment requests whose status changed
+// since they were last sent.
+func SendPaymentRequestsToBackend(walletName string) error {
+	requests, err := walletstatedb.GetUnsentPaymentRequests()
+	if err != nil {
+		return fmt.Errorf("error retrieving unsent payment requests: %v", err)
+	}
+
+	if len(requests) == 0 {
+		return nil
+	}
+
+	var requestList []map[string]interface{}
+	var ids []string
+	for _, request := range requests {
+		requestList = append(requestList, map[string]interface{}{
+			"wallet_name": walletName,
+			"id":          request.ID,
+			"address":     request.Address,
+			"amount":      request.Amount,
+			"memo":        request.Memo,
+			"expires_at":  request.ExpiresAt.Format(time.RFC3339),
+			"status":      request.Status,
+			"received":    request.Received,
+			"txids":       request.TxIDs,
+		})
+		ids = append(ids, request.ID)
+	}
+
+	jsonData, err := json.Marshal(requestList)
+	if err != nil {
+		return fmt.Errorf("error marshaling payment requests: %v", err)
+	}
+
+	responseBody, err := sendToBackend("/api/wallet/payment-requests", jsonData)
+	if err != nil {
+		return fmt.Errorf("backend request failed: %v", err)
+	}
+
+	var result struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return fmt.Errorf("error parsing backend response: %v", err)
+	}
+
+	if result.Status != "success" {
+		return fmt.Errorf("backend returned non-success status: %s - %s", result.Status, result.Message)
+	}
+
+	if err := walletstatedb.MarkPaymentRequestsAsSent(ids); err != nil {
+		return fmt.Errorf("error marking payment requests as sent: %v", err)
+	}
+
+	log.Printf("Successfully sent %d payment request updates: %s", len(requestList), result.Message)
+	return nil
+}
+
 func sendToBackend(endpoint string, data []byte) ([]byte, error) {
 	backendURL := viper.GetString("relay_backend_url")
 	if backendURL == "" {
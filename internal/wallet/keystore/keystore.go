@@ -0,0 +1,448 @@
+// Package keystore reads and writes the encrypted file that holds a wallet's
+// secrets.
+//
+// A keystore is a JSON document recording its format version, the KDF and
+// parameters that turn the wallet password into keys, the cipher and the
+// sealed secrets. One key encrypts the secrets; a second authenticates the
+// whole document, so edits to the parameters or metadata are caught before
+// anything is decrypted.
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// Version is the keystore format this package writes.
+	Version = 1
+
+	// Extension is the file extension of a keystore in the wallet directory.
+	Extension = ".keystore"
+
+	CipherAES256GCM = "aes-256-gcm"
+	KDFScrypt       = "scrypt"
+	KDFArgon2id     = "argon2id"
+
+	// DefaultTarget is how long one key derivation should take when Tune is
+	// not given a target.
+	DefaultTarget = 500 * time.Millisecond
+
+	// Upper bounds for Tune, so a fast machine does not produce a keystore
+	// that a slower one cannot open. Scrypt uses 128*N*r bytes, 256 MiB here.
+	maxScryptN    = 1 << 18
+	maxArgon2Time = 16
+
+	// Limits on the parameters Read accepts, so a crafted keystore cannot
+	// make Open spend unbounded memory or time before the MAC is checked.
+	// They leave room for keystores tuned under earlier, higher caps.
+	maxScryptWork   = 1 << 23 // N*r*p
+	maxArgon2Memory = 1 << 20 // KiB
+
+	saltSize = 32
+	keySize  = 32
+)
+
+// Names of the secrets a wallet keystore holds.
+const (
+	SeedPhrase        = "seed_phrase"
+	PublicPassphrase  = "public_passphrase"
+	PrivatePassphrase = "private_passphrase"
+	Birthdate         = "birthdate"
+	BIP39Passphrase   = "bip39_passphrase"
+	MasterFingerprint = "master_fingerprint"
+)
+
+// ErrIncorrectPassword is returned by Open when the password does not
+// authenticate the keystore.
+var ErrIncorrectPassword = errors.New("incorrect password or corrupted keystore")
+
+// KDF names the key derivation function and the parameters it was run with.
+// N, R and P are the scrypt cost; Time, Memory (KiB) and Threads the Argon2id
+// cost.
+type KDF struct {
+	Name    string `json:"name"`
+	Salt    string `json:"salt"`
+	N       int    `json:"n,omitempty"`
+	R       int    `json:"r,omitempty"`
+	P       int    `json:"p,omitempty"`
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+}
+
+// Sealed is one secret encrypted under its own nonce.
+type Sealed struct {
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// Keystore is the on-disk form of a wallet's secrets. Metadata is stored in
+// the clear but covered by the MAC.
+type Keystore struct {
+	Version  int               `json:"version"`
+	KDF      KDF               `json:"kdf"`
+	Cipher   string            `json:"cipher"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Secrets  map[string]Sealed `json:"secrets"`
+	MAC      string            `json:"mac"`
+}
+
+// MinimumKDF returns the cheapest parameters a new keystore is sealed with
+// for the KDF called name. Keystores below them are upgraded when opened.
+func MinimumKDF(name string) (KDF, error) {
+	switch name {
+	case KDFScrypt:
+		return KDF{Name: KDFScrypt, N: 1 << 17, R: 8, P: 1}, nil
+	case KDFArgon2id:
+		// The second recommended option of RFC 9106
+		return KDF{Name: KDFArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4}, nil
+	}
+	return KDF{}, fmt.Errorf("unsupported KDF %q, use %s or %s", name, KDFScrypt, KDFArgon2id)
+}
+
+// Tune benchmarks the KDF called name on this machine and raises its cost
+// from the minimum until one derivation takes about target.
+func Tune(name string, target time.Duration) (KDF, error) {
+	kdf, err := MinimumKDF(name)
+	if err != nil {
+		return KDF{}, err
+	}
+	if target <= 0 {
+		target = DefaultTarget
+	}
+	if kdf.Name == KDFArgon2id && runtime.NumCPU() < int(kdf.Threads) {
+		kdf.Threads = uint8(runtime.NumCPU())
+	}
+
+	start := time.Now()
+	if _, err := derive("benchmark", make([]byte, saltSize), kdf); err != nil {
+		return KDF{}, err
+	}
+	elapsed := time.Since(start)
+
+	// Each step scales the work linearly, so the time is predicted rather
+	// than measured again
+	for {
+		next, ratio, ok := kdf.step()
+		if !ok {
+			break
+		}
+		predicted := time.Duration(float64(elapsed) * ratio)
+		if predicted > target {
+			break
+		}
+		kdf, elapsed = next, predicted
+	}
+	return kdf, nil
+}
+
+// NeedsUpgrade reports whether a keystore sealed with kdf should be sealed
+// again with target: when it uses another algorithm, costs less than the
+// minimum, or costs less than half of target. The margin keeps benchmark
+// noise of one tuning step from re-sealing on every open.
+func NeedsUpgrade(kdf, target KDF) bool {
+	if kdf.Name != target.Name {
+		return true
+	}
+	min, err := MinimumKDF(kdf.Name)
+	if err != nil {
+		return false
+	}
+	switch kdf.Name {
+	case KDFScrypt:
+		if kdf.N < min.N || kdf.R < min.R {
+			return true
+		}
+	case KDFArgon2id:
+		if kdf.Time < min.Time || kdf.Memory < min.Memory {
+			return true
+		}
+	}
+	return 2*kdf.cost() < target.cost()
+}
+
+// cost is the work one derivation with kdf takes, in units comparable only
+// between parameters of the same algorithm.
+func (kdf KDF) cost() float64 {
+	switch kdf.Name {
+	case KDFScrypt:
+		return float64(kdf.N) * float64(kdf.R) * float64(kdf.P)
+	case KDFArgon2id:
+		return float64(kdf.Time) * float64(kdf.Memory)
+	}
+	return 0
+}
+
+// validate checks that kdf names a supported algorithm with parameters
+// inside the limits Read accepts.
+func (kdf KDF) validate() error {
+	switch kdf.Name {
+	case KDFScrypt:
+		if kdf.N < 2 || kdf.N&(kdf.N-1) != 0 || kdf.R < 1 || kdf.P < 1 {
+			return fmt.Errorf("invalid scrypt parameters")
+		}
+		if kdf.N > maxScryptWork || kdf.R > maxScryptWork || kdf.P > maxScryptWork ||
+			uint64(kdf.N)*uint64(kdf.R)*uint64(kdf.P) > maxScryptWork {
+			return fmt.Errorf("scrypt parameters exceed the supported cost")
+		}
+	case KDFArgon2id:
+		if kdf.Time < 1 || kdf.Threads < 1 || kdf.Memory < 8*uint32(kdf.Threads) {
+			return fmt.Errorf("invalid argon2id parameters")
+		}
+		if kdf.Time > maxArgon2Time || kdf.Memory > maxArgon2Memory {
+			return fmt.Errorf("argon2id parameters exceed the supported cost")
+		}
+	default:
+		return fmt.Errorf("unsupported KDF %q", kdf.Name)
+	}
+	return nil
+}
+
+// String describes the algorithm and cost of kdf, leaving out the salt.
+func (kdf KDF) String() string {
+	switch kdf.Name {
+	case KDFScrypt:
+		return fmt.Sprintf("scrypt N=%d r=%d p=%d", kdf.N, kdf.R, kdf.P)
+	case KDFArgon2id:
+		return fmt.Sprintf("argon2id t=%d m=%dKiB p=%d", kdf.Time, kdf.Memory, kdf.Threads)
+	}
+	return kdf.Name
+}
+
+// step returns kdf one cost step up and how much longer it takes.
+func (kdf KDF) step() (KDF, float64, bool) {
+	switch kdf.Name {
+	case KDFScrypt:
+		if kdf.N >= maxScryptN {
+			return kdf, 0, false
+		}
+		kdf.N *= 2
+		return kdf, 2, true
+	case KDFArgon2id:
+		if kdf.Time >= maxArgon2Time {
+			return kdf, 0, false
+		}
+		kdf.Time++
+		return kdf, float64(kdf.Time) / float64(kdf.Time-1), true
+	}
+	return kdf, 0, false
+}
+
+// Seal encrypts secrets with password, using kdf under a fresh salt, and
+// authenticates them together with metadata.
+func Seal(password string, kdf KDF, secrets, metadata map[string]string) (*Keystore, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("error generating salt: %v", err)
+	}
+	kdf.Salt = base64.StdEncoding.EncodeToString(salt)
+
+	encKey, macKey, err := deriveKeys(password, kdf)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(encKey)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Keystore{
+		Version:  Version,
+		KDF:      kdf,
+		Cipher:   CipherAES256GCM,
+		Metadata: metadata,
+		Secrets:  make(map[string]Sealed, len(secrets)),
+	}
+	for name, value := range secrets {
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, fmt.Errorf("error generating nonce: %v", err)
+		}
+		// The secret name is bound in so sealed values cannot be swapped
+		ciphertext := aead.Seal(nil, nonce, []byte(value), []byte(name))
+		k.Secrets[name] = Sealed{
+			Nonce:      base64.StdEncoding.EncodeToString(nonce),
+			Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+		}
+	}
+
+	mac, err := k.computeMAC(macKey)
+	if err != nil {
+		return nil, err
+	}
+	k.MAC = base64.StdEncoding.EncodeToString(mac)
+	return k, nil
+}
+
+// Open checks the MAC with password and returns the decrypted secrets.
+func (k *Keystore) Open(password string) (map[string]string, error) {
+	encKey, macKey, err := deriveKeys(password, k.KDF)
+	if err != nil {
+		return nil, err
+	}
+
+	expected, err := k.computeMAC(macKey)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := base64.StdEncoding.DecodeString(k.MAC)
+	if err != nil || !hmac.Equal(mac, expected) {
+		return nil, ErrIncorrectPassword
+	}
+
+	aead, err := newAEAD(encKey)
+	if err != nil {
+		return nil, err
+	}
+	secrets := make(map[string]string, len(k.Secrets))
+	for name, sealed := range k.Secrets {
+		nonce, err := base64.StdEncoding.DecodeString(sealed.Nonce)
+		if err != nil || len(nonce) != aead.NonceSize() {
+			return nil, fmt.Errorf("invalid nonce for %s", name)
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(sealed.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ciphertext for %s", name)
+		}
+		plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(name))
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting %s: %v", name, err)
+		}
+		secrets[name] = string(plaintext)
+	}
+	return secrets, nil
+}
+
+// Read loads the keystore at path and checks that its format is supported
+// and its KDF parameters are within bounds. Nothing is decrypted.
+func Read(path string) (*Keystore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var k Keystore
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, fmt.Errorf("invalid keystore: %v", err)
+	}
+	if k.Version != Version {
+		return nil, fmt.Errorf("unsupported keystore version %d", k.Version)
+	}
+	if k.Cipher != CipherAES256GCM {
+		return nil, fmt.Errorf("unsupported keystore cipher %q", k.Cipher)
+	}
+	if err := k.KDF.validate(); err != nil {
+		return nil, fmt.Errorf("invalid keystore: %v", err)
+	}
+	return &k, nil
+}
+
+// Write saves k to path readable by the owner only. The file is written
+// beside path, synced and renamed over it, so a crash leaves either the old
+// keystore or the new one.
+func (k *Keystore) Write(path string) error {
+	data, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("error creating wallet directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Sync the directory so the rename itself survives a crash
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// computeMAC authenticates everything in k except the MAC itself.
+func (k *Keystore) computeMAC(macKey []byte) ([]byte, error) {
+	unsigned := *k
+	unsigned.MAC = ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// deriveKeys runs kdf over password and splits the output into the cipher
+// key and the MAC key.
+func deriveKeys(password string, kdf KDF) ([]byte, []byte, error) {
+	salt, err := base64.StdEncoding.DecodeString(kdf.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, nil, fmt.Errorf("invalid KDF salt")
+	}
+	key, err := derive(password, salt, kdf)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key[:keySize], key[keySize:], nil
+}
+
+// derive runs kdf over password and salt for both keys.
+func derive(password string, salt []byte, kdf KDF) ([]byte, error) {
+	switch kdf.Name {
+	case KDFScrypt:
+		key, err := scrypt.Key([]byte(password), salt, kdf.N, kdf.R, kdf.P, 2*keySize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scrypt parameters: %v", err)
+		}
+		return key, nil
+	case KDFArgon2id:
+		if kdf.Time < 1 || kdf.Threads < 1 || kdf.Memory < 8*uint32(kdf.Threads) {
+			return nil, fmt.Errorf("invalid argon2id parameters")
+		}
+		return argon2.IDKey([]byte(password), salt, kdf.Time, kdf.Memory, kdf.Threads, 2*keySize), nil
+	}
+	return nil, fmt.Errorf("unsupported KDF %q", kdf.Name)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,94 @@
+package keystore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNeedsUpgrade(t *testing.T) {
+	scrypt := KDF{Name: KDFScrypt, N: 1 << 18, R: 8, P: 1}
+	argon := KDF{Name: KDFArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4}
+
+	tests := []struct {
+		name   string
+		kdf    KDF
+		target KDF
+		want   bool
+	}{
+		{"same parameters", scrypt, scrypt, false},
+		{"one step below target", KDF{Name: KDFScrypt, N: 1 << 17, R: 8, P: 1}, scrypt, false},
+		{"two steps below target", KDF{Name: KDFScrypt, N: 1 << 17, R: 8, P: 1}, KDF{Name: KDFScrypt, N: 1 << 19, R: 8, P: 1}, true},
+		{"above target", scrypt, KDF{Name: KDFScrypt, N: 1 << 17, R: 8, P: 1}, false},
+		{"below minimum", KDF{Name: KDFScrypt, N: 1 << 14, R: 8, P: 1}, KDF{Name: KDFScrypt, N: 1 << 14, R: 8, P: 1}, true},
+		{"other algorithm configured", scrypt, argon, true},
+		{"argon2id time raised", argon, KDF{Name: KDFArgon2id, Time: 8, Memory: 64 * 1024, Threads: 4}, true},
+	}
+	for _, tt := range tests {
+		if got := NeedsUpgrade(tt.kdf, tt.target); got != tt.want {
+			t.Errorf("%s: NeedsUpgrade(%v, %v) = %v, want %v", tt.name, tt.kdf, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestReadRejectsExpensiveKDF(t *testing.T) {
+	tests := []KDF{
+		{Name: KDFScrypt, N: 1 << 30, R: 8, P: 1},
+		{Name: KDFScrypt, N: 1 << 20, R: 8, P: 16},
+		{Name: KDFScrypt, N: 1000, R: 8, P: 1},
+		{Name: KDFArgon2id, Time: 1, Memory: 1 << 30, Threads: 1},
+		{Name: KDFArgon2id, Time: 1 << 30, Memory: 64 * 1024, Threads: 1},
+		{Name: "pbkdf2"},
+	}
+	for _, kdf := range tests {
+		path := writeKeystore(t, &Keystore{Version: Version, KDF: kdf, Cipher: CipherAES256GCM})
+		if _, err := Read(path); err == nil {
+			t.Errorf("Read accepted %v", kdf)
+		}
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	kdf, err := MinimumKDF(KDFScrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := map[string]string{SeedPhrase: "abandon abandon ability"}
+	k, err := Seal("password", kdf, secrets, map[string]string{"mode": "none"})
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "wallet"+Extension)
+	if err := k.Write(path); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	read, err := Read(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	opened, err := read.Open("password")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if opened[SeedPhrase] != secrets[SeedPhrase] {
+		t.Errorf("opened %q, want %q", opened[SeedPhrase], secrets[SeedPhrase])
+	}
+	if _, err := read.Open("wrong"); err != ErrIncorrectPassword {
+		t.Errorf("wrong password gave %v, want %v", err, ErrIncorrectPassword)
+	}
+}
+
+func writeKeystore(t *testing.T, k *Keystore) string {
+	t.Helper()
+	data, err := json.Marshal(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "wallet"+Extension)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
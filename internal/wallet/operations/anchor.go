@@ -0,0 +1,59 @@
+package operations
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	transaction "github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+)
+
+// AnchorDataAPI anchors hex data or document digests in an OP_RETURN output.
+// itemsStr is comma separated. The recipient and amount are optional; without
+// them the transaction sends its change back to the wallet.
+func (s *WalletServer) AnchorDataAPI(mode, feeRateStr, itemsStr, recipient, amountStr string, inputStrs []string) (map[string]interface{}, error) {
+	feeRate, err := strconv.ParseInt(feeRateStr, 10, 64)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid fee rate: %v", err)}, nil
+	}
+
+	payload, leaves, err := transaction.BuildAnchorPayload(mode, strings.Split(itemsStr, ","))
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	var recipients []transaction.Recipient
+	if recipient != "" {
+		amount, err := strconv.ParseInt(amountStr, 10, 64)
+		if err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("invalid amount: %v", err)}, nil
+		}
+		recipients = append(recipients, transaction.Recipient{Address: recipient, Amount: amount})
+	}
+
+	inputs, err := transaction.ParseOutpoints(inputStrs)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	txHash, verified, err := transaction.CreateAnchorTransaction(s.API.Wallet, s.API.ChainClient.CS, true, mode, payload, leaves, recipients, s.API.PrivPass, int(feeRate), inputs)
+	if err != nil {
+		return spendFailure("anchor transaction failed", err), nil
+	}
+
+	return map[string]interface{}{
+		"txHash":   txHash.String(),
+		"verified": verified,
+		"mode":     mode,
+		"payload":  fmt.Sprintf("%x", payload),
+	}, nil
+}
+
+func (s *WalletServer) ListAnchorsAPI() (map[string]interface{}, error) {
+	anchors, err := walletstatedb.GetAnchors()
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	return map[string]interface{}{"anchors": anchors}, nil
+}
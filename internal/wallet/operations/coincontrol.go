@@ -0,0 +1,51 @@
+package operations
+
+import (
+	"fmt"
+
+	transaction "github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+)
+
+func (s *WalletServer) ListUTXOsAPI() (map[string]interface{}, error) {
+	utxos, err := transaction.ListWalletUTXOs(s.API.Wallet)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to list UTXOs: %v", err)}, nil
+	}
+	return map[string]interface{}{"utxos": utxos}, nil
+}
+
+// FreezeUTXOsAPI freezes or unfreezes the given txid:vout outpoints.
+func (s *WalletServer) FreezeUTXOsAPI(outpointStrs []string, frozen bool) (map[string]interface{}, error) {
+	outpoints, err := transaction.ParseOutpoints(outpointStrs)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	if len(outpoints) == 0 {
+		return map[string]interface{}{"error": "no outpoints given"}, nil
+	}
+
+	if err := transaction.FreezeOutpoints(outpoints, frozen); err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	return map[string]interface{}{
+		"outpoints": outpointStrs,
+		"frozen":    frozen,
+	}, nil
+}
+
+func (s *WalletServer) LabelUTXOAPI(outpointStr, label string) (map[string]interface{}, error) {
+	outpoint, err := transaction.ParseOutpoint(outpointStr)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	if err := transaction.LabelOutpoint(outpoint, label); err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	return map[string]interface{}{
+		"outpoint": outpoint.String(),
+		"label":    label,
+	}, nil
+}
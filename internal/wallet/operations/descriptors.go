@@ -0,0 +1,275 @@
+package operations
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/api"
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/descriptor"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/spf13/viper"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// ImportDescriptorWallet saves a new wallet backed by an output descriptor in
+// place of a mnemonic. A wpkh() or tr() descriptor over the master private key
+// gives a full wallet; one over an account xpub gives a watch-only wallet, and
+// a wsh(sortedmulti()) descriptor gives a multisig wallet. The wallet is
+// rescanned from birthdate when it is first opened.
+func ImportDescriptorWallet(walletName, desc, password, birthdate, pubKey, apiKey string) error {
+	canonical, addrType, err := walletDescriptor(desc)
+	if err != nil {
+		return err
+	}
+
+	parsedBirthdate, err := time.Parse("2006-01-02", birthdate)
+	if err != nil {
+		return fmt.Errorf("invalid date format: %v", err)
+	}
+
+	if pubKey != "" && apiKey != "" {
+		viper.Set("relay_wallet_set", false)
+		viper.Set("wallet_name", walletName)
+		viper.Set("wallet_api_key", apiKey)
+		viper.Set("user_pubkey", pubKey)
+	}
+
+	// The descriptor fixes the script type the wallet hands out
+	viper.Set(utils.WalletSettingKey(walletName, "address_type"), addrType)
+
+	viper.Set("is_newly_imported", true)
+	viper.Set(utils.WalletSettingKey(walletName, "history_scopes"), []string{})
+	log.Printf("Setting is_newly_imported flag to true for descriptor wallet: %s", walletName)
+
+	err = viper.WriteConfig()
+	if err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+
+	pubPass, err := utils.GenerateRandomPassphrase(16)
+	if err != nil {
+		return fmt.Errorf("error generating public passphrase: %v", err)
+	}
+
+	privPass, err := utils.GenerateRandomPassphrase(32)
+	if err != nil {
+		return fmt.Errorf("error generating private passphrase: %v", err)
+	}
+
+	return SaveWalletData(walletName, password, canonical, "", pubPass, privPass, false, parsedBirthdate)
+}
+
+// walletDescriptor validates desc as the secret of a new wallet and returns
+// its canonical form and the address type the wallet hands out.
+func walletDescriptor(desc string) (string, string, error) {
+	params := &chaincfg.MainNetParams
+
+	if descriptor.IsMultisig(desc) {
+		m, err := descriptor.ParseMultisig(desc, params)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid descriptor: %v", err)
+		}
+		if err := m.ValidateForWallet(); err != nil {
+			return "", "", err
+		}
+		return m.String(), utils.AddressTypeP2WSH, nil
+	}
+
+	parsed, err := descriptor.Parse(desc, params)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid descriptor: %v", err)
+	}
+	if err := parsed.ValidateForWallet(); err != nil {
+		return "", "", err
+	}
+	addrType, err := parsed.AddressType()
+	if err != nil {
+		return "", "", err
+	}
+	return parsed.String(), addrType, nil
+}
+
+// CreateMultisigWallet saves a threshold-of-n P2WSH multisig wallet made of
+// the BIP48 account key of mnemonic, under its optional BIP39 passphrase, and
+// the cosigners' account keys. The wallet signs with its own key; spends need
+// threshold-1 cosigner signatures.
+func CreateMultisigWallet(walletName string, threshold int, mnemonic, passphrase string, cosigners []string, password, birthdate, pubKey, apiKey string) error {
+	params := &chaincfg.MainNetParams
+
+	ownKey, err := multisigAccountKey(mnemonic, passphrase)
+	if err != nil {
+		return err
+	}
+
+	m := &descriptor.Multisig{Threshold: threshold, Keys: []*descriptor.KeyExpr{ownKey}}
+	for i, cosigner := range cosigners {
+		key, err := descriptor.ParseCosignerKey(cosigner, params)
+		if err != nil {
+			return fmt.Errorf("cosigner %d: %v", i+1, err)
+		}
+		m.Keys = append(m.Keys, key)
+	}
+
+	return ImportDescriptorWallet(walletName, m.String(), password, birthdate, pubKey, apiKey)
+}
+
+// MultisigCosignerKey returns the public BIP48 account key of mnemonic, under
+// its optional BIP39 passphrase, in the [fingerprint/48h/0h/0h/2h]xpub form
+// other cosigners add to their wallets.
+func MultisigCosignerKey(mnemonic, passphrase string) (string, error) {
+	key, err := multisigAccountKey(mnemonic, passphrase)
+	if err != nil {
+		return "", err
+	}
+	public, err := key.Key.Neuter()
+	if err != nil {
+		return "", fmt.Errorf("failed to neuter account key: %v", err)
+	}
+	key.Key = public
+	return strings.TrimSuffix(key.String(), "/0/*"), nil
+}
+
+// multisigAccountKey derives the BIP48 P2WSH account key of mnemonic.
+func multisigAccountKey(mnemonic, passphrase string) (*descriptor.KeyExpr, error) {
+	params := &chaincfg.MainNetParams
+
+	seed, err := bip39.NewSeedWithErrorChecking(strings.TrimSpace(mnemonic), passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %v", err)
+	}
+	rootKey, err := hdkeychain.NewMaster(seed, params)
+	if err != nil {
+		return nil, fmt.Errorf("error generating root key: %v", err)
+	}
+	return descriptor.BIP48Key(rootKey, 0, params)
+}
+
+// ImportWatchOnlyWallet saves a watch-only wallet for an account-level
+// extended public key. zpubs are native segwit; an xpub is read as addrType,
+// p2wpkh or p2tr. The optional master key fingerprint is carried into exported
+// descriptors and PSBTs so hardware signers recognise their keys.
+func ImportWatchOnlyWallet(walletName, extendedKey, addrType, fingerprintHex, password, birthdate, pubKey, apiKey string) error {
+	params := &chaincfg.MainNetParams
+
+	key, err := hdkeychain.NewKeyFromString(strings.TrimSpace(extendedKey))
+	if err != nil {
+		return fmt.Errorf("invalid extended public key: %v", err)
+	}
+	if key.IsPrivate() {
+		return fmt.Errorf("watch-only wallets take an extended public key, not a private one")
+	}
+	if key.Depth() != 3 || key.ChildIndex() < hdkeychain.HardenedKeyStart {
+		return fmt.Errorf("expected an account-level key derived at m/purpose'/coin'/account'")
+	}
+
+	descType := descriptor.TypeWPKH
+	switch hex.EncodeToString(key.Version()) {
+	case "04b24746": // zpub
+		if addrType == utils.AddressTypeP2TR {
+			return fmt.Errorf("a zpub cannot back a %s wallet", addrType)
+		}
+	case hex.EncodeToString(params.HDPublicKeyID[:]): // xpub
+		if addrType == utils.AddressTypeP2TR {
+			descType = descriptor.TypeTR
+		}
+	default:
+		return fmt.Errorf("unsupported extended key version %x, use an xpub or zpub", key.Version())
+	}
+
+	// Descriptors always carry the plain xpub version
+	key, err = key.CloneWithVersion(params.HDPublicKeyID[:])
+	if err != nil {
+		return fmt.Errorf("failed to convert extended key: %v", err)
+	}
+
+	desc := &descriptor.Descriptor{Type: descType, Key: key, Path: []uint32{0}}
+	if fingerprintHex != "" {
+		fingerprint, err := strconv.ParseUint(fingerprintHex, 16, 32)
+		if err != nil || len(fingerprintHex) != 8 {
+			return fmt.Errorf("invalid master key fingerprint %q", fingerprintHex)
+		}
+		scope, err := desc.Scope()
+		if err != nil {
+			return err
+		}
+		desc.Fingerprint = uint32(fingerprint)
+		desc.Origin = []uint32{
+			hdkeychain.HardenedKeyStart + scope.Purpose,
+			hdkeychain.HardenedKeyStart + scope.Coin,
+			key.ChildIndex(),
+		}
+	}
+
+	return ImportDescriptorWallet(walletName, desc.String(), password, birthdate, pubKey, apiKey)
+}
+
+// ExportDescriptorsAPI returns the receive and change descriptors of every
+// account the wallet holds.
+func (s *WalletServer) ExportDescriptorsAPI() (map[string]interface{}, error) {
+	fingerprint, err := walletstatedb.GetMasterFingerprint()
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to read master key fingerprint: %v", err)}, nil
+	}
+
+	descriptors := multisig.Descriptors(s.API.Wallet, fingerprint)
+	if len(descriptors) == 0 {
+		return map[string]interface{}{"error": "wallet has no accounts to export"}, nil
+	}
+
+	return map[string]interface{}{
+		"fingerprint": fmt.Sprintf("%08x", fingerprint),
+		"addressType": utils.AddressType(),
+		"descriptors": descriptors,
+	}, nil
+}
+
+// ImportDescriptorAPI creates another wallet from a descriptor. It is opened
+// like any other wallet once this one exits.
+func (s *WalletServer) ImportDescriptorAPI(walletName, desc, password, birthdate string) (map[string]interface{}, error) {
+	if walletName == s.API.Name {
+		return map[string]interface{}{"error": "cannot import over the running wallet"}, nil
+	}
+
+	if err := ImportDescriptorWallet(walletName, desc, password, birthdate, "", ""); err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	return map[string]interface{}{
+		"walletName": walletName,
+		"message":    "Wallet imported successfully",
+	}, nil
+}
+
+// HandleImportDescriptor serves POST /descriptors/import. Wallet files are
+// written by this package, so the handler lives here rather than in api.
+func (s *WalletServer) HandleImportDescriptor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req api.DescriptorImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, _ := s.ImportDescriptorAPI(req.WalletName, req.Descriptor, req.Password, req.Birthdate)
+	if errMsg, ok := result["error"]; ok {
+		http.Error(w, fmt.Sprintf("Failed to import descriptor: %v", errMsg), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
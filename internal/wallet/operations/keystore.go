@@ -0,0 +1,283 @@
+package operations
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/keystore"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/joho/godotenv"
+	"github.com/spf13/viper"
+)
+
+const (
+	// legacyExtension is the .env file wallets were kept in before the
+	// keystore format.
+	legacyExtension = ".env"
+
+	// passphraseModeKey is the keystore metadata entry holding the BIP39
+	// passphrase mode.
+	passphraseModeKey = "bip39_passphrase"
+)
+
+var (
+	// tunedKDFs caches benchmark results by KDF name and target, so opening a
+	// wallet does not benchmark again each time.
+	tunedKDFs     = make(map[string]keystore.KDF)
+	tunedKDFMutex sync.Mutex
+)
+
+// legacyKeys maps the encrypted entries of a .env wallet file to the secrets
+// they become in a keystore.
+var legacyKeys = map[string]string{
+	"ENCRYPTED_SEED_PHRASE":        keystore.SeedPhrase,
+	"ENCRYPTED_PUBLIC_PASSPHRASE":  keystore.PublicPassphrase,
+	"ENCRYPTED_PRIVATE_PASSPHRASE": keystore.PrivatePassphrase,
+	"ENCRYPTED_BIRTHDATE":          keystore.Birthdate,
+	"ENCRYPTED_BIP39_PASSPHRASE":   keystore.BIP39Passphrase,
+	"ENCRYPTED_MASTER_FINGERPRINT": keystore.MasterFingerprint,
+}
+
+func walletFile(walletName string) string {
+	return filepath.Join(walletDir, walletName+keystore.Extension)
+}
+
+func legacyWalletFile(walletName string) string {
+	return filepath.Join(walletDir, walletName+legacyExtension)
+}
+
+// WalletExists reports whether walletName has a keystore or a .env file.
+func WalletExists(walletName string) bool {
+	if _, err := os.Stat(walletFile(walletName)); err == nil {
+		return true
+	}
+	return isLegacyWallet(walletName)
+}
+
+// isLegacyWallet reports whether walletName is still only in a .env file.
+func isLegacyWallet(walletName string) bool {
+	if _, err := os.Stat(walletFile(walletName)); err == nil {
+		return false
+	}
+	_, err := os.Stat(legacyWalletFile(walletName))
+	return err == nil
+}
+
+// LegacyWallets returns the names of the wallets still kept in .env files.
+func LegacyWallets() ([]string, error) {
+	wallets, err := ListWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	var legacy []string
+	for _, walletName := range wallets {
+		if isLegacyWallet(walletName) {
+			legacy = append(legacy, walletName)
+		}
+	}
+	return legacy, nil
+}
+
+// openWallet reads the keystore of walletName and decrypts it with password.
+// A wallet still in a .env file is migrated first, since the password needed
+// to do so is at hand.
+func openWallet(walletName, password string) (*keystore.Keystore, map[string]string, error) {
+	if isLegacyWallet(walletName) {
+		if err := MigrateWallet(walletName, password); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	k, err := keystore.Read(walletFile(walletName))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error loading wallet file: %v", err)
+	}
+	secrets, err := k.Open(password)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decrypting wallet: %v", err)
+	}
+
+	target, err := newWalletKDF()
+	if err != nil {
+		log.Printf("Error tuning KDF for wallet %s: %v", walletName, err)
+	} else if keystore.NeedsUpgrade(k.KDF, target) {
+		upgradeWallet(walletName, password, k, secrets, target)
+	}
+	return k, secrets, nil
+}
+
+// upgradeWallet re-seals an opened keystore with kdf, the configured KDF
+// tuned on this machine, when its own has fallen behind. A failure leaves the
+// old keystore in use and is only logged.
+func upgradeWallet(walletName, password string, k *keystore.Keystore, secrets map[string]string, kdf keystore.KDF) {
+	if err := rewriteWallet(walletName, password, kdf, secrets, k.Metadata); err != nil {
+		log.Printf("Error upgrading keystore of wallet %s: %v", walletName, err)
+		return
+	}
+	log.Printf("Upgraded keystore of wallet %s to %v", walletName, kdf)
+	k.KDF = kdf
+}
+
+// newWalletKDF returns the configured KDF, benchmarked on this machine for
+// wallet_kdf_target, that keystores are sealed with.
+func newWalletKDF() (keystore.KDF, error) {
+	name := viper.GetString("wallet_kdf")
+	if name == "" {
+		name = keystore.KDFScrypt
+	}
+	target := viper.GetDuration("wallet_kdf_target")
+	if target <= 0 {
+		target = keystore.DefaultTarget
+	}
+
+	tunedKDFMutex.Lock()
+	defer tunedKDFMutex.Unlock()
+
+	key := fmt.Sprintf("%s/%s", name, target)
+	if kdf, ok := tunedKDFs[key]; ok {
+		return kdf, nil
+	}
+	kdf, err := keystore.Tune(name, target)
+	if err != nil {
+		return keystore.KDF{}, err
+	}
+	tunedKDFs[key] = kdf
+	return kdf, nil
+}
+
+// rewriteWallet replaces the keystore of walletName with secrets and metadata
+// sealed under password with kdf.
+func rewriteWallet(walletName, password string, kdf keystore.KDF, secrets, metadata map[string]string) error {
+	k, err := keystore.Seal(password, kdf, secrets, metadata)
+	if err != nil {
+		return err
+	}
+	return k.Write(walletFile(walletName))
+}
+
+// ReadSeedPhrase decrypts the seed phrase, or descriptor, of walletName. It
+// doubles as the password check before destructive operations.
+func ReadSeedPhrase(walletName, password string) (string, error) {
+	_, secrets, err := openWallet(walletName, password)
+	if err != nil {
+		return "", err
+	}
+	seedPhrase := secrets[keystore.SeedPhrase]
+	if seedPhrase == "" {
+		return "", fmt.Errorf("encrypted seed phrase not found")
+	}
+	return seedPhrase, nil
+}
+
+// MigrateWallet converts the .env file of walletName into a keystore sealed
+// with the same password. The keystore is read back and checked before the
+// .env file is renamed to a .env.bak backup.
+func MigrateWallet(walletName, password string) error {
+	legacy := legacyWalletFile(walletName)
+	if _, err := os.Stat(walletFile(walletName)); err == nil {
+		return fmt.Errorf("wallet %s already uses a keystore", walletName)
+	}
+
+	// Read rather than Load, so the secrets stay out of the environment
+	data, err := godotenv.Read(legacy)
+	if err != nil {
+		return fmt.Errorf("error loading wallet file: %v", err)
+	}
+
+	secrets := make(map[string]string)
+	for key, name := range legacyKeys {
+		encrypted, ok := data[key]
+		if !ok {
+			continue
+		}
+		value, err := utils.Decrypt(encrypted, password)
+		if err != nil {
+			return fmt.Errorf("error decrypting %s: incorrect password or decryption failed", name)
+		}
+		secrets[name] = value
+	}
+	if secrets[keystore.SeedPhrase] == "" {
+		return fmt.Errorf("encrypted seed phrase not found")
+	}
+
+	metadata := map[string]string{passphraseModeKey: PassphraseNone}
+	if mode := data["BIP39_PASSPHRASE"]; mode != "" {
+		metadata[passphraseModeKey] = mode
+	}
+
+	kdf, err := newWalletKDF()
+	if err != nil {
+		return err
+	}
+	if err := rewriteWallet(walletName, password, kdf, secrets, metadata); err != nil {
+		return fmt.Errorf("error writing keystore: %v", err)
+	}
+
+	k, err := keystore.Read(walletFile(walletName))
+	if err == nil {
+		var opened map[string]string
+		if opened, err = k.Open(password); err == nil && !maps.Equal(opened, secrets) {
+			err = fmt.Errorf("secrets differ from the .env file")
+		}
+	}
+	if err != nil {
+		os.Remove(walletFile(walletName))
+		return fmt.Errorf("keystore for %s failed verification, .env file left in place: %v", walletName, err)
+	}
+
+	backup := legacy + ".bak"
+	if err := os.Rename(legacy, backup); err != nil {
+		return fmt.Errorf("error backing up %s: %v", legacy, err)
+	}
+
+	log.Printf("Migrated wallet %s to %s, previous file kept as %s", walletName, walletFile(walletName), backup)
+	return nil
+}
+
+// ChangeWalletPassword re-encrypts every secret of walletName under
+// newPassword. The private passphrase of w, the open btcwallet, is rotated at
+// the same time and returned; it is put back if the keystore cannot be
+// written, so the two never disagree. Watch-only wallets have no private
+// passphrase to rotate.
+func ChangeWalletPassword(w *wallet.Wallet, walletName, oldPassword, newPassword string) ([]byte, error) {
+	if newPassword == "" {
+		return nil, fmt.Errorf("new password must not be empty")
+	}
+
+	k, secrets, err := openWallet(walletName, oldPassword)
+	if err != nil {
+		return nil, err
+	}
+	oldPrivPass := []byte(secrets[keystore.PrivatePassphrase])
+	newPrivPass := oldPrivPass
+
+	if !w.Manager.WatchOnly() {
+		generated, err := utils.GenerateRandomPassphrase(32)
+		if err != nil {
+			return nil, fmt.Errorf("error generating private passphrase: %v", err)
+		}
+		newPrivPass = []byte(generated)
+		if err := w.ChangePrivatePassphrase(oldPrivPass, newPrivPass); err != nil {
+			return nil, fmt.Errorf("error changing wallet private passphrase: %v", err)
+		}
+		secrets[keystore.PrivatePassphrase] = generated
+	}
+
+	if err := rewriteWallet(walletName, newPassword, k.KDF, secrets, k.Metadata); err != nil {
+		if !w.Manager.WatchOnly() {
+			if rollbackErr := w.ChangePrivatePassphrase(newPrivPass, oldPrivPass); rollbackErr != nil {
+				log.Printf("Error restoring private passphrase of %s: %v", walletName, rollbackErr)
+			}
+		}
+		return nil, fmt.Errorf("error saving re-encrypted wallet: %v", err)
+	}
+
+	log.Printf("Changed password of wallet %s", walletName)
+	return newPrivPass, nil
+}
@@ -0,0 +1,33 @@
+package operations
+
+import (
+	"fmt"
+
+	transaction "github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+)
+
+func (s *WalletServer) SignMessageAPI(address, message, format string) (map[string]interface{}, error) {
+	signature, format, err := transaction.SignMessage(s.API.Wallet, s.API.PrivPass, address, message, format)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("message signing failed: %v", err)}, nil
+	}
+
+	return map[string]interface{}{
+		"address":   address,
+		"signature": signature,
+		"format":    format,
+	}, nil
+}
+
+func (s *WalletServer) VerifyMessageAPI(address, signature, message string) (map[string]interface{}, error) {
+	valid, format, err := transaction.VerifyMessage(address, signature, message, s.API.Wallet.ChainParams())
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("message verification failed: %v", err)}, nil
+	}
+
+	return map[string]interface{}{
+		"address": address,
+		"format":  format,
+		"valid":   valid,
+	}, nil
+}
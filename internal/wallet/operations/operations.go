@@ -5,15 +5,13 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/api"
 	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
-	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
-	"github.com/joho/godotenv"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/bip21"
 	"golang.org/x/term"
 )
 
@@ -80,12 +78,14 @@ func (s *WalletServer) HandleGetReceiveAddresses() (interface{}, error) {
 
 	// Convert []btcutil.Address to []string
 	receiveAddressStrings := make([]string, len(receiveAddresses))
+	receiveURIs := make([]string, len(receiveAddresses))
 	for i, addr := range receiveAddresses {
 		receiveAddressStrings[i] = addr.String() // Convert each btcutil.Address to its string representation
+		receiveURIs[i] = (&bip21.URI{Address: addr}).String()
 	}
 
 	log.Printf("Receive addresses retrieved: %v\n", receiveAddressStrings)
-	return map[string][]string{"addresses": receiveAddressStrings}, nil
+	return map[string][]string{"addresses": receiveAddressStrings, "uris": receiveURIs}, nil
 }
 
 func ViewSeedPhrase() error {
@@ -94,15 +94,8 @@ func ViewSeedPhrase() error {
 	scanner.Scan()
 	walletName := strings.TrimSpace(scanner.Text())
 
-	envFile := filepath.Join(walletDir, walletName+".env")
-	err := godotenv.Load(envFile)
-	if err != nil {
-		return fmt.Errorf("error loading wallet file: %v", err)
-	}
-
-	encryptedSeedPhrase := os.Getenv("ENCRYPTED_SEED_PHRASE")
-	if encryptedSeedPhrase == "" {
-		return fmt.Errorf("encrypted seed phrase not found")
+	if !WalletExists(walletName) {
+		return fmt.Errorf("wallet %s not found", walletName)
 	}
 
 	fmt.Print("Enter your wallet password: ")
@@ -113,13 +106,24 @@ func ViewSeedPhrase() error {
 	password := strings.TrimSpace(string(passwordBytes))
 	fmt.Println() // Add newline after password input
 
-	seedPhrase, err := utils.Decrypt(encryptedSeedPhrase, password)
+	seedPhrase, err := ReadSeedPhrase(walletName, password)
 	if err != nil {
-		return fmt.Errorf("error decrypting seed phrase: %v", err)
+		return err
 	}
 
 	fmt.Println("Your seed phrase is:")
 	fmt.Println(seedPhrase)
+
+	passphraseMode, err := WalletPassphraseMode(walletName)
+	if err != nil {
+		return err
+	}
+	switch passphraseMode {
+	case PassphraseStored:
+		fmt.Println("This wallet uses a BIP39 passphrase, stored encrypted with the wallet. Back it up with the seed phrase.")
+	case PassphraseRequired:
+		fmt.Println("This wallet uses a BIP39 passphrase that is not stored. The seed phrase alone does not restore it.")
+	}
 	fmt.Println("Please ensure you store this securely and never share it with anyone.")
 
 	return nil
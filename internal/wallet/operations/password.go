@@ -0,0 +1,27 @@
+package operations
+
+import (
+	"fmt"
+)
+
+// ChangePasswordAPI re-encrypts the open wallet under newPassword and keeps
+// the server's private passphrase in step with the rotated one, unless the
+// server runs with unlock sessions and holds none.
+func (s *WalletServer) ChangePasswordAPI(walletName, oldPassword, newPassword string) (map[string]interface{}, error) {
+	if walletName != s.API.Name {
+		return map[string]interface{}{"error": fmt.Sprintf("wallet %s is not the open wallet", walletName)}, nil
+	}
+
+	privPass, err := ChangeWalletPassword(s.API.Wallet, walletName, oldPassword, newPassword)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("password change failed: %v", err)}, nil
+	}
+	if !UnlockSessionsRequired() {
+		s.API.PrivPass = privPass
+	}
+
+	return map[string]interface{}{
+		"walletName": walletName,
+		"changed":    true,
+	}, nil
+}
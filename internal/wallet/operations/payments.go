@@ -0,0 +1,89 @@
+package operations
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/payments"
+)
+
+// CreatePaymentRequestAPI records a request for amount satoshis to a newly
+// allocated address. The expiry is a duration such as 30m or 24h.
+func (s *WalletServer) CreatePaymentRequestAPI(amountStr, expiryStr, memo string) (map[string]interface{}, error) {
+	amount, err := strconv.ParseInt(amountStr, 10, 64)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid amount: %v", err)}, nil
+	}
+
+	expiry := payments.DefaultExpiry
+	if expiryStr != "" {
+		expiry, err = time.ParseDuration(expiryStr)
+		if err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("invalid expiry: %v", err)}, nil
+		}
+	}
+
+	request, err := payments.CreateRequest(s.API.Wallet, amount, memo, expiry)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	return s.paymentRequestResult(request)
+}
+
+func (s *WalletServer) ListPaymentRequestsAPI(status string) (map[string]interface{}, error) {
+	requests, err := walletstatedb.GetPaymentRequests(status)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	return map[string]interface{}{"requests": requests}, nil
+}
+
+func (s *WalletServer) GetPaymentRequestAPI(id string) (map[string]interface{}, error) {
+	request, err := walletstatedb.GetPaymentRequest(id)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	return s.paymentRequestResult(request)
+}
+
+// UpdatePaymentRequestAPI changes a request's memo when one is given and,
+// when expiryStr is given, moves its expiry to that long from now.
+func (s *WalletServer) UpdatePaymentRequestAPI(id, expiryStr string, memo *string) (map[string]interface{}, error) {
+	var expiry time.Duration
+	if expiryStr != "" {
+		var err error
+		expiry, err = time.ParseDuration(expiryStr)
+		if err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("invalid expiry: %v", err)}, nil
+		}
+	}
+
+	request, err := payments.UpdateRequest(id, memo, expiry)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	return s.paymentRequestResult(request)
+}
+
+func (s *WalletServer) DeletePaymentRequestAPI(id string) (map[string]interface{}, error) {
+	if err := walletstatedb.DeletePaymentRequest(id); err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	return map[string]interface{}{
+		"id":      id,
+		"message": "Payment request deleted",
+	}, nil
+}
+
+func (s *WalletServer) paymentRequestResult(request *walletstatedb.PaymentRequest) (map[string]interface{}, error) {
+	uri, err := payments.URI(request, s.API.Wallet)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	return map[string]interface{}{
+		"request": request,
+		"uri":     uri,
+	}, nil
+}
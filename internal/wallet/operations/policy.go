@@ -0,0 +1,41 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/api"
+	transaction "github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+)
+
+// SpendingPolicyAPI reports the active spending policy and how much of the
+// rolling limits has been used.
+func (s *WalletServer) SpendingPolicyAPI() (map[string]interface{}, error) {
+	policy, err := transaction.LoadSpendingPolicy()
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	usage, err := transaction.GetSpendingUsage()
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to read spending usage: %v", err)}, nil
+	}
+
+	return map[string]interface{}{
+		"enabled": policy != nil,
+		"policy":  policy,
+		"usage":   usage,
+	}, nil
+}
+
+// spendFailure is the IPC reply to a failed spend. A refusal by the spending
+// policy also carries the rule that refused it.
+func spendFailure(prefix string, err error) map[string]interface{} {
+	result := map[string]interface{}{"error": fmt.Sprintf("%s: %v", prefix, err)}
+
+	var violation *transaction.PolicyViolation
+	if errors.As(err, &violation) {
+		result["code"] = api.ErrorCodePolicyViolation
+		result["violation"] = violation
+	}
+	return result
+}
@@ -0,0 +1,129 @@
+package operations
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/bip21"
+	transaction "github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+)
+
+func (s *WalletServer) CreatePSBTAPI(recipient, amountStr, feeRateStr string, inputStrs []string) (map[string]interface{}, error) {
+	amount, err := strconv.ParseInt(amountStr, 10, 64)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid amount: %v", err)}, nil
+	}
+
+	feeRate, err := strconv.Atoi(feeRateStr)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid fee rate: %v", err)}, nil
+	}
+
+	inputs, err := transaction.ParseOutpoints(inputStrs)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	recipient, amount, err = bip21.Resolve(recipient, amount, s.API.Wallet.ChainParams())
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid payment URI: %v", err)}, nil
+	}
+
+	packet, err := transaction.CreatePSBT(s.API.Wallet, true, amount, recipient, feeRate, inputs)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("PSBT creation failed: %v", err)}, nil
+	}
+
+	return psbtResult(packet, nil)
+}
+
+func (s *WalletServer) SignPSBTAPI(encoded string) (map[string]interface{}, error) {
+	packet, err := transaction.DecodePSBT([]byte(encoded))
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	signed, err := transaction.SignPSBT(s.API.Wallet, packet, s.API.PrivPass)
+	if err != nil {
+		return spendFailure("PSBT signing failed", err), nil
+	}
+
+	return psbtResult(packet, map[string]interface{}{"signedInputs": signed})
+}
+
+func (s *WalletServer) FinalizePSBTAPI(encoded string) (map[string]interface{}, error) {
+	packet, err := transaction.DecodePSBT([]byte(encoded))
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	tx, err := transaction.FinalizePSBT(packet)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("PSBT finalization failed: %v", err)}, nil
+	}
+
+	txHex, err := transaction.SerializeTransactionHex(tx)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	return psbtResult(packet, map[string]interface{}{
+		"txid":  tx.TxHash().String(),
+		"txHex": txHex,
+	})
+}
+
+func (s *WalletServer) BroadcastPSBTAPI(encoded string) (map[string]interface{}, error) {
+	packet, err := transaction.DecodePSBT([]byte(encoded))
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	txHash, verified, err := transaction.BroadcastPSBT(s.API.Wallet, s.API.ChainClient.CS, packet)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("PSBT broadcast failed: %v", err)}, nil
+	}
+
+	return map[string]interface{}{
+		"txHash":   txHash.String(),
+		"verified": verified,
+	}, nil
+}
+
+func psbtResult(packet *psbt.Packet, extra map[string]interface{}) (map[string]interface{}, error) {
+	encoded, err := transaction.EncodePSBT(packet)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to encode PSBT: %v", err)}, nil
+	}
+
+	result := map[string]interface{}{
+		"psbt":     encoded,
+		"complete": packet.IsComplete(),
+	}
+	if fee, err := packet.GetTxFee(); err == nil {
+		result["fee"] = int64(fee)
+	}
+	for k, v := range extra {
+		result[k] = v
+	}
+	return result, nil
+}
+
+// watchOnlySpendResult returns a spend the wallet cannot sign alone as a PSBT.
+// Multisig wallets add their own signature first, so the PSBT only needs the
+// cosigners'.
+func (s *WalletServer) watchOnlySpendResult(packet *psbt.Packet, extra map[string]interface{}) (map[string]interface{}, error) {
+	result := map[string]interface{}{"watchOnly": true}
+	if transaction.IsMultisig() {
+		signed, err := transaction.AddMultisigSignature(s.API.Wallet, packet)
+		if err != nil {
+			return spendFailure("PSBT signing failed", err), nil
+		}
+		result = map[string]interface{}{"multisig": true, "signedInputs": signed}
+	}
+	for k, v := range extra {
+		result[k] = v
+	}
+	return psbtResult(packet, result)
+}
@@ -0,0 +1,91 @@
+package operations
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	transaction "github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+)
+
+// scheduledTxInterval is how often the scheduler checks whether a scheduled
+// transaction's locktime has been reached.
+const scheduledTxInterval = time.Minute
+
+// StartScheduler broadcasts timelocked transactions once the chain tip
+// reaches their locktime. It runs until the process exits.
+func (s *WalletServer) StartScheduler() {
+	ticker := time.NewTicker(scheduledTxInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if transacting {
+			continue
+		}
+		count, err := transaction.BroadcastDueTransactions(s.API.ChainService)
+		if err != nil {
+			log.Printf("Error broadcasting scheduled transactions: %v", err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("Broadcast %d scheduled transactions", count)
+		}
+	}
+}
+
+// ScheduleTransactionAPI signs a payment locked until the given block height
+// or unix time and keeps it for the scheduler.
+func (s *WalletServer) ScheduleTransactionAPI(recipient, amountStr, feeRateStr, lockTimeStr string, inputStrs []string) (map[string]interface{}, error) {
+	amount, err := strconv.ParseInt(amountStr, 10, 64)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid amount: %v", err)}, nil
+	}
+
+	feeRate, err := strconv.Atoi(feeRateStr)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid fee rate: %v", err)}, nil
+	}
+
+	lockTime, err := strconv.ParseUint(lockTimeStr, 10, 32)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid locktime: %v", err)}, nil
+	}
+
+	inputs, err := transaction.ParseOutpoints(inputStrs)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	scheduled, err := transaction.CreateScheduledTransaction(s.API.Wallet, amount, recipient, uint32(lockTime), s.API.PrivPass, feeRate, inputs)
+	if err != nil {
+		return spendFailure("scheduling transaction failed", err), nil
+	}
+
+	return map[string]interface{}{
+		"txid":      scheduled.TxID,
+		"lockTime":  scheduled.LockTime,
+		"unlocksAt": transaction.DescribeLockTime(scheduled.LockTime),
+		"fee":       scheduled.Fee,
+		"status":    scheduled.Status,
+	}, nil
+}
+
+func (s *WalletServer) ListScheduledTransactionsAPI(status string) (map[string]interface{}, error) {
+	scheduled, err := walletstatedb.GetScheduledTransactions(status)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	return map[string]interface{}{"transactions": scheduled}, nil
+}
+
+func (s *WalletServer) CancelScheduledTransactionAPI(txID string) (map[string]interface{}, error) {
+	if err := transaction.CancelScheduledTransaction(txID); err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	return map[string]interface{}{
+		"txid":   txID,
+		"status": walletstatedb.ScheduledStatusCancelled,
+	}, nil
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/logger"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/core"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcwallet/chain"
 	"github.com/btcsuite/btcwallet/wallet"
@@ -36,13 +37,20 @@ func NewWalletServer(wallet *wallet.Wallet, chainParams *chaincfg.Params, chainS
 	}
 }
 
-func initializeWalletServer(seedPhrase string, pubPass []byte, privPass []byte, baseDir string, walletName string, birthdate time.Time, httpMode bool) (*WalletServer, error) {
+func initializeWalletServer(seedPhrase, passphrase string, pubPass []byte, privPass []byte, baseDir string, walletName string, birthdate time.Time, httpMode bool) (*WalletServer, error) {
 	// Initialize the wallet and necessary components
-	w, chainParams, chainService, chainClient, neutrinoDB, err := core.InitializeWallet(seedPhrase, pubPass, privPass, baseDir, walletName, birthdate)
+	w, chainParams, chainService, chainClient, neutrinoDB, err := core.InitializeWallet(seedPhrase, passphrase, pubPass, privPass, baseDir, walletName, birthdate)
 	if err != nil {
 		return nil, err
 	}
 
+	// With unlock sessions the passphrase is only held while unlocking
+	if UnlockSessionsRequired() {
+		w.Lock()
+		multisig.Lock()
+		privPass = nil
+	}
+
 	server := NewWalletServer(w, chainParams, chainService, chainClient, neutrinoDB, privPass, walletName, httpMode)
 
 	return server, nil
@@ -81,10 +89,36 @@ func (s *WalletServer) Close() {
 func (s *WalletServer) StartHTTPSServer() error {
 	// Start the background sync process
 	go s.StartSyncProcess()
+	go s.StartScheduler()
 
 	// Wrap your handlers with the CORS middleware
-	http.HandleFunc("/transaction", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.TransactionHandler)))
+	http.HandleFunc("/transaction", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.UnlockedMiddleware(s.API.TransactionHandler))))
 	http.HandleFunc("/calculate-tx-size", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandleTransactionSizeEstimate)))
+	http.HandleFunc("/scheduled-transactions", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandleListScheduledTransactions)))
+	http.HandleFunc("/scheduled-transactions/cancel", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandleCancelScheduledTransaction)))
+	http.HandleFunc("/anchor", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.UnlockedMiddleware(s.API.HandleAnchorData))))
+	http.HandleFunc("/anchors", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandleListAnchors)))
+	http.HandleFunc("/descriptors", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandleExportDescriptors)))
+	http.HandleFunc("/descriptors/import", s.API.CORSMiddleware(s.API.JWTMiddleware(s.HandleImportDescriptor)))
+	http.HandleFunc("/utxos", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandleListUTXOs)))
+	http.HandleFunc("/utxos/freeze", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandleFreezeUTXOs)))
+	http.HandleFunc("/utxos/label", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandleLabelUTXO)))
+	http.HandleFunc("/psbt/create", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandlePSBTCreate)))
+	http.HandleFunc("/psbt/sign", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.UnlockedMiddleware(s.API.HandlePSBTSign))))
+	http.HandleFunc("/psbt/finalize", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandlePSBTFinalize)))
+	http.HandleFunc("/psbt/broadcast", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandlePSBTBroadcast)))
+	http.HandleFunc("/message/sign", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.UnlockedMiddleware(s.API.HandleSignMessage))))
+
+	// Verifying a signature reveals nothing about the wallet, so customers need no token
+	http.HandleFunc("/message/verify", s.API.CORSMiddleware(s.API.HandleVerifyMessage))
+	http.HandleFunc("/payment-uri", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandlePaymentURI)))
+	http.HandleFunc("/payment-requests", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandlePaymentRequests)))
+	http.HandleFunc("/payment-requests/get", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandleGetPaymentRequest)))
+	http.HandleFunc("/payment-requests/update", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandleUpdatePaymentRequest)))
+	http.HandleFunc("/payment-requests/delete", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandleDeletePaymentRequest)))
+	http.HandleFunc("/spending-policy", s.API.CORSMiddleware(s.API.JWTMiddleware(s.API.HandleSpendingPolicy)))
+	http.HandleFunc("/unlock", s.API.CORSMiddleware(s.API.JWTMiddleware(s.HandleUnlock)))
+	http.HandleFunc("/lock", s.API.CORSMiddleware(s.API.JWTMiddleware(s.HandleLock)))
 	http.HandleFunc("/generate-addresses", s.API.CORSMiddleware(s.API.WalletAPIMiddleware(s.API.HandleAddressGeneration)))
 
 	// Route for challenge generation
@@ -144,14 +178,17 @@ func (s *WalletServer) StartHTTPSServer() error {
 	return server.ListenAndServe()
 }
 
-func StartWallet(seedPhrase string, pubPass []byte, privPass []byte, baseDir string, walletName string, birthdate time.Time, httpMode bool) error {
+func StartWallet(seedPhrase, passphrase string, pubPass []byte, privPass []byte, baseDir string, walletName string, birthdate time.Time, httpMode bool) error {
 
 	// Ensure the JWT key is available
 	if err := api.EnsureJWTKey(walletName); err != nil {
 		log.Printf("Failed to initialize JWT key: %v", err)
 	}
 
-	server, err := initializeWalletServer(seedPhrase, pubPass, privPass, baseDir, walletName, birthdate, useHTTPS)
+	// Per-wallet settings apply to everything this wallet builds
+	utils.SetActiveWallet(walletName)
+
+	server, err := initializeWalletServer(seedPhrase, passphrase, pubPass, privPass, baseDir, walletName, birthdate, useHTTPS)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,172 @@
+package operations
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/api"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/keystore"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/descriptor"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
+	transaction "github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
+	"github.com/spf13/viper"
+)
+
+// signingCommands are the IPC commands that need the wallet unlocked.
+var signingCommands = map[string]bool{
+	"new-transaction":      true,
+	"batch-transaction":    true,
+	"rbf-transaction":      true,
+	"sweep-transaction":    true,
+	"cancel-transaction":   true,
+	"cpfp-transaction":     true,
+	"psbt-sign":            true,
+	"sign-message":         true,
+	"schedule-transaction": true,
+	"anchor-data":          true,
+}
+
+// UnlockSessionsRequired reports whether the wallet signs only inside unlock
+// sessions. The private passphrase is then not kept by the server.
+func UnlockSessionsRequired() bool {
+	return viper.GetBool("unlock_sessions")
+}
+
+// UnlockAPI checks password against the wallet's keystore and unlocks the
+// wallet for signing until timeout passes or LockAPI is called. Multisig
+// wallets load the private key of their descriptor for the same time.
+func (s *WalletServer) UnlockAPI(password, timeout string) (map[string]interface{}, error) {
+	if !UnlockSessionsRequired() {
+		return map[string]interface{}{"error": "unlock sessions are not enabled; set unlock_sessions in the config"}, nil
+	}
+	isMultisig := multisig.Active() != nil
+	if isMultisig && !multisig.HasSigningKey() {
+		return map[string]interface{}{"error": "multisig wallet holds no signing key; every signature comes from cosigners"}, nil
+	}
+	if !isMultisig && transaction.IsWatchOnly(s.API.Wallet) {
+		return map[string]interface{}{"error": transaction.ErrWatchOnly.Error()}, nil
+	}
+
+	duration, err := parseUnlockTimeout(timeout)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	_, secrets, err := openWallet(s.API.Name, password)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("unlock failed: %v", err)}, nil
+	}
+
+	if isMultisig {
+		// The descriptor is dropped again when the session ends
+		m, err := descriptor.ParseMultisig(secrets[keystore.SeedPhrase], s.API.ChainParams)
+		if err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("unlock failed: error parsing multisig descriptor: %v", err)}, nil
+		}
+		if err := multisig.Unlock(m, duration); err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("unlock failed: %v", err)}, nil
+		}
+	} else if err := s.API.Wallet.Unlock([]byte(secrets[keystore.PrivatePassphrase]), time.After(duration)); err != nil {
+		// The wallet locks itself when the timer fires; the passphrase is
+		// dropped with secrets
+		return map[string]interface{}{"error": fmt.Sprintf("unlock failed: %v", err)}, nil
+	}
+	expiresAt := time.Now().Add(duration).UTC()
+
+	log.Printf("Wallet unlocked for signing until %s", expiresAt.Format(time.RFC3339))
+	return map[string]interface{}{
+		"unlocked":  true,
+		"expiresAt": expiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// LockAPI ends the current unlock session.
+func (s *WalletServer) LockAPI() (map[string]interface{}, error) {
+	if !UnlockSessionsRequired() {
+		return map[string]interface{}{"error": "unlock sessions are not enabled; set unlock_sessions in the config"}, nil
+	}
+
+	s.API.Wallet.Lock()
+	multisig.Lock()
+
+	log.Println("Wallet locked")
+	return map[string]interface{}{"unlocked": false}, nil
+}
+
+// lockedResult is the IPC reply to a signing command sent outside an unlock
+// session.
+func (s *WalletServer) lockedResult(command string) map[string]interface{} {
+	if !signingCommands[command] {
+		return nil
+	}
+	if err := transaction.CheckUnlocked(s.API.Wallet, s.API.PrivPass); err != nil {
+		return map[string]interface{}{"error": err.Error(), "code": api.ErrorCodeWalletLocked}
+	}
+	return nil
+}
+
+// parseUnlockTimeout reads a session length given as a duration or in
+// seconds, defaulting to unlock_timeout and capped at max_unlock_timeout.
+func parseUnlockTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		timeout = viper.GetString("unlock_timeout")
+	}
+
+	duration, err := time.ParseDuration(timeout)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(timeout)
+		if convErr != nil {
+			return 0, fmt.Errorf("invalid timeout %q: use a duration such as 10m or a number of seconds", timeout)
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("timeout must be positive")
+	}
+	if max := viper.GetDuration("max_unlock_timeout"); max > 0 && duration > max {
+		return 0, fmt.Errorf("timeout %s exceeds max_unlock_timeout of %s", duration, max)
+	}
+	return duration, nil
+}
+
+func (s *WalletServer) HandleUnlock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req api.UnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, _ := s.UnlockAPI(req.Password, req.Timeout)
+	if errMsg, ok := result["error"]; ok {
+		http.Error(w, fmt.Sprintf("Failed to unlock wallet: %v", errMsg), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *WalletServer) HandleLock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, _ := s.LockAPI()
+	if errMsg, ok := result["error"]; ok {
+		http.Error(w, fmt.Sprintf("Failed to lock wallet: %v", errMsg), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
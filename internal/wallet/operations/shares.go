@@ -0,0 +1,27 @@
+package operations
+
+import (
+	"fmt"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/slip39"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// BackupShares splits the entropy of walletName's mnemonic into SLIP-39 share
+// groups, any groupThreshold of which restore the mnemonic with
+// import-shares. sharePassphrase encrypts the shares and is needed to combine
+// them; it is separate from the wallet's BIP39 passphrase, which the shares do
+// not hold.
+func BackupShares(walletName, password string, groupThreshold int, groups []slip39.Group, sharePassphrase string, iterationExponent int) ([][]string, error) {
+	seedPhrase, err := ReadSeedPhrase(walletName, password)
+	if err != nil {
+		return nil, err
+	}
+
+	entropy, err := bip39.EntropyFromMnemonic(seedPhrase)
+	if err != nil {
+		return nil, fmt.Errorf("only wallets created from a mnemonic can be split into shares")
+	}
+
+	return slip39.Split(entropy, sharePassphrase, groupThreshold, groups, iterationExponent)
+}
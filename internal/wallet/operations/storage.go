@@ -1,7 +1,6 @@
 package operations
 
 import (
-	"log"
 	"os"
 	"path/filepath"
 
@@ -10,9 +9,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/keystore"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/joho/godotenv"
-	"github.com/spf13/viper"
+	"github.com/tyler-smith/go-bip39"
 	"golang.org/x/term"
 )
 
@@ -20,121 +22,209 @@ const (
 	timeFormat = "2006-01-02T15:04:05Z"
 )
 
-func SaveWalletData(walletName, encryptedSeedPhrase, encryptedPubPass, encryptedPrivPass, encryptedBirthdate string) {
-	err := os.MkdirAll(walletDir, os.ModePerm)
+// BIP39 passphrase modes recorded in a wallet file. A stored passphrase is
+// encrypted with the wallet password like the seed; a required one is kept
+// only by the user and must be given each time the wallet opens.
+const (
+	PassphraseNone     = "none"
+	PassphraseStored   = "stored"
+	PassphraseRequired = "required"
+)
+
+// SaveWalletData writes a new keystore for walletName holding its seed
+// phrase, btcwallet passphrases and birthdate, encrypted with password. A
+// non-empty passphrase is the BIP39 passphrase protecting the seed; it is
+// kept, encrypted like the seed, only when storePassphrase is set, while the
+// fingerprint of the master key it gives is always kept so a mistyped
+// passphrase is caught when the wallet opens.
+func SaveWalletData(walletName, password, seedPhrase, passphrase, pubPass, privPass string, storePassphrase bool, birthdate time.Time) error {
+	kdf, err := newWalletKDF()
 	if err != nil {
-		log.Fatalf("Error creating wallet directory: %v", err)
+		return err
 	}
 
-	envFile := filepath.Join(walletDir, walletName+".env")
-	err = godotenv.Write(map[string]string{
-		"ENCRYPTED_SEED_PHRASE":        encryptedSeedPhrase,
-		"ENCRYPTED_PUBLIC_PASSPHRASE":  encryptedPubPass,
-		"ENCRYPTED_PRIVATE_PASSPHRASE": encryptedPrivPass,
-		"ENCRYPTED_BIRTHDATE":          encryptedBirthdate,
-	}, envFile)
-
-	if err != nil {
-		log.Fatalf("Error saving encrypted data: %v", err)
+	secrets := map[string]string{
+		keystore.SeedPhrase:        seedPhrase,
+		keystore.PublicPassphrase:  pubPass,
+		keystore.PrivatePassphrase: privPass,
+		keystore.Birthdate:         birthdate.UTC().Format(timeFormat),
+	}
+	metadata := map[string]string{passphraseModeKey: PassphraseNone}
+
+	// The seed and its passphrase metadata are sealed together, so a wallet
+	// is never left on disk without the mode it has to be opened with
+	if passphrase != "" {
+		fingerprint, err := passphraseFingerprint(seedPhrase, passphrase)
+		if err != nil {
+			return err
+		}
+		secrets[keystore.MasterFingerprint] = fmt.Sprintf("%08x", fingerprint)
+		metadata[passphraseModeKey] = PassphraseRequired
+		if storePassphrase {
+			metadata[passphraseModeKey] = PassphraseStored
+			secrets[keystore.BIP39Passphrase] = passphrase
+		}
 	}
-}
 
-func LoadWallet(walletName string) (string, string, string, time.Time, error) {
-	envFile := filepath.Join(walletDir, walletName+".env")
-	err := godotenv.Load(envFile)
+	k, err := keystore.Seal(password, kdf, secrets, metadata)
 	if err != nil {
-		return "", "", "", time.Time{}, fmt.Errorf("error loading wallet file: %v", err)
+		return fmt.Errorf("error encrypting wallet data: %v", err)
 	}
 
-	encryptedSeedPhrase := os.Getenv("ENCRYPTED_SEED_PHRASE")
-	encryptedPubPass := os.Getenv("ENCRYPTED_PUBLIC_PASSPHRASE")
-	encryptedPrivPass := os.Getenv("ENCRYPTED_PRIVATE_PASSPHRASE")
-	encryptedBirthdate := os.Getenv("ENCRYPTED_BIRTHDATE")
+	if err := k.Write(walletFile(walletName)); err != nil {
+		return fmt.Errorf("error saving encrypted data: %v", err)
+	}
+	return nil
+}
 
-	if encryptedSeedPhrase == "" || encryptedPubPass == "" || encryptedPrivPass == "" || encryptedBirthdate == "" {
-		return "", "", "", time.Time{}, fmt.Errorf("encrypted wallet data not found")
+func LoadWallet(walletName string) (string, string, string, string, time.Time, error) {
+	if !WalletExists(walletName) {
+		return "", "", "", "", time.Time{}, fmt.Errorf("error loading wallet file: wallet %s not found", walletName)
 	}
 
 	fmt.Print("Enter your wallet password: ")
 	passwordBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
 	if err != nil {
-		return "", "", "", time.Time{}, fmt.Errorf("error reading password: %v", err)
+		return "", "", "", "", time.Time{}, fmt.Errorf("error reading password: %v", err)
 	}
 	password := strings.TrimSpace(string(passwordBytes))
 	fmt.Println() // Add newline after password input
 
-	seedPhrase, err := utils.Decrypt(encryptedSeedPhrase, password)
+	k, secrets, err := openWallet(walletName, password)
 	if err != nil {
-		return "", "", "", time.Time{}, fmt.Errorf("error decrypting seed phrase: %v", err)
+		return "", "", "", "", time.Time{}, err
 	}
 
-	pubPass, err := utils.Decrypt(encryptedPubPass, password)
-	if err != nil {
-		return "", "", "", time.Time{}, fmt.Errorf("error decrypting public passphrase: %v", err)
+	var supplied string
+	if passphraseMode(k) == PassphraseRequired {
+		fmt.Print("Enter your BIP39 passphrase: ")
+		passphraseBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
+		if err != nil {
+			return "", "", "", "", time.Time{}, fmt.Errorf("error reading passphrase: %v", err)
+		}
+		supplied = string(passphraseBytes)
+		fmt.Println() // Add newline after passphrase input
 	}
 
-	privPass, err := utils.Decrypt(encryptedPrivPass, password)
+	return walletSecrets(k, secrets, supplied)
+}
+
+// LoadWalletAPI decrypts walletName with password. passphrase is the BIP39
+// passphrase to open it with when the wallet does not store its own.
+func LoadWalletAPI(walletName, password, passphrase string) (string, string, string, string, time.Time, error) {
+	k, secrets, err := openWallet(walletName, password)
 	if err != nil {
-		return "", "", "", time.Time{}, fmt.Errorf("error decrypting private passphrase: %v", err)
+		return "", "", "", "", time.Time{}, err
 	}
+	return walletSecrets(k, secrets, passphrase)
+}
 
-	birthdateStr, err := utils.Decrypt(encryptedBirthdate, password)
-	if err != nil {
-		return "", "", "", time.Time{}, fmt.Errorf("error decrypting birthdate: %v", err)
+// walletSecrets returns the seed phrase, BIP39 passphrase, public and private
+// passphrases and birthdate held in an opened keystore.
+func walletSecrets(k *keystore.Keystore, secrets map[string]string, supplied string) (string, string, string, string, time.Time, error) {
+	seedPhrase := secrets[keystore.SeedPhrase]
+	pubPass := secrets[keystore.PublicPassphrase]
+	privPass := secrets[keystore.PrivatePassphrase]
+	birthdateStr := secrets[keystore.Birthdate]
+
+	if seedPhrase == "" || pubPass == "" || privPass == "" || birthdateStr == "" {
+		return "", "", "", "", time.Time{}, fmt.Errorf("encrypted wallet data not found")
 	}
 
 	birthdate, err := time.Parse(timeFormat, birthdateStr)
 	if err != nil {
-		return "", "", "", time.Time{}, fmt.Errorf("error parsing birthdate: %v", err)
+		return "", "", "", "", time.Time{}, fmt.Errorf("error parsing birthdate: %v", err)
 	}
 
-	return seedPhrase, pubPass, privPass, birthdate, nil
-}
-
-func LoadWalletAPI(walletName, password string) (string, string, string, time.Time, error) {
-	envFile := filepath.Join(walletDir, walletName+".env")
-	err := godotenv.Load(envFile)
+	passphrase, err := walletPassphrase(k, secrets, seedPhrase, supplied)
 	if err != nil {
-		return "", "", "", time.Time{}, fmt.Errorf("error loading wallet file: %v", err)
+		return "", "", "", "", time.Time{}, err
 	}
 
-	encryptedSeedPhrase := os.Getenv("ENCRYPTED_SEED_PHRASE")
-	encryptedPubPass := os.Getenv("ENCRYPTED_PUBLIC_PASSPHRASE")
-	encryptedPrivPass := os.Getenv("ENCRYPTED_PRIVATE_PASSPHRASE")
-	encryptedBirthdate := os.Getenv("ENCRYPTED_BIRTHDATE")
+	return seedPhrase, passphrase, pubPass, privPass, birthdate, nil
+}
 
-	if encryptedSeedPhrase == "" || encryptedPubPass == "" || encryptedPrivPass == "" || encryptedBirthdate == "" {
-		return "", "", "", time.Time{}, fmt.Errorf("encrypted wallet data not found")
+// WalletPassphraseMode reports how the BIP39 passphrase of walletName is kept:
+// PassphraseNone, PassphraseStored or PassphraseRequired.
+func WalletPassphraseMode(walletName string) (string, error) {
+	if isLegacyWallet(walletName) {
+		data, err := godotenv.Read(legacyWalletFile(walletName))
+		if err != nil {
+			return "", fmt.Errorf("error loading wallet file: %v", err)
+		}
+		if mode := data["BIP39_PASSPHRASE"]; mode != "" {
+			return mode, nil
+		}
+		return PassphraseNone, nil
 	}
 
-	seedPhrase, err := utils.Decrypt(encryptedSeedPhrase, password)
+	k, err := keystore.Read(walletFile(walletName))
 	if err != nil {
-		return "", "", "", time.Time{}, fmt.Errorf("error decrypting seed phrase: %v", err)
+		return "", fmt.Errorf("error loading wallet file: %v", err)
 	}
+	return passphraseMode(k), nil
+}
 
-	pubPass, err := utils.Decrypt(encryptedPubPass, password)
-	if err != nil {
-		return "", "", "", time.Time{}, fmt.Errorf("error decrypting public passphrase: %v", err)
+// passphraseMode returns the BIP39 passphrase mode recorded in k.
+func passphraseMode(k *keystore.Keystore) string {
+	if mode := k.Metadata[passphraseModeKey]; mode != "" {
+		return mode
 	}
+	return PassphraseNone
+}
 
-	privPass, err := utils.Decrypt(encryptedPrivPass, password)
-	if err != nil {
-		return "", "", "", time.Time{}, fmt.Errorf("error decrypting private passphrase: %v", err)
+// walletPassphrase returns the BIP39 passphrase an opened keystore opens
+// with: the stored one, or supplied when the wallet does not store it. The
+// result is checked against the master key fingerprint recorded at creation.
+func walletPassphrase(k *keystore.Keystore, secrets map[string]string, seedPhrase, supplied string) (string, error) {
+	var passphrase string
+	switch mode := passphraseMode(k); mode {
+	case PassphraseNone:
+		if supplied != "" {
+			return "", fmt.Errorf("wallet does not use a BIP39 passphrase")
+		}
+		return "", nil
+	case PassphraseStored:
+		passphrase = secrets[keystore.BIP39Passphrase]
+	case PassphraseRequired:
+		if supplied == "" {
+			return "", fmt.Errorf("wallet requires its BIP39 passphrase")
+		}
+		passphrase = supplied
+	default:
+		return "", fmt.Errorf("unknown passphrase mode %q", mode)
 	}
 
-	birthdateStr, err := utils.Decrypt(encryptedBirthdate, password)
+	expected, ok := secrets[keystore.MasterFingerprint]
+	if !ok {
+		return "", fmt.Errorf("master key fingerprint not found")
+	}
+	fingerprint, err := passphraseFingerprint(seedPhrase, passphrase)
 	if err != nil {
-		return "", "", "", time.Time{}, fmt.Errorf("error decrypting birthdate: %v", err)
+		return "", err
+	}
+	if fmt.Sprintf("%08x", fingerprint) != expected {
+		return "", fmt.Errorf("incorrect BIP39 passphrase")
 	}
+	return passphrase, nil
+}
 
-	birthdate, err := time.Parse(timeFormat, birthdateStr)
+// passphraseFingerprint returns the master key fingerprint of mnemonic under
+// passphrase.
+func passphraseFingerprint(mnemonic, passphrase string) (uint32, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
 	if err != nil {
-		return "", "", "", time.Time{}, fmt.Errorf("error parsing birthdate: %v", err)
+		return 0, fmt.Errorf("error generating seed: %v", err)
 	}
-
-	return seedPhrase, pubPass, privPass, birthdate, nil
+	rootKey, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return 0, fmt.Errorf("error generating root key: %v", err)
+	}
+	return utils.GetMasterFingerprint(rootKey)
 }
 
+// ListWallets returns the names of the wallets in the wallet directory,
+// including those still in the .env format.
 func ListWallets() ([]string, error) {
 	files, err := os.ReadDir(walletDir)
 	if err != nil {
@@ -142,9 +232,16 @@ func ListWallets() ([]string, error) {
 	}
 
 	var wallets []string
+	seen := make(map[string]bool)
 	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".env" {
-			wallets = append(wallets, strings.TrimSuffix(file.Name(), ".env"))
+		ext := filepath.Ext(file.Name())
+		if ext != keystore.Extension && ext != legacyExtension {
+			continue
+		}
+		name := strings.TrimSuffix(file.Name(), ext)
+		if !seen[name] {
+			seen[name] = true
+			wallets = append(wallets, name)
 		}
 	}
 
@@ -158,18 +255,8 @@ func DeleteWallet(reader *bufio.Reader) error {
 	walletName, _ := reader.ReadString('\n')
 	walletName = strings.TrimSpace(walletName)
 
-	// Load the wallet's .env file
-	walletDir := viper.GetString("wallet_dir") // Get the wallet directory path from the config
-	envFile := filepath.Join(walletDir, walletName+".env")
-	err := godotenv.Load(envFile)
-	if err != nil {
-		return fmt.Errorf("error loading wallet file: %v", err)
-	}
-
-	// Retrieve the encrypted seed phrase from the .env file
-	encryptedSeedPhrase := os.Getenv("ENCRYPTED_SEED_PHRASE")
-	if encryptedSeedPhrase == "" {
-		return fmt.Errorf("encrypted seed phrase not found")
+	if !WalletExists(walletName) {
+		return fmt.Errorf("wallet %s not found", walletName)
 	}
 
 	// Prompt for the wallet password
@@ -181,10 +268,9 @@ func DeleteWallet(reader *bufio.Reader) error {
 	password := strings.TrimSpace(string(passwordBytes))
 	fmt.Println() // Add newline after password input
 
-	// Attempt to decrypt the seed phrase using the provided password
-	_, err = utils.Decrypt(encryptedSeedPhrase, password)
-	if err != nil {
-		return fmt.Errorf("error decrypting seed phrase: incorrect password or decryption failed")
+	// Check the password against the keystore
+	if _, err := ReadSeedPhrase(walletName, password); err != nil {
+		return err
 	}
 
 	// Confirm the deletion
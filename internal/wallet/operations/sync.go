@@ -166,6 +166,7 @@ func (s *WalletServer) serverLoop() error {
 	logger.Info("Wallet synced")
 
 	go s.HandleIPCCommands(ipcServer)
+	go s.StartScheduler()
 
 	userCommandChannel := make(chan string)
 	go ListenForUserCommands(userCommandChannel)
@@ -225,24 +226,183 @@ func (s *WalletServer) HandleIPCCommands(server *ipc.Server) {
 		var result interface{}
 		var err error
 
+		if locked := s.lockedResult(cmd.Command); locked != nil {
+			server.SendResponse(cmd.ID, ipc.Response{ID: cmd.ID, Result: locked})
+			continue
+		}
+
 		switch cmd.Command {
 		case "new-transaction":
-			recipient := cmd.Args[0]
-			amount := cmd.Args[1]
-			feeRate := cmd.Args[2]
-			result, err = s.NewTransactionAPI(recipient, amount, feeRate)
+			if err = requireArgs(cmd, 3); err == nil {
+				recipient := cmd.Args[0]
+				amount := cmd.Args[1]
+				feeRate := cmd.Args[2]
+				result, err = s.NewTransactionAPI(recipient, amount, feeRate, cmd.Args[3:])
+			}
+		case "batch-transaction":
+			if err = requireArgs(cmd, 2); err == nil {
+				opReturnHex := ""
+				var inputs []string
+				if len(cmd.Args) > 2 {
+					opReturnHex = cmd.Args[2]
+					inputs = cmd.Args[3:]
+				}
+				result, err = s.BatchTransactionAPI(cmd.Args[0], cmd.Args[1], opReturnHex, inputs)
+			}
 		case "rbf-transaction":
 			originalTxID := cmd.Args[0]
 			newFeeRate := cmd.Args[1]
 			result, err = s.RBFTransactionAPI(originalTxID, newFeeRate)
+		case "sweep-transaction":
+			if err = requireArgs(cmd, 2); err == nil {
+				result, err = s.SweepTransactionAPI(cmd.Args[0], cmd.Args[1], cmd.Args[2:])
+			}
+		case "max-sendable":
+			if err = requireArgs(cmd, 2); err == nil {
+				result, err = s.MaxSendableAPI(cmd.Args[0], cmd.Args[1], cmd.Args[2:])
+			}
+		case "cancel-transaction":
+			if err = requireArgs(cmd, 1); err == nil {
+				feeRate := ""
+				if len(cmd.Args) > 1 {
+					feeRate = cmd.Args[1]
+				}
+				result, err = s.CancelTransactionAPI(cmd.Args[0], feeRate)
+			}
+		case "cpfp-transaction":
+			if err = requireArgs(cmd, 2); err == nil {
+				result, err = s.CPFPTransactionAPI(cmd.Args[0], cmd.Args[1])
+			}
+		case "psbt-create":
+			if err = requireArgs(cmd, 3); err == nil {
+				result, err = s.CreatePSBTAPI(cmd.Args[0], cmd.Args[1], cmd.Args[2], cmd.Args[3:])
+			}
+		case "psbt-sign":
+			if err = requireArgs(cmd, 1); err == nil {
+				result, err = s.SignPSBTAPI(cmd.Args[0])
+			}
+		case "psbt-finalize":
+			if err = requireArgs(cmd, 1); err == nil {
+				result, err = s.FinalizePSBTAPI(cmd.Args[0])
+			}
+		case "psbt-broadcast":
+			if err = requireArgs(cmd, 1); err == nil {
+				result, err = s.BroadcastPSBTAPI(cmd.Args[0])
+			}
+		case "sign-message":
+			if err = requireArgs(cmd, 2); err == nil {
+				format := ""
+				if len(cmd.Args) > 2 {
+					format = cmd.Args[2]
+				}
+				result, err = s.SignMessageAPI(cmd.Args[0], cmd.Args[1], format)
+			}
+		case "verify-message":
+			if err = requireArgs(cmd, 3); err == nil {
+				result, err = s.VerifyMessageAPI(cmd.Args[0], cmd.Args[1], cmd.Args[2])
+			}
+		case "schedule-transaction":
+			if err = requireArgs(cmd, 4); err == nil {
+				result, err = s.ScheduleTransactionAPI(cmd.Args[0], cmd.Args[1], cmd.Args[2], cmd.Args[3], cmd.Args[4:])
+			}
+		case "list-scheduled-transactions":
+			status := ""
+			if len(cmd.Args) > 0 {
+				status = cmd.Args[0]
+			}
+			result, err = s.ListScheduledTransactionsAPI(status)
+		case "cancel-scheduled-transaction":
+			if err = requireArgs(cmd, 1); err == nil {
+				result, err = s.CancelScheduledTransactionAPI(cmd.Args[0])
+			}
+		case "anchor-data":
+			if err = requireArgs(cmd, 3); err == nil {
+				recipient, amount := "", ""
+				var inputs []string
+				if len(cmd.Args) > 4 {
+					recipient, amount = cmd.Args[3], cmd.Args[4]
+					inputs = cmd.Args[5:]
+				}
+				result, err = s.AnchorDataAPI(cmd.Args[0], cmd.Args[1], cmd.Args[2], recipient, amount, inputs)
+			}
+		case "list-anchors":
+			result, err = s.ListAnchorsAPI()
+		case "export-descriptors":
+			result, err = s.ExportDescriptorsAPI()
+		case "import-descriptor":
+			if err = requireArgs(cmd, 4); err == nil {
+				result, err = s.ImportDescriptorAPI(cmd.Args[0], cmd.Args[1], cmd.Args[2], cmd.Args[3])
+			}
+		case "list-utxos":
+			result, err = s.ListUTXOsAPI()
+		case "freeze-utxo":
+			result, err = s.FreezeUTXOsAPI(cmd.Args, true)
+		case "unfreeze-utxo":
+			result, err = s.FreezeUTXOsAPI(cmd.Args, false)
+		case "label-utxo":
+			if err = requireArgs(cmd, 2); err == nil {
+				result, err = s.LabelUTXOAPI(cmd.Args[0], cmd.Args[1])
+			}
 		case "get-wallet-balance":
 			result, err = s.HandleGetWalletBalance()
 		case "estimate-transaction-size":
 			result, err = s.HandleEstimateTransactionSize(cmd.Args)
+		case "estimate-batch-transaction-size":
+			result, err = s.HandleEstimateBatchTransactionSize(cmd.Args)
 		case "get-transaction-history":
 			result, err = s.HandleGetTransactionHistory()
 		case "get-receive-addresses":
 			result, err = s.HandleGetReceiveAddresses()
+		case "create-payment-request":
+			if err = requireArgs(cmd, 1); err == nil {
+				args := append(cmd.Args, "", "")
+				result, err = s.CreatePaymentRequestAPI(args[0], args[1], args[2])
+			}
+		case "list-payment-requests":
+			status := ""
+			if len(cmd.Args) > 0 {
+				status = cmd.Args[0]
+			}
+			result, err = s.ListPaymentRequestsAPI(status)
+		case "get-payment-request":
+			if err = requireArgs(cmd, 1); err == nil {
+				result, err = s.GetPaymentRequestAPI(cmd.Args[0])
+			}
+		case "update-payment-request":
+			if err = requireArgs(cmd, 1); err == nil {
+				expiry := ""
+				if len(cmd.Args) > 1 {
+					expiry = cmd.Args[1]
+				}
+				var memo *string
+				if len(cmd.Args) > 2 {
+					memo = &cmd.Args[2]
+				}
+				result, err = s.UpdatePaymentRequestAPI(cmd.Args[0], expiry, memo)
+			}
+		case "delete-payment-request":
+			if err = requireArgs(cmd, 1); err == nil {
+				result, err = s.DeletePaymentRequestAPI(cmd.Args[0])
+			}
+		case "payment-uri":
+			args := append(cmd.Args, "", "", "", "")
+			result, err = s.PaymentURIAPI(args[0], args[1], args[2], args[3])
+		case "change-password":
+			if err = requireArgs(cmd, 3); err == nil {
+				result, err = s.ChangePasswordAPI(cmd.Args[0], cmd.Args[1], cmd.Args[2])
+			}
+		case "spending-policy":
+			result, err = s.SpendingPolicyAPI()
+		case "unlock":
+			if err = requireArgs(cmd, 1); err == nil {
+				timeout := ""
+				if len(cmd.Args) > 1 {
+					timeout = cmd.Args[1]
+				}
+				result, err = s.UnlockAPI(cmd.Args[0], timeout)
+			}
+		case "lock":
+			result, err = s.LockAPI()
 		case "exit":
 			err = s.ExitWalletCMD()
 		default:
@@ -254,6 +414,14 @@ func (s *WalletServer) HandleIPCCommands(server *ipc.Server) {
 	}
 }
 
+// requireArgs guards IPC handlers against commands sent with too few arguments.
+func requireArgs(cmd ipc.Command, n int) error {
+	if len(cmd.Args) < n {
+		return fmt.Errorf("%s expects %d arguments, got %d", cmd.Command, n, len(cmd.Args))
+	}
+	return nil
+}
+
 // outputProgressToStdout sends progress updates to stdout for CLI clients (like Electron)
 func outputProgressToStdout(update ipc.SyncProgressUpdate) {
 	// Output JSON format for easy parsing by Electron app
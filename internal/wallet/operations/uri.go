@@ -0,0 +1,32 @@
+package operations
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/addresses"
+)
+
+// PaymentURIAPI allocates a receive address and returns a BIP21 URI for it.
+// The amount is in satoshis; an empty or zero amount leaves it to the payer.
+func (s *WalletServer) PaymentURIAPI(amountStr, label, message, payJoin string) (map[string]interface{}, error) {
+	var amount int64
+	if amountStr != "" {
+		var err error
+		amount, err = strconv.ParseInt(amountStr, 10, 64)
+		if err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("invalid amount: %v", err)}, nil
+		}
+	}
+
+	uri, err := addresses.AllocatePaymentURI(s.API.Wallet, amount, label, message, payJoin)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	return map[string]interface{}{
+		"address": uri.Address.EncodeAddress(),
+		"amount":  int64(uri.Amount),
+		"uri":     uri.String(),
+	}, nil
+}
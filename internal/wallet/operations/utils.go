@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -14,6 +15,7 @@ import (
 
 	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/bip21"
 	transaction "github.com/Maphikza/btc-wallet-btcsuite.git/lib/transaction"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcwallet/wallet"
@@ -299,11 +301,45 @@ func (s *WalletServer) HandleEstimateTransactionSize(args []string) (interface{}
 		return nil, fmt.Errorf("invalid fee rate: %v", err)
 	}
 
-	size, err := EstimateTransactionSize(s.API.Wallet, spendAmount, recipientAddress, feeRate)
+	size, maxSendable, err := EstimateTransactionSize(s.API.Wallet, spendAmount, recipientAddress, feeRate)
 	if err != nil {
 		return nil, err
 	}
-	return map[string]int{"size": size}, nil
+	return map[string]int64{"size": int64(size), "maxSendable": maxSendable}, nil
+}
+
+// HandleEstimateBatchTransactionSize expects a JSON array of recipients, the
+// fee rate and an optional hex OP_RETURN payload.
+func (s *WalletServer) HandleEstimateBatchTransactionSize(args []string) (interface{}, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, fmt.Errorf("invalid number of arguments for estimate-batch-transaction-size")
+	}
+	var recipients []transaction.Recipient
+	if err := json.Unmarshal([]byte(args[0]), &recipients); err != nil {
+		return nil, fmt.Errorf("invalid recipients: %v", err)
+	}
+	feeRate, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid fee rate: %v", err)
+	}
+	var opReturn []byte
+	if len(args) == 3 {
+		opReturn, err = transaction.DecodeOpReturnHex(args[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	selection, err := transaction.EstimateBatchTransaction(s.API.Wallet, recipients, opReturn, feeRate)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int64{
+		"size":   int64(selection.VBytes),
+		"fee":    int64(selection.Fee),
+		"change": int64(selection.Change),
+		"inputs": int64(len(selection.Inputs)),
+	}, nil
 }
 
 func (s *WalletServer) HandleGetTransactionHistory() (interface{}, error) {
@@ -314,7 +350,7 @@ func (s *WalletServer) HandleGetTransactionHistory() (interface{}, error) {
 	return map[string]interface{}{"transactions": history}, nil
 }
 
-func (s *WalletServer) NewTransactionAPI(recipient string, amountStr, feeRateStr string) (map[string]interface{}, error) {
+func (s *WalletServer) NewTransactionAPI(recipient string, amountStr, feeRateStr string, inputStrs []string) (map[string]interface{}, error) {
 	amount, err := strconv.ParseInt(amountStr, 10, 64)
 	if err != nil {
 		log.Printf("invalid amount: %v", err)
@@ -327,10 +363,33 @@ func (s *WalletServer) NewTransactionAPI(recipient string, amountStr, feeRateStr
 		return map[string]interface{}{"error": fmt.Sprintf("invalid fee rate: %v", err)}, fmt.Errorf("invalid fee rate: %v", err)
 	}
 
-	txHash, verified, err := transaction.HttpCheckBalanceAndCreateTransaction(s.API.Wallet, s.API.ChainClient.CS, true, amount, recipient, s.API.PrivPass, int(feeRate))
+	inputs, err := transaction.ParseOutpoints(inputStrs)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	// The recipient may be a BIP21 URI carrying the amount
+	recipient, amount, err = bip21.Resolve(recipient, amount, s.API.Wallet.ChainParams())
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid payment URI: %v", err)}, nil
+	}
+	if amount <= 0 {
+		return map[string]interface{}{"error": "an amount is required unless the payment URI requests one"}, nil
+	}
+
+	// Watch-only wallets cannot sign, so hand back the spend as a PSBT
+	if transaction.IsWatchOnly(s.API.Wallet) {
+		packet, err := transaction.CreatePSBT(s.API.Wallet, true, amount, recipient, int(feeRate), inputs)
+		if err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("PSBT creation failed: %v", err)}, nil
+		}
+		return s.watchOnlySpendResult(packet, nil)
+	}
+
+	txHash, verified, err := transaction.HttpCheckBalanceAndCreateTransaction(s.API.Wallet, s.API.ChainClient.CS, true, amount, recipient, s.API.PrivPass, int(feeRate), inputs)
 	if err != nil {
 		log.Printf("transaction failed: %v", err)
-		return map[string]interface{}{"error": fmt.Sprintf("transaction failed: %v", err)}, fmt.Errorf("transaction failed: %v", err)
+		return spendFailure("transaction failed", err), fmt.Errorf("transaction failed: %v", err)
 	}
 
 	return map[string]interface{}{
@@ -357,10 +416,19 @@ func (s *WalletServer) RBFTransactionAPI(originalTxID, newFeeRateStr string) (ma
 	}
 	defer client.Shutdown()
 
+	// Watch-only and multisig wallets hand the replacement back as a PSBT
+	if transaction.IsWatchOnly(s.API.Wallet) {
+		packet, err := transaction.BumpFeePSBT(s.API.Wallet, originalTxID, newFeeRate, client)
+		if err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("RBF PSBT creation failed: %v", err)}, nil
+		}
+		return s.watchOnlySpendResult(packet, map[string]interface{}{"replaces": originalTxID})
+	}
+
 	newTxID, verified, err := transaction.ReplaceTransactionWithHigherFee(s.API.Wallet, s.API.ChainClient.CS, originalTxID, newFeeRate, client, s.API.PrivPass)
 	if err != nil {
 		log.Printf("RBF transaction failed: %v", err)
-		return map[string]interface{}{"error": fmt.Sprintf("RBF transaction failed: %v", err)}, fmt.Errorf("RBF transaction failed: %v", err)
+		return spendFailure("RBF transaction failed", err), fmt.Errorf("RBF transaction failed: %v", err)
 	}
 
 	return map[string]interface{}{
@@ -369,7 +437,178 @@ func (s *WalletServer) RBFTransactionAPI(originalTxID, newFeeRateStr string) (ma
 	}, nil
 }
 
-func EstimateTransactionSize(w *wallet.Wallet, spendAmount int64, recipientAddress string, feeRate int) (int, error) {
+// CancelTransactionAPI double-spends an unconfirmed transaction back to the
+// wallet. The fee rate is optional; the BIP125 minimum is used without one.
+func (s *WalletServer) CancelTransactionAPI(originalTxID, feeRateStr string) (map[string]interface{}, error) {
+	var feeRate int64
+	if feeRateStr != "" {
+		var err error
+		feeRate, err = strconv.ParseInt(feeRateStr, 10, 64)
+		if err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("invalid fee rate: %v", err)}, nil
+		}
+	}
+
+	client, err := transaction.CreateElectrumClient(transaction.ElectrumConfig{
+		ServerAddr: "electrum.blockstream.info:50002",
+		UseSSL:     true,
+	})
+	if err != nil {
+		log.Printf("failed to create Electrum client: %v", err)
+		return map[string]interface{}{"error": fmt.Sprintf("failed to create Electrum client: %v", err)}, nil
+	}
+	defer client.Shutdown()
+
+	newTxID, verified, err := transaction.CancelTransaction(s.API.Wallet, s.API.ChainClient.CS, originalTxID, feeRate, client, s.API.PrivPass)
+	if err != nil {
+		log.Printf("cancel transaction failed: %v", err)
+		return spendFailure("cancel transaction failed", err), nil
+	}
+
+	return map[string]interface{}{
+		"cancelledTxID": originalTxID,
+		"newTxID":       newTxID.String(),
+		"verified":      verified,
+	}, nil
+}
+
+// CPFPTransactionAPI bumps an unconfirmed transaction by spending one of our
+// outputs from it in a child paying for the whole package.
+func (s *WalletServer) CPFPTransactionAPI(parentTxID, targetFeeRateStr string) (map[string]interface{}, error) {
+	targetFeeRate, err := strconv.ParseInt(targetFeeRateStr, 10, 64)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid fee rate: %v", err)}, nil
+	}
+
+	client, err := transaction.CreateElectrumClient(transaction.ElectrumConfig{
+		ServerAddr: "electrum.blockstream.info:50002",
+		UseSSL:     true,
+	})
+	if err != nil {
+		log.Printf("failed to create Electrum client: %v", err)
+		return map[string]interface{}{"error": fmt.Sprintf("failed to create Electrum client: %v", err)}, nil
+	}
+	defer client.Shutdown()
+
+	childTxID, verified, err := transaction.CreateCPFPTransaction(s.API.Wallet, s.API.ChainClient.CS, parentTxID, targetFeeRate, client, s.API.PrivPass)
+	if err != nil {
+		log.Printf("CPFP transaction failed: %v", err)
+		return spendFailure("CPFP transaction failed", err), nil
+	}
+
+	return map[string]interface{}{
+		"parentTxID": parentTxID,
+		"childTxID":  childTxID.String(),
+		"verified":   verified,
+	}, nil
+}
+
+// SweepTransactionAPI sends every confirmed UTXO, or only the given
+// txid:vout outpoints, to recipient without change.
+func (s *WalletServer) SweepTransactionAPI(recipient, feeRateStr string, outpointStrs []string) (map[string]interface{}, error) {
+	feeRate, err := strconv.Atoi(feeRateStr)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid fee rate: %v", err)}, nil
+	}
+
+	outpoints, err := transaction.ParseOutpoints(outpointStrs)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	recipient, amount, err := bip21.Resolve(recipient, 0, s.API.Wallet.ChainParams())
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid payment URI: %v", err)}, nil
+	}
+	if amount != 0 {
+		return map[string]interface{}{"error": "a sweep sends everything and cannot pay a URI that requests an amount"}, nil
+	}
+
+	txHash, verified, err := transaction.SweepTransaction(s.API.Wallet, s.API.ChainClient.CS, true, recipient, outpoints, s.API.PrivPass, feeRate)
+	if err != nil {
+		return spendFailure("sweep transaction failed", err), nil
+	}
+
+	return map[string]interface{}{
+		"txHash":   txHash.String(),
+		"verified": verified,
+	}, nil
+}
+
+// MaxSendableAPI reports the largest changeless amount that can be sent to
+// recipient at the fee rate, optionally from the given outpoints only.
+func (s *WalletServer) MaxSendableAPI(recipient, feeRateStr string, outpointStrs []string) (map[string]interface{}, error) {
+	feeRate, err := strconv.Atoi(feeRateStr)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid fee rate: %v", err)}, nil
+	}
+
+	outpoints, err := transaction.ParseOutpoints(outpointStrs)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	amount, size, err := transaction.CalculateMaxSendable(s.API.Wallet, recipient, outpoints, feeRate)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	return map[string]interface{}{
+		"maxSendable": int64(amount),
+		"size":        size,
+		"fee":         int64(size) * int64(feeRate),
+	}, nil
+}
+
+// BatchTransactionAPI pays a JSON array of recipients in one transaction. The
+// OP_RETURN payload is optional and hex encoded. Any inputs given replace
+// automatic coin selection.
+func (s *WalletServer) BatchTransactionAPI(recipientsJSON, feeRateStr, opReturnHex string, inputStrs []string) (map[string]interface{}, error) {
+	var recipients []transaction.Recipient
+	if err := json.Unmarshal([]byte(recipientsJSON), &recipients); err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid recipients: %v", err)}, nil
+	}
+
+	feeRate, err := strconv.ParseInt(feeRateStr, 10, 64)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid fee rate: %v", err)}, nil
+	}
+
+	opReturn, err := transaction.DecodeOpReturnHex(opReturnHex)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	inputs, err := transaction.ParseOutpoints(inputStrs)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	if transaction.IsWatchOnly(s.API.Wallet) {
+		packet, err := transaction.CreateBatchPSBT(s.API.Wallet, true, recipients, opReturn, int(feeRate), inputs)
+		if err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("PSBT creation failed: %v", err)}, nil
+		}
+		return s.watchOnlySpendResult(packet, map[string]interface{}{"recipients": len(recipients)})
+	}
+
+	txHash, verified, err := transaction.CreateBatchTransaction(s.API.Wallet, s.API.ChainClient.CS, true, recipients, opReturn, s.API.PrivPass, int(feeRate), inputs)
+	if err != nil {
+		return spendFailure("batch transaction failed", err), nil
+	}
+
+	return map[string]interface{}{
+		"txHash":     txHash.String(),
+		"verified":   verified,
+		"recipients": len(recipients),
+	}, nil
+}
+
+func EstimateBatchTransactionSize(w *wallet.Wallet, recipients []transaction.Recipient, opReturn []byte, feeRate int) (int, error) {
+	return transaction.HttpCalculateBatchTransactionSize(w, recipients, opReturn, feeRate)
+}
+
+func EstimateTransactionSize(w *wallet.Wallet, spendAmount int64, recipientAddress string, feeRate int) (int, int64, error) {
 	return transaction.HttpCalculateTransactionSize(w, spendAmount, recipientAddress, feeRate)
 }
 
@@ -0,0 +1,243 @@
+package payments
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/addresses"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/bip21"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/spf13/viper"
+)
+
+// DefaultExpiry is how long a payment request stays open when no expiry is
+// given.
+const DefaultExpiry = time.Hour
+
+// CreateRequest allocates a receive address and records a request for amount
+// satoshis to be paid to it within expiry. A zero amount accepts any payment.
+func CreateRequest(w *wallet.Wallet, amount int64, memo string, expiry time.Duration) (*walletstatedb.PaymentRequest, error) {
+	if expiry <= 0 {
+		return nil, fmt.Errorf("expiry must be positive")
+	}
+
+	id, err := newRequestID()
+	if err != nil {
+		return nil, err
+	}
+
+	uri, err := addresses.AllocatePaymentURI(w, amount, "", memo, "")
+	if err != nil {
+		return nil, err
+	}
+
+	request := &walletstatedb.PaymentRequest{
+		ID:        id,
+		Address:   uri.Address.EncodeAddress(),
+		Amount:    amount,
+		Memo:      memo,
+		ExpiresAt: time.Now().Add(expiry).UTC(),
+		Status:    walletstatedb.PaymentStatusUnpaid,
+	}
+	if err := walletstatedb.SavePaymentRequest(request); err != nil {
+		return nil, fmt.Errorf("error saving payment request: %v", err)
+	}
+
+	log.Printf("Created payment request %s for %d sats to %s", id, amount, request.Address)
+	return request, nil
+}
+
+// UpdateRequest changes the memo when one is given and, when expiry is
+// positive, moves the expiry to that long from now. Settled requests cannot be
+// changed.
+func UpdateRequest(id string, memo *string, expiry time.Duration) (*walletstatedb.PaymentRequest, error) {
+	request, err := walletstatedb.GetPaymentRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if settled(request.Status) {
+		return nil, fmt.Errorf("payment request %s is already %s", id, request.Status)
+	}
+
+	updates := make(map[string]interface{})
+	if memo != nil {
+		updates["memo"] = *memo
+	}
+	if expiry > 0 {
+		updates["expires_at"] = time.Now().Add(expiry).UTC()
+		// Reopen an expired request; the next rescan settles its status
+		if request.Status == walletstatedb.PaymentStatusExpired {
+			updates["status"] = status(request.Amount, request.Received, false)
+		}
+	}
+	if len(updates) == 0 {
+		return request, nil
+	}
+	if err := walletstatedb.UpdatePaymentRequest(id, updates); err != nil {
+		return nil, err
+	}
+
+	return walletstatedb.GetPaymentRequest(id)
+}
+
+// URI returns the BIP21 URI the payer of request uses.
+func URI(request *walletstatedb.PaymentRequest, w *wallet.Wallet) (string, error) {
+	addr, err := btcutil.DecodeAddress(request.Address, w.ChainParams())
+	if err != nil {
+		return "", err
+	}
+	uri := &bip21.URI{Address: addr, Amount: btcutil.Amount(request.Amount), Message: request.Memo}
+	return uri.String(), nil
+}
+
+// UpdateStatuses matches the outputs the wallet has received against payment
+// requests and records every status change. Payments count once they have
+// payment_min_confirmations confirmations; a request with only newer ones is
+// pending. Settled requests are checked again, so one whose payment was
+// replaced or reorganised away reopens. It returns the number of requests
+// that changed.
+func UpdateStatuses(w *wallet.Wallet) (int, error) {
+	requests, err := walletstatedb.GetPaymentRequests("")
+	if err != nil {
+		return 0, err
+	}
+
+	byAddress := make(map[string][]walletstatedb.PaymentRequest)
+	for _, request := range requests {
+		byAddress[request.Address] = append(byAddress[request.Address], request)
+	}
+	if len(byAddress) == 0 {
+		return 0, nil
+	}
+
+	transactions, err := w.ListAllTransactions()
+	if err != nil {
+		return 0, fmt.Errorf("error listing transactions: %v", err)
+	}
+
+	minConf := int64(viper.GetInt("payment_min_confirmations"))
+	if minConf < 1 {
+		minConf = 1
+	}
+
+	type payment struct {
+		amount      btcutil.Amount // confirmed at least minConf times
+		unconfirmed btcutil.Amount
+		txids       map[string]bool
+		last        time.Time
+	}
+	received := make(map[string]*payment)
+	for _, tx := range transactions {
+		if tx.Category != "receive" || len(byAddress[tx.Address]) == 0 {
+			continue
+		}
+		amount, err := btcutil.NewAmount(tx.Amount)
+		if err != nil {
+			continue
+		}
+		p, ok := received[tx.Address]
+		if !ok {
+			p = &payment{txids: make(map[string]bool)}
+			received[tx.Address] = p
+		}
+		p.txids[tx.TxID] = true
+		if tx.Confirmations < minConf {
+			p.unconfirmed += amount
+			continue
+		}
+		p.amount += amount
+		if t := time.Unix(tx.Time, 0); t.After(p.last) {
+			p.last = t
+		}
+	}
+
+	now := time.Now()
+	var changed int
+	for address, addressRequests := range byAddress {
+		for _, request := range addressRequests {
+			var total int64
+			var pending bool
+			var txids []string
+			var paidAt *time.Time
+			if p, ok := received[address]; ok {
+				total = int64(p.amount)
+				pending = p.unconfirmed > 0
+				for txid := range p.txids {
+					txids = append(txids, txid)
+				}
+				sort.Strings(txids)
+				if !p.last.IsZero() {
+					last := p.last.UTC()
+					paidAt = &last
+				}
+			}
+
+			newStatus := status(request.Amount, total, now.After(request.ExpiresAt))
+			if pending && !settled(newStatus) {
+				newStatus = walletstatedb.PaymentStatusPending
+			}
+			joined := strings.Join(txids, ",")
+			if newStatus == request.Status && total == request.Received && joined == strings.Join(request.TxIDs, ",") {
+				continue
+			}
+
+			updates := map[string]interface{}{
+				"status":   newStatus,
+				"received": total,
+				"tx_ids":   joined,
+				"paid_at":  nil,
+			}
+			if settled(newStatus) {
+				updates["paid_at"] = paidAt
+			}
+			if err := walletstatedb.UpdatePaymentRequest(request.ID, updates); err != nil {
+				log.Printf("Error updating payment request %s: %v", request.ID, err)
+				continue
+			}
+			if settled(request.Status) && !settled(newStatus) {
+				log.Printf("Payment request %s is no longer paid: its payment is no longer confirmed", request.ID)
+			}
+			log.Printf("Payment request %s is %s (%d of %d sats)", request.ID, newStatus, total, request.Amount)
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// status classifies a request that asked for amount and has received
+// received. A request that was paid in full stays paid even if the payment
+// arrived after expiry; the relay decides whether to honour late payments.
+func status(amount, received int64, expired bool) string {
+	switch {
+	case received > 0 && received >= amount:
+		if amount > 0 && received > amount {
+			return walletstatedb.PaymentStatusOverpaid
+		}
+		return walletstatedb.PaymentStatusPaid
+	case expired:
+		return walletstatedb.PaymentStatusExpired
+	case received > 0:
+		return walletstatedb.PaymentStatusUnderpaid
+	}
+	return walletstatedb.PaymentStatusUnpaid
+}
+
+// settled reports whether a request with status has been paid in full.
+func settled(status string) bool {
+	return status == walletstatedb.PaymentStatusPaid || status == walletstatedb.PaymentStatusOverpaid
+}
+
+func newRequestID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", fmt.Errorf("error generating request id: %v", err)
+	}
+	return hex.EncodeToString(id[:]), nil
+}
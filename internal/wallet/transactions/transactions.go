@@ -312,11 +312,11 @@ func (s *WalletServer) HandleEstimateTransactionSize(args []string) (interface{}
 		return nil, fmt.Errorf("invalid fee rate: %v", err)
 	}
 
-	size, err := EstimateTransactionSize(s.API.Wallet, spendAmount, recipientAddress, feeRate)
+	size, maxSendable, err := EstimateTransactionSize(s.API.Wallet, spendAmount, recipientAddress, feeRate)
 	if err != nil {
 		return nil, err
 	}
-	return map[string]int{"size": size}, nil
+	return map[string]int64{"size": int64(size), "maxSendable": maxSendable}, nil
 }
 
 func (s *WalletServer) HandleGetTransactionHistory() (interface{}, error) {
@@ -338,7 +338,7 @@ func (s *WalletServer) NewTransactionAPI(recipient string, amountStr, feeRateStr
 		return map[string]interface{}{"error": fmt.Sprintf("invalid fee rate: %v", err)}, nil
 	}
 
-	txHash, verified, err := transaction.HttpCheckBalanceAndCreateTransaction(s.API.Wallet, s.API.ChainClient.CS, true, amount, recipient, s.API.PrivPass, int(feeRate))
+	txHash, verified, err := transaction.HttpCheckBalanceAndCreateTransaction(s.API.Wallet, s.API.ChainClient.CS, true, amount, recipient, s.API.PrivPass, int(feeRate), nil)
 	if err != nil {
 		return map[string]interface{}{"error": fmt.Sprintf("transaction failed: %v", err)}, nil
 	}
@@ -375,7 +375,7 @@ func (s *WalletServer) RBFTransactionAPI(originalTxID, newFeeRateStr string) (ma
 	}, nil
 }
 
-func EstimateTransactionSize(w *wallet.Wallet, spendAmount int64, recipientAddress string, feeRate int) (int, error) {
+func EstimateTransactionSize(w *wallet.Wallet, spendAmount int64, recipientAddress string, feeRate int) (int, int64, error) {
 	return transaction.HttpCalculateTransactionSize(w, spendAmount, recipientAddress, feeRate)
 }
 
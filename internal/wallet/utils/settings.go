@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/spf13/viper"
+)
+
+// Address types accepted by the address_type config key. p2wsh is only set
+// for multisig wallets, whose addresses come from their descriptor.
+const (
+	AddressTypeP2WPKH = "p2wpkh"
+	AddressTypeP2TR   = "p2tr"
+	AddressTypeP2WSH  = "p2wsh"
+)
+
+// activeWallet names the wallet whose wallet_settings apply.
+var activeWallet string
+
+// SetActiveWallet selects the wallet whose wallet_settings.<name> entries
+// override the global settings.
+func SetActiveWallet(walletName string) {
+	activeWallet = walletName
+}
+
+// ActiveWallet returns the wallet selected with SetActiveWallet.
+func ActiveWallet() string {
+	return activeWallet
+}
+
+// WalletSettingKey returns the viper key of key under walletName's
+// wallet_settings.
+func WalletSettingKey(walletName, key string) string {
+	return fmt.Sprintf("wallet_settings.%s.%s", walletName, key)
+}
+
+// WalletConfigKey returns the viper key to read for key: the active wallet's
+// wallet_settings.<name>.<key> when it is set, and the global key otherwise.
+func WalletConfigKey(key string) string {
+	if activeWallet != "" {
+		walletKey := WalletSettingKey(activeWallet, key)
+		if viper.IsSet(walletKey) {
+			return walletKey
+		}
+	}
+	return key
+}
+
+// AddressType returns the configured address type for the active wallet.
+func AddressType() string {
+	addrType := viper.GetString(WalletConfigKey("address_type"))
+	switch addrType {
+	case AddressTypeP2WPKH, AddressTypeP2TR, AddressTypeP2WSH:
+		return addrType
+	default:
+		log.Printf("Unknown address_type %q, using %s", addrType, AddressTypeP2WPKH)
+		return AddressTypeP2WPKH
+	}
+}
+
+// AddressKeyScope returns the key scope new receive and change addresses are
+// derived from: BIP86 for taproot, BIP84 otherwise.
+func AddressKeyScope() waddrmgr.KeyScope {
+	if AddressType() == AddressTypeP2TR {
+		return waddrmgr.KeyScopeBIP0086
+	}
+	return waddrmgr.KeyScopeBIP0084
+}
+
+// AddressScriptType names the output script an address pays to.
+func AddressScriptType(addr btcutil.Address) string {
+	switch addr.(type) {
+	case *btcutil.AddressTaproot:
+		return AddressTypeP2TR
+	case *btcutil.AddressWitnessPubKeyHash:
+		return AddressTypeP2WPKH
+	case *btcutil.AddressWitnessScriptHash:
+		return AddressTypeP2WSH
+	case *btcutil.AddressScriptHash:
+		return "p2sh"
+	case *btcutil.AddressPubKeyHash:
+		return "p2pkh"
+	default:
+		return "unknown"
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
@@ -18,6 +19,7 @@ import (
 	"time"
 
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/logger"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/keystore"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/ripemd160"
@@ -203,6 +205,31 @@ func IsNewlyImportedWallet() bool {
 	return viper.GetBool("is_newly_imported")
 }
 
+// SetHistoryScopes records the key scopes in which an import rescan of
+// walletName found address history.
+func SetHistoryScopes(walletName string, scopes []string) error {
+	err := viper.ReadInConfig()
+	if err != nil {
+		log.Printf("Error reading viper config: %s", err.Error())
+	}
+
+	viper.Set(WalletSettingKey(walletName, "history_scopes"), scopes)
+
+	err = viper.WriteConfig()
+	if err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	return nil
+}
+
+// HistoryScopes returns the key scopes recorded by SetHistoryScopes for
+// walletName.
+func HistoryScopes(walletName string) []string {
+	return viper.GetStringSlice(WalletSettingKey(walletName, "history_scopes"))
+}
+
+// Decrypt reads a salt:iv:ciphertext string from a .env wallet file. Wallets
+// are now kept in keystores, so it is only needed to migrate old files.
 func Decrypt(ciphertext string, password string) (string, error) {
 	parts := strings.Split(ciphertext, ":")
 	if len(parts) != 3 {
@@ -334,21 +361,28 @@ func TrustCertificateWindows(certFile string) error {
 func DeleteWalletFiles(walletName string) error {
 	// Get the base directories from the configuration
 	baseDir := viper.GetString("base_dir")                 // Base directory for general wallet-related files
-	walletDir := viper.GetString("wallet_dir")             // Directory containing .env files
+	walletDir := viper.GetString("wallet_dir")             // Directory containing wallet keystores
 	neutrinoDbDir := filepath.Join(baseDir, "neutrino_db") // Neutrino database directory
 	jwtKeysDir := filepath.Join(baseDir, "jwtkeys")        // JWT keys directory
 
 	// Paths for wallet-specific files and directories
-	envFile := filepath.Join(walletDir, walletName+".env")                                  // .env file
 	neutrinoWalletDir := filepath.Join(neutrinoDbDir, fmt.Sprintf("%s_wallet", walletName)) // Neutrino wallet directory
 	sqliteDbFile := filepath.Join(baseDir, fmt.Sprintf("%s_wallet.db", walletName))         // Graviton DB file
 	jwtKeyDir := filepath.Join(jwtKeysDir, walletName)                                      // JWT key directory
 
-	// Delete the wallet .env file
-	if err := os.Remove(envFile); err != nil {
-		log.Printf("Failed to delete .env file: %v", err) // Continue even if env file removal fails
-	} else {
-		log.Printf("Successfully deleted .env file: %s", envFile)
+	// Delete the keystore, along with any .env file and its migration backup
+	for _, walletFile := range []string{
+		filepath.Join(walletDir, walletName+keystore.Extension),
+		filepath.Join(walletDir, walletName+".env"),
+		filepath.Join(walletDir, walletName+".env.bak"),
+	} {
+		if err := os.Remove(walletFile); err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("Failed to delete wallet file: %v", err) // Continue even if wallet file removal fails
+			}
+		} else {
+			log.Printf("Successfully deleted wallet file: %s", walletFile)
+		}
 	}
 
 	//  // Clean up contents within Neutrino DB directory
@@ -405,20 +439,6 @@ func CleanupExistingData(neutrinoDBPath, walletDBPath string) error {
 	return nil
 }
 
-func Encrypt(plaintext string, password string) string {
-	key, salt := deriveKey(password, nil)
-	iv := make([]byte, 12)
-	rand.Read(iv)
-
-	block, _ := aes.NewCipher(key)
-	aesgcm, _ := cipher.NewGCM(block)
-	ciphertext := aesgcm.Seal(nil, iv, []byte(plaintext), nil)
-
-	return base64.StdEncoding.EncodeToString(salt) + ":" +
-		base64.StdEncoding.EncodeToString(iv) + ":" +
-		base64.StdEncoding.EncodeToString(ciphertext)
-}
-
 // FormatBlockHeight formats a block height with commas for better readability
 // For example: 700000 becomes "700,000"
 func FormatBlockHeight(height int32) string {
@@ -437,3 +457,13 @@ func FormatBlockHeight(height int32) string {
 
 	return strings.Join(parts, ",")
 }
+
+// GenerateRandomPassphrase returns length random bytes, hex encoded, for use
+// as a wallet passphrase.
+func GenerateRandomPassphrase(length int) (string, error) {
+	bytes := make([]byte, length)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,192 @@
+// Package bip21 builds and parses bitcoin: payment URIs.
+package bip21
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+const scheme = "bitcoin:"
+
+// amountPattern is a plain decimal BTC amount with at most eight decimals.
+var amountPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]{0,8})?$`)
+
+// URI is a BIP21 payment request. Amount is zero when the payer chooses it.
+// PayJoin is the BIP78 endpoint given by pj=.
+type URI struct {
+	Address btcutil.Address
+	Amount  btcutil.Amount
+	Label   string
+	Message string
+	PayJoin string
+
+	// Extra holds optional parameters this wallet does not use
+	Extra map[string]string
+}
+
+// IsURI reports whether s is a bitcoin: URI rather than a bare address.
+func IsURI(s string) bool {
+	return len(s) >= len(scheme) && strings.EqualFold(s[:len(scheme)], scheme)
+}
+
+// Parse decodes a bitcoin: URI and checks that its address is for params and
+// its amount is valid. Unknown req- parameters are refused as BIP21 requires.
+func Parse(s string, params *chaincfg.Params) (*URI, error) {
+	s = strings.TrimSpace(s)
+	if !IsURI(s) {
+		return nil, fmt.Errorf("not a bitcoin: URI")
+	}
+	rest := s[len(scheme):]
+
+	addrPart, query, _ := strings.Cut(rest, "?")
+	if addrPart == "" {
+		return nil, fmt.Errorf("URI has no address")
+	}
+	addr, err := btcutil.DecodeAddress(addrPart, params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address in URI: %v", err)
+	}
+	if !addr.IsForNet(params) {
+		return nil, fmt.Errorf("address %s is not for %s", addrPart, params.Name)
+	}
+
+	u := &URI{Address: addr}
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(query, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		key = strings.ToLower(key)
+		value, err := url.PathUnescape(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter: %v", key, err)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("parameter %s is given more than once", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "amount":
+			if u.Amount, err = ParseAmount(value); err != nil {
+				return nil, err
+			}
+		case "label":
+			u.Label = value
+		case "message":
+			u.Message = value
+		case "pj":
+			if err := validatePayJoin(value); err != nil {
+				return nil, err
+			}
+			u.PayJoin = value
+		default:
+			if strings.HasPrefix(key, "req-") {
+				return nil, fmt.Errorf("unsupported required parameter %s", key)
+			}
+			if u.Extra == nil {
+				u.Extra = make(map[string]string)
+			}
+			u.Extra[key] = value
+		}
+	}
+	return u, nil
+}
+
+// String encodes u with the amount in BTC and percent-encoded text.
+func (u *URI) String() string {
+	var params []string
+	if u.Amount > 0 {
+		params = append(params, "amount="+FormatAmount(u.Amount))
+	}
+	if u.Label != "" {
+		params = append(params, "label="+escape(u.Label))
+	}
+	if u.Message != "" {
+		params = append(params, "message="+escape(u.Message))
+	}
+	if u.PayJoin != "" {
+		params = append(params, "pj="+escape(u.PayJoin))
+	}
+
+	s := scheme + u.Address.EncodeAddress()
+	if len(params) > 0 {
+		s += "?" + strings.Join(params, "&")
+	}
+	return s
+}
+
+// ParseAmount reads a decimal BTC amount without rounding it through a float.
+func ParseAmount(s string) (btcutil.Amount, error) {
+	if !amountPattern.MatchString(s) {
+		return 0, fmt.Errorf("invalid amount %q: expected BTC with at most 8 decimals", s)
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	frac += strings.Repeat("0", 8-len(frac))
+
+	sats, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if sats.Sign() == 0 {
+		return 0, fmt.Errorf("amount must be greater than zero")
+	}
+	if sats.Cmp(big.NewInt(btcutil.MaxSatoshi)) > 0 {
+		return 0, fmt.Errorf("amount %s exceeds the 21 million BTC supply", s)
+	}
+	return btcutil.Amount(sats.Int64()), nil
+}
+
+// FormatAmount writes amount in BTC without trailing zeros.
+func FormatAmount(amount btcutil.Amount) string {
+	s := fmt.Sprintf("%d.%08d", amount/btcutil.SatoshiPerBitcoin, amount%btcutil.SatoshiPerBitcoin)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// Resolve turns a send recipient that may be a URI into an address and an
+// amount in satoshis. A URI amount fills in a zero amount and must match a
+// non-zero one. Bare addresses are returned unchanged.
+func Resolve(recipient string, amount int64, params *chaincfg.Params) (string, int64, error) {
+	if !IsURI(recipient) {
+		return recipient, amount, nil
+	}
+	u, err := Parse(recipient, params)
+	if err != nil {
+		return "", 0, err
+	}
+	if u.Amount > 0 {
+		if amount != 0 && amount != int64(u.Amount) {
+			return "", 0, fmt.Errorf("amount %d does not match the %d satoshis requested by the URI", amount, int64(u.Amount))
+		}
+		amount = int64(u.Amount)
+	}
+	return u.Address.EncodeAddress(), amount, nil
+}
+
+// validatePayJoin checks that a pj= endpoint is HTTPS, or plain HTTP to an
+// onion service, as BIP78 requires.
+func validatePayJoin(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid pj endpoint %q", endpoint)
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && strings.HasSuffix(u.Hostname(), ".onion"):
+	default:
+		return fmt.Errorf("pj endpoint must use https or an onion service")
+	}
+	return nil
+}
+
+// escape percent-encodes a parameter value; spaces become %20 rather than +.
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
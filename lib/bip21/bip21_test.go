@@ -0,0 +1,247 @@
+package bip21
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+const (
+	mainnetAddress = "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"
+	testnetAddress = "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    btcutil.Amount
+		wantErr bool
+	}{
+		{in: "1", want: 100000000},
+		{in: "0.00000001", want: 1},
+		{in: "20.3", want: 2030000000},
+		{in: "1.", want: 100000000},
+		{in: "21000000", want: btcutil.MaxSatoshi},
+		{in: "0.000000001", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "1e-3", wantErr: true},
+		{in: "1E2", wantErr: true},
+		{in: ".5", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "0.00000000", wantErr: true},
+		{in: "21000000.00000001", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+		{in: "1,5", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseAmount(%q) = %d, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAmount(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAmount(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		params  *chaincfg.Params
+		want    URI
+		wantErr bool
+	}{
+		{
+			name:   "bare address",
+			uri:    "bitcoin:" + mainnetAddress,
+			params: &chaincfg.MainNetParams,
+		},
+		{
+			name:   "amount label and message",
+			uri:    "bitcoin:" + mainnetAddress + "?amount=0.5&label=Luke%20Jr&message=Donation%20for%20project%20xyz",
+			params: &chaincfg.MainNetParams,
+			want:   URI{Amount: 50000000, Label: "Luke Jr", Message: "Donation for project xyz"},
+		},
+		{
+			name:   "percent-encoded reserved characters",
+			uri:    "bitcoin:" + mainnetAddress + "?label=a%26b%3Dc%3F&message=100%25",
+			params: &chaincfg.MainNetParams,
+			want:   URI{Label: "a&b=c?", Message: "100%"},
+		},
+		{
+			name:   "case-insensitive scheme and keys",
+			uri:    "BITCOIN:" + mainnetAddress + "?AMOUNT=1",
+			params: &chaincfg.MainNetParams,
+			want:   URI{Amount: 100000000},
+		},
+		{
+			name:   "testnet address on testnet",
+			uri:    "bitcoin:" + testnetAddress + "?amount=0.001",
+			params: &chaincfg.TestNet3Params,
+			want:   URI{Amount: 100000},
+		},
+		{
+			name:   "unknown optional parameter kept",
+			uri:    "bitcoin:" + mainnetAddress + "?somethingyoudontunderstand=50",
+			params: &chaincfg.MainNetParams,
+			want:   URI{Extra: map[string]string{"somethingyoudontunderstand": "50"}},
+		},
+		{
+			name:   "payjoin endpoint",
+			uri:    "bitcoin:" + mainnetAddress + "?pj=https://example.com/pj",
+			params: &chaincfg.MainNetParams,
+			want:   URI{PayJoin: "https://example.com/pj"},
+		},
+		{
+			name:    "unknown required parameter",
+			uri:     "bitcoin:" + mainnetAddress + "?req-somethingyoudontunderstand=50",
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+		{
+			name:    "unknown required parameter in upper case",
+			uri:     "bitcoin:" + mainnetAddress + "?REQ-foo=1",
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+		{
+			name:    "mainnet address on testnet",
+			uri:     "bitcoin:" + mainnetAddress,
+			params:  &chaincfg.TestNet3Params,
+			wantErr: true,
+		},
+		{
+			name:    "too many decimals",
+			uri:     "bitcoin:" + mainnetAddress + "?amount=0.123456789",
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+		{
+			name:    "exponent amount",
+			uri:     "bitcoin:" + mainnetAddress + "?amount=1e3",
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate amount",
+			uri:     "bitcoin:" + mainnetAddress + "?amount=1&amount=2",
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+		{
+			name:    "bad percent escape",
+			uri:     "bitcoin:" + mainnetAddress + "?label=%zz",
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+		{
+			name:    "plain http payjoin",
+			uri:     "bitcoin:" + mainnetAddress + "?pj=http://example.com/pj",
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+		{
+			name:    "missing address",
+			uri:     "bitcoin:?amount=1",
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+		{
+			name:    "wrong scheme",
+			uri:     "litecoin:" + mainnetAddress,
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.uri, tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) succeeded, want error", tt.uri)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.uri, err)
+			}
+			if got.Amount != tt.want.Amount || got.Label != tt.want.Label || got.Message != tt.want.Message || got.PayJoin != tt.want.PayJoin {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.uri, *got, tt.want)
+			}
+			if len(got.Extra) != len(tt.want.Extra) {
+				t.Errorf("Parse(%q) extra = %v, want %v", tt.uri, got.Extra, tt.want.Extra)
+			}
+			for k, v := range tt.want.Extra {
+				if got.Extra[k] != v {
+					t.Errorf("Parse(%q) extra[%s] = %q, want %q", tt.uri, k, got.Extra[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	addr, err := btcutil.DecodeAddress(mainnetAddress, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("decode address: %v", err)
+	}
+
+	tests := []*URI{
+		{Address: addr},
+		{Address: addr, Amount: 1},
+		{Address: addr, Amount: btcutil.MaxSatoshi},
+		{Address: addr, Amount: 123456789, Label: "Luke Jr", Message: "Café & 100% = ok?"},
+		{Address: addr, Label: "a+b", PayJoin: "https://example.com/pj?v=1"},
+	}
+	for _, want := range tests {
+		encoded := want.String()
+		if strings.Contains(encoded, " ") || strings.Contains(encoded, "+") {
+			t.Errorf("String() = %q, want spaces and plus signs percent-encoded", encoded)
+		}
+		got, err := Parse(encoded, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", encoded, err)
+		}
+		if got.Address.EncodeAddress() != want.Address.EncodeAddress() || got.Amount != want.Amount || got.Label != want.Label || got.Message != want.Message || got.PayJoin != want.PayJoin {
+			t.Errorf("round trip of %q = %+v, want %+v", encoded, *got, *want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	uri := "bitcoin:" + testnetAddress + "?amount=0.001"
+
+	address, amount, err := Resolve(uri, 0, &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if address != testnetAddress || amount != 100000 {
+		t.Errorf("Resolve = %s, %d, want %s, 100000", address, amount, testnetAddress)
+	}
+
+	if _, _, err := Resolve(uri, 100000, &chaincfg.TestNet3Params); err != nil {
+		t.Errorf("Resolve with a matching amount: %v", err)
+	}
+	if _, _, err := Resolve(uri, 5000, &chaincfg.TestNet3Params); err == nil {
+		t.Error("Resolve with a conflicting amount succeeded, want error")
+	}
+	if _, _, err := Resolve(uri, 0, &chaincfg.MainNetParams); err == nil {
+		t.Error("Resolve of a testnet URI on mainnet succeeded, want error")
+	}
+
+	address, amount, err = Resolve(mainnetAddress, 42, &chaincfg.MainNetParams)
+	if err != nil || address != mainnetAddress || amount != 42 {
+		t.Errorf("Resolve of a bare address = %s, %d, %v", address, amount, err)
+	}
+}
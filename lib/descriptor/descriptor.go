@@ -0,0 +1,412 @@
+package descriptor
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet"
+)
+
+// Script types a single-key descriptor can wrap.
+const (
+	TypePKH    = "pkh"
+	TypeSHWPKH = "sh(wpkh)"
+	TypeWPKH   = "wpkh"
+	TypeTR     = "tr"
+)
+
+const (
+	inputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	checksumLength  = 8
+)
+
+var checksumGenerator = [5]uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd}
+
+// Descriptor is a ranged single-key output descriptor such as
+// wpkh([d34db33f/84h/0h/0h]xpub.../0/*).
+type Descriptor struct {
+	Type        string
+	Fingerprint uint32
+	Origin      []uint32
+	Key         *hdkeychain.ExtendedKey
+	Path        []uint32
+}
+
+// KeyExpr is one ranged extended key of a descriptor together with the
+// fingerprint and path of the master key it was derived from.
+type KeyExpr struct {
+	Fingerprint uint32
+	Origin      []uint32
+	Key         *hdkeychain.ExtendedKey
+	Path        []uint32
+}
+
+// AccountDescriptors holds the receive and change descriptors of one account.
+type AccountDescriptors struct {
+	Scope   string `json:"scope"`
+	Receive string `json:"receive"`
+	Change  string `json:"change"`
+}
+
+// Checksum returns the BIP380 checksum of desc, which must not carry one.
+func Checksum(desc string) (string, error) {
+	var symbols []uint64
+	var groups []uint64
+	for _, c := range desc {
+		pos := strings.IndexRune(inputCharset, c)
+		if pos < 0 {
+			return "", fmt.Errorf("invalid descriptor character %q", c)
+		}
+		symbols = append(symbols, uint64(pos&31))
+		groups = append(groups, uint64(pos>>5))
+		if len(groups) == 3 {
+			symbols = append(symbols, groups[0]*9+groups[1]*3+groups[2])
+			groups = groups[:0]
+		}
+	}
+	switch len(groups) {
+	case 1:
+		symbols = append(symbols, groups[0])
+	case 2:
+		symbols = append(symbols, groups[0]*3+groups[1])
+	}
+	symbols = append(symbols, make([]uint64, checksumLength)...)
+
+	chk := uint64(1)
+	for _, value := range symbols {
+		top := chk >> 35
+		chk = (chk&0x7ffffffff)<<5 ^ value
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= checksumGenerator[i]
+			}
+		}
+	}
+	chk ^= 1
+
+	sum := make([]byte, checksumLength)
+	for i := range sum {
+		sum[i] = checksumCharset[(chk>>(5*(7-i)))&31]
+	}
+	return string(sum), nil
+}
+
+// AddChecksum appends the BIP380 checksum to desc.
+func AddChecksum(desc string) (string, error) {
+	sum, err := Checksum(desc)
+	if err != nil {
+		return "", err
+	}
+	return desc + "#" + sum, nil
+}
+
+// IsDescriptor reports whether s looks like an output descriptor rather
+// than a mnemonic.
+func IsDescriptor(s string) bool {
+	return strings.Contains(s, "(")
+}
+
+// Parse parses a ranged single-key descriptor for params. A checksum is
+// verified when present.
+func Parse(desc string, params *chaincfg.Params) (*Descriptor, error) {
+	desc, err := stripChecksum(desc)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Descriptor{}
+	var keyExpr string
+	for _, wrapper := range []struct{ typ, prefix, suffix string }{
+		{TypeSHWPKH, "sh(wpkh(", "))"},
+		{TypePKH, "pkh(", ")"},
+		{TypeWPKH, "wpkh(", ")"},
+		{TypeTR, "tr(", ")"},
+	} {
+		if strings.HasPrefix(desc, wrapper.prefix) && strings.HasSuffix(desc, wrapper.suffix) {
+			d.Type = wrapper.typ
+			keyExpr = strings.TrimSuffix(strings.TrimPrefix(desc, wrapper.prefix), wrapper.suffix)
+			break
+		}
+	}
+	if d.Type == "" {
+		return nil, fmt.Errorf("unsupported descriptor, expected pkh(), sh(wpkh()), wpkh() or tr() with one key")
+	}
+
+	key, err := parseKeyExpr(keyExpr, params)
+	if err != nil {
+		return nil, err
+	}
+	d.Fingerprint = key.Fingerprint
+	d.Origin = key.Origin
+	d.Key = key.Key
+	d.Path = key.Path
+	return d, nil
+}
+
+// stripChecksum trims desc and removes its checksum, which must match when
+// present.
+func stripChecksum(desc string) (string, error) {
+	desc = strings.TrimSpace(desc)
+	i := strings.IndexByte(desc, '#')
+	if i < 0 {
+		return desc, nil
+	}
+	sum, err := Checksum(desc[:i])
+	if err != nil {
+		return "", err
+	}
+	if desc[i+1:] != sum {
+		return "", fmt.Errorf("descriptor checksum mismatch: got %s, expected %s", desc[i+1:], sum)
+	}
+	return desc[:i], nil
+}
+
+// parseKeyExpr parses a ranged [fingerprint/origin]KEY/path/* expression.
+func parseKeyExpr(keyExpr string, params *chaincfg.Params) (*KeyExpr, error) {
+	k := &KeyExpr{}
+	if strings.HasPrefix(keyExpr, "[") {
+		end := strings.IndexByte(keyExpr, ']')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated key origin")
+		}
+		origin := strings.Split(keyExpr[1:end], "/")
+		fingerprint, err := hex.DecodeString(origin[0])
+		if err != nil || len(fingerprint) != 4 {
+			return nil, fmt.Errorf("invalid key origin fingerprint %q", origin[0])
+		}
+		k.Fingerprint = binary.BigEndian.Uint32(fingerprint)
+		k.Origin, err = parsePath(origin[1:])
+		if err != nil {
+			return nil, err
+		}
+		keyExpr = keyExpr[end+1:]
+	}
+
+	parts := strings.Split(keyExpr, "/")
+	if len(parts) < 2 || parts[len(parts)-1] != "*" {
+		return nil, fmt.Errorf("descriptor keys must be ranged and end in /*")
+	}
+	key, err := hdkeychain.NewKeyFromString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended key: %v", err)
+	}
+	if !key.IsForNet(params) {
+		return nil, fmt.Errorf("extended key is not for %s", params.Name)
+	}
+	k.Key = key
+	k.Path, err = parsePath(parts[1 : len(parts)-1])
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// String returns the key expression without a checksum.
+func (k *KeyExpr) String() string {
+	keyExpr := k.Key.String()
+	if len(k.Origin) > 0 || k.Fingerprint != 0 {
+		keyExpr = fmt.Sprintf("[%08x%s]%s", k.Fingerprint, formatPath(k.Origin), keyExpr)
+	}
+	return keyExpr + formatPath(k.Path) + "/*"
+}
+
+// String returns the descriptor with its checksum.
+func (d *Descriptor) String() string {
+	keyExpr := (&KeyExpr{Fingerprint: d.Fingerprint, Origin: d.Origin, Key: d.Key, Path: d.Path}).String()
+
+	var desc string
+	if d.Type == TypeSHWPKH {
+		desc = fmt.Sprintf("sh(wpkh(%s))", keyExpr)
+	} else {
+		desc = fmt.Sprintf("%s(%s)", d.Type, keyExpr)
+	}
+	// Every character produced above is in the checksum charset
+	withSum, _ := AddChecksum(desc)
+	return withSum
+}
+
+// Scope returns the BIP44-style key scope whose accounts produce d.Type
+// outputs.
+func (d *Descriptor) Scope() (waddrmgr.KeyScope, error) {
+	switch d.Type {
+	case TypePKH:
+		return waddrmgr.KeyScopeBIP0044, nil
+	case TypeSHWPKH:
+		return waddrmgr.KeyScopeBIP0049Plus, nil
+	case TypeWPKH:
+		return waddrmgr.KeyScopeBIP0084, nil
+	case TypeTR:
+		return waddrmgr.KeyScopeBIP0086, nil
+	default:
+		return waddrmgr.KeyScope{}, fmt.Errorf("unknown descriptor type %q", d.Type)
+	}
+}
+
+// AddressType returns the address_type setting a wallet created from d runs
+// with. Only native segwit and taproot descriptors can back a wallet.
+func (d *Descriptor) AddressType() (string, error) {
+	switch d.Type {
+	case TypeWPKH:
+		return utils.AddressTypeP2WPKH, nil
+	case TypeTR:
+		return utils.AddressTypeP2TR, nil
+	default:
+		return "", fmt.Errorf("wallets can only be created from wpkh() or tr() descriptors, got %s", d.Type)
+	}
+}
+
+// ValidateForWallet checks that d can back a wallet: either a master private
+// key ranged over purpose'/coin'/account'/branch, or an account-level public
+// key ranged over branch, which gives a watch-only wallet.
+func (d *Descriptor) ValidateForWallet() error {
+	if _, err := d.AddressType(); err != nil {
+		return err
+	}
+	scope, err := d.Scope()
+	if err != nil {
+		return err
+	}
+
+	if d.Key.IsPrivate() {
+		if d.Key.Depth() != 0 || len(d.Origin) != 0 {
+			return fmt.Errorf("private descriptors must use the master key, not an account key")
+		}
+		if len(d.Path) != 4 || d.Path[0] != hdkeychain.HardenedKeyStart+scope.Purpose {
+			return fmt.Errorf("private %s descriptors must derive %dh/coin'/account'/branch/*", d.Type, scope.Purpose)
+		}
+		return nil
+	}
+
+	if d.Key.Depth() != 3 || len(d.Path) != 1 {
+		return fmt.Errorf("public descriptors must use an account key ranged over branch/*")
+	}
+	if len(d.Origin) == 3 && d.Origin[0] != hdkeychain.HardenedKeyStart+scope.Purpose {
+		return fmt.Errorf("key origin purpose does not match %s", d.Type)
+	}
+	return nil
+}
+
+// ForAccount returns the descriptors for branch 0 (receive) and 1 (change) of
+// account 0 in scope, given the wallet's master key fingerprint.
+func ForAccount(w *wallet.Wallet, scope waddrmgr.KeyScope, fingerprint uint32) (*AccountDescriptors, error) {
+	props, err := w.AccountProperties(scope, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account properties: %v", err)
+	}
+	if props.AccountPubKey == nil {
+		return nil, fmt.Errorf("account has no extended public key")
+	}
+	if props.MasterKeyFingerprint != 0 {
+		fingerprint = props.MasterKeyFingerprint
+	}
+
+	// Descriptors always use the plain xpub/tpub version
+	xpub, err := utils.GetExtendedPubKey(props.AccountPubKey, w.ChainParams().HDPublicKeyID[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode account key: %v", err)
+	}
+	accountKey, err := hdkeychain.NewKeyFromString(xpub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode account key: %v", err)
+	}
+
+	schema, ok := waddrmgr.ScopeAddrMap[scope]
+	if !ok {
+		return nil, fmt.Errorf("no address schema for scope %s", scope)
+	}
+	origin := []uint32{
+		hdkeychain.HardenedKeyStart + scope.Purpose,
+		hdkeychain.HardenedKeyStart + scope.Coin,
+		hdkeychain.HardenedKeyStart + props.AccountNumber,
+	}
+
+	receiveType, err := typeForAddress(schema.ExternalAddrType)
+	if err != nil {
+		return nil, err
+	}
+	changeType, err := typeForAddress(schema.InternalAddrType)
+	if err != nil {
+		return nil, err
+	}
+
+	receive := &Descriptor{Type: receiveType, Fingerprint: fingerprint, Origin: origin, Key: accountKey, Path: []uint32{0}}
+	change := &Descriptor{Type: changeType, Fingerprint: fingerprint, Origin: origin, Key: accountKey, Path: []uint32{1}}
+	return &AccountDescriptors{
+		Scope:   fmt.Sprintf("bip%d", scope.Purpose),
+		Receive: receive.String(),
+		Change:  change.String(),
+	}, nil
+}
+
+// ForWallet returns the descriptors of every default scope that has an
+// account 0 in w.
+func ForWallet(w *wallet.Wallet, fingerprint uint32) []AccountDescriptors {
+	var descriptors []AccountDescriptors
+	for _, scope := range []waddrmgr.KeyScope{
+		waddrmgr.KeyScopeBIP0044,
+		waddrmgr.KeyScopeBIP0049Plus,
+		waddrmgr.KeyScopeBIP0084,
+		waddrmgr.KeyScopeBIP0086,
+	} {
+		account, err := ForAccount(w, scope, fingerprint)
+		if err != nil {
+			continue
+		}
+		descriptors = append(descriptors, *account)
+	}
+	return descriptors
+}
+
+func typeForAddress(addrType waddrmgr.AddressType) (string, error) {
+	switch addrType {
+	case waddrmgr.PubKeyHash:
+		return TypePKH, nil
+	case waddrmgr.NestedWitnessPubKey:
+		return TypeSHWPKH, nil
+	case waddrmgr.WitnessPubKey:
+		return TypeWPKH, nil
+	case waddrmgr.TaprootPubKey:
+		return TypeTR, nil
+	default:
+		return "", fmt.Errorf("no descriptor for address type %v", addrType)
+	}
+}
+
+func parsePath(elems []string) ([]uint32, error) {
+	path := make([]uint32, 0, len(elems))
+	for _, elem := range elems {
+		hardened := strings.HasSuffix(elem, "h") || strings.HasSuffix(elem, "'") || strings.HasSuffix(elem, "H")
+		if hardened {
+			elem = elem[:len(elem)-1]
+		}
+		index, err := strconv.ParseUint(elem, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation step %q", elem)
+		}
+		if hardened {
+			index += hdkeychain.HardenedKeyStart
+		}
+		path = append(path, uint32(index))
+	}
+	return path, nil
+}
+
+func formatPath(path []uint32) string {
+	var b strings.Builder
+	for _, index := range path {
+		if index >= hdkeychain.HardenedKeyStart {
+			fmt.Fprintf(&b, "/%dh", index-hdkeychain.HardenedKeyStart)
+		} else {
+			fmt.Fprintf(&b, "/%d", index)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,365 @@
+package descriptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// MaxMultisigKeys is the most keys a sortedmulti() descriptor may hold.
+const MaxMultisigKeys = txscript.MaxPubKeysPerMultiSig
+
+// bip48ScriptTypeP2WSH is the script type step of a BIP48
+// m/48'/coin'/account'/2' account path.
+const bip48ScriptTypeP2WSH = 2
+
+// Multisig is a ranged wsh(sortedmulti(k,KEY,...)) descriptor. Every key is
+// an account key ranged over a single branch step; branch 0 holds receive
+// addresses and branch 1 change.
+type Multisig struct {
+	Threshold int
+	Keys      []*KeyExpr
+}
+
+// IsMultisig reports whether s is a sortedmulti() descriptor.
+func IsMultisig(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), "wsh(sortedmulti(")
+}
+
+// ParseMultisig parses a wsh(sortedmulti()) descriptor for params. A checksum
+// is verified when present.
+func ParseMultisig(desc string, params *chaincfg.Params) (*Multisig, error) {
+	desc, err := stripChecksum(desc)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(desc, "wsh(sortedmulti(") || !strings.HasSuffix(desc, "))") {
+		return nil, fmt.Errorf("unsupported descriptor, expected wsh(sortedmulti())")
+	}
+	args := strings.Split(strings.TrimSuffix(strings.TrimPrefix(desc, "wsh(sortedmulti("), "))"), ",")
+	if len(args) < 2 {
+		return nil, fmt.Errorf("sortedmulti() needs a threshold and at least one key")
+	}
+
+	threshold, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid multisig threshold %q", args[0])
+	}
+	m := &Multisig{Threshold: threshold}
+	for i, arg := range args[1:] {
+		key, err := parseKeyExpr(arg, params)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %v", i+1, err)
+		}
+		m.Keys = append(m.Keys, key)
+	}
+	return m, nil
+}
+
+// String returns the descriptor with its checksum.
+func (m *Multisig) String() string {
+	keys := make([]string, len(m.Keys))
+	for i, key := range m.Keys {
+		keys[i] = key.String()
+	}
+	desc := fmt.Sprintf("wsh(sortedmulti(%d,%s))", m.Threshold, strings.Join(keys, ","))
+	withSum, _ := AddChecksum(desc)
+	return withSum
+}
+
+// ForBranch returns a copy of m with every key ranged over branch.
+func (m *Multisig) ForBranch(branch uint32) *Multisig {
+	out := &Multisig{Threshold: m.Threshold}
+	for _, key := range m.Keys {
+		copied := *key
+		copied.Path = []uint32{branch}
+		out.Keys = append(out.Keys, &copied)
+	}
+	return out
+}
+
+// Public returns a copy of m without private keys, safe to hand to cosigners.
+func (m *Multisig) Public() (*Multisig, error) {
+	out := &Multisig{Threshold: m.Threshold}
+	for _, key := range m.Keys {
+		copied := *key
+		if key.Key.IsPrivate() {
+			neutered, err := key.Key.Neuter()
+			if err != nil {
+				return nil, fmt.Errorf("failed to neuter key: %v", err)
+			}
+			copied.Key = neutered
+		}
+		out.Keys = append(out.Keys, &copied)
+	}
+	return out, nil
+}
+
+// AccountDescriptors returns the public receive and change descriptors of m,
+// which every cosigner's wallet shares.
+func (m *Multisig) AccountDescriptors() (*AccountDescriptors, error) {
+	public, err := m.Public()
+	if err != nil {
+		return nil, err
+	}
+	return &AccountDescriptors{
+		Scope:   "bip48",
+		Receive: public.ForBranch(0).String(),
+		Change:  public.ForBranch(1).String(),
+	}, nil
+}
+
+// ValidateForWallet checks that m can back a wallet: a sane threshold,
+// distinct account keys ranged over one branch step, and at most one private
+// key, which is the one this wallet signs with.
+func (m *Multisig) ValidateForWallet() error {
+	n := len(m.Keys)
+	if n < 2 || n > MaxMultisigKeys {
+		return fmt.Errorf("multisig wallets need between 2 and %d keys, got %d", MaxMultisigKeys, n)
+	}
+	if m.Threshold < 1 || m.Threshold > n {
+		return fmt.Errorf("threshold must be between 1 and %d, got %d", n, m.Threshold)
+	}
+
+	seen := make(map[string]bool)
+	private := 0
+	for i, key := range m.Keys {
+		if key.Key.Depth() == 0 {
+			return fmt.Errorf("key %d is a master key, use the account key", i+1)
+		}
+		if len(key.Path) != 1 {
+			return fmt.Errorf("key %d must be ranged over branch/*", i+1)
+		}
+		if key.Key.IsPrivate() {
+			private++
+		}
+		pubKey, err := key.Key.ECPubKey()
+		if err != nil {
+			return fmt.Errorf("key %d: %v", i+1, err)
+		}
+		id := string(pubKey.SerializeCompressed())
+		if seen[id] {
+			return fmt.Errorf("key %d appears more than once", i+1)
+		}
+		seen[id] = true
+	}
+	if private > 1 {
+		return fmt.Errorf("a multisig wallet holds at most one private key, got %d", private)
+	}
+	return nil
+}
+
+// SigningKey returns the key this wallet signs with, or nil when every key
+// belongs to a cosigner.
+func (m *Multisig) SigningKey() *KeyExpr {
+	for _, key := range m.Keys {
+		if key.Key.IsPrivate() {
+			return key
+		}
+	}
+	return nil
+}
+
+// WitnessScript returns the sorted multisig script at branch/index.
+func (m *Multisig) WitnessScript(branch, index uint32, params *chaincfg.Params) ([]byte, error) {
+	var pubKeys [][]byte
+	for _, key := range m.Keys {
+		child, err := key.child(branch, index)
+		if err != nil {
+			return nil, err
+		}
+		pubKey, err := child.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		pubKeys = append(pubKeys, pubKey.SerializeCompressed())
+	}
+
+	// BIP67: keys appear in lexicographic order of their compressed form
+	sort.Slice(pubKeys, func(i, j int) bool {
+		return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+	})
+
+	addrPubKeys := make([]*btcutil.AddressPubKey, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		addr, err := btcutil.NewAddressPubKey(pubKey, params)
+		if err != nil {
+			return nil, err
+		}
+		addrPubKeys[i] = addr
+	}
+	return txscript.MultiSigScript(addrPubKeys, m.Threshold)
+}
+
+// Address returns the P2WSH address at branch/index and its witness script.
+func (m *Multisig) Address(branch, index uint32, params *chaincfg.Params) (btcutil.Address, []byte, error) {
+	script, err := m.WitnessScript(branch, index, params)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build witness script: %v", err)
+	}
+	scriptHash := sha256.Sum256(script)
+	addr, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:], params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return addr, script, nil
+}
+
+// Derivations returns the BIP32 derivation of every cosigner's key at
+// branch/index, as PSBT signers expect them.
+func (m *Multisig) Derivations(branch, index uint32) ([]*psbt.Bip32Derivation, error) {
+	var derivations []*psbt.Bip32Derivation
+	for _, key := range m.Keys {
+		child, err := key.child(branch, index)
+		if err != nil {
+			return nil, err
+		}
+		pubKey, err := child.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		fingerprint, origin, err := key.origin()
+		if err != nil {
+			return nil, err
+		}
+		derivations = append(derivations, &psbt.Bip32Derivation{
+			PubKey:               pubKey.SerializeCompressed(),
+			MasterKeyFingerprint: PSBTFingerprint(fingerprint),
+			Bip32Path:            append(origin, branch, index),
+		})
+	}
+	return derivations, nil
+}
+
+// PrivKey returns the private key for derivation when it belongs to the
+// wallet's signing key, and nil otherwise.
+func (m *Multisig) PrivKey(derivation *psbt.Bip32Derivation) (*btcec.PrivateKey, error) {
+	key := m.SigningKey()
+	if key == nil {
+		return nil, nil
+	}
+	fingerprint, origin, err := key.origin()
+	if err != nil {
+		return nil, err
+	}
+	path := derivation.Bip32Path
+	if derivation.MasterKeyFingerprint != PSBTFingerprint(fingerprint) || len(path) != len(origin)+2 {
+		return nil, nil
+	}
+	for i, step := range origin {
+		if path[i] != step {
+			return nil, nil
+		}
+	}
+
+	child, err := key.child(path[len(origin)], path[len(origin)+1])
+	if err != nil {
+		return nil, err
+	}
+	privKey, err := child.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(privKey.PubKey().SerializeCompressed(), derivation.PubKey) {
+		return nil, nil
+	}
+	return privKey, nil
+}
+
+// BIP48Key derives the BIP48 P2WSH account key m/48'/coin'/account'/2' from
+// rootKey, ready to be placed in a sortedmulti() descriptor.
+func BIP48Key(rootKey *hdkeychain.ExtendedKey, account uint32, params *chaincfg.Params) (*KeyExpr, error) {
+	fingerprint, err := utils.GetMasterFingerprint(rootKey)
+	if err != nil {
+		return nil, err
+	}
+	origin := []uint32{
+		hdkeychain.HardenedKeyStart + 48,
+		hdkeychain.HardenedKeyStart + params.HDCoinType,
+		hdkeychain.HardenedKeyStart + account,
+		hdkeychain.HardenedKeyStart + bip48ScriptTypeP2WSH,
+	}
+	key := rootKey
+	for _, step := range origin {
+		key, err = key.Derive(step)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive BIP48 account key: %v", err)
+		}
+	}
+	return &KeyExpr{Fingerprint: fingerprint, Origin: origin, Key: key, Path: []uint32{0}}, nil
+}
+
+// ParseCosignerKey parses a cosigner's BIP48 account key as wallets export
+// it: an xpub, or a Zpub, with or without a [fingerprint/path] origin and a
+// branch/* suffix. Keys without a suffix are ranged over branch 0.
+func ParseCosignerKey(keyExpr string, params *chaincfg.Params) (*KeyExpr, error) {
+	keyExpr = strings.TrimSpace(keyExpr)
+	if !strings.HasSuffix(keyExpr, "/*") {
+		keyExpr += "/0/*"
+	}
+
+	// Descriptors always carry the plain xpub version
+	start := strings.IndexByte(keyExpr, ']') + 1
+	end := start + strings.IndexByte(keyExpr[start:], '/')
+	key, err := hdkeychain.NewKeyFromString(keyExpr[start:end])
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended key: %v", err)
+	}
+	if key.IsPrivate() {
+		return nil, fmt.Errorf("cosigners share an extended public key, not a private one")
+	}
+	key, err = key.CloneWithVersion(params.HDPublicKeyID[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert extended key: %v", err)
+	}
+
+	return parseKeyExpr(keyExpr[:start]+key.String()+keyExpr[end:], params)
+}
+
+// PSBTFingerprint converts a fingerprint as written in descriptors to the
+// byte order the psbt package serializes.
+func PSBTFingerprint(fingerprint uint32) uint32 {
+	return bits.ReverseBytes32(fingerprint)
+}
+
+// child derives the key at branch/index below k.
+func (k *KeyExpr) child(branch, index uint32) (*hdkeychain.ExtendedKey, error) {
+	branchKey, err := k.Key.Derive(branch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive branch %d: %v", branch, err)
+	}
+	child, err := branchKey.Derive(index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive index %d: %v", index, err)
+	}
+	return child, nil
+}
+
+// MasterFingerprint returns the fingerprint of the master key k descends
+// from, as PSBTs built from k name it.
+func (k *KeyExpr) MasterFingerprint() (uint32, error) {
+	fingerprint, _, err := k.origin()
+	return fingerprint, err
+}
+
+// origin returns the master fingerprint and path k was derived at. Keys given
+// without an origin are their own master.
+func (k *KeyExpr) origin() (uint32, []uint32, error) {
+	if len(k.Origin) > 0 || k.Fingerprint != 0 {
+		return k.Fingerprint, append([]uint32(nil), k.Origin...), nil
+	}
+	fingerprint, err := utils.GetMasterFingerprint(k.Key)
+	return fingerprint, nil, err
+}
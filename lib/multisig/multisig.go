@@ -0,0 +1,204 @@
+package multisig
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/descriptor"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// Descriptor branches: receive addresses on 0, change on 1.
+const (
+	BranchReceive uint32 = 0
+	BranchChange  uint32 = 1
+)
+
+// KeyScope is the address manager scope a multisig wallet stores its witness
+// scripts under. Nothing is derived from it; the descriptor gives every
+// address.
+var KeyScope = waddrmgr.KeyScope{Purpose: 48, Coin: 0}
+
+var scopeSchema = waddrmgr.ScopeAddrSchema{
+	ExternalAddrType: waddrmgr.WitnessScript,
+	InternalAddrType: waddrmgr.WitnessScript,
+}
+
+var (
+	// active is the public descriptor of the open multisig wallet, nil for
+	// single-sig wallets. hasSigningKey records whether the full descriptor
+	// holds one of the private keys.
+	active        *descriptor.Multisig
+	hasSigningKey bool
+
+	// signer is the full descriptor, with the private key, while the wallet
+	// is unlocked for signing. signerTimer locks it again.
+	signer      *descriptor.Multisig
+	signerTimer *time.Timer
+	signerMutex sync.Mutex
+)
+
+// SetActive marks the open wallet as a multisig wallet backed by m. Only the
+// public keys are kept; Unlock loads the private key for signing.
+func SetActive(m *descriptor.Multisig) error {
+	Lock()
+	if m == nil {
+		active, hasSigningKey = nil, false
+		return nil
+	}
+
+	public, err := m.Public()
+	if err != nil {
+		return err
+	}
+	active, hasSigningKey = public, m.SigningKey() != nil
+	return nil
+}
+
+// Active returns the open wallet's public multisig descriptor, or nil when
+// the wallet is single-sig.
+func Active() *descriptor.Multisig {
+	return active
+}
+
+// HasSigningKey reports whether the open multisig wallet signs with a key of
+// its own, rather than leaving every signature to cosigners.
+func HasSigningKey() bool {
+	return active != nil && hasSigningKey
+}
+
+// Unlock holds m, the wallet's descriptor with its private key, for signing
+// until timeout passes or Lock is called. A zero timeout never expires.
+func Unlock(m *descriptor.Multisig, timeout time.Duration) error {
+	if active == nil {
+		return fmt.Errorf("wallet is not a multisig wallet")
+	}
+	if m.SigningKey() == nil {
+		return fmt.Errorf("multisig descriptor holds no signing key")
+	}
+
+	signerMutex.Lock()
+	defer signerMutex.Unlock()
+
+	if signerTimer != nil {
+		signerTimer.Stop()
+		signerTimer = nil
+	}
+	signer = m
+	if timeout > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(timeout, func() {
+			signerMutex.Lock()
+			defer signerMutex.Unlock()
+			// A later Unlock replaced this timer
+			if signerTimer == timer {
+				signer, signerTimer = nil, nil
+			}
+		})
+		signerTimer = timer
+	}
+	return nil
+}
+
+// Lock drops the private descriptor loaded by Unlock.
+func Lock() {
+	signerMutex.Lock()
+	defer signerMutex.Unlock()
+
+	if signerTimer != nil {
+		signerTimer.Stop()
+		signerTimer = nil
+	}
+	signer = nil
+}
+
+// Signer returns the descriptor with the wallet's private key while it is
+// unlocked, and nil otherwise.
+func Signer() *descriptor.Multisig {
+	signerMutex.Lock()
+	defer signerMutex.Unlock()
+	return signer
+}
+
+// CreateScope adds KeyScope to a newly created watch-only wallet so witness
+// scripts can be imported into it.
+func CreateScope(w *wallet.Wallet) error {
+	return walletdb.Update(w.Database(), func(tx walletdb.ReadWriteTx) error {
+		addrmgrNs := tx.ReadWriteBucket([]byte("waddrmgr"))
+		_, err := w.Manager.NewScopedKeyManager(addrmgrNs, KeyScope, scopeSchema)
+		if err != nil {
+			return fmt.Errorf("failed to create multisig key scope: %v", err)
+		}
+		return nil
+	})
+}
+
+// ImportAddress derives the address at branch/index of the active descriptor
+// and imports its witness script, so the wallet and the chain client watch it.
+// Importing an address twice is not an error.
+func ImportAddress(w *wallet.Wallet, branch, index uint32) (btcutil.Address, error) {
+	if active == nil {
+		return nil, fmt.Errorf("wallet is not a multisig wallet")
+	}
+
+	addr, script, err := active.Address(branch, index, w.ChainParams())
+	if err != nil {
+		return nil, err
+	}
+
+	scopedMgr, err := w.Manager.FetchScopedKeyManager(KeyScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch multisig key scope: %v", err)
+	}
+	syncedTo := w.Manager.SyncedTo()
+	err = walletdb.Update(w.Database(), func(tx walletdb.ReadWriteTx) error {
+		addrmgrNs := tx.ReadWriteBucket([]byte("waddrmgr"))
+		_, err := scopedMgr.ImportWitnessScript(addrmgrNs, script, &syncedTo, 0, false)
+		if waddrmgr.IsError(err, waddrmgr.ErrDuplicateAddress) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import witness script: %v", err)
+	}
+
+	if chainClient := w.ChainClient(); chainClient != nil {
+		if err := chainClient.NotifyReceived([]btcutil.Address{addr}); err != nil {
+			return nil, fmt.Errorf("unable to subscribe for address notifications: %v", err)
+		}
+	}
+
+	log.Printf("Imported multisig address %s at %d/%d", addr, branch, index)
+	return addr, nil
+}
+
+// PoolAddress imports the address stored as entry poolIndex of the SQLite
+// address pool on branch. Pool entries are numbered from 1, so entry n is
+// derivation index n-1.
+func PoolAddress(w *wallet.Wallet, branch uint32, poolIndex uint) (btcutil.Address, error) {
+	if poolIndex == 0 {
+		return nil, fmt.Errorf("address pool entries are numbered from 1")
+	}
+	return ImportAddress(w, branch, uint32(poolIndex-1))
+}
+
+// Descriptors returns the descriptors the open wallet exports: the public
+// multisig descriptors of a multisig wallet, and those of every single-sig
+// account otherwise.
+func Descriptors(w *wallet.Wallet, fingerprint uint32) []descriptor.AccountDescriptors {
+	if active == nil {
+		return descriptor.ForWallet(w, fingerprint)
+	}
+	account, err := active.AccountDescriptors()
+	if err != nil {
+		log.Printf("Error exporting multisig descriptors: %v", err)
+		return nil
+	}
+	return []descriptor.AccountDescriptors{*account}
+}
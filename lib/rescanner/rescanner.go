@@ -12,6 +12,7 @@ import (
 
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/logger"
 	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -19,6 +20,7 @@ import (
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/wallet"
 	"github.com/lightninglabs/neutrino"
 	"github.com/lightninglabs/neutrino/headerfs"
@@ -67,6 +69,14 @@ func PerformRescan(config RescanConfig) error {
 		}
 	}
 
+	// An imported mnemonic may hold funds on any standard derivation path, so
+	// derive lookahead addresses in every scope before the wallet syncs
+	if config.IsImportedWallet {
+		if err := ExtendImportScopes(config.Wallet, addressGapLimit()); err != nil {
+			log.Printf("Error deriving import scope addresses: %v", err)
+		}
+	}
+
 	// Use a quick initial sync to get the basic wallet state with retry logic
 	log.Println("Performing quick initial synchronization...")
 
@@ -168,6 +178,15 @@ func PerformRescan(config RescanConfig) error {
 		return fmt.Errorf("failed to get addresses from wallet: %v", err)
 	}
 
+	// Multisig addresses are imported witness scripts rather than derived keys
+	if multisig.Active() != nil {
+		imported, err := config.Wallet.AccountAddresses(waddrmgr.ImportedAddrAccount)
+		if err != nil {
+			return fmt.Errorf("failed to get imported addresses from wallet: %v", err)
+		}
+		allAddresses = append(allAddresses, imported...)
+	}
+
 	addrCount := len(allAddresses)
 	log.Printf("Optimized rescanning for %d addresses", addrCount)
 	logger.Info("Rescanning " + fmt.Sprintf("%d", addrCount) + " addresses")
@@ -388,6 +407,16 @@ FullSyncLoop:
 
 	// If this was a newly imported wallet, reset the flag after successful sync
 	if config.IsImportedWallet {
+		historyScopes, err := ScopesWithHistory(config.Wallet)
+		if err != nil {
+			log.Printf("Error checking scope history: %v", err)
+		} else {
+			log.Printf("Address history found in scopes: %v", historyScopes)
+			if err := utils.SetHistoryScopes(config.WalletName, historyScopes); err != nil {
+				log.Printf("Error recording history scopes: %v", err)
+			}
+		}
+
 		log.Println("Resetting newly imported wallet flag after successful sync")
 		err = utils.SetNewlyImportedWallet(false)
 		if err != nil {
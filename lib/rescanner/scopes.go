@@ -0,0 +1,99 @@
+package rescanner
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/spf13/viper"
+)
+
+// defaultAddressGapLimit is the number of unused addresses derived ahead on
+// each branch of each scope when address_gap_limit is not set.
+const defaultAddressGapLimit = 20
+
+// ImportScopes are the derivation paths an imported mnemonic is scanned on:
+// legacy BIP44, nested segwit BIP49, native segwit BIP84 and taproot BIP86.
+var ImportScopes = []waddrmgr.KeyScope{
+	waddrmgr.KeyScopeBIP0044,
+	waddrmgr.KeyScopeBIP0049Plus,
+	waddrmgr.KeyScopeBIP0084,
+	waddrmgr.KeyScopeBIP0086,
+}
+
+// ScopeName returns the short BIP name of a key scope, e.g. "bip84".
+func ScopeName(scope waddrmgr.KeyScope) string {
+	return fmt.Sprintf("bip%d", scope.Purpose)
+}
+
+// addressGapLimit returns the configured lookahead per branch.
+func addressGapLimit() uint32 {
+	gap := viper.GetInt("address_gap_limit")
+	if gap <= 0 {
+		return defaultAddressGapLimit
+	}
+	return uint32(gap)
+}
+
+// ExtendImportScopes derives gap receive and change addresses for account 0
+// in every import scope, so the rescan and the wallet's own sync watch them.
+// Scopes missing from the wallet are skipped.
+func ExtendImportScopes(w *wallet.Wallet, gap uint32) error {
+	for _, scope := range ImportScopes {
+		err := walletdb.Update(w.Database(), func(tx walletdb.ReadWriteTx) error {
+			scopedMgr, err := w.Manager.FetchScopedKeyManager(scope)
+			if err != nil {
+				log.Printf("Skipping %s: %v", ScopeName(scope), err)
+				return nil
+			}
+			addrmgrNs := tx.ReadWriteBucket([]byte("waddrmgr"))
+			if err := scopedMgr.ExtendExternalAddresses(addrmgrNs, 0, gap-1); err != nil {
+				return fmt.Errorf("failed to extend %s receive addresses: %v", ScopeName(scope), err)
+			}
+			if err := scopedMgr.ExtendInternalAddresses(addrmgrNs, 0, gap-1); err != nil {
+				return fmt.Errorf("failed to extend %s change addresses: %v", ScopeName(scope), err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("Derived %d receive and change addresses in %s", gap, ScopeName(scope))
+	}
+	return nil
+}
+
+// ScopesWithHistory returns the names of the import scopes in which the
+// wallet has marked at least one address as used.
+func ScopesWithHistory(w *wallet.Wallet) ([]string, error) {
+	var scopes []string
+	err := walletdb.View(w.Database(), func(tx walletdb.ReadTx) error {
+		addrmgrNs := tx.ReadBucket([]byte("waddrmgr"))
+		for _, scope := range ImportScopes {
+			scopedMgr, err := w.Manager.FetchScopedKeyManager(scope)
+			if err != nil {
+				continue
+			}
+			used := false
+			err = scopedMgr.ForEachAccountAddress(addrmgrNs, 0, func(maddr waddrmgr.ManagedAddress) error {
+				if !used && maddr.Used(addrmgrNs) {
+					used = true
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to read %s addresses: %v", ScopeName(scope), err)
+			}
+			if used {
+				scopes = append(scopes, ScopeName(scope))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scopes, nil
+}
@@ -11,5 +11,6 @@ type RescanConfig struct {
 	ChainParams      *chaincfg.Params
 	StartBlock       int32
 	Wallet           *wallet.Wallet
+	WalletName       string
 	IsImportedWallet bool // Indicates if this is an imported wallet with potential history
 }
@@ -0,0 +1,186 @@
+package slip39
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// secretIndex and digestIndex are the x coordinates the shared secret
+	// and its digest are placed at.
+	secretIndex = 255
+	digestIndex = 254
+	digestSize  = 4
+
+	// baseIterations is the PBKDF2 work spread over the Feistel rounds at
+	// iteration exponent 0.
+	baseIterations = 10000
+	roundCount     = 4
+)
+
+// expTable and logTable hold powers and logarithms of 3 in GF(256) with the
+// Rijndael polynomial x^8 + x^4 + x^3 + x + 1.
+var expTable, logTable = func() ([255]byte, [256]byte) {
+	var exp [255]byte
+	var log [256]byte
+	poly := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(poly)
+		log[poly] = byte(i)
+		poly = (poly << 1) ^ poly
+		if poly&0x100 != 0 {
+			poly ^= 0x11b
+		}
+	}
+	return exp, log
+}()
+
+// share is one point of a polynomial per secret byte.
+type share struct {
+	x     byte
+	value []byte
+}
+
+// interpolate evaluates at x the polynomials that pass through shares.
+func interpolate(shares []share, x byte) ([]byte, error) {
+	for _, s := range shares {
+		if s.x == x {
+			return s.value, nil
+		}
+	}
+
+	size := len(shares[0].value)
+	logProd := 0
+	for _, s := range shares {
+		if len(s.value) != size {
+			return nil, fmt.Errorf("shares have different lengths")
+		}
+		logProd += int(logTable[s.x^x])
+	}
+
+	result := make([]byte, size)
+	for _, s := range shares {
+		// Lagrange basis polynomial of s evaluated at x, as a logarithm
+		logBasis := logProd - int(logTable[s.x^x])
+		for _, other := range shares {
+			logBasis -= int(logTable[s.x^other.x])
+		}
+		logBasis = ((logBasis % 255) + 255) % 255
+
+		for i, b := range s.value {
+			if b != 0 {
+				result[i] ^= expTable[(int(logTable[b])+logBasis)%255]
+			}
+		}
+	}
+	return result, nil
+}
+
+// secretDigest returns the first digestSize bytes of HMAC-SHA256(key, secret).
+func secretDigest(key, secret []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(secret)
+	return mac.Sum(nil)[:digestSize]
+}
+
+// splitSecret splits secret into count shares, any threshold of which
+// recover it.
+func splitSecret(threshold, count int, secret []byte) ([]share, error) {
+	if threshold < 1 || threshold > count {
+		return nil, fmt.Errorf("threshold must be between 1 and %d, got %d", count, threshold)
+	}
+	if count > maxShareCount {
+		return nil, fmt.Errorf("at most %d shares are allowed, got %d", maxShareCount, count)
+	}
+
+	var shares []share
+	if threshold == 1 {
+		for i := 0; i < count; i++ {
+			shares = append(shares, share{x: byte(i), value: secret})
+		}
+		return shares, nil
+	}
+
+	for i := 0; i < threshold-2; i++ {
+		value := make([]byte, len(secret))
+		if _, err := rand.Read(value); err != nil {
+			return nil, fmt.Errorf("error generating share: %v", err)
+		}
+		shares = append(shares, share{x: byte(i), value: value})
+	}
+
+	randomPart := make([]byte, len(secret)-digestSize)
+	if _, err := rand.Read(randomPart); err != nil {
+		return nil, fmt.Errorf("error generating digest: %v", err)
+	}
+	digest := append(secretDigest(randomPart, secret), randomPart...)
+
+	base := append(append([]share(nil), shares...),
+		share{x: digestIndex, value: digest},
+		share{x: secretIndex, value: secret},
+	)
+	for i := threshold - 2; i < count; i++ {
+		value, err := interpolate(base, byte(i))
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share{x: byte(i), value: value})
+	}
+	return shares, nil
+}
+
+// recoverSecret recovers the secret from threshold shares and checks it
+// against the digest share.
+func recoverSecret(threshold int, shares []share) ([]byte, error) {
+	if threshold == 1 {
+		return shares[0].value, nil
+	}
+
+	secret, err := interpolate(shares, secretIndex)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := interpolate(shares, digestIndex)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(digest[:digestSize], secretDigest(digest[digestSize:], secret)) {
+		return nil, fmt.Errorf("invalid digest of the shared secret")
+	}
+	return secret, nil
+}
+
+// crypt runs the four round Feistel network that encrypts a master secret
+// with a passphrase. Decryption runs the rounds in reverse.
+func crypt(secret []byte, passphrase string, exponent int, identifier uint16, extendable, decrypt bool) []byte {
+	half := len(secret) / 2
+	left := append([]byte(nil), secret[:half]...)
+	right := append([]byte(nil), secret[half:]...)
+
+	// Extendable backups keep the same encryption when groups are added, so
+	// the identifier stays out of the salt
+	var saltPrefix []byte
+	if !extendable {
+		saltPrefix = append([]byte(customizationString), byte(identifier>>8), byte(identifier))
+	}
+	iterations := (baseIterations / roundCount) << exponent
+
+	for r := 0; r < roundCount; r++ {
+		round := byte(r)
+		if decrypt {
+			round = byte(roundCount - 1 - r)
+		}
+		password := append([]byte{round}, passphrase...)
+		salt := append(append([]byte(nil), saltPrefix...), right...)
+		f := pbkdf2.Key(password, salt, iterations, half, sha256.New)
+		for i := range f {
+			f[i] ^= left[i]
+		}
+		left, right = right, f
+	}
+	return append(right, left...)
+}
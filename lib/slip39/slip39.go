@@ -0,0 +1,298 @@
+package slip39
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+const (
+	radix               = 1024
+	radixBits           = 10
+	idBits              = 15
+	checksumWords       = 3
+	metadataWords       = 7 // two of identifier, two of group data, three of checksum
+	minSecretBytes      = 16
+	maxShareCount       = 16
+	maxIterationExp     = 15
+	customizationString = "shamir"
+	extendableString    = "shamir_extendable"
+)
+
+// minMnemonicWords is the length of a share of a 128-bit secret.
+var minMnemonicWords = metadataWords + (minSecretBytes*8+radixBits-1)/radixBits
+
+// Group is the member threshold and member count of one group of shares.
+type Group struct {
+	Threshold int
+	Count     int
+}
+
+// Share is a decoded SLIP-39 share mnemonic.
+type Share struct {
+	Identifier        uint16
+	Extendable        bool
+	IterationExponent int
+	GroupIndex        int
+	GroupThreshold    int
+	GroupCount        int
+	MemberIndex       int
+	MemberThreshold   int
+	Value             []byte
+}
+
+// Split encrypts secret with passphrase and splits it into groups of share
+// mnemonics. Any groupThreshold groups with their member threshold of shares
+// each recover it. Higher iteration exponents slow down passphrase guessing.
+func Split(secret []byte, passphrase string, groupThreshold int, groups []Group, iterationExponent int) ([][]string, error) {
+	if len(secret) < minSecretBytes || len(secret)%2 != 0 {
+		return nil, fmt.Errorf("secret must be an even number of bytes and at least %d bytes", minSecretBytes)
+	}
+	if groupThreshold < 1 || groupThreshold > len(groups) {
+		return nil, fmt.Errorf("group threshold must be between 1 and %d, got %d", len(groups), groupThreshold)
+	}
+	if iterationExponent < 0 || iterationExponent > maxIterationExp {
+		return nil, fmt.Errorf("iteration exponent must be between 0 and %d", maxIterationExp)
+	}
+	for i, group := range groups {
+		if group.Threshold == 1 && group.Count > 1 {
+			return nil, fmt.Errorf("group %d: a member threshold of 1 needs exactly one share", i+1)
+		}
+	}
+
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, fmt.Errorf("error generating identifier: %v", err)
+	}
+	identifier := binary.BigEndian.Uint16(id[:]) & (1<<idBits - 1)
+
+	encrypted := crypt(secret, passphrase, iterationExponent, identifier, true, false)
+
+	groupShares, err := splitSecret(groupThreshold, len(groups), encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("error splitting into groups: %v", err)
+	}
+
+	mnemonics := make([][]string, len(groups))
+	for i, group := range groups {
+		memberShares, err := splitSecret(group.Threshold, group.Count, groupShares[i].value)
+		if err != nil {
+			return nil, fmt.Errorf("group %d: %v", i+1, err)
+		}
+		for _, member := range memberShares {
+			s := &Share{
+				Identifier:        identifier,
+				Extendable:        true,
+				IterationExponent: iterationExponent,
+				GroupIndex:        i,
+				GroupThreshold:    groupThreshold,
+				GroupCount:        len(groups),
+				MemberIndex:       int(member.x),
+				MemberThreshold:   group.Threshold,
+				Value:             member.value,
+			}
+			mnemonics[i] = append(mnemonics[i], s.Mnemonic())
+		}
+	}
+	return mnemonics, nil
+}
+
+// Combine recovers the secret from share mnemonics covering the group
+// threshold and decrypts it with passphrase. A wrong passphrase gives a
+// different secret rather than an error.
+func Combine(mnemonics []string, passphrase string) ([]byte, error) {
+	if len(mnemonics) == 0 {
+		return nil, fmt.Errorf("no shares given")
+	}
+
+	var first *Share
+	groups := make(map[int]map[int]*Share)
+	thresholds := make(map[int]int)
+	for i, mnemonic := range mnemonics {
+		s, err := ParseShare(mnemonic)
+		if err != nil {
+			return nil, fmt.Errorf("share %d: %v", i+1, err)
+		}
+		if first == nil {
+			first = s
+		}
+		if s.Identifier != first.Identifier || s.Extendable != first.Extendable || s.IterationExponent != first.IterationExponent {
+			return nil, fmt.Errorf("share %d belongs to a different backup", i+1)
+		}
+		if s.GroupThreshold != first.GroupThreshold || s.GroupCount != first.GroupCount {
+			return nil, fmt.Errorf("share %d has different group parameters", i+1)
+		}
+
+		members, ok := groups[s.GroupIndex]
+		if !ok {
+			members = make(map[int]*Share)
+			groups[s.GroupIndex] = members
+			thresholds[s.GroupIndex] = s.MemberThreshold
+		}
+		if thresholds[s.GroupIndex] != s.MemberThreshold {
+			return nil, fmt.Errorf("share %d has a different member threshold than its group", i+1)
+		}
+		if other, ok := members[s.MemberIndex]; ok && string(other.Value) != string(s.Value) {
+			return nil, fmt.Errorf("share %d conflicts with another share of the same index", i+1)
+		}
+		members[s.MemberIndex] = s
+	}
+
+	var groupIndexes []int
+	for index := range groups {
+		groupIndexes = append(groupIndexes, index)
+	}
+	sort.Ints(groupIndexes)
+
+	var groupShares []share
+	for _, index := range groupIndexes {
+		if len(groupShares) == first.GroupThreshold {
+			break
+		}
+		threshold := thresholds[index]
+		var memberShares []share
+		for _, s := range groups[index] {
+			memberShares = append(memberShares, share{x: byte(s.MemberIndex), value: s.Value})
+		}
+		if len(memberShares) < threshold {
+			continue
+		}
+		sort.Slice(memberShares, func(i, j int) bool { return memberShares[i].x < memberShares[j].x })
+
+		groupSecret, err := recoverSecret(threshold, memberShares[:threshold])
+		if err != nil {
+			return nil, fmt.Errorf("group %d: %v", index+1, err)
+		}
+		groupShares = append(groupShares, share{x: byte(index), value: groupSecret})
+	}
+	if len(groupShares) < first.GroupThreshold {
+		return nil, fmt.Errorf("%d of %d required groups are complete", len(groupShares), first.GroupThreshold)
+	}
+
+	encrypted, err := recoverSecret(first.GroupThreshold, groupShares)
+	if err != nil {
+		return nil, err
+	}
+	return crypt(encrypted, passphrase, first.IterationExponent, first.Identifier, first.Extendable, true), nil
+}
+
+// ParseShare decodes a share mnemonic and verifies its checksum.
+func ParseShare(mnemonic string) (*Share, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) < minMnemonicWords {
+		return nil, fmt.Errorf("share must be at least %d words, got %d", minMnemonicWords, len(words))
+	}
+
+	indices := make([]int, len(words))
+	for i, word := range words {
+		index, ok := wordIndex[word]
+		if !ok {
+			return nil, fmt.Errorf("unknown word %q", word)
+		}
+		indices[i] = index
+	}
+
+	paddingBits := (radixBits * (len(words) - metadataWords)) % 16
+	if paddingBits > 8 {
+		return nil, fmt.Errorf("invalid share length of %d words", len(words))
+	}
+
+	header := indices[0]<<radixBits | indices[1]
+	s := &Share{
+		Identifier:        uint16(header >> 5),
+		Extendable:        header>>4&1 == 1,
+		IterationExponent: header & 0xf,
+	}
+	if polymod(checksumCustomization(s.Extendable), indices) != 1 {
+		return nil, fmt.Errorf("invalid share checksum")
+	}
+
+	groupData := indices[2]<<radixBits | indices[3]
+	s.GroupIndex = groupData >> 16
+	s.GroupThreshold = groupData>>12&0xf + 1
+	s.GroupCount = groupData>>8&0xf + 1
+	s.MemberIndex = groupData >> 4 & 0xf
+	s.MemberThreshold = groupData&0xf + 1
+	if s.GroupThreshold > s.GroupCount {
+		return nil, fmt.Errorf("group threshold exceeds the number of groups")
+	}
+	if s.GroupIndex >= s.GroupCount {
+		return nil, fmt.Errorf("group index %d exceeds the number of groups", s.GroupIndex)
+	}
+
+	valueWords := indices[4 : len(indices)-checksumWords]
+	value := new(big.Int)
+	for _, index := range valueWords {
+		value.Lsh(value, radixBits)
+		value.Or(value, big.NewInt(int64(index)))
+	}
+	size := (radixBits*len(valueWords) - paddingBits) / 8
+	if value.BitLen() > size*8 {
+		return nil, fmt.Errorf("invalid share padding")
+	}
+	s.Value = value.FillBytes(make([]byte, size))
+	return s, nil
+}
+
+// Mnemonic encodes s as words with its checksum.
+func (s *Share) Mnemonic() string {
+	var ext int
+	if s.Extendable {
+		ext = 1
+	}
+	header := int(s.Identifier)<<5 | ext<<4 | s.IterationExponent
+	groupData := s.GroupIndex<<16 | (s.GroupThreshold-1)<<12 | (s.GroupCount-1)<<8 | s.MemberIndex<<4 | (s.MemberThreshold - 1)
+
+	indices := []int{header >> radixBits, header & (radix - 1), groupData >> radixBits, groupData & (radix - 1)}
+
+	valueWords := (len(s.Value)*8 + radixBits - 1) / radixBits
+	value := new(big.Int).SetBytes(s.Value)
+	for i := valueWords - 1; i >= 0; i-- {
+		word := new(big.Int).Rsh(value, uint(i*radixBits))
+		indices = append(indices, int(word.Int64()&(radix-1)))
+	}
+
+	checksum := polymod(checksumCustomization(s.Extendable), append(append([]int(nil), indices...), 0, 0, 0)) ^ 1
+	for i := checksumWords - 1; i >= 0; i-- {
+		indices = append(indices, checksum>>(i*radixBits)&(radix-1))
+	}
+
+	words := make([]string, len(indices))
+	for i, index := range indices {
+		words[i] = wordlist[index]
+	}
+	return strings.Join(words, " ")
+}
+
+// checksumCustomization returns the string the share checksum commits to.
+func checksumCustomization(extendable bool) string {
+	if extendable {
+		return extendableString
+	}
+	return customizationString
+}
+
+// polymod computes the RS1024 checksum of customization followed by values.
+func polymod(customization string, values []int) int {
+	generator := [10]int{0xe0e040, 0x1c1c080, 0x3838100, 0x7070200, 0xe0e0009, 0x1c0c2412, 0x38086c24, 0x3090fc48, 0x21b1f890, 0x3f3f120}
+	chk := 1
+	step := func(v int) {
+		b := chk >> 20
+		chk = (chk&0xfffff)<<10 ^ v
+		for i := 0; i < 10; i++ {
+			if (b>>i)&1 != 0 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	for _, c := range []byte(customization) {
+		step(int(c))
+	}
+	for _, v := range values {
+		step(v)
+	}
+	return chk
+}
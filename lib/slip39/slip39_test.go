@@ -0,0 +1,155 @@
+package slip39
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors from the SLIP-39 specification. All use the passphrase
+// "TREZOR"; an empty secret means the mnemonics must be rejected.
+var vectors = []struct {
+	name      string
+	mnemonics []string
+	secret    string
+}{
+	{
+		name:      "valid mnemonic without sharing (128 bits)",
+		mnemonics: []string{"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard"},
+		secret:    "bb54aac4b89dc868ba37d9cc21b2cece",
+	},
+	{
+		name:      "mnemonic with invalid checksum (128 bits)",
+		mnemonics: []string{"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision kidney"},
+	},
+	{
+		name:      "mnemonic with invalid padding (128 bits)",
+		mnemonics: []string{"duckling enlarge academic academic email result length solution fridge kidney coal piece deal husband erode duke ajar music cargo fitness"},
+	},
+	{
+		name: "basic sharing 2-of-3 (128 bits)",
+		mnemonics: []string{
+			"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed",
+			"shadow pistol academic acid actress prayer class unknown daughter sweater depict flip twice unkind craft early superior advocate guest smoking",
+		},
+		secret: "b43ceb7e57a0ea8766221624d01b0864",
+	},
+	{
+		name:      "basic sharing 2-of-3 with one share (128 bits)",
+		mnemonics: []string{"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed"},
+	},
+	{
+		name: "mnemonics with different identifiers (128 bits)",
+		mnemonics: []string{
+			"adequate smoking academic acid debut wine petition glen cluster slow rhyme slow simple epidemic rumor junk tracks treat olympic tolerate",
+			"adequate stay academic agency agency formal party ting frequent learn upstairs remember smear leaf damage anatomy ladle market hush corner",
+		},
+	},
+	{
+		name: "mnemonics with different iteration exponents (128 bits)",
+		mnemonics: []string{
+			"peasant leaves academic acid desert exact olympic math alive axle trial tackle drug deny decent smear dominant desert bucket remind",
+			"peasant leader academic agency cultural blessing percent network envelope medal junk primary human pumps jacket fragment payroll ticket evoke voice",
+		},
+	},
+	{
+		name: "threshold number of groups and members in each group (128 bits)",
+		mnemonics: []string{
+			"eraser senior beard romp adorn nuclear spill corner cradle style ancient family general leader ambition exchange unusual garlic promise voice",
+			"eraser senior ceramic snake clay various huge numb argue hesitate auction category timber browser greatest hanger petition script leaf pickup",
+			"eraser senior ceramic shaft dynamic become junior wrist silver peasant force math alto coal amazing segment yelp velvet image paces",
+			"eraser senior ceramic round column hawk trust auction smug shame alive greatest sheriff living perfect corner chest sled fumes adequate",
+		},
+		secret: "7c3397a292a5941682d7a4ae2d898d11",
+	},
+	{
+		name: "threshold number of groups but too few members in one (128 bits)",
+		mnemonics: []string{
+			"eraser senior decision shadow artist work morning estate greatest pipeline plan ting petition forget hormone flexible general goat admit surface",
+			"eraser senior beard romp adorn nuclear spill corner cradle style ancient family general leader ambition exchange unusual garlic promise voice",
+		},
+	},
+	{
+		name:      "valid mnemonic without sharing (256 bits)",
+		mnemonics: []string{"theory painting academic academic armed sweater year military elder discuss acne wildlife boring employer fused large satoshi bundle carbon diagnose anatomy hamster leaves tracks paces beyond phantom capital marvel lips brave detect luck"},
+		secret:    "989baf9dcaad5b10ca33dfd8cc75e42477025dce88ae83e75a230086a0e00e92",
+	},
+	{
+		name:      "valid extendable mnemonic without sharing (128 bits)",
+		mnemonics: []string{"testify swimming academic academic column loyalty smear include exotic bedroom exotic wrist lobe cover grief golden smart junior estimate learn"},
+		secret:    "1679b4516e0ee5954351d288a838f45e",
+	},
+}
+
+func TestCombineVectors(t *testing.T) {
+	for _, tt := range vectors {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := Combine(tt.mnemonics, "TREZOR")
+			if tt.secret == "" {
+				if err == nil {
+					t.Fatalf("recovered %x, want an error", secret)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := hex.EncodeToString(secret); got != tt.secret {
+				t.Errorf("recovered %s, want %s", got, tt.secret)
+			}
+		})
+	}
+}
+
+func TestParseShareRoundTrip(t *testing.T) {
+	for _, tt := range vectors {
+		if tt.secret == "" {
+			continue
+		}
+		for _, mnemonic := range tt.mnemonics {
+			s, err := ParseShare(mnemonic)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if got := s.Mnemonic(); got != mnemonic {
+				t.Errorf("%s: re-encoded as %q, want %q", tt.name, got, mnemonic)
+			}
+		}
+	}
+}
+
+func TestParseShareRejectsGroupIndex(t *testing.T) {
+	s := &Share{
+		Identifier:      1,
+		GroupIndex:      2,
+		GroupThreshold:  1,
+		GroupCount:      2,
+		MemberThreshold: 1,
+		Value:           make([]byte, minSecretBytes),
+	}
+	if _, err := ParseShare(s.Mnemonic()); err == nil {
+		t.Fatal("parsed a share whose group index is not below the group count")
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret, _ := hex.DecodeString("bb54aac4b89dc868ba37d9cc21b2cece")
+	groups := []Group{{Threshold: 1, Count: 1}, {Threshold: 2, Count: 3}, {Threshold: 3, Count: 5}}
+	shares, err := Split(secret, "TREZOR", 2, groups, 0)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+
+	mnemonics := append([]string{shares[0][0]}, shares[2][1:4]...)
+	got, err := Combine(mnemonics, "TREZOR")
+	if err != nil {
+		t.Fatalf("combine: %v", err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Errorf("recovered %x, want %x", got, secret)
+	}
+
+	if _, err := Combine(append([]string{shares[0][0]}, shares[1][0]), "TREZOR"); err == nil {
+		t.Error("combined with too few members of the second group")
+	}
+}
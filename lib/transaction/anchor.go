@@ -0,0 +1,145 @@
+package transaction
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/lightninglabs/neutrino"
+)
+
+// Anchor modes describe how the OP_RETURN payload was derived.
+const (
+	// AnchorModeHex anchors raw bytes given as hex.
+	AnchorModeHex = "hex"
+	// AnchorModeSHA256 anchors the SHA-256 digest of a single document.
+	AnchorModeSHA256 = "sha256"
+	// AnchorModeMerkle anchors the merkle root of several document digests.
+	AnchorModeMerkle = "merkle"
+)
+
+// HashDocument returns the hex encoded SHA-256 digest of a file.
+func HashDocument(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %v", path, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// MerkleRoot folds leaves pairwise with SHA-256 until one hash is left. An odd
+// node at any level is paired with itself, as in Bitcoin's block merkle tree.
+func MerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return nil
+	}
+
+	level := leaves
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			sum := sha256.Sum256(append(append([]byte{}, level[i]...), right...))
+			next = append(next, sum[:])
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// BuildAnchorPayload derives the OP_RETURN payload for mode from items. In hex
+// mode items holds one hex string; in sha256 mode one document digest; in
+// merkle mode one or more digests whose merkle root is anchored. It returns
+// the payload and, for merkle anchors, the normalised leaves.
+func BuildAnchorPayload(mode string, items []string) ([]byte, []string, error) {
+	var payload []byte
+	var leaves []string
+
+	switch mode {
+	case AnchorModeHex, AnchorModeSHA256:
+		if len(items) != 1 {
+			return nil, nil, fmt.Errorf("%s anchors take exactly one value, got %d", mode, len(items))
+		}
+		data, err := hex.DecodeString(strings.TrimSpace(items[0]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid hex payload: %v", err)
+		}
+		if mode == AnchorModeSHA256 && len(data) != sha256.Size {
+			return nil, nil, fmt.Errorf("SHA-256 digest must be %d bytes, got %d", sha256.Size, len(data))
+		}
+		payload = data
+
+	case AnchorModeMerkle:
+		if len(items) == 0 {
+			return nil, nil, fmt.Errorf("merkle anchors need at least one digest")
+		}
+		digests := make([][]byte, 0, len(items))
+		for i, item := range items {
+			digest, err := hex.DecodeString(strings.TrimSpace(item))
+			if err != nil {
+				return nil, nil, fmt.Errorf("digest %d: invalid hex: %v", i, err)
+			}
+			if len(digest) != sha256.Size {
+				return nil, nil, fmt.Errorf("digest %d must be %d bytes, got %d", i, sha256.Size, len(digest))
+			}
+			digests = append(digests, digest)
+			leaves = append(leaves, hex.EncodeToString(digest))
+		}
+		payload = MerkleRoot(digests)
+
+	default:
+		return nil, nil, fmt.Errorf("unknown anchor mode %q (use %s, %s or %s)", mode, AnchorModeHex, AnchorModeSHA256, AnchorModeMerkle)
+	}
+
+	if len(payload) == 0 {
+		return nil, nil, fmt.Errorf("anchor payload is empty")
+	}
+	if len(payload) > txscript.MaxDataCarrierSize {
+		return nil, nil, fmt.Errorf("anchor payload is %d bytes, the limit is %d", len(payload), txscript.MaxDataCarrierSize)
+	}
+	return payload, leaves, nil
+}
+
+// CreateAnchorTransaction commits payload to the chain in an OP_RETURN output
+// and records it with the txid. Recipients are optional; without any the
+// transaction only pays the fee and returns the change to the wallet.
+func CreateAnchorTransaction(w *wallet.Wallet, service *neutrino.ChainService, enableRBF bool, mode string, payload []byte, leaves []string, recipients []Recipient, privPass []byte, feeRate int, inputs []wire.OutPoint) (chainhash.Hash, bool, error) {
+	log.Printf("Anchoring %d byte %s payload %x", len(payload), mode, payload)
+
+	txHash, verified, err := CreateBatchTransaction(w, service, enableRBF, recipients, payload, privPass, feeRate, inputs)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	anchor := &walletstatedb.Anchor{
+		TxID:    txHash.String(),
+		Mode:    mode,
+		Payload: hex.EncodeToString(payload),
+		Leaves:  leaves,
+	}
+	if err := walletstatedb.SaveAnchor(anchor); err != nil {
+		// The transaction is already out, so only log the bookkeeping failure
+		log.Printf("Failed to record anchor for %s: %v", anchor.TxID, err)
+	}
+
+	log.Printf("Payload anchored in transaction %s", anchor.TxID)
+	return txHash, verified, nil
+}
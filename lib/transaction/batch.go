@@ -0,0 +1,222 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/lightninglabs/neutrino"
+)
+
+// Recipient is a single payment inside a batch.
+type Recipient struct {
+	Address string `json:"address"`
+	Amount  int64  `json:"amount"` // satoshis
+}
+
+// BuildPaymentOutputs turns recipients and an optional OP_RETURN payload into
+// transaction outputs, rejecting dust amounts and oversized payloads.
+func BuildPaymentOutputs(w *wallet.Wallet, recipients []Recipient, opReturn []byte) ([]*wire.TxOut, error) {
+	if len(recipients) == 0 && len(opReturn) == 0 {
+		return nil, fmt.Errorf("no recipients given")
+	}
+
+	var outputs []*wire.TxOut
+	for i, recipient := range recipients {
+		if btcutil.Amount(recipient.Amount) < DustLimit() {
+			return nil, fmt.Errorf("recipient %d: amount %d is below the dust limit of %d satoshis", i, recipient.Amount, DustLimit())
+		}
+		addr, err := btcutil.DecodeAddress(recipient.Address, w.ChainParams())
+		if err != nil {
+			return nil, fmt.Errorf("recipient %d: failed to decode address: %v", i, err)
+		}
+		if !addr.IsForNet(w.ChainParams()) {
+			return nil, fmt.Errorf("recipient %d: address %s is not for %s", i, recipient.Address, w.ChainParams().Name)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %d: failed to create output script: %v", i, err)
+		}
+		outputs = append(outputs, wire.NewTxOut(recipient.Amount, pkScript))
+	}
+
+	if len(opReturn) > 0 {
+		if len(opReturn) > txscript.MaxDataCarrierSize {
+			return nil, fmt.Errorf("OP_RETURN payload is %d bytes, the limit is %d", len(opReturn), txscript.MaxDataCarrierSize)
+		}
+		opReturnScript, err := txscript.NullDataScript(opReturn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OP_RETURN script: %v", err)
+		}
+		outputs = append(outputs, wire.NewTxOut(0, opReturnScript))
+	}
+
+	return outputs, nil
+}
+
+// ParseRecipientsFile reads batch recipients from a JSON array of
+// {"address", "amount"} objects or from a CSV file with address,amount rows.
+// A CSV header row is skipped.
+func ParseRecipientsFile(path string) ([]Recipient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipients file: %v", err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var recipients []Recipient
+		if err := json.Unmarshal(trimmed, &recipients); err != nil {
+			return nil, fmt.Errorf("failed to parse recipients JSON: %v", err)
+		}
+		return recipients, nil
+	}
+
+	reader := csv.NewReader(bytes.NewReader(trimmed))
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	var recipients []Recipient
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse recipients CSV: %v", err)
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected address,amount", line)
+		}
+		amount, err := strconv.ParseInt(strings.TrimSpace(record[1]), 10, 64)
+		if err != nil {
+			if line == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("line %d: invalid amount: %v", line, err)
+		}
+		recipients = append(recipients, Recipient{Address: strings.TrimSpace(record[0]), Amount: amount})
+	}
+
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("recipients file %s is empty", path)
+	}
+	return recipients, nil
+}
+
+// DecodeOpReturnHex decodes an optional hex encoded OP_RETURN payload.
+func DecodeOpReturnHex(payload string) ([]byte, error) {
+	if payload == "" {
+		return nil, nil
+	}
+	data, err := hex.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OP_RETURN hex: %v", err)
+	}
+	return data, nil
+}
+
+// CreateBatchTransaction pays every recipient from a single transaction with
+// at most one change output. Non-empty inputs replace automatic selection.
+func CreateBatchTransaction(w *wallet.Wallet, service *neutrino.ChainService, enableRBF bool, recipients []Recipient, opReturn []byte, privPass []byte, feeRate int, inputs []wire.OutPoint) (chainhash.Hash, bool, error) {
+	log.Printf("Starting batch transaction creation for %d recipients.", len(recipients))
+	// Reset locked outpoints
+	w.ResetLockedOutpoints()
+
+	// Unlock wallet
+	log.Printf("Unlocking wallet.")
+	err := unlockWallet(w, privPass)
+	if err != nil {
+		log.Printf("Failed to unlock wallet: %v", err)
+		return chainhash.Hash{}, false, err
+	}
+
+	outputs, err := BuildPaymentOutputs(w, recipients, opReturn)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	selection, err := selectSpendCoins(w, outputs, int64(feeRate), inputs)
+	if err != nil {
+		log.Printf("Coin selection failed: %v", err)
+		return chainhash.Hash{}, false, fmt.Errorf("coin selection failed: %v", err)
+	}
+
+	tx, err := buildUnsignedTransaction(w, enableRBF, outputs, selection)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	if err := signTransactionInputs(w, tx, selection.Inputs); err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	log.Printf("Batch transaction created successfully. Details:")
+	log.Printf("  TxID: %s", tx.TxHash().String())
+	log.Printf("  Recipients: %d", len(recipients))
+	log.Printf("  Total paid: %d satoshis", selection.Total-selection.Fee-selection.Change)
+	log.Printf("  Fee: %d satoshis", selection.Fee)
+	log.Printf("  Change amount: %d satoshis", selection.Change)
+	log.Printf("  Estimated size: %d vBytes", selection.VBytes)
+	log.Printf("  Number of inputs: %d", len(tx.TxIn))
+	log.Printf("  Number of outputs: %d", len(tx.TxOut))
+
+	// Save the transaction in the database
+	_, err = walletstatedb.SaveTransactionToDB(tx)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	txHash, verified, err := broadcastAndVerifyTransaction(tx, service)
+	if err != nil {
+		// Release the output we tried to spend
+		releaseErr := w.ReleaseOutput(wtxmgr.LockID(tx.TxHash()), tx.TxIn[0].PreviousOutPoint)
+		if releaseErr != nil {
+			log.Printf("Failed to release output: %v", releaseErr)
+		}
+		releaseSpend(tx)
+		return chainhash.Hash{}, false, fmt.Errorf("failed to broadcast and verify transaction: %v", err)
+	}
+
+	log.Println("Batch transaction broadcast and verified successfully.")
+	return txHash, verified, nil
+}
+
+// HttpCalculateBatchTransactionSize runs coin selection for a batch and
+// returns the estimated size of the signed transaction in vbytes.
+func HttpCalculateBatchTransactionSize(w *wallet.Wallet, recipients []Recipient, opReturn []byte, feeRate int) (int, error) {
+	selection, err := EstimateBatchTransaction(w, recipients, opReturn, feeRate)
+	if err != nil {
+		return 0, err
+	}
+	return selection.VBytes, nil
+}
+
+// EstimateBatchTransaction returns the coin selection a batch would use,
+// including its fee and change, without building the transaction.
+func EstimateBatchTransaction(w *wallet.Wallet, recipients []Recipient, opReturn []byte, feeRate int) (*CoinSelection, error) {
+	outputs, err := BuildPaymentOutputs(w, recipients, opReturn)
+	if err != nil {
+		return nil, err
+	}
+
+	selection, err := selectWalletCoins(w, outputs, int64(feeRate))
+	if err != nil {
+		return nil, fmt.Errorf("coin selection failed: %v", err)
+	}
+	return selection, nil
+}
@@ -92,6 +92,7 @@ func broadcastAndVerifyTransaction(tx *wire.MsgTx, service *neutrino.ChainServic
 	err := BroadcastTransactionMultiAPI(tx)
 	if err == nil {
 		log.Printf("Transaction broadcast successfully via API. TxID: %s", tx.TxHash().String())
+		spendBroadcast(tx)
 		return tx.TxHash(), true, nil
 	}
 
@@ -113,6 +114,7 @@ func broadcastAndVerifyTransaction(tx *wire.MsgTx, service *neutrino.ChainServic
 
 		if inMempool {
 			log.Printf("Transaction successfully broadcast and found in mempool. TxID: %s", tx.TxHash().String())
+			spendBroadcast(tx)
 			return tx.TxHash(), true, nil
 		}
 
@@ -0,0 +1,186 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/checksum0/go-electrum/electrum"
+	"github.com/lightninglabs/neutrino"
+)
+
+// IncrementalRelayFeeRate is the sat/vB a replacement must add on top of the
+// fee it replaces, per BIP125 rule 4.
+const IncrementalRelayFeeRate = 1
+
+// ErrRBFNotSignaled is returned when a transaction cannot be replaced because
+// none of its inputs opt in to BIP125.
+var ErrRBFNotSignaled = errors.New("transaction does not signal replace-by-fee (BIP125)")
+
+// SignalsRBF reports whether tx opts in to replacement under BIP125.
+func SignalsRBF(tx *wire.MsgTx) bool {
+	for _, txIn := range tx.TxIn {
+		if txIn.Sequence < wire.MaxTxInSequenceNum-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplacementFee returns the smallest fee a replacement of replacementVBytes
+// may pay: at least the original fee plus the incremental relay fee for its
+// own size (BIP125 rule 4), and a strictly higher fee rate than the original.
+// A higher requested fee rate wins.
+func ReplacementFee(originalFee btcutil.Amount, originalVBytes, replacementVBytes int, feeRate int64) btcutil.Amount {
+	fee := originalFee + btcutil.Amount(int64(replacementVBytes)*IncrementalRelayFeeRate)
+
+	rateFee := btcutil.Amount(int64(originalFee)*int64(replacementVBytes)/int64(originalVBytes) + 1)
+	if rateFee > fee {
+		fee = rateFee
+	}
+
+	if requested := btcutil.Amount(int64(replacementVBytes) * feeRate); requested > fee {
+		fee = requested
+	}
+	return fee
+}
+
+// spentOutputs looks up the outputs spent by tx and returns them in the form
+// the signer expects. Every input must belong to the wallet.
+func spentOutputs(w *wallet.Wallet, tx *wire.MsgTx, electrumClient *electrum.Client) ([]*btcjson.ListUnspentResult, error) {
+	var utxos []*btcjson.ListUnspentResult
+	for i, txIn := range tx.TxIn {
+		prevTx, err := fetchTransaction(txIn.PreviousOutPoint.Hash.String(), electrumClient)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch previous transaction for input %d: %v", i, err)
+		}
+		if int(txIn.PreviousOutPoint.Index) >= len(prevTx.TxOut) {
+			return nil, fmt.Errorf("input %d references missing output %s", i, txIn.PreviousOutPoint)
+		}
+		prevOut := prevTx.TxOut[txIn.PreviousOutPoint.Index]
+
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(prevOut.PkScript, w.ChainParams())
+		if err != nil || len(addrs) == 0 {
+			return nil, fmt.Errorf("failed to extract address for input %d", i)
+		}
+		if _, err := w.AddressInfo(addrs[0]); err != nil {
+			return nil, fmt.Errorf("input %d (%s) does not belong to this wallet", i, addrs[0])
+		}
+
+		utxos = append(utxos, &btcjson.ListUnspentResult{
+			TxID:         txIn.PreviousOutPoint.Hash.String(),
+			Vout:         txIn.PreviousOutPoint.Index,
+			Address:      addrs[0].EncodeAddress(),
+			ScriptPubKey: hex.EncodeToString(prevOut.PkScript),
+			Amount:       btcutil.Amount(prevOut.Value).ToBTC(),
+		})
+	}
+	return utxos, nil
+}
+
+// CancelTransaction replaces an unconfirmed transaction with one spending the
+// same inputs back to a fresh change address, so the original payment never
+// confirms. The replacement pays at least the BIP125 minimum, or feeRate if
+// that is higher; pass 0 to use the minimum.
+func CancelTransaction(w *wallet.Wallet, service *neutrino.ChainService, originalTxID string, feeRate int64, electrumClient *electrum.Client, privPass []byte) (chainhash.Hash, bool, error) {
+	log.Printf("Starting cancellation of transaction %s", originalTxID)
+
+	// Unlock wallet
+	log.Printf("Unlocking wallet.")
+	err := unlockWallet(w, privPass)
+	if err != nil {
+		log.Printf("Failed to unlock wallet: %v", err)
+		return chainhash.Hash{}, false, err
+	}
+
+	originalTx, err := fetchTransaction(originalTxID, electrumClient)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+	if !SignalsRBF(originalTx) {
+		return chainhash.Hash{}, false, fmt.Errorf("cannot cancel %s: %w", originalTxID, ErrRBFNotSignaled)
+	}
+
+	inputs, err := spentOutputs(w, originalTx, electrumClient)
+	if err != nil {
+		return chainhash.Hash{}, false, fmt.Errorf("cannot cancel transaction: %v", err)
+	}
+
+	var totalIn, totalOut int64
+	var prevScripts [][]byte
+	for _, utxo := range inputs {
+		totalIn += int64(UTXOAmount(utxo))
+		scriptPubKey, err := decodeScriptPubKey(utxo.ScriptPubKey)
+		if err != nil {
+			return chainhash.Hash{}, false, err
+		}
+		prevScripts = append(prevScripts, scriptPubKey)
+	}
+	for _, txOut := range originalTx.TxOut {
+		totalOut += txOut.Value
+	}
+	originalFee := btcutil.Amount(totalIn - totalOut)
+	originalVBytes := TransactionVSize(originalTx)
+
+	changeAddr, err := getChangeAddress(w)
+	if err != nil {
+		return chainhash.Hash{}, false, fmt.Errorf("failed to get change address: %v", err)
+	}
+	changePkScript, err := txscript.PayToAddrScript(changeAddr)
+	if err != nil {
+		return chainhash.Hash{}, false, fmt.Errorf("failed to create change script: %v", err)
+	}
+
+	// Spend the same inputs to ourselves
+	cancelTx := wire.NewMsgTx(wire.TxVersion)
+	for _, txIn := range originalTx.TxIn {
+		newTxIn := wire.NewTxIn(&txIn.PreviousOutPoint, nil, nil)
+		newTxIn.Sequence = RBFSequenceNumber
+		cancelTx.AddTxIn(newTxIn)
+	}
+	cancelTx.AddTxOut(wire.NewTxOut(0, changePkScript))
+
+	txSize := EstimateTxVBytes(cancelTx, prevScripts)
+	newFee := ReplacementFee(originalFee, originalVBytes, txSize, feeRate)
+	refund := btcutil.Amount(totalIn) - newFee
+	if refund < DustLimit() {
+		return chainhash.Hash{}, false, fmt.Errorf("inputs worth %d satoshis cannot cover a replacement fee of %d", totalIn, newFee)
+	}
+	cancelTx.TxOut[0].Value = int64(refund)
+
+	inputs = applyPrivacyPolicy(w, cancelTx, inputs)
+
+	if err := signTransactionInputs(w, cancelTx, inputs); err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	log.Printf("Cancellation transaction created successfully. Details:")
+	log.Printf("  TxID: %s", cancelTx.TxHash().String())
+	log.Printf("  Replaces: %s", originalTxID)
+	log.Printf("  Original fee: %d satoshis (%d vBytes)", originalFee, originalVBytes)
+	log.Printf("  New fee: %d satoshis (%d vBytes)", newFee, txSize)
+	log.Printf("  Returned to %s: %d satoshis", changeAddr.EncodeAddress(), refund)
+
+	// Save the transaction in the database
+	_, err = walletstatedb.SaveTransactionToDB(cancelTx)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	txHash, verified, err := broadcastAndVerifyTransaction(cancelTx, service)
+	if err != nil {
+		releaseSpend(cancelTx)
+		return chainhash.Hash{}, false, fmt.Errorf("failed to broadcast and verify cancellation transaction: %v", err)
+	}
+
+	log.Printf("Cancellation transaction successfully broadcast. New TxID: %s", txHash.String())
+	return txHash, verified, nil
+}
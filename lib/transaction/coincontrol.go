@@ -0,0 +1,169 @@
+package transaction
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+)
+
+// CoinSelectionManual is the strategy reported when the caller picked the
+// inputs explicitly.
+const CoinSelectionManual = "manual"
+
+// UTXOInfo describes a wallet UTXO for coin control.
+type UTXOInfo struct {
+	Outpoint      string `json:"outpoint"`
+	Value         int64  `json:"value"` // satoshis
+	Address       string `json:"address"`
+	Confirmations int64  `json:"confirmations"`
+	Label         string `json:"label,omitempty"`
+	Frozen        bool   `json:"frozen"`
+}
+
+// ListWalletUTXOs returns every wallet UTXO, including unconfirmed ones,
+// with its label and frozen flag. Largest values come first.
+func ListWalletUTXOs(w *wallet.Wallet) ([]UTXOInfo, error) {
+	utxos, err := w.ListUnspent(0, 9999999, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unspent outputs: %v", err)
+	}
+
+	tags, err := walletstatedb.GetOutpointTags()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]UTXOInfo, 0, len(utxos))
+	for _, utxo := range utxos {
+		outpoint := utxoOutpoint(utxo)
+		tag := tags[outpoint]
+		infos = append(infos, UTXOInfo{
+			Outpoint:      outpoint,
+			Value:         int64(UTXOAmount(utxo)),
+			Address:       utxo.Address,
+			Confirmations: utxo.Confirmations,
+			Label:         tag.Label,
+			Frozen:        tag.Frozen,
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Value > infos[j].Value
+	})
+	return infos, nil
+}
+
+// FreezeOutpoints marks outpoints as frozen, or unfreezes them, so automatic
+// coin selection leaves them alone.
+func FreezeOutpoints(outpoints []wire.OutPoint, frozen bool) error {
+	for _, op := range outpoints {
+		if err := walletstatedb.SetOutpointFrozen(op.String(), frozen); err != nil {
+			return fmt.Errorf("failed to update %s: %v", op, err)
+		}
+	}
+	return nil
+}
+
+// LabelOutpoint attaches a free-form label to an outpoint.
+func LabelOutpoint(outpoint wire.OutPoint, label string) error {
+	if err := walletstatedb.SetOutpointLabel(outpoint.String(), label); err != nil {
+		return fmt.Errorf("failed to label %s: %v", outpoint, err)
+	}
+	return nil
+}
+
+func utxoOutpoint(utxo *btcjson.ListUnspentResult) string {
+	return fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)
+}
+
+// removeFrozenUTXOs drops every UTXO the user has frozen.
+func removeFrozenUTXOs(utxos []*btcjson.ListUnspentResult) ([]*btcjson.ListUnspentResult, error) {
+	frozen, err := walletstatedb.GetFrozenOutpoints()
+	if err != nil {
+		return nil, err
+	}
+	if len(frozen) == 0 {
+		return utxos, nil
+	}
+
+	spendable := make([]*btcjson.ListUnspentResult, 0, len(utxos))
+	for _, utxo := range utxos {
+		if frozen[utxoOutpoint(utxo)] {
+			continue
+		}
+		spendable = append(spendable, utxo)
+	}
+	log.Printf("Skipping %d frozen unspent outputs.", len(utxos)-len(spendable))
+	return spendable, nil
+}
+
+// listSpendableUTXOs returns the confirmed wallet UTXOs that are not frozen.
+func listSpendableUTXOs(w *wallet.Wallet) ([]*btcjson.ListUnspentResult, error) {
+	utxos, err := w.ListUnspent(1, 9999999, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unspent outputs: %v", err)
+	}
+	return removeFrozenUTXOs(utxos)
+}
+
+// listRequestedUTXOs returns the confirmed wallet UTXOs matching outpoints.
+// Requesting a frozen or unknown outpoint is an error.
+func listRequestedUTXOs(w *wallet.Wallet, outpoints []wire.OutPoint) ([]*btcjson.ListUnspentResult, error) {
+	utxos, err := w.ListUnspent(1, 9999999, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unspent outputs: %v", err)
+	}
+
+	frozen, err := walletstatedb.GetFrozenOutpoints()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range outpoints {
+		if frozen[op.String()] {
+			return nil, fmt.Errorf("outpoint %s is frozen", op)
+		}
+	}
+
+	return filterUTXOsByOutpoint(utxos, outpoints)
+}
+
+// selectManualCoins funds outputs from exactly the given UTXOs, working out
+// fee and change the same way the automatic selectors do.
+func selectManualCoins(utxos []*btcjson.ListUnspentResult, outputs []*wire.TxOut, feeRate int64) (*CoinSelection, error) {
+	params := NewSelectionParams(outputs, feeRate)
+
+	chosen := make([]candidate, 0, len(utxos))
+	for _, utxo := range utxos {
+		vbytes := utxoInputVBytes(utxo)
+		value := UTXOAmount(utxo)
+		chosen = append(chosen, candidate{
+			utxo:      utxo,
+			value:     value,
+			effective: value - btcutil.Amount(int64(vbytes)*feeRate),
+			vbytes:    vbytes,
+		})
+	}
+
+	return finalizeSelection(chosen, params, CoinSelectionManual)
+}
+
+// selectSpendCoins funds outputs from the given inputs when any are listed,
+// and from automatic coin selection otherwise.
+func selectSpendCoins(w *wallet.Wallet, outputs []*wire.TxOut, feeRate int64, inputs []wire.OutPoint) (*CoinSelection, error) {
+	if len(inputs) == 0 {
+		return selectWalletCoins(w, outputs, feeRate)
+	}
+
+	utxos, err := listRequestedUTXOs(w, inputs)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Using %d manually selected inputs.", len(utxos))
+	return selectManualCoins(utxos, outputs, feeRate)
+}
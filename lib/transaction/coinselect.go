@@ -0,0 +1,524 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/spf13/viper"
+	"golang.org/x/exp/rand"
+)
+
+const (
+	// Coin selection strategies accepted by the coin_selection config key.
+	CoinSelectionAuto         = "auto"
+	CoinSelectionBnB          = "bnb"
+	CoinSelectionKnapsack     = "knapsack"
+	CoinSelectionLargestFirst = "largest-first"
+
+	// Virtual sizes used when estimating fees before signing.
+	TxOverheadVBytes      = 11 // version, locktime, counts and segwit marker
+	P2WPKHInputVBytes     = 68
+	P2PKHInputVBytes      = 148
+	P2SHP2WPKHInputVBytes = 91
+	P2TRInputVBytes       = 58
+	P2WPKHOutputVBytes    = 31
+	P2TROutputVBytes      = 43
+	P2WSHOutputVBytes     = 43
+
+	bnbMaxTries        = 100000
+	knapsackIterations = 1000
+)
+
+// ErrNoChangelessSolution is returned by the branch-and-bound selector when no
+// input set lands inside the changeless window.
+var ErrNoChangelessSolution = errors.New("no changeless input set found")
+
+// CoinSelector picks a set of UTXOs that funds a spend at a given fee rate.
+type CoinSelector interface {
+	Name() string
+	Select(utxos []*btcjson.ListUnspentResult, params SelectionParams) (*CoinSelection, error)
+}
+
+// SelectionParams describes the spend a selector has to fund.
+type SelectionParams struct {
+	Target       btcutil.Amount // sum of the non-change outputs
+	FeeRate      int64          // sat/vB
+	BaseVBytes   int            // tx overhead plus the non-change outputs
+	ChangeVBytes int            // size of the change output, if one is added
+	DustLimit    btcutil.Amount
+
+	// ChangeSpendVBytes is the size of the input that later spends the
+	// change. Zero means P2WPKH.
+	ChangeSpendVBytes int
+}
+
+// CoinSelection is the result of a selector run.
+type CoinSelection struct {
+	Inputs   []*btcjson.ListUnspentResult
+	Total    btcutil.Amount
+	Fee      btcutil.Amount
+	Change   btcutil.Amount // zero when the spend is changeless
+	VBytes   int
+	Strategy string
+}
+
+type candidate struct {
+	utxo      *btcjson.ListUnspentResult
+	value     btcutil.Amount
+	effective btcutil.Amount
+	vbytes    int
+}
+
+// NewCoinSelector returns the selector for the given strategy name.
+func NewCoinSelector(strategy string) (CoinSelector, error) {
+	switch strategy {
+	case "", CoinSelectionAuto:
+		return &AutoSelector{}, nil
+	case CoinSelectionBnB:
+		return &BranchAndBoundSelector{MaxTries: bnbMaxTries}, nil
+	case CoinSelectionKnapsack:
+		return &KnapsackSelector{Iterations: knapsackIterations}, nil
+	case CoinSelectionLargestFirst:
+		return &LargestFirstSelector{}, nil
+	default:
+		return nil, fmt.Errorf("unknown coin selection strategy: %s", strategy)
+	}
+}
+
+// DustLimit returns the configured dust limit, falling back to DustThreshold.
+func DustLimit() btcutil.Amount {
+	if limit := viper.GetInt64("dust_limit"); limit > 0 {
+		return btcutil.Amount(limit)
+	}
+	return DustThreshold
+}
+
+// UTXOAmount converts a ListUnspent amount in BTC to satoshis.
+func UTXOAmount(utxo *btcjson.ListUnspentResult) btcutil.Amount {
+	amount, err := btcutil.NewAmount(utxo.Amount)
+	if err != nil {
+		return btcutil.Amount(utxo.Amount * btcutil.SatoshiPerBitcoin)
+	}
+	return amount
+}
+
+// EstimateInputVBytes returns the signed size of an input spending pkScript.
+func EstimateInputVBytes(pkScript []byte) int {
+	switch txscript.GetScriptClass(pkScript) {
+	case txscript.PubKeyHashTy:
+		return P2PKHInputVBytes
+	case txscript.ScriptHashTy:
+		return P2SHP2WPKHInputVBytes
+	case txscript.WitnessV1TaprootTy:
+		return P2TRInputVBytes
+	case txscript.WitnessV0ScriptHashTy:
+		if m := multisig.Active(); m != nil {
+			return MultisigInputVBytes(m.Threshold, len(m.Keys))
+		}
+		return P2WPKHInputVBytes
+	default:
+		return P2WPKHInputVBytes
+	}
+}
+
+// MultisigInputVBytes returns the signed size of a P2WSH input spending a
+// threshold-of-keys multisig script: the outpoint, empty script sig and
+// sequence, plus a witness of the CHECKMULTISIG dummy, threshold signatures
+// and the script itself.
+func MultisigInputVBytes(threshold, keys int) int {
+	script := 3 + 34*keys
+	witness := 1 + 1 + 73*threshold + wire.VarIntSerializeSize(uint64(script)) + script
+	weight := 41*blockchain.WitnessScaleFactor + witness
+	return (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+}
+
+// EstimateOutputVBytes returns the serialized size of an output paying pkScript.
+func EstimateOutputVBytes(pkScript []byte) int {
+	return wire.NewTxOut(0, pkScript).SerializeSize()
+}
+
+// EstimateTxVBytes estimates the signed size of tx from the scripts of the
+// outputs its inputs spend.
+func EstimateTxVBytes(tx *wire.MsgTx, prevScripts [][]byte) int {
+	vbytes := TxOverheadVBytes
+	for _, pkScript := range prevScripts {
+		vbytes += EstimateInputVBytes(pkScript)
+	}
+	for _, out := range tx.TxOut {
+		vbytes += EstimateOutputVBytes(out.PkScript)
+	}
+	return vbytes
+}
+
+func utxoInputVBytes(utxo *btcjson.ListUnspentResult) int {
+	pkScript, err := hex.DecodeString(utxo.ScriptPubKey)
+	if err != nil {
+		return P2WPKHInputVBytes
+	}
+	return EstimateInputVBytes(pkScript)
+}
+
+// buildCandidates computes effective values and drops UTXOs that cost more to
+// spend than they are worth at the requested fee rate.
+func buildCandidates(utxos []*btcjson.ListUnspentResult, feeRate int64) []candidate {
+	var candidates []candidate
+	for _, utxo := range utxos {
+		vbytes := utxoInputVBytes(utxo)
+		value := UTXOAmount(utxo)
+		effective := value - btcutil.Amount(int64(vbytes)*feeRate)
+		if effective <= 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{utxo: utxo, value: value, effective: effective, vbytes: vbytes})
+	}
+	return candidates
+}
+
+// finalizeSelection works out fee and change for a chosen input set. Change
+// below the dust limit is dropped and goes to the fee instead.
+func finalizeSelection(chosen []candidate, params SelectionParams, strategy string) (*CoinSelection, error) {
+	selection := &CoinSelection{Strategy: strategy}
+	inputVBytes := 0
+	for _, c := range chosen {
+		selection.Inputs = append(selection.Inputs, c.utxo)
+		selection.Total += c.value
+		inputVBytes += c.vbytes
+	}
+
+	changelessVBytes := params.BaseVBytes + inputVBytes
+	changelessFee := btcutil.Amount(int64(changelessVBytes) * params.FeeRate)
+	if selection.Total < params.Target+changelessFee {
+		return nil, fmt.Errorf("insufficient funds: selected %d satoshis, need %d", selection.Total, params.Target+changelessFee)
+	}
+
+	withChangeVBytes := changelessVBytes + params.ChangeVBytes
+	withChangeFee := btcutil.Amount(int64(withChangeVBytes) * params.FeeRate)
+	change := selection.Total - params.Target - withChangeFee
+	if params.ChangeVBytes > 0 && change >= params.DustLimit {
+		selection.Change = change
+		selection.Fee = withChangeFee
+		selection.VBytes = withChangeVBytes
+	} else {
+		selection.Fee = selection.Total - params.Target
+		selection.VBytes = changelessVBytes
+	}
+	return selection, nil
+}
+
+// BranchAndBoundSelector searches for an input set whose effective value
+// lands between the target and the target plus the cost of change, so the
+// transaction needs no change output.
+type BranchAndBoundSelector struct {
+	MaxTries int
+}
+
+func (s *BranchAndBoundSelector) Name() string { return CoinSelectionBnB }
+
+func (s *BranchAndBoundSelector) Select(utxos []*btcjson.ListUnspentResult, params SelectionParams) (*CoinSelection, error) {
+	candidates := buildCandidates(utxos, params.FeeRate)
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].effective > candidates[j].effective
+	})
+
+	target := params.Target + btcutil.Amount(int64(params.BaseVBytes)*params.FeeRate)
+	changeSpendVBytes := params.ChangeSpendVBytes
+	if changeSpendVBytes == 0 {
+		changeSpendVBytes = P2WPKHInputVBytes
+	}
+	costOfChange := btcutil.Amount(int64(params.ChangeVBytes+changeSpendVBytes) * params.FeeRate)
+
+	var available btcutil.Amount
+	for _, c := range candidates {
+		available += c.effective
+	}
+	if available < target {
+		return nil, fmt.Errorf("insufficient funds: have %d satoshis effective, need %d", available, target)
+	}
+
+	maxTries := s.MaxTries
+	if maxTries <= 0 {
+		maxTries = bnbMaxTries
+	}
+
+	var (
+		tries     int
+		current   = make([]bool, len(candidates))
+		best      []bool
+		bestWaste btcutil.Amount = -1
+	)
+
+	var search func(depth int, value, remaining btcutil.Amount)
+	search = func(depth int, value, remaining btcutil.Amount) {
+		tries++
+		if tries > maxTries {
+			return
+		}
+		if value > target+costOfChange || value+remaining < target {
+			return
+		}
+		if value >= target {
+			waste := value - target
+			if bestWaste < 0 || waste < bestWaste {
+				bestWaste = waste
+				best = append(best[:0], current...)
+			}
+			return
+		}
+		if depth == len(candidates) {
+			return
+		}
+
+		remaining -= candidates[depth].effective
+
+		// Including an input equal to the one just excluded explores the same
+		// subsets again, so only the exclusion branch is taken.
+		if depth == 0 || current[depth-1] || candidates[depth].effective != candidates[depth-1].effective {
+			current[depth] = true
+			search(depth+1, value+candidates[depth].effective, remaining)
+			current[depth] = false
+		}
+		search(depth+1, value, remaining)
+	}
+	search(0, 0, available)
+
+	if best == nil {
+		return nil, ErrNoChangelessSolution
+	}
+
+	var chosen []candidate
+	for i, selected := range best {
+		if selected {
+			chosen = append(chosen, candidates[i])
+		}
+	}
+	return finalizeSelection(chosen, params, s.Name())
+}
+
+// KnapsackSelector is the stochastic subset-sum approximation used by Bitcoin
+// Core before branch-and-bound. It aims for the target plus a change output
+// that clears the dust limit.
+type KnapsackSelector struct {
+	Iterations int
+}
+
+func (s *KnapsackSelector) Name() string { return CoinSelectionKnapsack }
+
+func (s *KnapsackSelector) Select(utxos []*btcjson.ListUnspentResult, params SelectionParams) (*CoinSelection, error) {
+	candidates := buildCandidates(utxos, params.FeeRate)
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	target := params.Target + btcutil.Amount(int64(params.BaseVBytes)*params.FeeRate)
+	targetWithChange := target + btcutil.Amount(int64(params.ChangeVBytes)*params.FeeRate) + params.DustLimit
+
+	var (
+		lowestLarger *candidate
+		lower        []candidate
+		totalLower   btcutil.Amount
+	)
+	for i := range candidates {
+		c := candidates[i]
+		switch {
+		case c.effective == target:
+			return finalizeSelection([]candidate{c}, params, s.Name())
+		case c.effective < targetWithChange:
+			lower = append(lower, c)
+			totalLower += c.effective
+		case lowestLarger == nil || c.effective < lowestLarger.effective:
+			lowestLarger = &candidates[i]
+		}
+	}
+
+	if totalLower == target || totalLower == targetWithChange {
+		return finalizeSelection(lower, params, s.Name())
+	}
+
+	if totalLower < targetWithChange {
+		if lowestLarger != nil {
+			return finalizeSelection([]candidate{*lowestLarger}, params, s.Name())
+		}
+		if totalLower >= target {
+			return finalizeSelection(lower, params, s.Name())
+		}
+		return nil, fmt.Errorf("insufficient funds: have %d satoshis effective, need %d", totalLower, target)
+	}
+
+	sort.Slice(lower, func(i, j int) bool {
+		return lower[i].effective > lower[j].effective
+	})
+
+	iterations := s.Iterations
+	if iterations <= 0 {
+		iterations = knapsackIterations
+	}
+	best, bestValue := approximateBestSubset(lower, totalLower, targetWithChange, iterations)
+
+	if lowestLarger != nil && ((bestValue != target && bestValue < targetWithChange) || lowestLarger.effective <= bestValue) {
+		return finalizeSelection([]candidate{*lowestLarger}, params, s.Name())
+	}
+
+	var chosen []candidate
+	for i, selected := range best {
+		if selected {
+			chosen = append(chosen, lower[i])
+		}
+	}
+	return finalizeSelection(chosen, params, s.Name())
+}
+
+func approximateBestSubset(candidates []candidate, total, target btcutil.Amount, iterations int) ([]bool, btcutil.Amount) {
+	best := make([]bool, len(candidates))
+	for i := range best {
+		best[i] = true
+	}
+	bestValue := total
+
+	included := make([]bool, len(candidates))
+	for rep := 0; rep < iterations && bestValue != target; rep++ {
+		for i := range included {
+			included[i] = false
+		}
+		var value btcutil.Amount
+		reachedTarget := false
+		for pass := 0; pass < 2 && !reachedTarget; pass++ {
+			for i := range candidates {
+				// First pass picks inputs at random, the second fills in
+				// whatever the first pass left out.
+				include := rand.Intn(2) == 0
+				if pass == 1 {
+					include = !included[i]
+				}
+				if !include || included[i] {
+					continue
+				}
+				value += candidates[i].effective
+				included[i] = true
+				if value >= target {
+					reachedTarget = true
+					if value < bestValue {
+						bestValue = value
+						copy(best, included)
+					}
+					value -= candidates[i].effective
+					included[i] = false
+				}
+			}
+		}
+	}
+	return best, bestValue
+}
+
+// LargestFirstSelector spends the biggest UTXOs first until the spend and its
+// fee are covered.
+type LargestFirstSelector struct{}
+
+func (s *LargestFirstSelector) Name() string { return CoinSelectionLargestFirst }
+
+func (s *LargestFirstSelector) Select(utxos []*btcjson.ListUnspentResult, params SelectionParams) (*CoinSelection, error) {
+	candidates := buildCandidates(utxos, params.FeeRate)
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].value > candidates[j].value
+	})
+
+	var (
+		chosen      []candidate
+		total       btcutil.Amount
+		inputVBytes int
+	)
+	for _, c := range candidates {
+		chosen = append(chosen, c)
+		total += c.value
+		inputVBytes += c.vbytes
+
+		fee := btcutil.Amount(int64(params.BaseVBytes+inputVBytes) * params.FeeRate)
+		if total >= params.Target+fee {
+			return finalizeSelection(chosen, params, s.Name())
+		}
+	}
+	return nil, fmt.Errorf("insufficient funds: have %d satoshis, need at least %d", total, params.Target)
+}
+
+// AutoSelector tries branch-and-bound for a changeless spend and falls back to
+// knapsack when no exact match exists.
+type AutoSelector struct{}
+
+func (s *AutoSelector) Name() string { return CoinSelectionAuto }
+
+func (s *AutoSelector) Select(utxos []*btcjson.ListUnspentResult, params SelectionParams) (*CoinSelection, error) {
+	bnb := &BranchAndBoundSelector{MaxTries: bnbMaxTries}
+	selection, err := bnb.Select(utxos, params)
+	if err == nil {
+		return selection, nil
+	}
+	log.Printf("Branch-and-bound selection failed, falling back to knapsack: %v", err)
+
+	knapsack := &KnapsackSelector{Iterations: knapsackIterations}
+	return knapsack.Select(utxos, params)
+}
+
+// NewSelectionParams sizes a spend paying outputs with a change output of
+// the wallet's configured address type.
+func NewSelectionParams(outputs []*wire.TxOut, feeRate int64) SelectionParams {
+	params := SelectionParams{
+		FeeRate:           feeRate,
+		BaseVBytes:        TxOverheadVBytes,
+		ChangeVBytes:      P2WPKHOutputVBytes,
+		ChangeSpendVBytes: P2WPKHInputVBytes,
+		DustLimit:         DustLimit(),
+	}
+	if m := multisig.Active(); m != nil {
+		params.ChangeVBytes = P2WSHOutputVBytes
+		params.ChangeSpendVBytes = MultisigInputVBytes(m.Threshold, len(m.Keys))
+	} else if utils.AddressType() == utils.AddressTypeP2TR {
+		params.ChangeVBytes = P2TROutputVBytes
+		params.ChangeSpendVBytes = P2TRInputVBytes
+	}
+	for _, out := range outputs {
+		params.Target += btcutil.Amount(out.Value)
+		params.BaseVBytes += EstimateOutputVBytes(out.PkScript)
+	}
+	return params
+}
+
+// SelectCoins runs the configured coin selector over utxos for a spend paying
+// outputs at feeRate. Frozen UTXOs are never selected.
+func SelectCoins(utxos []*btcjson.ListUnspentResult, outputs []*wire.TxOut, feeRate int64) (*CoinSelection, error) {
+	selector, err := NewCoinSelector(viper.GetString("coin_selection"))
+	if err != nil {
+		return nil, err
+	}
+	utxos, err = removeFrozenUTXOs(utxos)
+	if err != nil {
+		return nil, err
+	}
+	params := NewSelectionParams(outputs, feeRate)
+	selection, err := selector.Select(utxos, params)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Coin selection (%s): %d inputs, total %d, fee %d, change %d, %d vBytes",
+		selection.Strategy, len(selection.Inputs), selection.Total, selection.Fee, selection.Change, selection.VBytes)
+	return selection, nil
+}
+
+// selectWalletCoins lists the wallet's confirmed UTXOs and selects from them.
+func selectWalletCoins(w *wallet.Wallet, outputs []*wire.TxOut, feeRate int64) (*CoinSelection, error) {
+	utxos, err := w.ListUnspent(1, 9999999, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unspent outputs: %v", err)
+	}
+	log.Printf("Found %d unspent outputs.", len(utxos))
+	return SelectCoins(utxos, outputs, feeRate)
+}
@@ -0,0 +1,243 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+)
+
+// p2wpkhScript is a P2WPKH scriptPubKey, so test UTXOs size as 68 vB inputs.
+const p2wpkhScript = "00140000000000000000000000000000000000000000"
+
+func testUTXOs(values ...int64) []*btcjson.ListUnspentResult {
+	utxos := make([]*btcjson.ListUnspentResult, len(values))
+	for i, value := range values {
+		utxos[i] = &btcjson.ListUnspentResult{
+			TxID:         fmt.Sprintf("%064x", i),
+			Amount:       btcutil.Amount(value).ToBTC(),
+			ScriptPubKey: p2wpkhScript,
+		}
+	}
+	return utxos
+}
+
+func selectedValues(selection *CoinSelection) []int64 {
+	values := make([]int64, len(selection.Inputs))
+	for i, utxo := range selection.Inputs {
+		values[i] = int64(UTXOAmount(utxo))
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] > values[j] })
+	return values
+}
+
+func TestBranchAndBoundSelect(t *testing.T) {
+	tests := []struct {
+		name    string
+		utxos   []int64
+		params  SelectionParams
+		want    []int64
+		wantFee btcutil.Amount
+		wantErr error
+	}{
+		{
+			name:   "exact single input",
+			utxos:  []int64{6000, 4000, 3000},
+			params: SelectionParams{Target: 4000},
+			want:   []int64{4000},
+		},
+		{
+			name:   "skips consecutive equal inputs",
+			utxos:  []int64{6000, 6000, 4000},
+			params: SelectionParams{Target: 4000},
+			want:   []int64{4000},
+		},
+		{
+			name:   "exact pair",
+			utxos:  []int64{5000, 3000, 2000, 1000},
+			params: SelectionParams{Target: 6000},
+			want:   []int64{5000, 1000},
+		},
+		{
+			name:   "equal inputs both needed",
+			utxos:  []int64{3000, 3000, 3000},
+			params: SelectionParams{Target: 6000},
+			want:   []int64{3000, 3000},
+		},
+		{
+			name:    "within cost of change at a fee rate",
+			utxos:   []int64{10068, 5068},
+			params:  SelectionParams{Target: 9989, FeeRate: 1, BaseVBytes: 11, ChangeVBytes: P2WPKHOutputVBytes},
+			want:    []int64{10068},
+			wantFee: 79,
+		},
+		{
+			name:    "no changeless set",
+			utxos:   []int64{5000, 3000},
+			params:  SelectionParams{Target: 4000},
+			wantErr: ErrNoChangelessSolution,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector := &BranchAndBoundSelector{}
+			selection, err := selector.Select(testUTXOs(tt.utxos...), tt.params)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := selectedValues(selection); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selected %v, want %v", got, tt.want)
+			}
+			if selection.Change != 0 {
+				t.Errorf("change %d, want a changeless spend", selection.Change)
+			}
+			if selection.Fee != tt.wantFee {
+				t.Errorf("fee %d, want %d", selection.Fee, tt.wantFee)
+			}
+		})
+	}
+}
+
+func TestBranchAndBoundInsufficientFunds(t *testing.T) {
+	selector := &BranchAndBoundSelector{}
+	_, err := selector.Select(testUTXOs(1000, 2000), SelectionParams{Target: 5000})
+	if err == nil || errors.Is(err, ErrNoChangelessSolution) {
+		t.Fatalf("got %v, want an insufficient funds error", err)
+	}
+}
+
+func TestKnapsackSelect(t *testing.T) {
+	tests := []struct {
+		name    string
+		utxos   []int64
+		target  btcutil.Amount
+		want    []int64
+		wantErr bool
+	}{
+		{
+			name:   "exact single input",
+			utxos:  []int64{1000, 5000, 20000},
+			target: 5000,
+			want:   []int64{5000},
+		},
+		{
+			name:   "smaller inputs add up to the target",
+			utxos:  []int64{1000, 2000, 3000, 50000},
+			target: 6000,
+			want:   []int64{3000, 2000, 1000},
+		},
+		{
+			name:   "smallest larger input when the rest fall short",
+			utxos:  []int64{1000, 2000, 50000, 20000},
+			target: 10000,
+			want:   []int64{20000},
+		},
+		{
+			name:   "subset with room for change",
+			utxos:  []int64{4000, 4000, 3000},
+			target: 5000,
+			want:   []int64{4000, 3000},
+		},
+		{
+			name:    "insufficient funds",
+			utxos:   []int64{1000, 2000},
+			target:  5000,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector := &KnapsackSelector{}
+			params := SelectionParams{Target: tt.target, DustLimit: 546}
+			selection, err := selector.Select(testUTXOs(tt.utxos...), params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("selected %v, want an error", selectedValues(selection))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := selectedValues(selection); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selected %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLargestFirstSelect(t *testing.T) {
+	tests := []struct {
+		name       string
+		utxos      []int64
+		params     SelectionParams
+		want       []int64
+		wantFee    btcutil.Amount
+		wantChange btcutil.Amount
+		wantErr    bool
+	}{
+		{
+			name:       "largest input covers the spend",
+			utxos:      []int64{1000, 50000, 20000},
+			params:     SelectionParams{Target: 10000, FeeRate: 1, BaseVBytes: 42, ChangeVBytes: P2WPKHOutputVBytes, DustLimit: 546},
+			want:       []int64{50000},
+			wantFee:    42 + 68 + 31,
+			wantChange: 50000 - 10000 - (42 + 68 + 31),
+		},
+		{
+			name:       "adds inputs until the fee is covered",
+			utxos:      []int64{10000, 5000, 3000},
+			params:     SelectionParams{Target: 14900, FeeRate: 1, BaseVBytes: 42, ChangeVBytes: P2WPKHOutputVBytes, DustLimit: 546},
+			want:       []int64{10000, 5000, 3000},
+			wantFee:    42 + 3*68 + 31,
+			wantChange: 18000 - 14900 - (42 + 3*68 + 31),
+		},
+		{
+			name:    "dust change goes to the fee",
+			utxos:   []int64{10300},
+			params:  SelectionParams{Target: 10000, FeeRate: 1, BaseVBytes: 42, ChangeVBytes: P2WPKHOutputVBytes, DustLimit: 546},
+			want:    []int64{10300},
+			wantFee: 300,
+		},
+		{
+			name:    "insufficient funds",
+			utxos:   []int64{1000, 2000},
+			params:  SelectionParams{Target: 3000, FeeRate: 1, BaseVBytes: 42},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector := &LargestFirstSelector{}
+			selection, err := selector.Select(testUTXOs(tt.utxos...), tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("selected %v, want an error", selectedValues(selection))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := selectedValues(selection); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selected %v, want %v", got, tt.want)
+			}
+			if selection.Fee != tt.wantFee || selection.Change != tt.wantChange {
+				t.Errorf("fee %d change %d, want fee %d change %d", selection.Fee, selection.Change, tt.wantFee, tt.wantChange)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/checksum0/go-electrum/electrum"
+	"github.com/lightninglabs/neutrino"
+)
+
+// fetchTransaction loads a transaction from the local database, falling back
+// to Electrum when it is not stored locally.
+func fetchTransaction(txid string, electrumClient *electrum.Client) (*wire.MsgTx, error) {
+	txHex, err := RetrieveTransaction(txid)
+	if err != nil {
+		log.Printf("Transaction %s not in local database, trying Electrum: %v", txid, err)
+		txHex, err = GetAndPrintTransaction(electrumClient, txid)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching transaction from both local database and Electrum: %v", err)
+		}
+		if txHex == "" {
+			return nil, fmt.Errorf("transaction %s not found", txid)
+		}
+	}
+
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction hex: %v", err)
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, fmt.Errorf("failed to deserialize transaction: %v", err)
+	}
+	return tx, nil
+}
+
+// transactionFee sums the outputs spent by tx, looking each one up, and
+// returns the fee the transaction pays.
+func transactionFee(tx *wire.MsgTx, electrumClient *electrum.Client) (btcutil.Amount, error) {
+	var totalIn int64
+	for i, txIn := range tx.TxIn {
+		prevTx, err := fetchTransaction(txIn.PreviousOutPoint.Hash.String(), electrumClient)
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch previous transaction for input %d: %v", i, err)
+		}
+		if int(txIn.PreviousOutPoint.Index) >= len(prevTx.TxOut) {
+			return 0, fmt.Errorf("input %d references missing output %s", i, txIn.PreviousOutPoint)
+		}
+		totalIn += prevTx.TxOut[txIn.PreviousOutPoint.Index].Value
+	}
+
+	var totalOut int64
+	for _, txOut := range tx.TxOut {
+		totalOut += txOut.Value
+	}
+
+	if totalIn < totalOut {
+		return 0, fmt.Errorf("transaction outputs exceed inputs")
+	}
+	return btcutil.Amount(totalIn - totalOut), nil
+}
+
+// TransactionVSize returns the virtual size of a signed transaction.
+func TransactionVSize(tx *wire.MsgTx) int {
+	return int(blockchain.GetTransactionWeight(btcutil.NewTx(tx))+3) / blockchain.WitnessScaleFactor
+}
+
+// CPFPChildFee returns the fee a child of childVBytes must pay so that it and
+// its parent together reach targetFeeRate.
+func CPFPChildFee(parentVBytes int, parentFee btcutil.Amount, childVBytes int, targetFeeRate int64) btcutil.Amount {
+	packageFee := btcutil.Amount(int64(parentVBytes+childVBytes) * targetFeeRate)
+	return packageFee - parentFee
+}
+
+// CreateCPFPTransaction bumps an unconfirmed parent by spending one of its
+// outputs that we own in a child that pays for both. Confirmed UTXOs are
+// added when the output alone cannot cover the child fee.
+func CreateCPFPTransaction(w *wallet.Wallet, service *neutrino.ChainService, parentTxID string, targetFeeRate int64, electrumClient *electrum.Client, privPass []byte) (chainhash.Hash, bool, error) {
+	log.Printf("Starting CPFP for transaction %s with target package fee rate %d sat/vB", parentTxID, targetFeeRate)
+
+	// Unlock wallet
+	log.Printf("Unlocking wallet.")
+	err := unlockWallet(w, privPass)
+	if err != nil {
+		log.Printf("Failed to unlock wallet: %v", err)
+		return chainhash.Hash{}, false, err
+	}
+
+	parentTx, err := fetchTransaction(parentTxID, electrumClient)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	parentFee, err := transactionFee(parentTx, electrumClient)
+	if err != nil {
+		return chainhash.Hash{}, false, fmt.Errorf("failed to compute parent fee: %v", err)
+	}
+	parentVBytes := TransactionVSize(parentTx)
+	log.Printf("Parent %s: %d vBytes, fee %d satoshis (%.2f sat/vB)", parentTxID, parentVBytes, parentFee, float64(parentFee)/float64(parentVBytes))
+
+	if int64(parentFee) >= int64(parentVBytes)*targetFeeRate {
+		return chainhash.Hash{}, false, fmt.Errorf("parent already pays %.2f sat/vB, at or above the target of %d sat/vB", float64(parentFee)/float64(parentVBytes), targetFeeRate)
+	}
+
+	// Find the unconfirmed outputs of the parent that belong to us
+	unconfirmed, err := w.ListUnspent(0, 0, "")
+	if err != nil {
+		return chainhash.Hash{}, false, fmt.Errorf("failed to list unconfirmed outputs: %v", err)
+	}
+	unconfirmed, err = removeFrozenUTXOs(unconfirmed)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+	var parentOutput *btcjson.ListUnspentResult
+	for _, utxo := range unconfirmed {
+		if utxo.TxID != parentTxID {
+			continue
+		}
+		if parentOutput == nil || utxo.Amount > parentOutput.Amount {
+			parentOutput = utxo
+		}
+	}
+	if parentOutput == nil {
+		return chainhash.Hash{}, false, fmt.Errorf("no unconfirmed output of %s belongs to this wallet", parentTxID)
+	}
+	log.Printf("Spending parent output %s:%d worth %d satoshis", parentOutput.TxID, parentOutput.Vout, UTXOAmount(parentOutput))
+
+	changeAddr, err := getChangeAddress(w)
+	if err != nil {
+		return chainhash.Hash{}, false, fmt.Errorf("failed to get change address: %v", err)
+	}
+	changePkScript, err := txscript.PayToAddrScript(changeAddr)
+	if err != nil {
+		return chainhash.Hash{}, false, fmt.Errorf("failed to create change script: %v", err)
+	}
+
+	inputs := []*btcjson.ListUnspentResult{parentOutput}
+	totalIn := UTXOAmount(parentOutput)
+	inputVBytes := utxoInputVBytes(parentOutput)
+
+	childVBytes := TxOverheadVBytes + inputVBytes + EstimateOutputVBytes(changePkScript)
+	childFee := CPFPChildFee(parentVBytes, parentFee, childVBytes, targetFeeRate)
+
+	if totalIn-childFee < DustLimit() {
+		// The parent output cannot carry the fee on its own, so bring in
+		// confirmed coins. Each extra input raises the child fee by its
+		// own size, which the selector accounts for.
+		shortfall := childFee + DustLimit() - totalIn
+		log.Printf("Parent output cannot cover a child fee of %d satoshis, selecting %d more", childFee, shortfall)
+
+		additional, additionalAmount, err := selectAdditionalUTXOs(w, shortfall, targetFeeRate, 0)
+		if err != nil {
+			return chainhash.Hash{}, false, fmt.Errorf("failed to select additional UTXOs: %v", err)
+		}
+		for _, utxo := range additional {
+			inputVBytes += utxoInputVBytes(utxo)
+		}
+		inputs = append(inputs, additional...)
+		totalIn += additionalAmount
+
+		childVBytes = TxOverheadVBytes + inputVBytes + EstimateOutputVBytes(changePkScript)
+		childFee = CPFPChildFee(parentVBytes, parentFee, childVBytes, targetFeeRate)
+	}
+
+	changeAmount := totalIn - childFee
+	if changeAmount < DustLimit() {
+		return chainhash.Hash{}, false, fmt.Errorf("insufficient funds: child would pay %d satoshis from %d", childFee, totalIn)
+	}
+
+	// Build the child transaction
+	childTx := wire.NewMsgTx(wire.TxVersion)
+	for _, utxo := range inputs {
+		prevOutHash, err := chainhash.NewHashFromStr(utxo.TxID)
+		if err != nil {
+			return chainhash.Hash{}, false, fmt.Errorf("failed to parse txid: %v", err)
+		}
+		txIn := wire.NewTxIn(wire.NewOutPoint(prevOutHash, utxo.Vout), nil, nil)
+		txIn.Sequence = RBFSequenceNumber
+		childTx.AddTxIn(txIn)
+	}
+	childTx.AddTxOut(wire.NewTxOut(int64(changeAmount), changePkScript))
+
+	inputs = applyPrivacyPolicy(w, childTx, inputs)
+
+	if err := signTransactionInputs(w, childTx, inputs); err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	actualChildVBytes := TransactionVSize(childTx)
+	packageRate := float64(int64(parentFee)+int64(childFee)) / float64(parentVBytes+actualChildVBytes)
+
+	log.Printf("CPFP transaction created successfully. Details:")
+	log.Printf("  TxID: %s", childTx.TxHash().String())
+	log.Printf("  Parent TxID: %s", parentTxID)
+	log.Printf("  Child fee: %d satoshis", childFee)
+	log.Printf("  Child size: %d vBytes", actualChildVBytes)
+	log.Printf("  Package fee rate: %.2f sat/vB", packageRate)
+	log.Printf("  Number of inputs: %d", len(childTx.TxIn))
+
+	// Save the transaction in the database
+	_, err = walletstatedb.SaveTransactionToDB(childTx)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	txHash, verified, err := broadcastAndVerifyTransaction(childTx, service)
+	if err != nil {
+		releaseSpend(childTx)
+		return chainhash.Hash{}, false, fmt.Errorf("failed to broadcast and verify CPFP transaction: %v", err)
+	}
+
+	log.Printf("CPFP transaction successfully broadcast. Child TxID: %s", txHash.String())
+	return txHash, verified, nil
+}
@@ -1,15 +1,14 @@
 package transaction
 
 import (
-	"bytes"
 	"encoding/hex"
 	"fmt"
 	"log"
-	"sort"
 
 	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
@@ -28,34 +27,9 @@ const (
 
 func CheckBalanceAndCreateTransaction(w *wallet.Wallet, service *neutrino.ChainService, enableRBF bool, spendAmount int64, recipientAddress string, privPass []byte) (chainhash.Hash, bool, error) {
 	log.Printf("Starting transaction creation process.")
-	// Reset locked outpoints
-	log.Printf("Resetting locked outpoints.")
-	w.ResetLockedOutpoints()
-
-	// Unlock wallet
-	log.Printf("Unlocking wallet.")
-	err := w.Unlock(privPass, nil)
-	if err != nil {
-		log.Printf("Failed to unlock wallet: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to unlock wallet: %v", err)
-	}
-
-	// Calculate wallet balance
-	balance, err := w.CalculateBalance(1)
+	recipientOutput, err := preparePayment(w, spendAmount, recipientAddress, privPass)
 	if err != nil {
-		log.Printf("Failed to calculate balance: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to calculate balance: %v", err)
-	}
-	log.Printf("Available balance: %s\n", balance.String())
-
-	// Set recipient address and amount
-	amountToSend := btcutil.Amount(spendAmount) // 0.00005 BTC
-	log.Printf("Recipient address: %s, Amount to send: %d satoshis", recipientAddress, amountToSend)
-
-	// Check sufficient balance
-	if balance < amountToSend {
-		log.Printf("Insufficient balance: have %d satoshis, want to send %d satoshis", int64(balance), amountToSend)
-		return chainhash.Hash{}, false, fmt.Errorf("insufficient balance: have %d satoshis, want to send %d satoshis", int64(balance), amountToSend)
+		return chainhash.Hash{}, false, err
 	}
 
 	// Get fee recommendation
@@ -74,386 +48,105 @@ func CheckBalanceAndCreateTransaction(w *wallet.Wallet, service *neutrino.ChainS
 	}
 	log.Printf("Selected fee rate: %d sat/vB", feeRate)
 
-	// List unspent outputs
-	utxos, err := w.ListUnspent(1, 9999999, "")
-	if err != nil {
-		log.Printf("Failed to list unspent outputs: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to list unspent outputs: %v", err)
-	}
-	log.Printf("Found %d unspent outputs.", len(utxos))
-
-	// Sort UTXOs by amount in descending order to prioritize larger UTXOs
-	sort.Slice(utxos, func(i, j int) bool {
-		return utxos[i].Amount > utxos[j].Amount
-	})
-
-	// Select UTXOs, starting from the largest, until the required amount is met
-	var selectedUTXOs []*btcjson.ListUnspentResult
-	var totalSelected btcutil.Amount
-
-	for _, utxo := range utxos {
-		utxoAmount := btcutil.Amount(utxo.Amount * btcutil.SatoshiPerBitcoin)
-		selectedUTXOs = append(selectedUTXOs, utxo)
-		totalSelected += utxoAmount
-
-		if totalSelected >= amountToSend+btcutil.Amount(feeRate) {
-			break
-		}
-	}
-
-	// Check if we accumulated enough funds
-	if totalSelected < amountToSend+btcutil.Amount(feeRate) {
-		log.Printf("Insufficient UTXOs for the transaction.")
-		return chainhash.Hash{}, false, fmt.Errorf("insufficient UTXOs for the transaction")
-	}
-	log.Printf("Total selected amount: %d satoshis", totalSelected)
-
-	// Create new transaction
-	tx := wire.NewMsgTx(wire.TxVersion)
-
-	// Add inputs from selected UTXOs
-	for _, utxo := range selectedUTXOs {
-		prevOutHash, err := chainhash.NewHashFromStr(utxo.TxID)
-		if err != nil {
-			log.Printf("Failed to parse txid: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to parse txid: %v", err)
-		}
-		prevOut := wire.NewOutPoint(prevOutHash, utxo.Vout)
-		txIn := wire.NewTxIn(prevOut, nil, nil)
-		if enableRBF {
-			txIn.Sequence = RBFSequenceNumber
-		}
-		tx.AddTxIn(txIn)
-	}
-
-	// Add recipient output
-	recipientAddr, err := btcutil.DecodeAddress(recipientAddress, w.ChainParams())
+	// Select UTXOs with the configured coin selector
+	outputs := []*wire.TxOut{recipientOutput}
+	selection, err := selectWalletCoins(w, outputs, int64(feeRate))
 	if err != nil {
-		log.Printf("Failed to decode recipient address: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to decode recipient address: %v", err)
+		log.Printf("Coin selection failed: %v", err)
+		return chainhash.Hash{}, false, fmt.Errorf("coin selection failed: %v", err)
 	}
-	pkScript, err := txscript.PayToAddrScript(recipientAddr)
-	if err != nil {
-		log.Printf("Failed to create output script: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to create output script: %v", err)
-	}
-	tx.AddTxOut(wire.NewTxOut(int64(amountToSend), pkScript))
-
-	// Calculate transaction size
-	txSize := tx.SerializeSize()
-
-	// Calculate the required fee
-	requiredFee := btcutil.Amount(txSize * int(feeRate))
 
-	// Calculate change amount
-	changeAmount := totalSelected - amountToSend - requiredFee
-	if changeAmount > DustThreshold {
-		changeAddr, err := getChangeAddress(w)
-		if err != nil {
-			log.Printf("Failed to get change address: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to get change address: %v", err)
-		}
-		changePkScript, err := txscript.PayToAddrScript(changeAddr)
-		if err != nil {
-			log.Printf("Failed to create change script: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to create change script: %v", err)
-		}
-		tx.AddTxOut(wire.NewTxOut(int64(changeAmount), changePkScript))
-	}
-
-	// Sign the transaction
-	for i, utxo := range selectedUTXOs {
-		utxoAddr, err := btcutil.DecodeAddress(utxo.Address, w.ChainParams())
-		if err != nil {
-			log.Printf("Failed to decode UTXO address: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to decode UTXO address: %v", err)
-		}
-		privKey, err := w.PrivKeyForAddress(utxoAddr)
-		if err != nil {
-			log.Printf("Failed to get private key for address: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to get private key for address: %v", err)
-		}
-		scriptPubKey, err := hex.DecodeString(utxo.ScriptPubKey)
-		if err != nil {
-			log.Printf("Failed to decode scriptPubKey: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to decode scriptPubKey: %v", err)
-		}
-
-		utxoAmount := int64(btcutil.Amount(utxo.Amount * btcutil.SatoshiPerBitcoin))
-		prevOutputs := txscript.NewCannedPrevOutputFetcher(scriptPubKey, utxoAmount)
-
-		if isSegWitAddress(utxoAddr) {
-			// Create the witness script for SegWit inputs
-			witnessScript, err := txscript.WitnessSignature(tx, txscript.NewTxSigHashes(tx, prevOutputs), i, utxoAmount, scriptPubKey, txscript.SigHashAll, privKey, true)
-			if err != nil {
-				log.Printf("Failed to create witness script for input %d: %v", i, err)
-				return chainhash.Hash{}, false, fmt.Errorf("failed to create witness script for input %d: %v", i, err)
-			}
-			tx.TxIn[i].Witness = witnessScript
-		} else {
-			// Create the signature script for non-SegWit inputs
-			sigScript, err := txscript.SignatureScript(tx, i, scriptPubKey, txscript.SigHashAll, privKey, true)
-			if err != nil {
-				log.Printf("Failed to create signature script for input %d: %v", i, err)
-				return chainhash.Hash{}, false, fmt.Errorf("failed to create signature script for input %d: %v", i, err)
-			}
-			tx.TxIn[i].SignatureScript = sigScript
-		}
-
-		// Verify the signature for each input
-		valid, err := verifySignature(tx, i, scriptPubKey, utxoAmount)
-		if err != nil {
-			log.Printf("Failed to verify signature for input %d: %v", i, err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to verify signature for input %d: %v", i, err)
-		}
-		if !valid {
-			log.Printf("Signature verification failed for input %d", i)
-			return chainhash.Hash{}, false, fmt.Errorf("signature verification failed for input %d", i)
-		}
-		log.Printf("Signature verification succeeded for input %d", i)
-	}
-
-	log.Printf("Transaction created successfully. Details:")
-	log.Printf("  TxID: %s", tx.TxHash().String())
-	log.Printf("  Amount to send: %d satoshis", amountToSend)
-	log.Printf("  Fee: %d satoshis", requiredFee)
-	log.Printf("  Change amount: %d satoshis", changeAmount)
-	log.Printf("  Transaction size: %d vBytes", tx.SerializeSize())
-	log.Printf("  Number of inputs: %d", len(tx.TxIn))
-	log.Printf("  Number of outputs: %d", len(tx.TxOut))
-
-	log.Println("Detailed Transaction Information:")
-	log.Printf("TxID: %s", tx.TxHash())
-	log.Printf("Version: %d", tx.Version)
-	log.Printf("Locktime: %d", tx.LockTime)
-
-	log.Println("Inputs:")
-	for i, input := range tx.TxIn {
-		log.Printf("  Input %d:", i)
-		log.Printf("    Previous OutPoint: %s", input.PreviousOutPoint)
-		log.Printf("    Sequence: %d", input.Sequence)
-		log.Printf("    SignatureScript: %x", input.SignatureScript)
-		if len(input.Witness) > 0 {
-			log.Printf("    Witness: %x", input.Witness)
-		}
-	}
-
-	log.Println("Outputs:")
-	for i, output := range tx.TxOut {
-		log.Printf("  Output %d:", i)
-		log.Printf("    Value: %d satoshis", output.Value)
-		log.Printf("    PkScript: %x", output.PkScript)
-	}
+	return sendTransaction(w, service, enableRBF, outputs, selection)
+}
 
-	// Save the transaction in the database
-	_, err = walletstatedb.SaveTransactionToDB(tx)
+// HttpCheckBalanceAndCreateTransaction pays recipientAddress at feeRate. When
+// inputs is non-empty exactly those outpoints are spent instead of running
+// coin selection.
+func HttpCheckBalanceAndCreateTransaction(w *wallet.Wallet, service *neutrino.ChainService, enableRBF bool, spendAmount int64, recipientAddress string, privPass []byte, feeRate int, inputs []wire.OutPoint) (chainhash.Hash, bool, error) {
+	log.Printf("Starting transaction creation process.")
+	recipientOutput, err := preparePayment(w, spendAmount, recipientAddress, privPass)
 	if err != nil {
 		return chainhash.Hash{}, false, err
 	}
 
-	// Create Electrum client once
-	electrumClient, err := CreateElectrumClient(ElectrumConfig{
-		ServerAddr: "electrum.blockstream.info:50002",
-		UseSSL:     true,
-	})
-	if err != nil {
-		return chainhash.Hash{}, false, fmt.Errorf("failed to create Electrum client: %v", err)
-	}
-	defer electrumClient.Shutdown()
-
-	txHash, verified, err := broadcastAndVerifyTransaction(tx, service)
+	// Select UTXOs with the configured coin selector, or use the given inputs
+	outputs := []*wire.TxOut{recipientOutput}
+	selection, err := selectSpendCoins(w, outputs, int64(feeRate), inputs)
 	if err != nil {
-		// Release the output we tried to spend
-		releaseErr := w.ReleaseOutput(wtxmgr.LockID(tx.TxHash()), tx.TxIn[0].PreviousOutPoint)
-		if releaseErr != nil {
-			log.Printf("Failed to release output: %v", releaseErr)
-		}
-		return chainhash.Hash{}, false, fmt.Errorf("failed to broadcast and verify transaction: %v", err)
+		log.Printf("Coin selection failed: %v", err)
+		return chainhash.Hash{}, false, fmt.Errorf("coin selection failed: %v", err)
 	}
 
-	log.Println("Transaction broadcast and verified successfully.")
-	return txHash, verified, nil
+	return sendTransaction(w, service, enableRBF, outputs, selection)
 }
 
-func HttpCheckBalanceAndCreateTransaction(w *wallet.Wallet, service *neutrino.ChainService, enableRBF bool, spendAmount int64, recipientAddress string, privPass []byte, feeRate int) (chainhash.Hash, bool, error) {
-	log.Printf("Starting transaction creation process.")
+// preparePayment resets locked outpoints, unlocks the wallet, checks the
+// balance covers spendAmount and builds the output paying recipientAddress.
+func preparePayment(w *wallet.Wallet, spendAmount int64, recipientAddress string, privPass []byte) (*wire.TxOut, error) {
 	// Reset locked outpoints
 	log.Printf("Resetting locked outpoints.")
 	w.ResetLockedOutpoints()
 
 	// Unlock wallet
 	log.Printf("Unlocking wallet.")
-	err := w.Unlock(privPass, nil)
-	if err != nil {
+	if err := unlockWallet(w, privPass); err != nil {
 		log.Printf("Failed to unlock wallet: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to unlock wallet: %v", err)
+		return nil, err
 	}
 
 	// Calculate wallet balance
 	balance, err := w.CalculateBalance(1)
 	if err != nil {
 		log.Printf("Failed to calculate balance: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to calculate balance: %v", err)
+		return nil, fmt.Errorf("failed to calculate balance: %v", err)
 	}
 	log.Printf("Available balance: %s\n", balance.String())
 
-	// Set recipient address and amount
-	amountToSend := btcutil.Amount(spendAmount) // 0.00005 BTC
+	amountToSend := btcutil.Amount(spendAmount)
 	log.Printf("Recipient address: %s, Amount to send: %d satoshis", recipientAddress, amountToSend)
 
 	// Check sufficient balance
 	if balance < amountToSend {
 		log.Printf("Insufficient balance: have %d satoshis, want to send %d satoshis", int64(balance), amountToSend)
-		return chainhash.Hash{}, false, fmt.Errorf("insufficient balance: have %d satoshis, want to send %d satoshis", int64(balance), amountToSend)
-	}
-
-	// List unspent outputs
-	utxos, err := w.ListUnspent(1, 9999999, "")
-	if err != nil {
-		log.Printf("Failed to list unspent outputs: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to list unspent outputs: %v", err)
-	}
-	log.Printf("Found %d unspent outputs.", len(utxos))
-
-	// Sort UTXOs by amount in descending order to prioritize larger UTXOs
-	sort.Slice(utxos, func(i, j int) bool {
-		return utxos[i].Amount > utxos[j].Amount
-	})
-
-	// Select UTXOs, starting from the largest, until the required amount is met
-	var selectedUTXOs []*btcjson.ListUnspentResult
-	var totalSelected btcutil.Amount
-
-	for _, utxo := range utxos {
-		utxoAmount := btcutil.Amount(utxo.Amount * btcutil.SatoshiPerBitcoin)
-		selectedUTXOs = append(selectedUTXOs, utxo)
-		totalSelected += utxoAmount
-
-		if totalSelected >= amountToSend+btcutil.Amount(feeRate) {
-			break
-		}
-	}
-
-	// Check if we accumulated enough funds
-	if totalSelected < amountToSend+btcutil.Amount(feeRate) {
-		log.Printf("Insufficient UTXOs for the transaction.")
-		return chainhash.Hash{}, false, fmt.Errorf("insufficient UTXOs for the transaction")
-	}
-	log.Printf("Total selected amount: %d satoshis", totalSelected)
-
-	// Create new transaction
-	// Create new transaction
-	tx := wire.NewMsgTx(wire.TxVersion)
-
-	// Add inputs from selected UTXOs
-	for _, utxo := range selectedUTXOs {
-		prevOutHash, err := chainhash.NewHashFromStr(utxo.TxID)
-		if err != nil {
-			log.Printf("Failed to parse txid: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to parse txid: %v", err)
-		}
-		prevOut := wire.NewOutPoint(prevOutHash, utxo.Vout)
-		txIn := wire.NewTxIn(prevOut, nil, nil)
-		if enableRBF {
-			txIn.Sequence = RBFSequenceNumber
-		}
-		tx.AddTxIn(txIn)
+		return nil, fmt.Errorf("insufficient balance: have %d satoshis, want to send %d satoshis", int64(balance), amountToSend)
 	}
 
-	// Add recipient output
+	// Build the recipient output
 	recipientAddr, err := btcutil.DecodeAddress(recipientAddress, w.ChainParams())
 	if err != nil {
 		log.Printf("Failed to decode recipient address: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to decode recipient address: %v", err)
+		return nil, fmt.Errorf("failed to decode recipient address: %v", err)
 	}
 	pkScript, err := txscript.PayToAddrScript(recipientAddr)
 	if err != nil {
 		log.Printf("Failed to create output script: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to create output script: %v", err)
+		return nil, fmt.Errorf("failed to create output script: %v", err)
 	}
-	tx.AddTxOut(wire.NewTxOut(int64(amountToSend), pkScript))
-
-	// Calculate transaction size
-	txSize := tx.SerializeSize()
+	return wire.NewTxOut(int64(amountToSend), pkScript), nil
+}
 
-	// Calculate the required fee
-	requiredFee := btcutil.Amount(txSize * int(feeRate))
+// sendTransaction builds the transaction paying outputs from selection, signs
+// it, records it and broadcasts it.
+func sendTransaction(w *wallet.Wallet, service *neutrino.ChainService, enableRBF bool, outputs []*wire.TxOut, selection *CoinSelection) (chainhash.Hash, bool, error) {
+	log.Printf("Total selected amount: %d satoshis", selection.Total)
 
-	// Calculate change amount
-	changeAmount := totalSelected - amountToSend - requiredFee
-	if changeAmount > DustThreshold {
-		changeAddr, err := getChangeAddress(w)
-		if err != nil {
-			log.Printf("Failed to get change address: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to get change address: %v", err)
-		}
-		changePkScript, err := txscript.PayToAddrScript(changeAddr)
-		if err != nil {
-			log.Printf("Failed to create change script: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to create change script: %v", err)
-		}
-		tx.AddTxOut(wire.NewTxOut(int64(changeAmount), changePkScript))
+	tx, err := buildUnsignedTransaction(w, enableRBF, outputs, selection)
+	if err != nil {
+		return chainhash.Hash{}, false, err
 	}
 
 	// Sign the transaction
-	for i, utxo := range selectedUTXOs {
-		utxoAddr, err := btcutil.DecodeAddress(utxo.Address, w.ChainParams())
-		if err != nil {
-			log.Printf("Failed to decode UTXO address: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to decode UTXO address: %v", err)
-		}
-		privKey, err := w.PrivKeyForAddress(utxoAddr)
-		if err != nil {
-			log.Printf("Failed to get private key for address: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to get private key for address: %v", err)
-		}
-		scriptPubKey, err := hex.DecodeString(utxo.ScriptPubKey)
-		if err != nil {
-			log.Printf("Failed to decode scriptPubKey: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to decode scriptPubKey: %v", err)
-		}
-
-		utxoAmount := int64(btcutil.Amount(utxo.Amount * btcutil.SatoshiPerBitcoin))
-		prevOutputs := txscript.NewCannedPrevOutputFetcher(scriptPubKey, utxoAmount)
-
-		if isSegWitAddress(utxoAddr) {
-			// Create the witness script for SegWit inputs
-			witnessScript, err := txscript.WitnessSignature(tx, txscript.NewTxSigHashes(tx, prevOutputs), i, utxoAmount, scriptPubKey, txscript.SigHashAll, privKey, true)
-			if err != nil {
-				log.Printf("Failed to create witness script for input %d: %v", i, err)
-				return chainhash.Hash{}, false, fmt.Errorf("failed to create witness script for input %d: %v", i, err)
-			}
-			tx.TxIn[i].Witness = witnessScript
-		} else {
-			// Create the signature script for non-SegWit inputs
-			sigScript, err := txscript.SignatureScript(tx, i, scriptPubKey, txscript.SigHashAll, privKey, true)
-			if err != nil {
-				log.Printf("Failed to create signature script for input %d: %v", i, err)
-				return chainhash.Hash{}, false, fmt.Errorf("failed to create signature script for input %d: %v", i, err)
-			}
-			tx.TxIn[i].SignatureScript = sigScript
-		}
-
-		// Verify the signature for each input
-		valid, err := verifySignature(tx, i, scriptPubKey, utxoAmount)
-		if err != nil {
-			log.Printf("Failed to verify signature for input %d: %v", i, err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to verify signature for input %d: %v", i, err)
-		}
-		if !valid {
-			log.Printf("Signature verification failed for input %d", i)
-			return chainhash.Hash{}, false, fmt.Errorf("signature verification failed for input %d", i)
-		}
-		log.Printf("Signature verification succeeded for input %d", i)
+	if err := signTransactionInputs(w, tx, selection.Inputs); err != nil {
+		return chainhash.Hash{}, false, err
 	}
 	log.Printf("Signature verification succeeded")
 
 	log.Printf("Transaction created successfully. Details:")
 	log.Printf("  TxID: %s", tx.TxHash().String())
-	log.Printf("  Amount to send: %d satoshis", amountToSend)
-	log.Printf("  Fee: %d satoshis", requiredFee)
-	log.Printf("  Total input: %d satoshis", totalSelected)
-	log.Printf("  Change amount: %d satoshis", changeAmount)
+	log.Printf("  Amount to send: %d satoshis", selection.Total-selection.Fee-selection.Change)
+	log.Printf("  Fee: %d satoshis", selection.Fee)
+	log.Printf("  Total input: %d satoshis", selection.Total)
+	log.Printf("  Change amount: %d satoshis", selection.Change)
 	log.Printf("  Transaction size: %d vBytes", tx.SerializeSize())
 	log.Printf("  Number of inputs: %d", len(tx.TxIn))
 	log.Printf("  Number of outputs: %d", len(tx.TxOut))
@@ -487,16 +180,6 @@ func HttpCheckBalanceAndCreateTransaction(w *wallet.Wallet, service *neutrino.Ch
 		return chainhash.Hash{}, false, err
 	}
 
-	// Create Electrum client once
-	electrumClient, err := CreateElectrumClient(ElectrumConfig{
-		ServerAddr: "electrum.blockstream.info:50002",
-		UseSSL:     false,
-	})
-	if err != nil {
-		return chainhash.Hash{}, false, fmt.Errorf("failed to create Electrum client: %v", err)
-	}
-	defer electrumClient.Shutdown()
-
 	txHash, verified, err := broadcastAndVerifyTransaction(tx, service)
 	if err != nil {
 		// Release the output we tried to spend
@@ -504,6 +187,7 @@ func HttpCheckBalanceAndCreateTransaction(w *wallet.Wallet, service *neutrino.Ch
 		if releaseErr != nil {
 			log.Printf("Failed to release output: %v", releaseErr)
 		}
+		releaseSpend(tx)
 		return chainhash.Hash{}, false, fmt.Errorf("failed to broadcast and verify transaction: %v", err)
 	}
 
@@ -511,78 +195,47 @@ func HttpCheckBalanceAndCreateTransaction(w *wallet.Wallet, service *neutrino.Ch
 	return txHash, verified, nil
 }
 
-func HttpCalculateTransactionSize(w *wallet.Wallet, spendAmount int64, recipientAddress string, feeRate int) (int, error) {
+// HttpCalculateTransactionSize returns the estimated size in vbytes of a
+// payment of spendAmount, along with the maximum amount sendable to the same
+// address at feeRate. A spendAmount of zero or less sizes a full sweep.
+func HttpCalculateTransactionSize(w *wallet.Wallet, spendAmount int64, recipientAddress string, feeRate int) (int, int64, error) {
 	log.Printf("Starting transaction size calculation process.")
 
-	// Set recipient address and amount
-	amountToSend := btcutil.Amount(spendAmount)
-
-	// List unspent outputs
-	utxos, err := w.ListUnspent(1, 9999999, "")
+	maxSendable, sweepVBytes, err := CalculateMaxSendable(w, recipientAddress, nil, feeRate)
 	if err != nil {
-		log.Printf("Failed to list unspent outputs: %v", err)
-		return 0, fmt.Errorf("failed to list unspent outputs: %v", err)
+		log.Printf("Could not calculate max sendable amount: %v", err)
+		maxSendable = 0
 	}
-
-	// Select all UTXOs, including dust
-	var selectedUTXOs []*btcjson.ListUnspentResult
-	var totalSelected btcutil.Amount
-
-	for _, utxo := range utxos {
-		utxoAmount := btcutil.Amount(utxo.Amount * btcutil.SatoshiPerBitcoin)
-		selectedUTXOs = append(selectedUTXOs, utxo)
-		totalSelected += utxoAmount
-	}
-
-	// Create new transaction
-	tx := wire.NewMsgTx(wire.TxVersion)
-
-	// Add inputs
-	for _, utxo := range selectedUTXOs {
-		prevOutHash, err := chainhash.NewHashFromStr(utxo.TxID)
+	if spendAmount <= 0 {
 		if err != nil {
-			return 0, fmt.Errorf("failed to parse txid: %v", err)
+			return 0, 0, err
 		}
-		prevOut := wire.NewOutPoint(prevOutHash, utxo.Vout)
-		txIn := wire.NewTxIn(prevOut, nil, nil)
-		tx.AddTxIn(txIn)
+		log.Printf("Calculated sweep size: %d vBytes, max sendable %d satoshis", sweepVBytes, maxSendable)
+		return sweepVBytes, int64(maxSendable), nil
 	}
 
-	// Add recipient output
+	// Set recipient address and amount
+	amountToSend := btcutil.Amount(spendAmount)
+
+	// Build the recipient output
 	recipientAddr, err := btcutil.DecodeAddress(recipientAddress, w.ChainParams())
 	if err != nil {
-		return 0, fmt.Errorf("failed to decode recipient address: %v", err)
+		return 0, 0, fmt.Errorf("failed to decode recipient address: %v", err)
 	}
 	pkScript, err := txscript.PayToAddrScript(recipientAddr)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create output script: %v", err)
+		return 0, 0, fmt.Errorf("failed to create output script: %v", err)
 	}
-	tx.AddTxOut(wire.NewTxOut(int64(amountToSend), pkScript))
-
-	// Calculate initial transaction size and fee
-	initialSize := tx.SerializeSize()
-	initialFee := btcutil.Amount(initialSize * feeRate)
-
-	// Calculate change
-	changeAmount := totalSelected - amountToSend - initialFee
 
-	// Add change output if it's not dust
-	if changeAmount > DustThreshold {
-		changeAddr, err := getChangeAddress(w)
-		if err != nil {
-			return 0, fmt.Errorf("failed to get change address: %v", err)
-		}
-		changePkScript, err := txscript.PayToAddrScript(changeAddr)
-		if err != nil {
-			return 0, fmt.Errorf("failed to create change script: %v", err)
-		}
-		tx.AddTxOut(wire.NewTxOut(int64(changeAmount), changePkScript))
+	// Run the same coin selection the send path uses so the estimate matches
+	// the inputs and change that would actually be spent
+	selection, err := selectWalletCoins(w, []*wire.TxOut{wire.NewTxOut(int64(amountToSend), pkScript)}, int64(feeRate))
+	if err != nil {
+		return 0, int64(maxSendable), fmt.Errorf("coin selection failed: %v", err)
 	}
 
-	// Calculate the final size of the transaction
-	finalSize := tx.SerializeSize()
-	log.Printf("Calculated transaction size: %d bytes", finalSize)
-	return finalSize, nil
+	log.Printf("Calculated transaction size: %d vBytes", selection.VBytes)
+	return selection.VBytes, int64(maxSendable), nil
 }
 
 func ReplaceTransactionWithHigherFee(w *wallet.Wallet, service *neutrino.ChainService, originalTxID string, newFeeRate int64, electrumClient *electrum.Client, privPass []byte) (chainhash.Hash, bool, error) {
@@ -590,58 +243,121 @@ func ReplaceTransactionWithHigherFee(w *wallet.Wallet, service *neutrino.ChainSe
 
 	// Unlock wallet
 	log.Printf("Unlocking wallet.")
-	err := w.Unlock(privPass, nil)
+	err := unlockWallet(w, privPass)
 	if err != nil {
 		log.Printf("Failed to unlock wallet: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to unlock wallet: %v", err)
+		return chainhash.Hash{}, false, err
+	}
+	if privPass != nil {
+		defer w.Lock()
 	}
-	defer w.Lock()
 
-	// First attempt to fetch the transaction from the local database
-	txDetails, err := RetrieveTransaction(originalTxID)
+	bump, err := buildFeeBump(w, originalTxID, newFeeRate, electrumClient)
 	if err != nil {
-		// If retrieval from the database fails, try Electrum
-		log.Printf("Error fetching transaction from local database: %v", err)
-		log.Printf("Attempting to fetch transaction from Electrum")
+		return chainhash.Hash{}, false, err
+	}
+	newTx := bump.tx
 
-		txDetails, err = GetAndPrintTransaction(electrumClient, originalTxID)
-		if err != nil {
-			log.Printf("Error fetching transaction from Electrum: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("error fetching transaction from both local database and Electrum: %v", err)
-		}
-		log.Printf("Successfully retrieved transaction from Electrum")
-	} else {
-		log.Printf("Successfully retrieved transaction from local database")
+	// Sign the transaction
+	if err := signTransactionInputs(w, newTx, bump.spent); err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	log.Printf("RBF Transaction created successfully. Details:")
+	log.Printf("  TxID: %s", newTx.TxHash().String())
+	log.Printf("  New fee: %d satoshis", bump.newFee)
+	log.Printf("  Extra fee: %d satoshis", bump.extraFee)
+	log.Printf("  Transaction size: %d vBytes", newTx.SerializeSize())
+	log.Printf("  Transaction weight: %d weight units", bump.weight)
+	log.Printf("  Number of inputs: %d", len(newTx.TxIn))
+	log.Printf("  Number of outputs: %d", len(newTx.TxOut))
+
+	// Save the transaction in the database
+	_, err = walletstatedb.SaveTransactionToDB(newTx)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	// Broadcast and verify the transaction
+	txHash, verified, err := broadcastAndVerifyTransaction(newTx, service)
+	if err != nil {
+		releaseSpend(newTx)
+		return chainhash.Hash{}, false, fmt.Errorf("failed to broadcast and verify RBF transaction: %v", err)
+	}
+
+	log.Printf("RBF transaction successfully broadcast. New TxID: %s", txHash.String())
+	return txHash, verified, nil
+}
+
+// BumpFeePSBT builds the same replacement as ReplaceTransactionWithHigherFee
+// but returns it unsigned as a PSBT, for wallets whose keys live elsewhere or
+// are shared with cosigners.
+func BumpFeePSBT(w *wallet.Wallet, originalTxID string, newFeeRate int64, electrumClient *electrum.Client) (*psbt.Packet, error) {
+	log.Printf("Creating RBF PSBT for transaction %s with new fee rate %d sat/vB", originalTxID, newFeeRate)
+
+	bump, err := buildFeeBump(w, originalTxID, newFeeRate, electrumClient)
+	if err != nil {
+		return nil, err
 	}
 
-	// Decode the original transaction
-	txBytes, err := hex.DecodeString(txDetails)
+	packet, err := psbt.NewFromUnsignedTx(bump.tx)
 	if err != nil {
-		return chainhash.Hash{}, false, fmt.Errorf("failed to decode transaction hex: %v", err)
+		return nil, fmt.Errorf("failed to create PSBT: %v", err)
+	}
+	if err := addPSBTInputInfo(w, packet, bump.spent); err != nil {
+		return nil, err
 	}
-	originalTx := wire.NewMsgTx(wire.TxVersion)
-	if err := originalTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
-		return chainhash.Hash{}, false, fmt.Errorf("failed to deserialize original transaction: %v", err)
+
+	log.Printf("RBF PSBT created with %d inputs and a fee of %d satoshis (%d more than the original)", len(bump.tx.TxIn), bump.newFee, bump.extraFee)
+	return packet, nil
+}
+
+// feeBump is an unsigned replacement built by buildFeeBump.
+type feeBump struct {
+	tx       *wire.MsgTx
+	spent    []*btcjson.ListUnspentResult // in input order
+	newFee   btcutil.Amount
+	extraFee btcutil.Amount
+	weight   int
+}
+
+// buildFeeBump rebuilds originalTxID at newFeeRate, taking the extra fee from
+// its change output or, when that is too small, from additional UTXOs.
+func buildFeeBump(w *wallet.Wallet, originalTxID string, newFeeRate int64, electrumClient *electrum.Client) (*feeBump, error) {
+	originalTx, err := fetchTransaction(originalTxID, electrumClient)
+	if err != nil {
+		return nil, err
 	}
 
 	log.Printf("Original transaction decoded. TxID: %s", originalTx.TxHash().String())
+	if !SignalsRBF(originalTx) {
+		return nil, fmt.Errorf("cannot bump the fee of %s: %w", originalTxID, ErrRBFNotSignaled)
+	}
+
+	// The original's inputs are already spent in the wallet's view, so look
+	// the outputs up from the transactions that created them
+	spent, err := spentOutputs(w, originalTx, electrumClient)
+	if err != nil {
+		return nil, fmt.Errorf("cannot replace transaction: %v", err)
+	}
 
 	// Create new transaction
 	newTx := wire.NewMsgTx(wire.TxVersion)
 
 	// Copy inputs from the original transaction
 	var totalIn int64
-	for _, txIn := range originalTx.TxIn {
+	var prevScripts [][]byte
+	for i, txIn := range originalTx.TxIn {
 		newTxIn := wire.NewTxIn(&txIn.PreviousOutPoint, nil, nil)
 		newTxIn.Sequence = RBFSequenceNumber // Enable RBF
 		newTx.AddTxIn(newTxIn)
 
-		// Fetch UTXO details
-		utxo, err := fetchUTXO(w, &txIn.PreviousOutPoint)
+		totalIn += int64(UTXOAmount(spent[i]))
+		scriptPubKey, err := decodeScriptPubKey(spent[i].ScriptPubKey)
 		if err != nil {
-			return chainhash.Hash{}, false, fmt.Errorf("failed to get UTXO for input: %v", err)
+			return nil, err
 		}
-		totalIn += int64(utxo.Amount * btcutil.SatoshiPerBitcoin)
+		prevScripts = append(prevScripts, scriptPubKey)
 	}
 
 	// Copy outputs from the original transaction
@@ -651,165 +367,119 @@ func ReplaceTransactionWithHigherFee(w *wallet.Wallet, service *neutrino.ChainSe
 		totalOut += txOut.Value
 	}
 
-	// Calculate new fee
-	txSize := newTx.SerializeSize()
-	newFee := btcutil.Amount(txSize * int(newFeeRate))
+	// Calculate new fee from the estimated signed size, paying at least what
+	// BIP125 requires to replace the original
 	oldFee := btcutil.Amount(totalIn - totalOut)
+	originalVBytes := TransactionVSize(originalTx)
+	txSize := EstimateTxVBytes(newTx, prevScripts)
+	newFee := ReplacementFee(oldFee, originalVBytes, txSize, newFeeRate)
 	extraFee := newFee - oldFee
 
-	if extraFee <= 0 {
-		return chainhash.Hash{}, false, fmt.Errorf("new fee is not higher than the original fee")
+	// Find the change output; outputs may have been shuffled, so it is not
+	// necessarily the last one
+	changeIndex := changeOutputIndex(w, newTx)
+	var changeValue btcutil.Amount
+	if changeIndex >= 0 {
+		changeValue = btcutil.Amount(newTx.TxOut[changeIndex].Value)
 	}
 
 	// Check if the change output can cover the extra fee
-	lastOutputIndex := len(newTx.TxOut) - 1
-	changeOutput := newTx.TxOut[lastOutputIndex]
-	if btcutil.Amount(changeOutput.Value) < extraFee {
+	if changeValue < extraFee {
 		log.Printf("Change output insufficient to cover new fee. Selecting additional UTXOs.")
 
 		// Calculate how much more we need
-		additionalFundsNeeded := extraFee - btcutil.Amount(changeOutput.Value)
+		additionalFundsNeeded := extraFee - changeValue
 
-		// Select additional UTXOs
-		additionalUTXOs, additionalAmount, err := selectAdditionalUTXOs(w, additionalFundsNeeded)
+		// Select additional UTXOs. The shortfall already pays for the
+		// replacement as it stands; the change output added below does not
+		// exist yet, so the selection has to fund it too.
+		changeVBytes := NewSelectionParams(nil, newFeeRate).ChangeVBytes
+		additionalUTXOs, additionalAmount, err := selectAdditionalUTXOs(w, additionalFundsNeeded, newFeeRate, changeVBytes)
 		if err != nil {
-			return chainhash.Hash{}, false, fmt.Errorf("failed to select additional UTXOs: %v", err)
+			return nil, fmt.Errorf("failed to select additional UTXOs: %v", err)
 		}
 
 		// Add new inputs
 		for _, utxo := range additionalUTXOs {
 			txHash, err := chainhash.NewHashFromStr(utxo.TxID)
 			if err != nil {
-				return chainhash.Hash{}, false, fmt.Errorf("failed to parse txid: %v", err)
+				return nil, fmt.Errorf("failed to parse txid: %v", err)
 			}
 			outpoint := wire.NewOutPoint(txHash, utxo.Vout)
 			txIn := wire.NewTxIn(outpoint, nil, nil)
 			txIn.Sequence = RBFSequenceNumber
 			newTx.AddTxIn(txIn)
+
+			scriptPubKey, err := hex.DecodeString(utxo.ScriptPubKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode scriptPubKey: %v", err)
+			}
+			prevScripts = append(prevScripts, scriptPubKey)
+			spent = append(spent, utxo)
 		}
 
 		// Adjust the total input amount
 		totalIn += int64(additionalAmount)
 
-		// Recalculate fee based on new transaction size
-		txSize = newTx.SerializeSize()
-		newFee = btcutil.Amount(txSize * int(newFeeRate))
-		extraFee = newFee - oldFee
+		// The old change is folded into the new one
+		if changeIndex >= 0 {
+			totalOut -= int64(changeValue)
+			newTx.TxOut = append(newTx.TxOut[:changeIndex], newTx.TxOut[changeIndex+1:]...)
+		}
 
 		// Create a new change output
 		changeAddr, err := getChangeAddress(w)
 		if err != nil {
-			return chainhash.Hash{}, false, fmt.Errorf("failed to generate new change address: %v", err)
+			return nil, fmt.Errorf("failed to generate new change address: %v", err)
 		}
 		changePkScript, err := txscript.PayToAddrScript(changeAddr)
 		if err != nil {
-			return chainhash.Hash{}, false, fmt.Errorf("failed to create change script: %v", err)
+			return nil, fmt.Errorf("failed to create change script: %v", err)
 		}
+		newChange := wire.NewTxOut(0, changePkScript)
+		newTx.AddTxOut(newChange)
+
+		// Recalculate fee based on new transaction size
+		txSize = EstimateTxVBytes(newTx, prevScripts)
+		newFee = ReplacementFee(oldFee, originalVBytes, txSize, newFeeRate)
+		extraFee = newFee - oldFee
 
 		newChangeAmount := btcutil.Amount(totalIn) - btcutil.Amount(totalOut) - newFee
-		if newChangeAmount > DustThreshold {
-			newTx.TxOut[lastOutputIndex] = wire.NewTxOut(int64(newChangeAmount), changePkScript)
+		if newChangeAmount >= DustLimit() {
+			newChange.Value = int64(newChangeAmount)
 		} else {
-			// If there's no change, remove the change output
-			newTx.TxOut = newTx.TxOut[:lastOutputIndex]
-		}
+			// What is left would be dust, so it goes to the fee
+			newTx.TxOut = newTx.TxOut[:len(newTx.TxOut)-1]
+			newFee = btcutil.Amount(totalIn - totalOut)
+			extraFee = newFee - oldFee
+		}
+	} else if newChangeAmount := changeValue - extraFee; newChangeAmount >= DustLimit() {
+		// Adjust the change output to accommodate the new fee
+		newTx.TxOut[changeIndex].Value = int64(newChangeAmount)
 	} else {
-		// Adjust the last output (change) to accommodate the new fee
-		newTx.TxOut[lastOutputIndex].Value -= int64(extraFee)
+		// What is left of the change would be dust, so it goes to the fee
+		newTx.TxOut = append(newTx.TxOut[:changeIndex], newTx.TxOut[changeIndex+1:]...)
+		newFee = oldFee + changeValue
+		extraFee = changeValue
 	}
 
+	// Re-apply the wallet's locktime and ordering policy to the replacement,
+	// keeping the spent outputs in input order
+	spent = applyPrivacyPolicy(w, newTx, spent)
+
 	// Check transaction weight
 	txWeight := newTx.SerializeSizeStripped()*3 + newTx.SerializeSize()
 	if txWeight > MaxStandardTxWeight {
-		return chainhash.Hash{}, false, fmt.Errorf("transaction weight (%d) exceeds maximum allowed (%d)", txWeight, MaxStandardTxWeight)
+		return nil, fmt.Errorf("transaction weight (%d) exceeds maximum allowed (%d)", txWeight, MaxStandardTxWeight)
 	}
 
-	// Sign the transaction
-	for i, txIn := range newTx.TxIn {
-		utxo, err := fetchUTXO(w, &txIn.PreviousOutPoint)
-		if err != nil {
-			return chainhash.Hash{}, false, fmt.Errorf("failed to get UTXO for input %d: %v", i, err)
-		}
-
-		scriptPubKey, err := hex.DecodeString(utxo.ScriptPubKey)
-		if err != nil {
-			return chainhash.Hash{}, false, fmt.Errorf("failed to decode scriptPubKey for input %d: %v", i, err)
-		}
-
-		inputAmount := int64(utxo.Amount * btcutil.SatoshiPerBitcoin)
-
-		_, addrs, _, err := txscript.ExtractPkScriptAddrs(scriptPubKey, w.ChainParams())
-		if err != nil {
-			return chainhash.Hash{}, false, fmt.Errorf("failed to extract address for input %d: %v", i, err)
-		}
-
-		if len(addrs) == 0 {
-			return chainhash.Hash{}, false, fmt.Errorf("no addresses found for input %d", i)
-		}
-
-		log.Printf("Processing input %d: Address: %s, Amount: %d", i, addrs[0].String(), inputAmount)
-
-		privKey, err := w.PrivKeyForAddress(addrs[0])
-		if err != nil {
-			return chainhash.Hash{}, false, fmt.Errorf("failed to get private key for input %d: %v", i, err)
-		}
-
-		prevOutputFetcher := txscript.NewCannedPrevOutputFetcher(scriptPubKey, inputAmount)
-
-		if txscript.IsPayToWitnessPubKeyHash(scriptPubKey) {
-			log.Printf("Input %d is SegWit", i)
-			witnessScript, err := txscript.WitnessSignature(newTx, txscript.NewTxSigHashes(newTx, prevOutputFetcher), i, inputAmount, scriptPubKey, txscript.SigHashAll, privKey, true)
-			if err != nil {
-				return chainhash.Hash{}, false, fmt.Errorf("failed to create witness script for input %d: %v", i, err)
-			}
-			newTx.TxIn[i].Witness = witnessScript
-			log.Printf("Witness script created for input %d", i)
-		} else {
-			log.Printf("Input %d is non-SegWit", i)
-			sigScript, err := txscript.SignatureScript(newTx, i, scriptPubKey, txscript.SigHashAll, privKey, true)
-			if err != nil {
-				return chainhash.Hash{}, false, fmt.Errorf("failed to create signature script for input %d: %v", i, err)
-			}
-			newTx.TxIn[i].SignatureScript = sigScript
-			log.Printf("Signature script created for input %d", i)
-		}
-
-		// Verify the signature
-		valid, err := verifySignature(newTx, i, scriptPubKey, inputAmount)
-		if err != nil {
-			log.Printf("Signature verification failed for input %d: %v", i, err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to verify signature for input %d: %v", i, err)
-		}
-		if !valid {
-			log.Printf("Invalid signature for input %d", i)
-			return chainhash.Hash{}, false, fmt.Errorf("signature verification failed for input %d", i)
-		}
-		log.Printf("Signature verification succeeded for input %d", i)
-	}
-
-	log.Printf("RBF Transaction created successfully. Details:")
-	log.Printf("  TxID: %s", newTx.TxHash().String())
-	log.Printf("  New fee: %d satoshis", newFee)
-	log.Printf("  Extra fee: %d satoshis", extraFee)
-	log.Printf("  Transaction size: %d vBytes", newTx.SerializeSize())
-	log.Printf("  Transaction weight: %d weight units", txWeight)
-	log.Printf("  Number of inputs: %d", len(newTx.TxIn))
-	log.Printf("  Number of outputs: %d", len(newTx.TxOut))
-
-	// Save the transaction in the database
-	_, err = walletstatedb.SaveTransactionToDB(newTx)
-	if err != nil {
-		return chainhash.Hash{}, false, err
-	}
-
-	// Broadcast and verify the transaction
-	txHash, verified, err := broadcastAndVerifyTransaction(newTx, service)
-	if err != nil {
-		return chainhash.Hash{}, false, fmt.Errorf("failed to broadcast and verify RBF transaction: %v", err)
-	}
-
-	log.Printf("RBF transaction successfully broadcast. New TxID: %s", txHash.String())
-	return txHash, verified, nil
+	return &feeBump{
+		tx:       newTx,
+		spent:    spent,
+		newFee:   newFee,
+		extraFee: extraFee,
+		weight:   txWeight,
+	}, nil
 }
 
 func RetrieveTransaction(txHash string) (string, error) {
@@ -827,34 +497,9 @@ func RetrieveTransaction(txHash string) (string, error) {
 
 func CreateTransactionWithHash(w *wallet.Wallet, service *neutrino.ChainService, enableRBF bool, spendAmount int64, recipientAddress string, fileHash string, privPass []byte) (chainhash.Hash, bool, error) {
 	log.Printf("Starting transaction creation process with file hash.")
-	// Reset locked outpoints
-	log.Printf("Resetting locked outpoints.")
-	w.ResetLockedOutpoints()
-
-	// Unlock wallet
-	log.Printf("Unlocking wallet.")
-	err := w.Unlock(privPass, nil)
-	if err != nil {
-		log.Printf("Failed to unlock wallet: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to unlock wallet: %v", err)
-	}
-
-	// Calculate wallet balance
-	balance, err := w.CalculateBalance(1)
+	recipientOutput, err := preparePayment(w, spendAmount, recipientAddress, privPass)
 	if err != nil {
-		log.Printf("Failed to calculate balance: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to calculate balance: %v", err)
-	}
-	log.Printf("Available balance: %s\n", balance.String())
-
-	// Set recipient address and amount
-	amountToSend := btcutil.Amount(spendAmount)
-	log.Printf("Recipient address: %s, Amount to send: %d satoshis", recipientAddress, amountToSend)
-
-	// Check sufficient balance
-	if balance < amountToSend {
-		log.Printf("Insufficient balance: have %d satoshis, want to send %d satoshis", int64(balance), amountToSend)
-		return chainhash.Hash{}, false, fmt.Errorf("insufficient balance: have %d satoshis, want to send %d satoshis", int64(balance), amountToSend)
+		return chainhash.Hash{}, false, err
 	}
 
 	// Get fee recommendation
@@ -881,8 +526,8 @@ func CreateTransactionWithHash(w *wallet.Wallet, service *neutrino.ChainService,
 	}
 	log.Printf("Found %d unspent outputs.", len(utxos))
 
-	// Select suitable UTXO
-	var selectedUTXO *btcjson.ListUnspentResult
+	// Only consider UTXOs that are still unspent according to mempool.space
+	var validUTXOs []*btcjson.ListUnspentResult
 	for _, utxo := range utxos {
 		log.Printf("Checking UTXO: %s:%d", utxo.TxID, utxo.Vout)
 
@@ -891,188 +536,24 @@ func CreateTransactionWithHash(w *wallet.Wallet, service *neutrino.ChainService,
 			log.Printf("UTXO %s:%d is invalid: %v", utxo.TxID, utxo.Vout, err)
 			continue
 		}
-
-		if btcutil.Amount(utxo.Amount*btcutil.SatoshiPerBitcoin) >= amountToSend+btcutil.Amount(feeRate) {
-			selectedUTXO = utxo
-			break
-		}
-	}
-
-	if selectedUTXO == nil {
-		log.Printf("No suitable UTXO found.")
-		return chainhash.Hash{}, false, fmt.Errorf("no suitable UTXO found")
-	}
-	log.Printf("Selected UTXO: %s:%d", selectedUTXO.TxID, selectedUTXO.Vout)
-
-	// Create new transaction
-	tx := wire.NewMsgTx(wire.TxVersion)
-	prevOutHash, err := chainhash.NewHashFromStr(selectedUTXO.TxID)
-	if err != nil {
-		log.Printf("Failed to parse txid: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to parse txid: %v", err)
-	}
-	prevOut := wire.NewOutPoint(prevOutHash, selectedUTXO.Vout)
-	txIn := wire.NewTxIn(prevOut, nil, nil)
-
-	if enableRBF {
-		txIn.Sequence = RBFSequenceNumber
-		log.Printf("RBF enabled for this transaction (sequence number: %d)", RBFSequenceNumber)
-	}
-
-	tx.AddTxIn(txIn)
-
-	// Add recipient output
-	recipientAddr, err := btcutil.DecodeAddress(recipientAddress, w.ChainParams())
-	if err != nil {
-		log.Printf("Failed to decode recipient address: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to decode recipient address: %v", err)
+		validUTXOs = append(validUTXOs, utxo)
 	}
-	pkScript, err := txscript.PayToAddrScript(recipientAddr)
-	if err != nil {
-		log.Printf("Failed to create output script: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to create output script: %v", err)
-	}
-	tx.AddTxOut(wire.NewTxOut(int64(amountToSend), pkScript))
 
-	// Add OP_RETURN output with file hash
+	// Build the OP_RETURN output with file hash
 	opReturnScript, err := txscript.NullDataScript([]byte(fileHash))
 	if err != nil {
 		log.Printf("Failed to create OP_RETURN script: %v", err)
 		return chainhash.Hash{}, false, fmt.Errorf("failed to create OP_RETURN script: %v", err)
 	}
-	tx.AddTxOut(wire.NewTxOut(0, opReturnScript))
-
-	// Calculate transaction size
-	txSize := tx.SerializeSize()
-
-	// Calculate the required fee
-	requiredFee := btcutil.Amount(txSize * int(feeRate))
-
-	// Calculate input and change amounts
-	inputAmount := btcutil.Amount(selectedUTXO.Amount * btcutil.SatoshiPerBitcoin)
-	changeAmount := inputAmount - amountToSend - requiredFee
-	if changeAmount > 0 {
-		changeAddr, err := getChangeAddress(w)
-		if err != nil {
-			log.Printf("Failed to get change address: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to get change address: %v", err)
-		}
-		changePkScript, err := txscript.PayToAddrScript(changeAddr)
-		if err != nil {
-			log.Printf("Failed to create change script: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to create change script: %v", err)
-		}
-		tx.AddTxOut(wire.NewTxOut(int64(changeAmount), changePkScript))
-	}
+	log.Printf("File hash included: %s", fileHash)
 
-	// Sign the transaction
-	utxoAddr, err := btcutil.DecodeAddress(selectedUTXO.Address, w.ChainParams())
-	if err != nil {
-		log.Printf("Failed to decode UTXO address: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to decode UTXO address: %v", err)
-	}
-	privKey, err := w.PrivKeyForAddress(utxoAddr)
-	if err != nil {
-		log.Printf("Failed to get private key: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to get private key: %v", err)
-	}
-	scriptPubKey, err := hex.DecodeString(selectedUTXO.ScriptPubKey)
+	// Select UTXOs with the configured coin selector
+	outputs := []*wire.TxOut{recipientOutput, wire.NewTxOut(0, opReturnScript)}
+	selection, err := SelectCoins(validUTXOs, outputs, int64(feeRate))
 	if err != nil {
-		log.Printf("Failed to decode scriptPubKey: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to decode scriptPubKey: %v", err)
+		log.Printf("Coin selection failed: %v", err)
+		return chainhash.Hash{}, false, fmt.Errorf("coin selection failed: %v", err)
 	}
 
-	prevOutputs := txscript.NewCannedPrevOutputFetcher(scriptPubKey, int64(inputAmount))
-
-	if isSegWitAddress(utxoAddr) {
-		// Create the witness script for SegWit inputs
-		witnessScript, err := txscript.WitnessSignature(tx, txscript.NewTxSigHashes(tx, prevOutputs), 0, int64(inputAmount), scriptPubKey, txscript.SigHashAll, privKey, true)
-		if err != nil {
-			log.Printf("Failed to create witness script: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to create witness script: %v", err)
-		}
-		tx.TxIn[0].Witness = witnessScript
-	} else {
-		// Create the signature script for non-SegWit inputs
-		sigScript, err := txscript.SignatureScript(tx, 0, scriptPubKey, txscript.SigHashAll, privKey, true)
-		if err != nil {
-			log.Printf("Failed to create signature script: %v", err)
-			return chainhash.Hash{}, false, fmt.Errorf("failed to create signature script: %v", err)
-		}
-		tx.TxIn[0].SignatureScript = sigScript
-	}
-
-	// Verify the signature
-	valid, err := verifySignature(tx, 0, scriptPubKey, int64(inputAmount))
-	if err != nil {
-		log.Printf("Failed to verify signature: %v", err)
-		return chainhash.Hash{}, false, fmt.Errorf("failed to verify signature: %v", err)
-	}
-	if !valid {
-		log.Printf("Signature verification failed")
-		return chainhash.Hash{}, false, fmt.Errorf("signature verification failed")
-	}
-	log.Printf("Signature verification succeeded")
-
-	log.Printf("Transaction created successfully. Details:")
-	log.Printf("  TxID: %s", tx.TxHash().String())
-	log.Printf("  Amount to send: %d satoshis", amountToSend)
-	log.Printf("  Fee: %d satoshis", requiredFee)
-	log.Printf("  Total input: %d satoshis", inputAmount)
-	log.Printf("  Change amount: %d satoshis", changeAmount)
-	log.Printf("  Transaction size: %d vBytes", tx.SerializeSize())
-	log.Printf("  Number of inputs: %d", len(tx.TxIn))
-	log.Printf("  Number of outputs: %d", len(tx.TxOut))
-	log.Printf("  File hash included: %s", fileHash)
-
-	log.Println("Detailed Transaction Information:")
-	log.Printf("TxID: %s", tx.TxHash())
-	log.Printf("Version: %d", tx.Version)
-	log.Printf("Locktime: %d", tx.LockTime)
-
-	log.Println("Inputs:")
-	for i, input := range tx.TxIn {
-		log.Printf("  Input %d:", i)
-		log.Printf("    Previous OutPoint: %s", input.PreviousOutPoint)
-		log.Printf("    Sequence: %d", input.Sequence)
-		log.Printf("    SignatureScript: %x", input.SignatureScript)
-		if len(input.Witness) > 0 {
-			log.Printf("    Witness: %x", input.Witness)
-		}
-	}
-
-	log.Println("Outputs:")
-	for i, output := range tx.TxOut {
-		log.Printf("  Output %d:", i)
-		log.Printf("    Value: %d satoshis", output.Value)
-		log.Printf("    PkScript: %x", output.PkScript)
-	}
-
-	// Save the transaction in the database
-	_, err = walletstatedb.SaveTransactionToDB(tx)
-	if err != nil {
-		return chainhash.Hash{}, false, err
-	}
-	// Create Electrum client once
-	electrumClient, err := CreateElectrumClient(ElectrumConfig{
-		ServerAddr: "electrum.blockstream.info:50002",
-		UseSSL:     true,
-	})
-	if err != nil {
-		return chainhash.Hash{}, false, fmt.Errorf("failed to create Electrum client: %v", err)
-	}
-	defer electrumClient.Shutdown()
-
-	txHash, verified, err := broadcastAndVerifyTransaction(tx, service)
-	if err != nil {
-		// Release the output we tried to spend
-		releaseErr := w.ReleaseOutput(wtxmgr.LockID(tx.TxHash()), tx.TxIn[0].PreviousOutPoint)
-		if releaseErr != nil {
-			log.Printf("Failed to release output: %v", releaseErr)
-		}
-		return chainhash.Hash{}, false, fmt.Errorf("failed to broadcast and verify transaction: %v", err)
-	}
-
-	log.Println("Transaction broadcast and verified successfully.")
-	return txHash, verified, nil
+	return sendTransaction(w, service, enableRBF, outputs, selection)
 }
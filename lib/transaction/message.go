@@ -0,0 +1,311 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+)
+
+// Message signature formats.
+const (
+	MessageFormatBIP322 = "bip322"
+	MessageFormatLegacy = "legacy"
+)
+
+const (
+	messageMagic  = "Bitcoin Signed Message:\n"
+	bip322Tag     = "BIP0322-signed-message"
+	compactSigLen = 65
+
+	// BIP137 header bases; the recovery id is added to each.
+	headerP2PKHCompressed = 31
+	headerP2SHP2WPKH      = 35
+	headerP2WPKH          = 39
+)
+
+// SignMessage signs message with the key of one of the wallet's addresses.
+// An empty format picks BIP322 simple for P2WPKH and P2TR addresses and the
+// legacy Bitcoin Signed Message format for the rest. The signature is base64
+// encoded.
+func SignMessage(w *wallet.Wallet, privPass []byte, address, message, format string) (string, string, error) {
+	addr, err := btcutil.DecodeAddress(address, w.ChainParams())
+	if err != nil {
+		return "", "", fmt.Errorf("invalid address: %v", err)
+	}
+	if !addr.IsForNet(w.ChainParams()) {
+		return "", "", fmt.Errorf("address %s is not for %s", address, w.ChainParams().Name)
+	}
+
+	if format == "" {
+		format = defaultMessageFormat(addr)
+	}
+
+	if err := unlockWallet(w, privPass); err != nil {
+		return "", "", err
+	}
+	// An unlock session outlives the signature; only a wallet unlocked here is locked again
+	if privPass != nil {
+		defer w.Lock()
+	}
+
+	privKey, err := w.PrivKeyForAddress(addr)
+	if err != nil {
+		log.Printf("Error fetching key for %s: %v", address, err)
+		return "", "", fmt.Errorf("address %s does not belong to this wallet or has no single signing key", address)
+	}
+
+	var signature string
+	switch format {
+	case MessageFormatBIP322:
+		signature, err = signBIP322Simple(privKey, addr, message)
+	case MessageFormatLegacy:
+		signature, err = signLegacyMessage(privKey, addr, message)
+	default:
+		return "", "", fmt.Errorf("unknown message format %q, use %s or %s", format, MessageFormatBIP322, MessageFormatLegacy)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return signature, format, nil
+}
+
+// VerifyMessage checks a base64 signature of message by address. Legacy and
+// BIP137 signatures are recognised by their length and header byte; anything
+// else is read as a BIP322 simple signature. It returns the format that was
+// checked.
+func VerifyMessage(address, signature, message string, params *chaincfg.Params) (bool, string, error) {
+	addr, err := btcutil.DecodeAddress(address, params)
+	if err != nil {
+		return false, "", fmt.Errorf("invalid address: %v", err)
+	}
+	if !addr.IsForNet(params) {
+		return false, "", fmt.Errorf("address %s is not for %s", address, params.Name)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, "", fmt.Errorf("signature is not valid base64: %v", err)
+	}
+
+	if len(sig) == compactSigLen && sig[0] >= 27 && sig[0] <= 42 {
+		valid, err := verifyLegacyMessage(addr, sig, message, params)
+		return valid, MessageFormatLegacy, err
+	}
+	valid, err := verifyBIP322Simple(addr, sig, message)
+	return valid, MessageFormatBIP322, err
+}
+
+// defaultMessageFormat returns the format a signature for addr is made in when
+// none is asked for.
+func defaultMessageFormat(addr btcutil.Address) string {
+	switch addr.(type) {
+	case *btcutil.AddressWitnessPubKeyHash, *btcutil.AddressTaproot:
+		return MessageFormatBIP322
+	}
+	return MessageFormatLegacy
+}
+
+// legacyMessageHash is the double SHA-256 of the magic prefixed message.
+func legacyMessageHash(message string) []byte {
+	var buf bytes.Buffer
+	wire.WriteVarString(&buf, 0, messageMagic)
+	wire.WriteVarString(&buf, 0, message)
+	return chainhash.DoubleHashB(buf.Bytes())
+}
+
+// signLegacyMessage makes a compact signature whose header follows BIP137, so
+// verifiers learn the address type from it.
+func signLegacyMessage(privKey *btcec.PrivateKey, addr btcutil.Address, message string) (string, error) {
+	var header byte
+	switch addr.(type) {
+	case *btcutil.AddressPubKeyHash:
+		header = headerP2PKHCompressed
+	case *btcutil.AddressScriptHash:
+		header = headerP2SHP2WPKH
+	case *btcutil.AddressWitnessPubKeyHash:
+		header = headerP2WPKH
+	default:
+		return "", fmt.Errorf("legacy signatures are not defined for %T addresses, use %s", addr, MessageFormatBIP322)
+	}
+
+	sig, err := ecdsa.SignCompact(privKey, legacyMessageHash(message), true)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign message: %v", err)
+	}
+	// SignCompact sets the compressed P2PKH header; move it to the address type
+	sig[0] = sig[0] - headerP2PKHCompressed + header
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// verifyLegacyMessage recovers the signing key and checks that it pays to
+// addr. The header is only used for the recovery id and compression, since
+// some signers always write the P2PKH header.
+func verifyLegacyMessage(addr btcutil.Address, sig []byte, message string, params *chaincfg.Params) (bool, error) {
+	header := sig[0]
+	compressed := header >= headerP2PKHCompressed
+	recovery := (header - 27) % 4
+
+	// RecoverCompact expects the header of a P2PKH signature
+	compact := append([]byte(nil), sig...)
+	compact[0] = 27 + recovery
+	if compressed {
+		compact[0] += 4
+	}
+
+	pubKey, _, err := ecdsa.RecoverCompact(compact, legacyMessageHash(message))
+	if err != nil {
+		return false, nil
+	}
+
+	var serialized []byte
+	if compressed {
+		serialized = pubKey.SerializeCompressed()
+	} else {
+		serialized = pubKey.SerializeUncompressed()
+	}
+	keyHash := btcutil.Hash160(serialized)
+
+	switch a := addr.(type) {
+	case *btcutil.AddressPubKeyHash:
+		return bytes.Equal(a.Hash160()[:], keyHash), nil
+	case *btcutil.AddressWitnessPubKeyHash:
+		return compressed && bytes.Equal(a.Hash160()[:], keyHash), nil
+	case *btcutil.AddressScriptHash:
+		if !compressed {
+			return false, nil
+		}
+		witnessAddr, err := btcutil.NewAddressWitnessPubKeyHash(keyHash, params)
+		if err != nil {
+			return false, err
+		}
+		redeemScript, err := txscript.PayToAddrScript(witnessAddr)
+		if err != nil {
+			return false, err
+		}
+		return bytes.Equal(a.Hash160()[:], btcutil.Hash160(redeemScript)), nil
+	default:
+		return false, fmt.Errorf("legacy signatures are not defined for %T addresses", addr)
+	}
+}
+
+// bip322Transactions builds the virtual to_spend and to_sign transactions of
+// BIP322 for message and the output script of the signing address.
+func bip322Transactions(pkScript []byte, message string) (*wire.MsgTx, *wire.MsgTx, error) {
+	messageHash := chainhash.TaggedHash([]byte(bip322Tag), []byte(message))
+	sigScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(messageHash[:]).Script()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	toSpend := wire.NewMsgTx(0)
+	spendIn := wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), sigScript, nil)
+	spendIn.Sequence = 0
+	toSpend.AddTxIn(spendIn)
+	toSpend.AddTxOut(wire.NewTxOut(0, pkScript))
+
+	toSpendHash := toSpend.TxHash()
+	toSign := wire.NewMsgTx(0)
+	signIn := wire.NewTxIn(wire.NewOutPoint(&toSpendHash, 0), nil, nil)
+	signIn.Sequence = 0
+	toSign.AddTxIn(signIn)
+	toSign.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+
+	return toSpend, toSign, nil
+}
+
+// signBIP322Simple signs the BIP322 to_sign transaction and returns its
+// witness, the simple signature.
+func signBIP322Simple(privKey *btcec.PrivateKey, addr btcutil.Address, message string) (string, error) {
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to build output script: %v", err)
+	}
+	_, toSign, err := bip322Transactions(pkScript, message)
+	if err != nil {
+		return "", fmt.Errorf("failed to build BIP322 transactions: %v", err)
+	}
+
+	fetcher := txscript.NewCannedPrevOutputFetcher(pkScript, 0)
+	sigHashes := txscript.NewTxSigHashes(toSign, fetcher)
+
+	var witness wire.TxWitness
+	switch addr.(type) {
+	case *btcutil.AddressWitnessPubKeyHash:
+		witness, err = txscript.WitnessSignature(toSign, sigHashes, 0, 0, pkScript, txscript.SigHashAll, privKey, true)
+	case *btcutil.AddressTaproot:
+		var sig []byte
+		sig, err = txscript.RawTxInTaprootSignature(toSign, sigHashes, 0, 0, pkScript, nil, txscript.SigHashDefault, privKey)
+		witness = wire.TxWitness{sig}
+	default:
+		return "", fmt.Errorf("BIP322 simple signatures need a P2WPKH or P2TR address, use %s for %T", MessageFormatLegacy, addr)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to sign message: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := wire.WriteVarInt(&buf, 0, uint64(len(witness))); err != nil {
+		return "", err
+	}
+	for _, item := range witness {
+		if err := wire.WriteVarBytes(&buf, 0, item); err != nil {
+			return "", err
+		}
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// verifyBIP322Simple runs the script of addr against the witness in sig as
+// the input of the BIP322 to_sign transaction.
+func verifyBIP322Simple(addr btcutil.Address, sig []byte, message string) (bool, error) {
+	switch addr.(type) {
+	case *btcutil.AddressWitnessPubKeyHash, *btcutil.AddressWitnessScriptHash, *btcutil.AddressTaproot:
+	default:
+		return false, fmt.Errorf("BIP322 simple signatures need a segwit address, got %T", addr)
+	}
+
+	r := bytes.NewReader(sig)
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil || count > uint64(len(sig)) {
+		return false, fmt.Errorf("malformed BIP322 signature")
+	}
+	witness := make(wire.TxWitness, 0, count)
+	for i := uint64(0); i < count; i++ {
+		item, err := wire.ReadVarBytes(r, 0, txscript.MaxScriptSize, "witness item")
+		if err != nil {
+			return false, fmt.Errorf("malformed BIP322 signature: %v", err)
+		}
+		witness = append(witness, item)
+	}
+	if r.Len() != 0 {
+		return false, fmt.Errorf("malformed BIP322 signature: %d trailing bytes", r.Len())
+	}
+
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return false, fmt.Errorf("failed to build output script: %v", err)
+	}
+	_, toSign, err := bip322Transactions(pkScript, message)
+	if err != nil {
+		return false, fmt.Errorf("failed to build BIP322 transactions: %v", err)
+	}
+	toSign.TxIn[0].Witness = witness
+
+	fetcher := txscript.NewCannedPrevOutputFetcher(pkScript, 0)
+	sigHashes := txscript.NewTxSigHashes(toSign, fetcher)
+	engine, err := txscript.NewEngine(pkScript, toSign, 0, txscript.StandardVerifyFlags, nil, sigHashes, 0, fetcher)
+	if err != nil {
+		return false, fmt.Errorf("failed to create script engine: %v", err)
+	}
+	return engine.Execute() == nil, nil
+}
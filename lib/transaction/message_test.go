@@ -0,0 +1,242 @@
+package transaction
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// The BIP322 test vectors sign with this key.
+const bip322WIF = "L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k"
+
+func bip322Key(t *testing.T) *btcutil.WIF {
+	t.Helper()
+	wif, err := btcutil.DecodeWIF(bip322WIF)
+	if err != nil {
+		t.Fatalf("decode WIF: %v", err)
+	}
+	return wif
+}
+
+func decodeTestAddress(t *testing.T, address string) btcutil.Address {
+	t.Helper()
+	addr, err := btcutil.DecodeAddress(address, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("decode address: %v", err)
+	}
+	return addr
+}
+
+func TestBIP322MessageHash(t *testing.T) {
+	tests := map[string]string{
+		"":            "c90c269c4f8fcbe6880f72a721ddfbf1914268a794cbb21cfafee13770ae19f1",
+		"Hello World": "f0eb03b1a75ac6d9847f55c624a99169b5dccba2a31f5b23bea77ba270de0a7a",
+	}
+	for message, want := range tests {
+		hash := chainhash.TaggedHash([]byte(bip322Tag), []byte(message))
+		if got := hex.EncodeToString(hash[:]); got != want {
+			t.Errorf("hash of %q is %s, want %s", message, got, want)
+		}
+	}
+}
+
+func TestBIP322Transactions(t *testing.T) {
+	addr := decodeTestAddress(t, "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l")
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		message string
+		toSpend string
+		toSign  string
+	}{
+		{"", "c5680aa69bb8d860bf82d4e9cd3504b55dde018de765a91bb566283c545a99a7", "1e9654e951a5ba44c8604c4de6c67fd78a27e81dcadcfe1edf638ba3aaebaed6"},
+		{"Hello World", "b79d196740ad5217771c1098fc4a4b51e0535c32236c71f1ea4d61a2d603352b", "88737ae86f2077145f93cc4b153ae9a1cb8d56afa511988c149c5c8c9d93bddf"},
+	}
+	for _, tt := range tests {
+		toSpend, toSign, err := bip322Transactions(pkScript, tt.message)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.message, err)
+		}
+		if got := toSpend.TxHash().String(); got != tt.toSpend {
+			t.Errorf("%q: to_spend %s, want %s", tt.message, got, tt.toSpend)
+		}
+		if got := toSign.TxHash().String(); got != tt.toSign {
+			t.Errorf("%q: to_sign %s, want %s", tt.message, got, tt.toSign)
+		}
+	}
+}
+
+func TestBIP322SimpleVectors(t *testing.T) {
+	tests := []struct {
+		name      string
+		address   string
+		message   string
+		signature string
+	}{
+		{
+			name:      "P2WPKH empty message",
+			address:   "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l",
+			message:   "",
+			signature: "AkcwRAIgM2gBAQqvZX15ZiysmKmQpDrG83avLIT492QBzLnQIxYCIBaTpOaD20qRlEylyxFSeEA2ba9YOixpX8z46TSDtS40ASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+		},
+		{
+			name:      "P2WPKH Hello World",
+			address:   "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l",
+			message:   "Hello World",
+			signature: "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+		},
+		{
+			name:      "P2WPKH Hello World, high-R signature",
+			address:   "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l",
+			message:   "Hello World",
+			signature: "AkgwRQIhAOzyynlqt93lOKJr+wmmxIens//zPzl9tqIOua93wO6MAiBi5n5EyAcPScOjf1lAqIUIQtr3zKNeavYabHyR8eGhowEhAsfxIAMZZEKUPYWI4BruhAQjzFT8FSFSajuFwrDL1Yhy",
+		},
+		{
+			name:      "P2TR Hello World",
+			address:   "bc1ppv609nr0vr25u07u95waq5lucwfm6tde4nydujnu8npg4q75mr5sxq8lt3",
+			message:   "Hello World",
+			signature: "AUHd69PrJQEv+oKTfZ8l+WROBHuy9HKrbFCJu7U1iK2iiEy1vMU5EfMtjc+VSHM7aU0SDbak5IUZRVno2P5mjSafAQ==",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, format, err := VerifyMessage(tt.address, tt.signature, tt.message, &chaincfg.MainNetParams)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if !valid || format != MessageFormatBIP322 {
+				t.Errorf("got valid=%v format=%s, want a valid %s signature", valid, format, MessageFormatBIP322)
+			}
+
+			valid, _, err = VerifyMessage(tt.address, tt.signature, tt.message+"!", &chaincfg.MainNetParams)
+			if err != nil {
+				t.Fatalf("verify altered message: %v", err)
+			}
+			if valid {
+				t.Error("signature verified for a different message")
+			}
+		})
+	}
+}
+
+func TestSignBIP322Simple(t *testing.T) {
+	wif := bip322Key(t)
+	addresses := []string{
+		"bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l",
+		"bc1ppv609nr0vr25u07u95waq5lucwfm6tde4nydujnu8npg4q75mr5sxq8lt3",
+	}
+	for _, address := range addresses {
+		addr := decodeTestAddress(t, address)
+		for _, message := range []string{"", "Hello World"} {
+			signature, err := signBIP322Simple(wif.PrivKey, addr, message)
+			if err != nil {
+				t.Fatalf("%s %q: sign: %v", address, message, err)
+			}
+			sig, err := base64.StdEncoding.DecodeString(signature)
+			if err != nil {
+				t.Fatal(err)
+			}
+			valid, err := verifyBIP322Simple(addr, sig, message)
+			if err != nil || !valid {
+				t.Errorf("%s %q: own signature did not verify: %v", address, message, err)
+			}
+		}
+	}
+}
+
+func TestSignBIP322SimpleDeterministic(t *testing.T) {
+	// RFC6979 nonces without low-R grinding give the spec's second
+	// P2WPKH "Hello World" signature
+	const want = "AkgwRQIhAOzyynlqt93lOKJr+wmmxIens//zPzl9tqIOua93wO6MAiBi5n5EyAcPScOjf1lAqIUIQtr3zKNeavYabHyR8eGhowEhAsfxIAMZZEKUPYWI4BruhAQjzFT8FSFSajuFwrDL1Yhy"
+
+	addr := decodeTestAddress(t, "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l")
+	signature, err := signBIP322Simple(bip322Key(t).PrivKey, addr, "Hello World")
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if signature != want {
+		t.Errorf("signature %s, want %s", signature, want)
+	}
+}
+
+func TestLegacyMessageRoundTrip(t *testing.T) {
+	wif := bip322Key(t)
+	params := &chaincfg.MainNetParams
+	keyHash := btcutil.Hash160(wif.PrivKey.PubKey().SerializeCompressed())
+
+	p2pkh, err := btcutil.NewAddressPubKeyHash(keyHash, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2wpkh, err := btcutil.NewAddressWitnessPubKeyHash(keyHash, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redeemScript, err := txscript.PayToAddrScript(p2wpkh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2shP2wpkh, err := btcutil.NewAddressScriptHash(redeemScript, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		addr   btcutil.Address
+		header byte
+	}{
+		{"P2PKH", p2pkh, headerP2PKHCompressed},
+		{"P2SH-P2WPKH", p2shP2wpkh, headerP2SHP2WPKH},
+		{"P2WPKH", p2wpkh, headerP2WPKH},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signature, err := signLegacyMessage(wif.PrivKey, tt.addr, "Hello World")
+			if err != nil {
+				t.Fatalf("sign: %v", err)
+			}
+			sig, err := base64.StdEncoding.DecodeString(signature)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sig) != compactSigLen || sig[0] < tt.header || sig[0] > tt.header+3 {
+				t.Fatalf("header %d, want %d to %d", sig[0], tt.header, tt.header+3)
+			}
+
+			valid, format, err := VerifyMessage(tt.addr.EncodeAddress(), signature, "Hello World", params)
+			if err != nil || !valid || format != MessageFormatLegacy {
+				t.Errorf("got valid=%v format=%s err=%v, want a valid %s signature", valid, format, err, MessageFormatLegacy)
+			}
+
+			valid, err = verifyLegacyMessage(tt.addr, sig, "Hello World!", params)
+			if err != nil || valid {
+				t.Errorf("altered message: valid=%v err=%v, want invalid", valid, err)
+			}
+		})
+	}
+}
+
+func TestLegacyMessageWrongAddress(t *testing.T) {
+	wif := bip322Key(t)
+	addr := decodeTestAddress(t, "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l")
+	signature, err := signLegacyMessage(wif.PrivKey, addr, "Hello World")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := decodeTestAddress(t, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4")
+	valid, _, err := VerifyMessage(other.EncodeAddress(), signature, "Hello World", &chaincfg.MainNetParams)
+	if err != nil || valid {
+		t.Errorf("got valid=%v err=%v for another address, want invalid", valid, err)
+	}
+}
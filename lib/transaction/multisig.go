@@ -0,0 +1,206 @@
+package transaction
+
+import (
+	"fmt"
+	"log"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+	"golang.org/x/exp/rand"
+)
+
+// multisigAddressPath returns the descriptor branch and index of a multisig
+// address from its entry in the SQLite address pool.
+func multisigAddressPath(addr btcutil.Address) (uint32, uint32, error) {
+	addrType, entry, err := walletstatedb.GetAddressType(addr.EncodeAddress())
+	if err != nil {
+		return 0, 0, err
+	}
+	if entry.Index == 0 {
+		return 0, 0, fmt.Errorf("address %s has no pool index", addr)
+	}
+
+	branch := multisig.BranchReceive
+	if addrType == "change" {
+		branch = multisig.BranchChange
+	}
+	return branch, uint32(entry.Index - 1), nil
+}
+
+// isMultisigChange reports whether addr is one of the multisig wallet's
+// change addresses.
+func isMultisigChange(addr btcutil.Address) bool {
+	if multisig.Active() == nil {
+		return false
+	}
+	branch, _, err := multisigAddressPath(addr)
+	return err == nil && branch == multisig.BranchChange
+}
+
+// findUnusedMultisigChangeAddress picks a random change address from the pool
+// that no transaction has paid yet, importing the next one when all are used.
+func findUnusedMultisigChangeAddress(w *wallet.Wallet) (btcutil.Address, error) {
+	pool, err := walletstatedb.GetAddressesWithType("change")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read change addresses: %v", err)
+	}
+
+	transactions, err := w.ListTransactions(0, 1<<31-1)
+	if err != nil {
+		return nil, fmt.Errorf("error listing transactions: %v", err)
+	}
+	usedAddresses := make(map[string]bool)
+	for _, tx := range transactions {
+		usedAddresses[tx.Address] = true
+	}
+
+	var unusedAddresses []btcutil.Address
+	for _, entry := range pool {
+		if usedAddresses[entry.Address] {
+			continue
+		}
+		addr, err := btcutil.DecodeAddress(entry.Address, w.ChainParams())
+		if err != nil {
+			continue
+		}
+		unusedAddresses = append(unusedAddresses, addr)
+	}
+
+	if len(unusedAddresses) > 0 {
+		changeAddr := unusedAddresses[rand.Intn(len(unusedAddresses))]
+		log.Printf("Using existing unused multisig change address: %s", changeAddr.String())
+		return changeAddr, nil
+	}
+
+	lastIndex, err := walletstatedb.GetLastAddressIndexWithType("change")
+	if err != nil {
+		return nil, fmt.Errorf("error getting last change address index: %v", err)
+	}
+	poolIndex := uint(lastIndex + 1)
+	newAddr, err := multisig.PoolAddress(w, multisig.BranchChange, poolIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate new change address: %v", err)
+	}
+	err = walletstatedb.SaveAddressWithType("change", walletstatedb.Address{
+		Index:         poolIndex,
+		Address:       newAddr.EncodeAddress(),
+		Status:        walletstatedb.AddressStatusAvailable,
+		ScriptType:    utils.AddressScriptType(newAddr),
+		SentToBackend: false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save change address: %v", err)
+	}
+	log.Printf("Generated new multisig change address: %s", newAddr.String())
+	return newAddr, nil
+}
+
+// addMultisigInputInfo attaches the witness script and every cosigner's key
+// derivation to input i when it spends one of the multisig wallet's outputs.
+func addMultisigInputInfo(w *wallet.Wallet, packet *psbt.Packet, i int, prevOut *wire.TxOut) error {
+	m := multisig.Active()
+	if m == nil || !txscript.IsPayToWitnessScriptHash(prevOut.PkScript) {
+		return nil
+	}
+
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(prevOut.PkScript, w.ChainParams())
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("failed to extract address for input %d", i)
+	}
+	branch, index, err := multisigAddressPath(addrs[0])
+	if err != nil {
+		return fmt.Errorf("input %d is not a multisig address of this wallet: %v", i, err)
+	}
+
+	witnessScript, err := m.WitnessScript(branch, index, w.ChainParams())
+	if err != nil {
+		return fmt.Errorf("failed to build witness script for input %d: %v", i, err)
+	}
+	derivations, err := m.Derivations(branch, index)
+	if err != nil {
+		return fmt.Errorf("failed to derive cosigner keys for input %d: %v", i, err)
+	}
+
+	packet.Inputs[i].WitnessScript = witnessScript
+	packet.Inputs[i].Bip32Derivation = derivations
+	return nil
+}
+
+// signMultisigInput adds the wallet's signature to a P2WSH multisig input and
+// reports whether it did. Inputs that already carry the wallet's signature or
+// whose derivations name none of its keys are left alone.
+func signMultisigInput(packet *psbt.Packet, updater *psbt.Updater, sigHashes *txscript.TxSigHashes, i int, prevOut *wire.TxOut) (bool, error) {
+	input := packet.Inputs[i]
+	if !multisig.HasSigningKey() || len(input.WitnessScript) == 0 {
+		return false, nil
+	}
+	// The session may have ended since SignPSBT checked it
+	signer := multisig.Signer()
+	if signer == nil {
+		return false, ErrWalletLocked
+	}
+
+	for _, derivation := range input.Bip32Derivation {
+		privKey, err := signer.PrivKey(derivation)
+		if err != nil {
+			return false, fmt.Errorf("failed to derive signing key for input %d: %v", i, err)
+		}
+		if privKey == nil {
+			continue
+		}
+		for _, partial := range input.PartialSigs {
+			if string(partial.PubKey) == string(derivation.PubKey) {
+				return false, nil
+			}
+		}
+
+		sig, err := txscript.RawTxInWitnessSignature(packet.UnsignedTx, sigHashes, i, prevOut.Value, input.WitnessScript, txscript.SigHashAll, privKey)
+		if err != nil {
+			return false, fmt.Errorf("failed to sign multisig input %d: %v", i, err)
+		}
+		if _, err := updater.Sign(i, sig, derivation.PubKey, nil, input.WitnessScript); err != nil {
+			return false, fmt.Errorf("failed to add signature for input %d: %v", i, err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// checkMultisigSignatures fails when a multisig input of packet holds fewer
+// partial signatures than its script requires, naming how many are missing.
+func checkMultisigSignatures(packet *psbt.Packet) error {
+	for i, input := range packet.Inputs {
+		if len(input.FinalScriptWitness) > 0 || len(input.WitnessScript) == 0 {
+			continue
+		}
+		_, required, err := txscript.CalcMultiSigStats(input.WitnessScript)
+		if err != nil {
+			continue
+		}
+		if len(input.PartialSigs) < required {
+			return fmt.Errorf("input %d has %d of %d required signatures", i, len(input.PartialSigs), required)
+		}
+	}
+	return nil
+}
+
+// AddMultisigSignature signs packet with the multisig wallet's own key, so a
+// spend it creates only needs the cosigners' signatures. It returns how many
+// inputs were signed and does nothing in other wallets.
+func AddMultisigSignature(w *wallet.Wallet, packet *psbt.Packet) (int, error) {
+	if !multisig.HasSigningKey() {
+		return 0, nil
+	}
+	return SignPSBT(w, packet, nil)
+}
+
+// IsMultisig reports whether the open wallet is a multisig wallet.
+func IsMultisig() bool {
+	return multisig.Active() != nil
+}
@@ -0,0 +1,116 @@
+package transaction
+
+import (
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil/txsort"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/spf13/viper"
+)
+
+const (
+	// Input and output orderings accepted by the tx_ordering config key.
+	TxOrderingBIP69  = "bip69"
+	TxOrderingRandom = "random"
+	TxOrderingNone   = "none"
+
+	// antiFeeSnipingMaxTipAge is how stale the wallet's view of the chain may
+	// be before the current height stops being a safe locktime.
+	antiFeeSnipingMaxTipAge = 8 * time.Hour
+)
+
+// PrivacyPolicy controls how the builders set nLockTime and order inputs and
+// outputs.
+type PrivacyPolicy struct {
+	AntiFeeSniping bool
+	Ordering       string
+}
+
+// LoadPrivacyPolicy reads the anti_fee_sniping and tx_ordering settings of
+// the active wallet.
+func LoadPrivacyPolicy() PrivacyPolicy {
+	policy := PrivacyPolicy{
+		AntiFeeSniping: viper.GetBool(utils.WalletConfigKey("anti_fee_sniping")),
+		Ordering:       viper.GetString(utils.WalletConfigKey("tx_ordering")),
+	}
+
+	switch policy.Ordering {
+	case TxOrderingBIP69, TxOrderingRandom, TxOrderingNone:
+	default:
+		log.Printf("Unknown tx_ordering %q, using %s", policy.Ordering, TxOrderingRandom)
+		policy.Ordering = TxOrderingRandom
+	}
+	return policy
+}
+
+// AntiFeeSnipingLockTime returns the wallet's synced height as a locktime, so
+// the transaction cannot be mined in a reorg of an earlier block. One time in
+// ten it is pushed back by up to 99 blocks, so delayed broadcasts do not stand
+// out. It returns 0 when the wallet is not close to the chain tip.
+func AntiFeeSnipingLockTime(w *wallet.Wallet) uint32 {
+	synced := w.Manager.SyncedTo()
+	if synced.Height <= 0 || time.Since(synced.Timestamp) > antiFeeSnipingMaxTipAge {
+		return 0
+	}
+
+	lockTime := uint32(synced.Height)
+	if rand.Intn(10) == 0 {
+		backdate := uint32(rand.Intn(100))
+		if backdate > lockTime {
+			backdate = lockTime
+		}
+		lockTime -= backdate
+	}
+	return lockTime
+}
+
+// applyPrivacyPolicy sets the anti-fee-sniping locktime and reorders the
+// inputs and outputs of an unsigned tx according to the active wallet's
+// policy. utxos must line up with tx.TxIn; they are returned in the new input
+// order so they can be passed straight to the signer. A locktime that is
+// already set is kept.
+func applyPrivacyPolicy(w *wallet.Wallet, tx *wire.MsgTx, utxos []*btcjson.ListUnspentResult) []*btcjson.ListUnspentResult {
+	policy := LoadPrivacyPolicy()
+
+	if policy.AntiFeeSniping && tx.LockTime == 0 {
+		tx.LockTime = AntiFeeSnipingLockTime(w)
+	}
+	if tx.LockTime != 0 {
+		// A locktime is ignored when every input is final
+		for _, txIn := range tx.TxIn {
+			if txIn.Sequence == wire.MaxTxInSequenceNum {
+				txIn.Sequence = wire.MaxTxInSequenceNum - 1
+			}
+		}
+	}
+
+	switch policy.Ordering {
+	case TxOrderingBIP69:
+		txsort.InPlaceSort(tx)
+	case TxOrderingRandom:
+		rand.Shuffle(len(tx.TxIn), func(i, j int) {
+			tx.TxIn[i], tx.TxIn[j] = tx.TxIn[j], tx.TxIn[i]
+		})
+		rand.Shuffle(len(tx.TxOut), func(i, j int) {
+			tx.TxOut[i], tx.TxOut[j] = tx.TxOut[j], tx.TxOut[i]
+		})
+	}
+
+	if len(utxos) == 0 {
+		return utxos
+	}
+	byOutpoint := make(map[string]*btcjson.ListUnspentResult, len(utxos))
+	for _, utxo := range utxos {
+		byOutpoint[utxoOutpoint(utxo)] = utxo
+	}
+	ordered := make([]*btcjson.ListUnspentResult, 0, len(tx.TxIn))
+	for _, txIn := range tx.TxIn {
+		ordered = append(ordered, byOutpoint[txIn.PreviousOutPoint.String()])
+	}
+	return ordered
+}
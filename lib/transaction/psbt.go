@@ -0,0 +1,510 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/lightninglabs/neutrino"
+)
+
+// psbtMagic prefixes every binary PSBT (BIP174).
+var psbtMagic = []byte{0x70, 0x73, 0x62, 0x74, 0xff}
+
+// DecodePSBT parses a PSBT in either binary or base64 form. Only version 0
+// packets are understood; BIP370 version 2 packets carry no unsigned
+// transaction and are rejected by the parser.
+func DecodePSBT(data []byte) (*psbt.Packet, error) {
+	if bytes.HasPrefix(data, psbtMagic) {
+		packet, err := psbt.NewFromRawBytes(bytes.NewReader(data), false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse binary PSBT: %v", err)
+		}
+		return packet, nil
+	}
+
+	packet, err := psbt.NewFromRawBytes(bytes.NewReader(bytes.TrimSpace(data)), true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base64 PSBT: %v", err)
+	}
+	return packet, nil
+}
+
+// EncodePSBT returns the base64 form of a PSBT.
+func EncodePSBT(packet *psbt.Packet) (string, error) {
+	return packet.B64Encode()
+}
+
+// ReadPSBTFile loads a PSBT from a file in either binary or base64 form.
+func ReadPSBTFile(path string) (*psbt.Packet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PSBT file: %v", err)
+	}
+	return DecodePSBT(data)
+}
+
+// WritePSBTFile writes a PSBT to path, as raw bytes when binary is set and as
+// base64 text otherwise.
+func WritePSBTFile(path string, packet *psbt.Packet, binary bool) error {
+	var buf bytes.Buffer
+	if err := packet.Serialize(&buf); err != nil {
+		return fmt.Errorf("failed to serialize PSBT: %v", err)
+	}
+
+	data := buf.Bytes()
+	if !binary {
+		data = []byte(base64.StdEncoding.EncodeToString(data) + "\n")
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write PSBT file: %v", err)
+	}
+	return nil
+}
+
+// CreatePSBT builds an unsigned PSBT paying spendAmount to recipientAddress.
+// Inputs are chosen by the same coin selector the send paths use unless
+// given explicitly, and each input carries the UTXO and BIP32 data a signer
+// needs.
+func CreatePSBT(w *wallet.Wallet, enableRBF bool, spendAmount int64, recipientAddress string, feeRate int, inputs []wire.OutPoint) (*psbt.Packet, error) {
+	log.Printf("Creating PSBT paying %d satoshis to %s at %d sat/vB", spendAmount, recipientAddress, feeRate)
+
+	recipientAddr, err := btcutil.DecodeAddress(recipientAddress, w.ChainParams())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode recipient address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(recipientAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output script: %v", err)
+	}
+	outputs := []*wire.TxOut{wire.NewTxOut(spendAmount, pkScript)}
+
+	return createPSBTForOutputs(w, enableRBF, outputs, feeRate, inputs)
+}
+
+// CreateBatchPSBT builds an unsigned PSBT paying every recipient and, when
+// opReturn is set, carrying it in an OP_RETURN output.
+func CreateBatchPSBT(w *wallet.Wallet, enableRBF bool, recipients []Recipient, opReturn []byte, feeRate int, inputs []wire.OutPoint) (*psbt.Packet, error) {
+	log.Printf("Creating PSBT paying %d recipients at %d sat/vB", len(recipients), feeRate)
+
+	outputs, err := BuildPaymentOutputs(w, recipients, opReturn)
+	if err != nil {
+		return nil, err
+	}
+
+	return createPSBTForOutputs(w, enableRBF, outputs, feeRate, inputs)
+}
+
+// createPSBTForOutputs funds outputs and wraps the unsigned result in a PSBT.
+func createPSBTForOutputs(w *wallet.Wallet, enableRBF bool, outputs []*wire.TxOut, feeRate int, inputs []wire.OutPoint) (*psbt.Packet, error) {
+	selection, err := selectSpendCoins(w, outputs, int64(feeRate), inputs)
+	if err != nil {
+		return nil, fmt.Errorf("coin selection failed: %v", err)
+	}
+
+	tx, err := buildUnsignedTransaction(w, enableRBF, outputs, selection)
+	if err != nil {
+		return nil, err
+	}
+
+	packet, err := psbt.NewFromUnsignedTx(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PSBT: %v", err)
+	}
+	if err := addPSBTInputInfo(w, packet, selection.Inputs); err != nil {
+		return nil, err
+	}
+
+	log.Printf("PSBT created with %d inputs, %d outputs and a fee of %d satoshis", len(tx.TxIn), len(tx.TxOut), selection.Fee)
+	return packet, nil
+}
+
+// buildUnsignedTransaction assembles the inputs of a coin selection, the given
+// outputs and, when the selection calls for one, a change output.
+func buildUnsignedTransaction(w *wallet.Wallet, enableRBF bool, outputs []*wire.TxOut, selection *CoinSelection) (*wire.MsgTx, error) {
+	tx := wire.NewMsgTx(wire.TxVersion)
+
+	for _, utxo := range selection.Inputs {
+		prevOutHash, err := chainhash.NewHashFromStr(utxo.TxID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse txid: %v", err)
+		}
+		txIn := wire.NewTxIn(wire.NewOutPoint(prevOutHash, utxo.Vout), nil, nil)
+		if enableRBF {
+			txIn.Sequence = RBFSequenceNumber
+		}
+		tx.AddTxIn(txIn)
+	}
+
+	for _, out := range outputs {
+		tx.AddTxOut(out)
+	}
+
+	if selection.Change > 0 {
+		changeAddr, err := getChangeAddress(w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get change address: %v", err)
+		}
+		changePkScript, err := txscript.PayToAddrScript(changeAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create change script: %v", err)
+		}
+		tx.AddTxOut(wire.NewTxOut(int64(selection.Change), changePkScript))
+	}
+
+	// Keep the selection in input order for the signer
+	selection.Inputs = applyPrivacyPolicy(w, tx, selection.Inputs)
+
+	return tx, nil
+}
+
+// addPSBTInputInfo attaches the spent outputs and key derivations the wallet
+// knows about to every input of packet. spent, when given, lists the outputs
+// the inputs spend in input order and is used when the wallet cannot supply
+// the previous transaction.
+func addPSBTInputInfo(w *wallet.Wallet, packet *psbt.Packet, spent []*btcjson.ListUnspentResult) error {
+	updater, err := psbt.NewUpdater(packet)
+	if err != nil {
+		return fmt.Errorf("failed to create PSBT updater: %v", err)
+	}
+
+	for i, txIn := range packet.UnsignedTx.TxIn {
+		prevTx, prevOut, derivation, _, err := w.FetchInputInfo(&txIn.PreviousOutPoint)
+		if err != nil {
+			// Fall back to the UTXO set when the full transaction is not
+			// available, e.g. before the chain client is attached.
+			var utxo *btcjson.ListUnspentResult
+			if len(spent) == len(packet.UnsignedTx.TxIn) {
+				utxo = spent[i]
+			} else {
+				var utxoErr error
+				utxo, utxoErr = fetchUTXO(w, &txIn.PreviousOutPoint)
+				if utxoErr != nil {
+					return fmt.Errorf("failed to fetch input %d: %v", i, utxoErr)
+				}
+			}
+			scriptPubKey, decodeErr := decodeScriptPubKey(utxo.ScriptPubKey)
+			if decodeErr != nil {
+				return decodeErr
+			}
+			witnessUtxo := wire.NewTxOut(int64(UTXOAmount(utxo)), scriptPubKey)
+			if err := updater.AddInWitnessUtxo(witnessUtxo, i); err != nil {
+				return fmt.Errorf("failed to add witness UTXO for input %d: %v", i, err)
+			}
+			if err := addMultisigInputInfo(w, packet, i, witnessUtxo); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Segwit v0 signers want the full previous transaction as well as
+		// the witness UTXO, see bitcoin/bitcoin#19215.
+		if !txscript.IsPayToTaproot(prevOut.PkScript) {
+			if err := updater.AddInNonWitnessUtxo(prevTx, i); err != nil {
+				return fmt.Errorf("failed to add previous transaction for input %d: %v", i, err)
+			}
+		}
+		if txscript.IsWitnessProgram(prevOut.PkScript) || txscript.IsPayToScriptHash(prevOut.PkScript) {
+			if err := updater.AddInWitnessUtxo(prevOut, i); err != nil {
+				return fmt.Errorf("failed to add witness UTXO for input %d: %v", i, err)
+			}
+		}
+		if derivation != nil {
+			packet.Inputs[i].Bip32Derivation = append(packet.Inputs[i].Bip32Derivation, derivation)
+		}
+		if err := addMultisigInputInfo(w, packet, i, prevOut); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// psbtPrevOutput returns the output spent by input i of packet.
+func psbtPrevOutput(packet *psbt.Packet, i int) (*wire.TxOut, error) {
+	input := packet.Inputs[i]
+	if input.WitnessUtxo != nil {
+		return input.WitnessUtxo, nil
+	}
+	if input.NonWitnessUtxo != nil {
+		index := packet.UnsignedTx.TxIn[i].PreviousOutPoint.Index
+		if int(index) >= len(input.NonWitnessUtxo.TxOut) {
+			return nil, fmt.Errorf("input %d references missing output %d", i, index)
+		}
+		return input.NonWitnessUtxo.TxOut[index], nil
+	}
+	return nil, fmt.Errorf("input %d has no UTXO information", i)
+}
+
+// trustedPrevOutput returns the output spent by input i of packet and
+// whether it is the wallet's. The wallet's own inputs take their amount from
+// a source the PSBT cannot forge: the wallet's records or a previous
+// transaction whose hash matches the outpoint. A witness UTXO alone is not
+// enough for them, as legacy signatures do not commit to the amount and the
+// spending policy would see whatever the PSBT claims. Inputs of other parties
+// are taken as the PSBT gives them; the policy only counts what leaves the
+// wallet's own inputs.
+func trustedPrevOutput(w *wallet.Wallet, packet *psbt.Packet, i int) (*wire.TxOut, bool, error) {
+	outpoint := packet.UnsignedTx.TxIn[i].PreviousOutPoint
+	if _, prevOut, _, _, err := w.FetchInputInfo(&outpoint); err == nil {
+		return prevOut, true, nil
+	}
+	if utxo, err := fetchUTXO(w, &outpoint); err == nil {
+		scriptPubKey, err := decodeScriptPubKey(utxo.ScriptPubKey)
+		if err != nil {
+			return nil, false, err
+		}
+		return wire.NewTxOut(int64(UTXOAmount(utxo)), scriptPubKey), true, nil
+	}
+
+	prevOut, err := psbtPrevOutput(packet, i)
+	if err != nil {
+		return nil, false, err
+	}
+	if prevTx := packet.Inputs[i].NonWitnessUtxo; prevTx != nil {
+		if prevTx.TxHash() != outpoint.Hash {
+			return nil, false, fmt.Errorf("previous transaction of input %d does not match outpoint %s", i, outpoint)
+		}
+		if int(outpoint.Index) >= len(prevTx.TxOut) {
+			return nil, false, fmt.Errorf("input %d references missing output %d", i, outpoint.Index)
+		}
+		prevOut = prevTx.TxOut[outpoint.Index]
+	}
+
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(prevOut.PkScript, w.ChainParams())
+	ours := err == nil && len(addrs) == 1 && isWalletAddress(w, addrs[0])
+	if ours && packet.Inputs[i].NonWitnessUtxo == nil {
+		return nil, false, fmt.Errorf("input %d spends %s, which the wallet does not know and the PSBT carries no previous transaction for", i, outpoint)
+	}
+	return prevOut, ours, nil
+}
+
+// SignPSBT adds the wallet's signatures to every input it holds keys for and
+// returns how many inputs it signed. Inputs owned by other parties are left
+// untouched so the packet can be passed on to co-signers, and multisig inputs
+// get one partial signature for the other cosigners to add to. The spending
+// policy applies as to any other spend.
+func SignPSBT(w *wallet.Wallet, packet *psbt.Packet, privPass []byte) (int, error) {
+	// Multisig wallets sign with the key in their descriptor; the address
+	// manager behind them holds no private keys to unlock, so only the
+	// descriptor key has to be loaded.
+	if multisig.Active() == nil {
+		log.Printf("Unlocking wallet.")
+		if err := unlockWallet(w, privPass); err != nil {
+			log.Printf("Failed to unlock wallet: %v", err)
+			return 0, err
+		}
+	} else if err := CheckUnlocked(w, privPass); err != nil {
+		return 0, err
+	}
+
+	if err := psbt.InputsReadyToSign(packet); err != nil {
+		return 0, fmt.Errorf("PSBT is not ready to sign: %v", err)
+	}
+
+	updater, err := psbt.NewUpdater(packet)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create PSBT updater: %v", err)
+	}
+
+	tx := packet.UnsignedTx
+	sigHashes := txscript.NewTxSigHashes(tx, wallet.PsbtPrevOutputFetcher(packet))
+
+	prevOuts := make([]*wire.TxOut, len(tx.TxIn))
+	own := make([]bool, len(tx.TxIn))
+	for i := range tx.TxIn {
+		if prevOuts[i], own[i], err = trustedPrevOutput(w, packet, i); err != nil {
+			return 0, err
+		}
+	}
+	spendingMutex.Lock()
+	defer spendingMutex.Unlock()
+	spend, err := checkSpendingPolicy(w, tx, prevOuts, own)
+	if err != nil {
+		return 0, err
+	}
+
+	signed := 0
+	for i := range tx.TxIn {
+		input := packet.Inputs[i]
+		if len(input.FinalScriptWitness) > 0 || len(input.FinalScriptSig) > 0 {
+			continue
+		}
+
+		prevOut, err := psbtPrevOutput(packet, i)
+		if err != nil {
+			return signed, err
+		}
+
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(prevOut.PkScript, w.ChainParams())
+		if err != nil || len(addrs) == 0 {
+			log.Printf("Skipping input %d: unable to extract address", i)
+			continue
+		}
+
+		managedAddr, err := w.AddressInfo(addrs[0])
+		if err != nil {
+			log.Printf("Skipping input %d: address %s is not ours", i, addrs[0])
+			continue
+		}
+
+		if txscript.IsPayToWitnessScriptHash(prevOut.PkScript) {
+			ok, err := signMultisigInput(packet, updater, sigHashes, i, prevOut)
+			if err != nil {
+				return signed, err
+			}
+			if ok {
+				log.Printf("Signed PSBT multisig input %d for address %s", i, managedAddr.Address())
+				signed++
+			}
+			continue
+		}
+
+		privKey, err := w.PrivKeyForAddress(addrs[0])
+		if err != nil {
+			return signed, fmt.Errorf("failed to get private key for input %d: %v", i, err)
+		}
+		pubKey := privKey.PubKey().SerializeCompressed()
+
+		switch {
+		case txscript.IsPayToTaproot(prevOut.PkScript):
+			sig, err := txscript.RawTxInTaprootSignature(tx, sigHashes, i, prevOut.Value, prevOut.PkScript, nil, txscript.SigHashDefault, privKey)
+			if err != nil {
+				return signed, fmt.Errorf("failed to sign taproot input %d: %v", i, err)
+			}
+			packet.Inputs[i].TaprootKeySpendSig = sig
+
+		case txscript.IsPayToWitnessPubKeyHash(prevOut.PkScript):
+			sig, err := txscript.RawTxInWitnessSignature(tx, sigHashes, i, prevOut.Value, prevOut.PkScript, txscript.SigHashAll, privKey)
+			if err != nil {
+				return signed, fmt.Errorf("failed to sign input %d: %v", i, err)
+			}
+			if _, err := updater.Sign(i, sig, pubKey, nil, nil); err != nil {
+				return signed, fmt.Errorf("failed to add signature for input %d: %v", i, err)
+			}
+
+		case txscript.IsPayToScriptHash(prevOut.PkScript):
+			// Nested P2WPKH: the redeem script is the witness program and
+			// the sighash commits to it rather than the P2SH script.
+			p2wkhAddr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey), w.ChainParams())
+			if err != nil {
+				return signed, fmt.Errorf("failed to derive witness address for input %d: %v", i, err)
+			}
+			witnessProgram, err := txscript.PayToAddrScript(p2wkhAddr)
+			if err != nil {
+				return signed, fmt.Errorf("failed to build witness program for input %d: %v", i, err)
+			}
+			sig, err := txscript.RawTxInWitnessSignature(tx, sigHashes, i, prevOut.Value, witnessProgram, txscript.SigHashAll, privKey)
+			if err != nil {
+				return signed, fmt.Errorf("failed to sign input %d: %v", i, err)
+			}
+			if _, err := updater.Sign(i, sig, pubKey, witnessProgram, nil); err != nil {
+				return signed, fmt.Errorf("failed to add signature for input %d: %v", i, err)
+			}
+
+		default:
+			sig, err := txscript.RawTxInSignature(tx, i, prevOut.PkScript, txscript.SigHashAll, privKey)
+			if err != nil {
+				return signed, fmt.Errorf("failed to sign input %d: %v", i, err)
+			}
+			if _, err := updater.Sign(i, sig, pubKey, nil, nil); err != nil {
+				return signed, fmt.Errorf("failed to add signature for input %d: %v", i, err)
+			}
+		}
+
+		log.Printf("Signed PSBT input %d for address %s", i, managedAddr.Address())
+		signed++
+	}
+
+	if signed > 0 {
+		if err := recordSpend(spend, tx); err != nil {
+			return signed, err
+		}
+	}
+	return signed, nil
+}
+
+// FinalizePSBT finalizes every input of packet and extracts the network
+// transaction. It fails if any input is still missing signatures.
+func FinalizePSBT(packet *psbt.Packet) (*wire.MsgTx, error) {
+	if !packet.IsComplete() {
+		if err := checkMultisigSignatures(packet); err != nil {
+			return nil, err
+		}
+		if err := psbt.MaybeFinalizeAll(packet); err != nil {
+			return nil, fmt.Errorf("failed to finalize PSBT: %v", err)
+		}
+	}
+
+	tx, err := psbt.Extract(packet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract transaction from PSBT: %v", err)
+	}
+
+	// Run the script engine over every input before the transaction can be
+	// broadcast.
+	for i := range tx.TxIn {
+		prevOut, err := psbtPrevOutput(packet, i)
+		if err != nil {
+			return nil, err
+		}
+		valid, err := verifySignatureWithFetcher(tx, i, prevOut, wallet.PsbtPrevOutputFetcher(packet))
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify signature for input %d: %v", i, err)
+		}
+		if !valid {
+			return nil, fmt.Errorf("signature verification failed for input %d", i)
+		}
+	}
+
+	return tx, nil
+}
+
+// BroadcastPSBT finalizes packet if needed, stores the resulting transaction
+// and broadcasts it.
+func BroadcastPSBT(w *wallet.Wallet, service *neutrino.ChainService, packet *psbt.Packet) (chainhash.Hash, bool, error) {
+	tx, err := FinalizePSBT(packet)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	fee, err := packet.GetTxFee()
+	if err == nil {
+		log.Printf("Broadcasting PSBT transaction %s with a fee of %d satoshis", tx.TxHash(), fee)
+	}
+
+	_, err = walletstatedb.SaveTransactionToDB(tx)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	txHash, verified, err := broadcastAndVerifyTransaction(tx, service)
+	if err != nil {
+		releaseSpend(tx)
+		return chainhash.Hash{}, false, fmt.Errorf("failed to broadcast and verify transaction: %v", err)
+	}
+
+	log.Println("PSBT transaction broadcast and verified successfully.")
+	return txHash, verified, nil
+}
+
+// SerializeTransactionHex returns the hex encoded network serialization of tx.
+func SerializeTransactionHex(tx *wire.MsgTx) (string, error) {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", fmt.Errorf("failed to serialize transaction: %v", err)
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
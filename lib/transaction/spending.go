@@ -0,0 +1,422 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/spf13/viper"
+)
+
+const (
+	// Rules of the spending policy, as named in the policy file and in
+	// PolicyViolation.Rule.
+	PolicyMaxTxAmount   = "max_tx_amount"
+	PolicyDailyLimit    = "daily_limit"
+	PolicyMonthlyLimit  = "monthly_limit"
+	PolicyAllowlist     = "allowlist"
+	PolicyDenylist      = "denylist"
+	PolicyMaxFeeRate    = "max_fee_rate"
+	PolicyMaxFeePercent = "max_fee_percent"
+
+	dailyWindow   = 24 * time.Hour
+	monthlyWindow = 30 * 24 * time.Hour
+
+	// pendingSpendTimeout is how long a signed transaction that has not been
+	// broadcast counts against the rolling limits. It covers the gap between
+	// signing and broadcasting, so concurrent spends cannot both fit under a
+	// limit, without counting PSBTs that are never broadcast. Scheduled
+	// transactions count until they are broadcast or cancelled instead.
+	pendingSpendTimeout = time.Hour
+)
+
+// SpendingPolicy limits what the wallet signs. Amounts are in satoshis and
+// count the outputs paying addresses outside the wallet, fees excluded. A
+// zero or empty rule is off.
+type SpendingPolicy struct {
+	MaxTxAmount   int64    `json:"max_tx_amount"`
+	DailyLimit    int64    `json:"daily_limit"`   // rolling 24 hours
+	MonthlyLimit  int64    `json:"monthly_limit"` // rolling 30 days
+	Allowlist     []string `json:"allowlist"`
+	Denylist      []string `json:"denylist"`
+	MaxFeeRate    int64    `json:"max_fee_rate"`    // sat/vB
+	MaxFeePercent float64  `json:"max_fee_percent"` // of the amount, or of the inputs when nothing leaves the wallet
+}
+
+// PolicyViolation is returned when the spending policy refuses to sign a
+// transaction. Limit and Value are in the unit of the rule.
+type PolicyViolation struct {
+	Rule    string  `json:"rule"`
+	Message string  `json:"message"`
+	Limit   float64 `json:"limit,omitempty"`
+	Value   float64 `json:"value,omitempty"`
+	Address string  `json:"address,omitempty"`
+}
+
+func (v *PolicyViolation) Error() string {
+	return "spending policy: " + v.Message
+}
+
+// SpendingUsage is what the wallet has broadcast, or is about to, in the
+// rolling windows of the spending policy.
+type SpendingUsage struct {
+	Daily   int64 `json:"daily"`
+	Monthly int64 `json:"monthly"`
+}
+
+// spendingMutex is held from the policy check until the spend is recorded as
+// pending, so concurrent spends cannot both fit under a rolling limit.
+var spendingMutex sync.Mutex
+
+// LoadSpendingPolicy reads the spending_policy_file of the active wallet, or
+// returns nil when none is configured. The file is read on every spend, so
+// edits apply without a restart. Unknown rules are refused rather than
+// ignored, since a misspelt limit would otherwise be silently off.
+func LoadSpendingPolicy() (*SpendingPolicy, error) {
+	path := viper.GetString(utils.WalletConfigKey("spending_policy_file"))
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spending policy: %v", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var policy SpendingPolicy
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("invalid spending policy %s: %v", path, err)
+	}
+	if policy.MaxTxAmount < 0 || policy.DailyLimit < 0 || policy.MonthlyLimit < 0 || policy.MaxFeeRate < 0 || policy.MaxFeePercent < 0 {
+		return nil, fmt.Errorf("invalid spending policy %s: limits must not be negative", path)
+	}
+	return &policy, nil
+}
+
+// GetSpendingUsage sums the amounts broadcast in the last 24 hours and 30
+// days, along with recently signed transactions still waiting to be.
+func GetSpendingUsage() (SpendingUsage, error) {
+	now := time.Now()
+	spends, err := walletstatedb.GetSpendsSince(now.Add(-monthlyWindow))
+	if err != nil {
+		return SpendingUsage{}, err
+	}
+	scheduled, err := scheduledTxIDs()
+	if err != nil {
+		return SpendingUsage{}, err
+	}
+	return rollingUsage(spends, now, scheduled, nil), nil
+}
+
+// checkSpendingPolicy evaluates the unsigned tx against the active spending
+// policy. prevOuts are the outputs its inputs spend, in input order, and own
+// marks the inputs that are the wallet's; nil means all of them. It returns
+// the spend to record once tx is signed. Recorded spends tx replaces by
+// sharing an input do not count against the rolling limits. The caller must
+// hold spendingMutex.
+func checkSpendingPolicy(w *wallet.Wallet, tx *wire.MsgTx, prevOuts []*wire.TxOut, own []bool) (*walletstatedb.Spend, error) {
+	policy, err := LoadSpendingPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	var allowed, denied map[string]bool
+	if policy != nil {
+		if allowed, err = policyAddresses(policy.Allowlist, w); err != nil {
+			return nil, err
+		}
+		if denied, err = policyAddresses(policy.Denylist, w); err != nil {
+			return nil, err
+		}
+	}
+
+	ours := func(addr btcutil.Address) bool { return isWalletAddress(w, addr) }
+	spend, ownInput, err := describeSpend(tx, prevOuts, own, w.ChainParams(), ours, allowed, denied)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	recent, err := walletstatedb.GetSpendsSince(now.Add(-monthlyWindow))
+	if err != nil {
+		return nil, err
+	}
+	scheduled, err := scheduledTxIDs()
+	if err != nil {
+		return nil, err
+	}
+	spent := make(map[string]bool, len(spend.Inputs))
+	for _, input := range spend.Inputs {
+		spent[input] = true
+	}
+	usage := rollingUsage(recent, now, scheduled, spent)
+
+	if policy != nil {
+		prevScripts := make([][]byte, len(prevOuts))
+		for i, prevOut := range prevOuts {
+			prevScripts[i] = prevOut.PkScript
+		}
+		vbytes := EstimateTxVBytes(tx, prevScripts)
+		if violation := policy.evaluate(spend, vbytes, ownInput, usage); violation != nil {
+			log.Printf("Spending policy refused transaction: %s", violation.Message)
+			return nil, violation
+		}
+	}
+	return spend, nil
+}
+
+// describeSpend works out what tx sends out of the wallet, checking every
+// external output against the allowlist and denylist. ours reports whether
+// an address belongs to the wallet, and own marks the wallet's inputs as in
+// checkSpendingPolicy. It returns the spend and the total of the wallet's
+// inputs.
+//
+// When other parties contribute inputs, as in a collaborative PSBT, the
+// external outputs are partly theirs, so the amount is capped at what the
+// wallet's own inputs lose: their total less the outputs paying the wallet.
+func describeSpend(tx *wire.MsgTx, prevOuts []*wire.TxOut, own []bool, params *chaincfg.Params, ours func(btcutil.Address) bool, allowed, denied map[string]bool) (*walletstatedb.Spend, int64, error) {
+	spend := &walletstatedb.Spend{}
+	var inputTotal, ownInput, outputTotal, toWallet int64
+	foreign := false
+	for i, prevOut := range prevOuts {
+		inputTotal += prevOut.Value
+		if own != nil && !own[i] {
+			foreign = true
+			continue
+		}
+		spend.Inputs = append(spend.Inputs, tx.TxIn[i].PreviousOutPoint.String())
+		ownInput += prevOut.Value
+	}
+
+	for i, txOut := range tx.TxOut {
+		outputTotal += txOut.Value
+
+		class, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript, params)
+		if err == nil && class == txscript.NullDataTy {
+			// Value burned in a data output still leaves the wallet
+			spend.Amount += txOut.Value
+			continue
+		}
+		if err != nil || len(addrs) != 1 {
+			if len(allowed) > 0 {
+				return nil, 0, &PolicyViolation{
+					Rule:    PolicyAllowlist,
+					Message: fmt.Sprintf("output %d pays a script with no address, which the allowlist cannot match", i),
+				}
+			}
+			spend.Amount += txOut.Value
+			continue
+		}
+		if ours(addrs[0]) {
+			toWallet += txOut.Value
+			continue
+		}
+
+		address := addrs[0].EncodeAddress()
+		if denied[address] {
+			return nil, 0, &PolicyViolation{
+				Rule:    PolicyDenylist,
+				Message: fmt.Sprintf("recipient %s is on the denylist", address),
+				Address: address,
+			}
+		}
+		if len(allowed) > 0 && !allowed[address] {
+			return nil, 0, &PolicyViolation{
+				Rule:    PolicyAllowlist,
+				Message: fmt.Sprintf("recipient %s is not on the allowlist", address),
+				Address: address,
+			}
+		}
+		spend.Amount += txOut.Value
+		spend.Recipients = append(spend.Recipients, address)
+	}
+	spend.Fee = inputTotal - outputTotal
+
+	if foreign {
+		outflow := ownInput - toWallet
+		if outflow < 0 {
+			outflow = 0
+		}
+		if spend.Amount > outflow {
+			spend.Amount = outflow
+		}
+	}
+	return spend, ownInput, nil
+}
+
+// rollingUsage sums spends into the daily and monthly windows ending at now,
+// skipping those that no longer count and those sharing an outpoint in
+// spent, which the transaction being checked replaces.
+func rollingUsage(spends []walletstatedb.Spend, now time.Time, scheduled, spent map[string]bool) SpendingUsage {
+	var usage SpendingUsage
+	for _, spend := range spends {
+		if sharesInput(spend, spent) || !countsTowardLimits(spend, now, scheduled) {
+			continue
+		}
+		if spend.CreatedAt.After(now.Add(-monthlyWindow)) {
+			usage.Monthly += spend.Amount
+		}
+		if spend.CreatedAt.After(now.Add(-dailyWindow)) {
+			usage.Daily += spend.Amount
+		}
+	}
+	return usage
+}
+
+// evaluate applies the amount and fee rules to spend, a transaction of
+// vbytes spending inputTotal, given what already counts in the rolling
+// windows.
+func (p *SpendingPolicy) evaluate(spend *walletstatedb.Spend, vbytes int, inputTotal int64, usage SpendingUsage) *PolicyViolation {
+	if p.MaxTxAmount > 0 && spend.Amount > p.MaxTxAmount {
+		return &PolicyViolation{
+			Rule:    PolicyMaxTxAmount,
+			Message: fmt.Sprintf("amount of %d sats exceeds the %d sat limit per transaction", spend.Amount, p.MaxTxAmount),
+			Limit:   float64(p.MaxTxAmount),
+			Value:   float64(spend.Amount),
+		}
+	}
+
+	if p.MaxFeeRate > 0 && vbytes > 0 && spend.Fee > p.MaxFeeRate*int64(vbytes) {
+		feeRate := (spend.Fee + int64(vbytes) - 1) / int64(vbytes)
+		return &PolicyViolation{
+			Rule:    PolicyMaxFeeRate,
+			Message: fmt.Sprintf("fee rate of %d sat/vB exceeds the %d sat/vB limit", feeRate, p.MaxFeeRate),
+			Limit:   float64(p.MaxFeeRate),
+			Value:   float64(feeRate),
+		}
+	}
+
+	// Transactions back to the wallet, such as CPFP children and
+	// cancellations, send nothing, so their fee is weighed against the inputs
+	base, baseName := spend.Amount, "amount"
+	if base == 0 {
+		base, baseName = inputTotal, "inputs"
+	}
+	if p.MaxFeePercent > 0 && base > 0 && float64(spend.Fee)*100 > p.MaxFeePercent*float64(base) {
+		percent := float64(spend.Fee) * 100 / float64(base)
+		return &PolicyViolation{
+			Rule:    PolicyMaxFeePercent,
+			Message: fmt.Sprintf("fee of %d sats is %.2f%% of the %s, over the %.2f%% limit", spend.Fee, percent, baseName, p.MaxFeePercent),
+			Limit:   p.MaxFeePercent,
+			Value:   percent,
+		}
+	}
+
+	if p.DailyLimit > 0 && usage.Daily+spend.Amount > p.DailyLimit {
+		return &PolicyViolation{
+			Rule:    PolicyDailyLimit,
+			Message: fmt.Sprintf("amount of %d sats would bring the last 24 hours to %d sats, over the %d sat limit", spend.Amount, usage.Daily+spend.Amount, p.DailyLimit),
+			Limit:   float64(p.DailyLimit),
+			Value:   float64(usage.Daily + spend.Amount),
+		}
+	}
+
+	if p.MonthlyLimit > 0 && usage.Monthly+spend.Amount > p.MonthlyLimit {
+		return &PolicyViolation{
+			Rule:    PolicyMonthlyLimit,
+			Message: fmt.Sprintf("amount of %d sats would bring the last 30 days to %d sats, over the %d sat limit", spend.Amount, usage.Monthly+spend.Amount, p.MonthlyLimit),
+			Limit:   float64(p.MonthlyLimit),
+			Value:   float64(usage.Monthly + spend.Amount),
+		}
+	}
+
+	return nil
+}
+
+// recordSpend stores spend under the txid of the now signed tx, pending until
+// the transaction is broadcast.
+func recordSpend(spend *walletstatedb.Spend, tx *wire.MsgTx) error {
+	spend.TxID = tx.TxHash().String()
+	spend.Pending = true
+	if err := walletstatedb.SaveSpend(spend); err != nil {
+		return fmt.Errorf("failed to record spend: %v", err)
+	}
+	return nil
+}
+
+// spendBroadcast moves the spend recorded for tx out of pending, dropping the
+// spends it replaced.
+func spendBroadcast(tx *wire.MsgTx) {
+	if err := walletstatedb.MarkSpendBroadcast(tx.TxHash().String()); err != nil {
+		log.Printf("Failed to record broadcast of %s against the spending limits: %v", tx.TxHash(), err)
+	}
+}
+
+// releaseSpend forgets the spend recorded for tx, which failed to broadcast
+// and will not be retried.
+func releaseSpend(tx *wire.MsgTx) {
+	if err := walletstatedb.DeleteSpend(tx.TxHash().String()); err != nil {
+		log.Printf("Failed to release spend of %s: %v", tx.TxHash(), err)
+	}
+}
+
+// countsTowardLimits reports whether spend counts against the rolling limits
+// at now: once broadcast, while waiting for its locktime in scheduled, or
+// while recently signed.
+func countsTowardLimits(spend walletstatedb.Spend, now time.Time, scheduled map[string]bool) bool {
+	return !spend.Pending || scheduled[spend.TxID] || spend.CreatedAt.After(now.Add(-pendingSpendTimeout))
+}
+
+// scheduledTxIDs returns the txids of scheduled transactions that are still
+// waiting to be broadcast.
+func scheduledTxIDs() (map[string]bool, error) {
+	pending, err := walletstatedb.GetScheduledTransactions(walletstatedb.ScheduledStatusScheduled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scheduled transactions: %v", err)
+	}
+	txIDs := make(map[string]bool, len(pending))
+	for _, st := range pending {
+		txIDs[st.TxID] = true
+	}
+	return txIDs, nil
+}
+
+// policyAddresses decodes an allowlist or denylist into the set of its
+// encoded addresses, so entries match however they were written.
+func policyAddresses(list []string, w *wallet.Wallet) (map[string]bool, error) {
+	addresses := make(map[string]bool, len(list))
+	for _, entry := range list {
+		addr, err := btcutil.DecodeAddress(entry, w.ChainParams())
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q in spending policy: %v", entry, err)
+		}
+		addresses[addr.EncodeAddress()] = true
+	}
+	return addresses, nil
+}
+
+// isWalletAddress reports whether addr belongs to the wallet or, for a
+// multisig wallet, to its descriptor.
+func isWalletAddress(w *wallet.Wallet, addr btcutil.Address) bool {
+	if multisig.Active() != nil {
+		if _, _, err := multisigAddressPath(addr); err == nil {
+			return true
+		}
+	}
+	ours, err := w.HaveAddress(addr)
+	return err == nil && ours
+}
+
+// sharesInput reports whether spend spent any of the outpoints in spent.
+func sharesInput(spend walletstatedb.Spend, spent map[string]bool) bool {
+	for _, input := range spend.Inputs {
+		if spent[input] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,400 @@
+package transaction
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testAddress returns a mainnet P2WPKH address whose key hash is id repeated,
+// and its scriptPubKey.
+func testAddress(t *testing.T, id byte) (string, []byte) {
+	t.Helper()
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(bytes.Repeat([]byte{id}, 20), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("create address: %v", err)
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("create script: %v", err)
+	}
+	return addr.EncodeAddress(), script
+}
+
+// testSpendTx spends one input per value in inputs, each from a distinct
+// outpoint, to outputs.
+func testSpendTx(inputScript []byte, inputs []int64, outputs ...*wire.TxOut) (*wire.MsgTx, []*wire.TxOut) {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	prevOuts := make([]*wire.TxOut, len(inputs))
+	for i, value := range inputs {
+		hash := chainhash.Hash{byte(i + 1)}
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&hash, 0), nil, nil))
+		prevOuts[i] = wire.NewTxOut(value, inputScript)
+	}
+	for _, out := range outputs {
+		tx.AddTxOut(out)
+	}
+	return tx, prevOuts
+}
+
+func TestDescribeSpend(t *testing.T) {
+	wallet, walletScript := testAddress(t, 1)
+	alice, aliceScript := testAddress(t, 2)
+	bob, bobScript := testAddress(t, 3)
+	nullData, err := txscript.NullDataScript([]byte("anchor"))
+	if err != nil {
+		t.Fatalf("create null data script: %v", err)
+	}
+	bareScript := []byte{txscript.OP_TRUE}
+
+	ours := func(addr btcutil.Address) bool { return addr.EncodeAddress() == wallet }
+
+	tests := []struct {
+		name           string
+		inputs         []int64
+		own            []bool
+		outputs        []*wire.TxOut
+		allowed        []string
+		denied         []string
+		wantAmount     int64
+		wantFee        int64
+		wantOwnInput   int64
+		wantRecipients []string
+		wantRule       string
+	}{
+		{
+			name:           "payment with change",
+			inputs:         []int64{100000},
+			outputs:        []*wire.TxOut{wire.NewTxOut(60000, aliceScript), wire.NewTxOut(39000, walletScript)},
+			wantAmount:     60000,
+			wantFee:        1000,
+			wantOwnInput:   100000,
+			wantRecipients: []string{alice},
+		},
+		{
+			name:         "self-spend sends nothing",
+			inputs:       []int64{50000, 50000},
+			outputs:      []*wire.TxOut{wire.NewTxOut(99000, walletScript)},
+			wantFee:      1000,
+			wantOwnInput: 100000,
+		},
+		{
+			name:         "null data output counts as sent",
+			inputs:       []int64{100000},
+			outputs:      []*wire.TxOut{wire.NewTxOut(500, nullData), wire.NewTxOut(98500, walletScript)},
+			wantAmount:   500,
+			wantFee:      1000,
+			wantOwnInput: 100000,
+		},
+		{
+			name:         "output without an address counts as sent",
+			inputs:       []int64{100000},
+			outputs:      []*wire.TxOut{wire.NewTxOut(20000, bareScript), wire.NewTxOut(79000, walletScript)},
+			wantAmount:   20000,
+			wantFee:      1000,
+			wantOwnInput: 100000,
+		},
+		{
+			name:     "output without an address fails an allowlist",
+			inputs:   []int64{100000},
+			outputs:  []*wire.TxOut{wire.NewTxOut(20000, bareScript)},
+			allowed:  []string{alice},
+			wantRule: PolicyAllowlist,
+		},
+		{
+			name:         "null data output passes an allowlist",
+			inputs:       []int64{100000},
+			outputs:      []*wire.TxOut{wire.NewTxOut(0, nullData), wire.NewTxOut(99000, walletScript)},
+			allowed:      []string{alice},
+			wantFee:      1000,
+			wantOwnInput: 100000,
+		},
+		{
+			name:           "allowlisted recipient",
+			inputs:         []int64{100000},
+			outputs:        []*wire.TxOut{wire.NewTxOut(60000, aliceScript)},
+			allowed:        []string{alice},
+			wantAmount:     60000,
+			wantFee:        40000,
+			wantOwnInput:   100000,
+			wantRecipients: []string{alice},
+		},
+		{
+			name:     "recipient missing from the allowlist",
+			inputs:   []int64{100000},
+			outputs:  []*wire.TxOut{wire.NewTxOut(60000, bobScript)},
+			allowed:  []string{alice},
+			wantRule: PolicyAllowlist,
+		},
+		{
+			name:     "denylisted recipient",
+			inputs:   []int64{100000},
+			outputs:  []*wire.TxOut{wire.NewTxOut(30000, aliceScript), wire.NewTxOut(30000, bobScript)},
+			denied:   []string{bob},
+			wantRule: PolicyDenylist,
+		},
+		{
+			name:           "change to the wallet ignores the allowlist",
+			inputs:         []int64{100000},
+			outputs:        []*wire.TxOut{wire.NewTxOut(60000, aliceScript), wire.NewTxOut(39000, walletScript)},
+			allowed:        []string{alice},
+			wantAmount:     60000,
+			wantFee:        1000,
+			wantOwnInput:   100000,
+			wantRecipients: []string{alice},
+		},
+		{
+			name:   "collaborative spend counts only the wallet's outflow",
+			inputs: []int64{100000, 500000},
+			own:    []bool{true, false},
+			outputs: []*wire.TxOut{
+				wire.NewTxOut(70000, walletScript),
+				wire.NewTxOut(25000, aliceScript),
+				wire.NewTxOut(504000, bobScript),
+			},
+			wantAmount:     30000,
+			wantFee:        1000,
+			wantOwnInput:   100000,
+			wantRecipients: []string{alice, bob},
+		},
+		{
+			name:   "collaborative spend paying the wallet counts nothing",
+			inputs: []int64{100000, 500000},
+			own:    []bool{true, false},
+			outputs: []*wire.TxOut{
+				wire.NewTxOut(150000, walletScript),
+				wire.NewTxOut(449000, bobScript),
+			},
+			wantFee:        1000,
+			wantOwnInput:   100000,
+			wantRecipients: []string{bob},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, prevOuts := testSpendTx(walletScript, tt.inputs, tt.outputs...)
+			allowed := make(map[string]bool)
+			for _, a := range tt.allowed {
+				allowed[a] = true
+			}
+			denied := make(map[string]bool)
+			for _, d := range tt.denied {
+				denied[d] = true
+			}
+
+			spend, ownInput, err := describeSpend(tx, prevOuts, tt.own, &chaincfg.MainNetParams, ours, allowed, denied)
+			if tt.wantRule != "" {
+				var violation *PolicyViolation
+				if !errors.As(err, &violation) || violation.Rule != tt.wantRule {
+					t.Fatalf("describeSpend error = %v, want %s violation", err, tt.wantRule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("describeSpend: %v", err)
+			}
+			if spend.Amount != tt.wantAmount || spend.Fee != tt.wantFee || ownInput != tt.wantOwnInput {
+				t.Errorf("amount, fee, own input = %d, %d, %d, want %d, %d, %d", spend.Amount, spend.Fee, ownInput, tt.wantAmount, tt.wantFee, tt.wantOwnInput)
+			}
+			if len(spend.Recipients) != len(tt.wantRecipients) {
+				t.Fatalf("recipients = %v, want %v", spend.Recipients, tt.wantRecipients)
+			}
+			for i, r := range tt.wantRecipients {
+				if spend.Recipients[i] != r {
+					t.Errorf("recipients = %v, want %v", spend.Recipients, tt.wantRecipients)
+				}
+			}
+			wantInputs := len(tt.inputs)
+			for _, own := range tt.own {
+				if !own {
+					wantInputs--
+				}
+			}
+			if len(spend.Inputs) != wantInputs {
+				t.Errorf("recorded %d inputs, want %d", len(spend.Inputs), wantInputs)
+			}
+		})
+	}
+}
+
+func TestSpendingPolicyEvaluate(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     SpendingPolicy
+		spend      walletstatedb.Spend
+		vbytes     int
+		inputTotal int64
+		usage      SpendingUsage
+		wantRule   string
+	}{
+		{
+			name:   "no rules",
+			spend:  walletstatedb.Spend{Amount: 1000000, Fee: 500000},
+			vbytes: 141,
+		},
+		{
+			name:   "at the per-transaction cap",
+			policy: SpendingPolicy{MaxTxAmount: 50000},
+			spend:  walletstatedb.Spend{Amount: 50000, Fee: 1000},
+			vbytes: 141,
+		},
+		{
+			name:     "over the per-transaction cap",
+			policy:   SpendingPolicy{MaxTxAmount: 50000},
+			spend:    walletstatedb.Spend{Amount: 50001, Fee: 1000},
+			vbytes:   141,
+			wantRule: PolicyMaxTxAmount,
+		},
+		{
+			name:   "at the fee rate cap",
+			policy: SpendingPolicy{MaxFeeRate: 10},
+			spend:  walletstatedb.Spend{Amount: 50000, Fee: 1410},
+			vbytes: 141,
+		},
+		{
+			name:     "over the fee rate cap",
+			policy:   SpendingPolicy{MaxFeeRate: 10},
+			spend:    walletstatedb.Spend{Amount: 50000, Fee: 1411},
+			vbytes:   141,
+			wantRule: PolicyMaxFeeRate,
+		},
+		{
+			name:   "fee within the percentage of the amount",
+			policy: SpendingPolicy{MaxFeePercent: 2},
+			spend:  walletstatedb.Spend{Amount: 50000, Fee: 1000},
+			vbytes: 141,
+		},
+		{
+			name:     "fee over the percentage of the amount",
+			policy:   SpendingPolicy{MaxFeePercent: 2},
+			spend:    walletstatedb.Spend{Amount: 50000, Fee: 1001},
+			vbytes:   141,
+			wantRule: PolicyMaxFeePercent,
+		},
+		{
+			name:       "self-spend fee weighed against the inputs",
+			policy:     SpendingPolicy{MaxFeePercent: 2},
+			spend:      walletstatedb.Spend{Fee: 1000},
+			vbytes:     141,
+			inputTotal: 100000,
+		},
+		{
+			name:       "self-spend fee over the percentage of the inputs",
+			policy:     SpendingPolicy{MaxFeePercent: 2},
+			spend:      walletstatedb.Spend{Fee: 2001},
+			vbytes:     141,
+			inputTotal: 100000,
+			wantRule:   PolicyMaxFeePercent,
+		},
+		{
+			name:   "fills the daily limit",
+			policy: SpendingPolicy{DailyLimit: 100000},
+			spend:  walletstatedb.Spend{Amount: 40000},
+			usage:  SpendingUsage{Daily: 60000, Monthly: 60000},
+		},
+		{
+			name:     "over the daily limit",
+			policy:   SpendingPolicy{DailyLimit: 100000},
+			spend:    walletstatedb.Spend{Amount: 40001},
+			usage:    SpendingUsage{Daily: 60000, Monthly: 60000},
+			wantRule: PolicyDailyLimit,
+		},
+		{
+			name:     "over the monthly limit",
+			policy:   SpendingPolicy{DailyLimit: 100000, MonthlyLimit: 200000},
+			spend:    walletstatedb.Spend{Amount: 40000},
+			usage:    SpendingUsage{Daily: 0, Monthly: 160001},
+			wantRule: PolicyMonthlyLimit,
+		},
+		{
+			name:     "per-transaction cap is checked first",
+			policy:   SpendingPolicy{MaxTxAmount: 10000, DailyLimit: 5000},
+			spend:    walletstatedb.Spend{Amount: 20000},
+			wantRule: PolicyMaxTxAmount,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spend := tt.spend
+			violation := tt.policy.evaluate(&spend, tt.vbytes, tt.inputTotal, tt.usage)
+			switch {
+			case tt.wantRule == "" && violation != nil:
+				t.Errorf("evaluate refused: %s", violation.Message)
+			case tt.wantRule != "" && violation == nil:
+				t.Errorf("evaluate allowed the spend, want %s violation", tt.wantRule)
+			case tt.wantRule != "" && violation.Rule != tt.wantRule:
+				t.Errorf("evaluate rule = %s, want %s", violation.Rule, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestCountsTowardLimits(t *testing.T) {
+	now := time.Now()
+	scheduled := map[string]bool{"scheduled": true}
+
+	tests := []struct {
+		name  string
+		spend walletstatedb.Spend
+		want  bool
+	}{
+		{
+			name:  "broadcast",
+			spend: walletstatedb.Spend{TxID: "a", CreatedAt: now.Add(-2 * time.Hour)},
+			want:  true,
+		},
+		{
+			name:  "recently signed",
+			spend: walletstatedb.Spend{TxID: "b", Pending: true, CreatedAt: now.Add(-pendingSpendTimeout + time.Minute)},
+			want:  true,
+		},
+		{
+			name:  "signed but never broadcast",
+			spend: walletstatedb.Spend{TxID: "c", Pending: true, CreatedAt: now.Add(-pendingSpendTimeout - time.Minute)},
+			want:  false,
+		},
+		{
+			name:  "scheduled past the timeout",
+			spend: walletstatedb.Spend{TxID: "scheduled", Pending: true, CreatedAt: now.Add(-48 * time.Hour)},
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		if got := countsTowardLimits(tt.spend, now, scheduled); got != tt.want {
+			t.Errorf("%s: countsTowardLimits = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRollingUsage(t *testing.T) {
+	now := time.Now()
+	spends := []walletstatedb.Spend{
+		{TxID: "today", Amount: 1000, Inputs: []string{"in:0"}, CreatedAt: now.Add(-time.Hour)},
+		{TxID: "yesterday", Amount: 2000, Inputs: []string{"in:1"}, CreatedAt: now.Add(-25 * time.Hour)},
+		{TxID: "last month", Amount: 4000, Inputs: []string{"in:2"}, CreatedAt: now.Add(-31 * 24 * time.Hour)},
+		{TxID: "abandoned", Amount: 8000, Inputs: []string{"in:3"}, Pending: true, CreatedAt: now.Add(-2 * time.Hour)},
+		{TxID: "scheduled", Amount: 16000, Inputs: []string{"in:4"}, Pending: true, CreatedAt: now.Add(-2 * time.Hour)},
+	}
+	scheduled := map[string]bool{"scheduled": true}
+
+	usage := rollingUsage(spends, now, scheduled, nil)
+	if usage.Daily != 17000 || usage.Monthly != 19000 {
+		t.Errorf("usage = %+v, want daily 17000 and monthly 19000", usage)
+	}
+
+	// A replacement of today's spend does not count it twice
+	usage = rollingUsage(spends, now, scheduled, map[string]bool{"in:0": true})
+	if usage.Daily != 16000 || usage.Monthly != 18000 {
+		t.Errorf("usage with a replacement = %+v, want daily 16000 and monthly 18000", usage)
+	}
+}
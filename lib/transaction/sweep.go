@@ -0,0 +1,220 @@
+package transaction
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/lightninglabs/neutrino"
+)
+
+// ParseOutpoint parses an outpoint in txid:vout form.
+func ParseOutpoint(s string) (wire.OutPoint, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 {
+		return wire.OutPoint{}, fmt.Errorf("invalid outpoint %q, expected txid:vout", s)
+	}
+	hash, err := chainhash.NewHashFromStr(parts[0])
+	if err != nil {
+		return wire.OutPoint{}, fmt.Errorf("invalid outpoint txid %q: %v", parts[0], err)
+	}
+	index, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return wire.OutPoint{}, fmt.Errorf("invalid outpoint index %q: %v", parts[1], err)
+	}
+	return wire.OutPoint{Hash: *hash, Index: uint32(index)}, nil
+}
+
+// ParseOutpoints parses a list of txid:vout strings. Empty entries are skipped.
+func ParseOutpoints(outpoints []string) ([]wire.OutPoint, error) {
+	var parsed []wire.OutPoint
+	for _, s := range outpoints {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		op, err := ParseOutpoint(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, op)
+	}
+	return parsed, nil
+}
+
+// filterUTXOsByOutpoint keeps only the UTXOs matching outpoints and fails if
+// any requested outpoint is not spendable by the wallet.
+func filterUTXOsByOutpoint(utxos []*btcjson.ListUnspentResult, outpoints []wire.OutPoint) ([]*btcjson.ListUnspentResult, error) {
+	byOutpoint := make(map[string]*btcjson.ListUnspentResult, len(utxos))
+	for _, utxo := range utxos {
+		byOutpoint[utxoOutpoint(utxo)] = utxo
+	}
+
+	var filtered []*btcjson.ListUnspentResult
+	seen := make(map[wire.OutPoint]bool)
+	for _, op := range outpoints {
+		if seen[op] {
+			continue
+		}
+		seen[op] = true
+		utxo, ok := byOutpoint[op.String()]
+		if !ok {
+			return nil, fmt.Errorf("outpoint %s is not a spendable wallet UTXO", op)
+		}
+		filtered = append(filtered, utxo)
+	}
+	return filtered, nil
+}
+
+// SweepCoins spends every economical UTXO in utxos to outputs, with the last
+// output receiving whatever is left after the fee. No change is created.
+// UTXOs worth less than the cost of spending them at feeRate are left out.
+func SweepCoins(utxos []*btcjson.ListUnspentResult, outputs []*wire.TxOut, feeRate int64) (*CoinSelection, error) {
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("sweep needs a destination output")
+	}
+
+	params := NewSelectionParams(outputs, feeRate)
+	candidates := buildCandidates(utxos, feeRate)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("insufficient funds: no UTXO is worth spending at %d sat/vB", feeRate)
+	}
+
+	selection := &CoinSelection{Strategy: "sweep"}
+	inputVBytes := 0
+	for _, c := range candidates {
+		selection.Inputs = append(selection.Inputs, c.utxo)
+		selection.Total += c.value
+		inputVBytes += c.vbytes
+	}
+	selection.VBytes = params.BaseVBytes + inputVBytes
+	selection.Fee = btcutil.Amount(int64(selection.VBytes) * feeRate)
+
+	// Every output but the last keeps its fixed amount
+	fixed := params.Target - btcutil.Amount(outputs[len(outputs)-1].Value)
+	sweepAmount := selection.Total - selection.Fee - fixed
+	if sweepAmount < DustLimit() {
+		return nil, fmt.Errorf("insufficient funds: %d satoshis left after a %d satoshi fee is below the dust limit", sweepAmount, selection.Fee)
+	}
+	outputs[len(outputs)-1].Value = int64(sweepAmount)
+
+	return selection, nil
+}
+
+// sweepWalletCoins lists the wallet's confirmed UTXOs, restricted to
+// outpoints when any are given, and sweeps them to outputs. Frozen UTXOs are
+// left out.
+func sweepWalletCoins(w *wallet.Wallet, outputs []*wire.TxOut, outpoints []wire.OutPoint, feeRate int64) (*CoinSelection, error) {
+	var utxos []*btcjson.ListUnspentResult
+	var err error
+	if len(outpoints) > 0 {
+		utxos, err = listRequestedUTXOs(w, outpoints)
+	} else {
+		utxos, err = listSpendableUTXOs(w)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Sweeping from %d unspent outputs.", len(utxos))
+	return SweepCoins(utxos, outputs, feeRate)
+}
+
+// CalculateMaxSendable returns the largest amount that can be sent to
+// recipientAddress at feeRate without change, and the size of that
+// transaction in vbytes.
+func CalculateMaxSendable(w *wallet.Wallet, recipientAddress string, outpoints []wire.OutPoint, feeRate int) (btcutil.Amount, int, error) {
+	recipientAddr, err := btcutil.DecodeAddress(recipientAddress, w.ChainParams())
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode recipient address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(recipientAddr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create output script: %v", err)
+	}
+
+	output := wire.NewTxOut(0, pkScript)
+	selection, err := sweepWalletCoins(w, []*wire.TxOut{output}, outpoints, int64(feeRate))
+	if err != nil {
+		return 0, 0, err
+	}
+	return btcutil.Amount(output.Value), selection.VBytes, nil
+}
+
+// SweepTransaction sends the wallet's whole spendable balance, or just the
+// given outpoints, to recipientAddress with no change output.
+func SweepTransaction(w *wallet.Wallet, service *neutrino.ChainService, enableRBF bool, recipientAddress string, outpoints []wire.OutPoint, privPass []byte, feeRate int) (chainhash.Hash, bool, error) {
+	log.Printf("Starting sweep to %s.", recipientAddress)
+	// Reset locked outpoints
+	w.ResetLockedOutpoints()
+
+	// Unlock wallet
+	log.Printf("Unlocking wallet.")
+	err := unlockWallet(w, privPass)
+	if err != nil {
+		log.Printf("Failed to unlock wallet: %v", err)
+		return chainhash.Hash{}, false, err
+	}
+
+	recipientAddr, err := btcutil.DecodeAddress(recipientAddress, w.ChainParams())
+	if err != nil {
+		return chainhash.Hash{}, false, fmt.Errorf("failed to decode recipient address: %v", err)
+	}
+	if !recipientAddr.IsForNet(w.ChainParams()) {
+		return chainhash.Hash{}, false, fmt.Errorf("address %s is not for %s", recipientAddress, w.ChainParams().Name)
+	}
+	pkScript, err := txscript.PayToAddrScript(recipientAddr)
+	if err != nil {
+		return chainhash.Hash{}, false, fmt.Errorf("failed to create output script: %v", err)
+	}
+
+	outputs := []*wire.TxOut{wire.NewTxOut(0, pkScript)}
+	selection, err := sweepWalletCoins(w, outputs, outpoints, int64(feeRate))
+	if err != nil {
+		log.Printf("Sweep failed: %v", err)
+		return chainhash.Hash{}, false, err
+	}
+
+	tx, err := buildUnsignedTransaction(w, enableRBF, outputs, selection)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	if err := signTransactionInputs(w, tx, selection.Inputs); err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	log.Printf("Sweep transaction created successfully. Details:")
+	log.Printf("  TxID: %s", tx.TxHash().String())
+	log.Printf("  Amount sent: %d satoshis", outputs[0].Value)
+	log.Printf("  Fee: %d satoshis", selection.Fee)
+	log.Printf("  Estimated size: %d vBytes", selection.VBytes)
+	log.Printf("  Number of inputs: %d", len(tx.TxIn))
+
+	// Save the transaction in the database
+	_, err = walletstatedb.SaveTransactionToDB(tx)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+
+	txHash, verified, err := broadcastAndVerifyTransaction(tx, service)
+	if err != nil {
+		// Release the output we tried to spend
+		releaseErr := w.ReleaseOutput(wtxmgr.LockID(tx.TxHash()), tx.TxIn[0].PreviousOutPoint)
+		if releaseErr != nil {
+			log.Printf("Failed to release output: %v", releaseErr)
+		}
+		releaseSpend(tx)
+		return chainhash.Hash{}, false, fmt.Errorf("failed to broadcast and verify transaction: %v", err)
+	}
+
+	log.Println("Sweep transaction broadcast and verified successfully.")
+	return txHash, verified, nil
+}
@@ -0,0 +1,192 @@
+package transaction
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"time"
+
+	walletstatedb "github.com/Maphikza/btc-wallet-btcsuite.git/internal/database"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/lightninglabs/neutrino"
+)
+
+// LockTimeReached reports whether a transaction with lockTime can be mined in
+// the block after a tip at height with the given timestamp. Locktimes below
+// txscript.LockTimeThreshold are block heights, the rest are unix times.
+// Nodes compare time locks against the median time past, which trails the tip
+// timestamp, so a time-locked broadcast may still be refused for a while and
+// is simply retried.
+func LockTimeReached(lockTime uint32, height int32, timestamp time.Time) bool {
+	if lockTime < txscript.LockTimeThreshold {
+		return int64(lockTime) <= int64(height)
+	}
+	return int64(lockTime) < timestamp.Unix()
+}
+
+// DescribeLockTime renders a locktime as a block height or a UTC time.
+func DescribeLockTime(lockTime uint32) string {
+	if lockTime < txscript.LockTimeThreshold {
+		return fmt.Sprintf("block %d", lockTime)
+	}
+	return time.Unix(int64(lockTime), 0).UTC().Format(time.RFC3339)
+}
+
+// CreateScheduledTransaction signs a payment that cannot be mined before
+// lockTime and stores it as scheduled instead of broadcasting it. Its inputs
+// are frozen so other spends do not pick them up in the meantime.
+func CreateScheduledTransaction(w *wallet.Wallet, spendAmount int64, recipientAddress string, lockTime uint32, privPass []byte, feeRate int, inputs []wire.OutPoint) (*walletstatedb.ScheduledTransaction, error) {
+	if lockTime == 0 {
+		return nil, fmt.Errorf("locktime must be a block height or unix time")
+	}
+	log.Printf("Scheduling payment of %d satoshis to %s for %s", spendAmount, recipientAddress, DescribeLockTime(lockTime))
+
+	// Unlock wallet
+	log.Printf("Unlocking wallet.")
+	err := unlockWallet(w, privPass)
+	if err != nil {
+		log.Printf("Failed to unlock wallet: %v", err)
+		return nil, err
+	}
+
+	recipientAddr, err := btcutil.DecodeAddress(recipientAddress, w.ChainParams())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode recipient address: %v", err)
+	}
+	if !recipientAddr.IsForNet(w.ChainParams()) {
+		return nil, fmt.Errorf("address %s is not for %s", recipientAddress, w.ChainParams().Name)
+	}
+	pkScript, err := txscript.PayToAddrScript(recipientAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output script: %v", err)
+	}
+	outputs := []*wire.TxOut{wire.NewTxOut(spendAmount, pkScript)}
+
+	selection, err := selectSpendCoins(w, outputs, int64(feeRate), inputs)
+	if err != nil {
+		return nil, fmt.Errorf("coin selection failed: %v", err)
+	}
+
+	// The locktime is only enforced when an input has a non-final sequence,
+	// which the RBF sequence already is
+	tx, err := buildUnsignedTransaction(w, true, outputs, selection)
+	if err != nil {
+		return nil, err
+	}
+	tx.LockTime = lockTime
+
+	if err := signTransactionInputs(w, tx, selection.Inputs); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return nil, fmt.Errorf("failed to serialize transaction: %v", err)
+	}
+
+	var spent []wire.OutPoint
+	var spentStrs []string
+	for _, txIn := range tx.TxIn {
+		spent = append(spent, txIn.PreviousOutPoint)
+		spentStrs = append(spentStrs, txIn.PreviousOutPoint.String())
+	}
+
+	scheduled := &walletstatedb.ScheduledTransaction{
+		TxID:      tx.TxHash().String(),
+		RawTx:     buf.Bytes(),
+		LockTime:  lockTime,
+		Recipient: recipientAddress,
+		Amount:    spendAmount,
+		Fee:       int64(selection.Fee),
+		Inputs:    spentStrs,
+		Status:    walletstatedb.ScheduledStatusScheduled,
+	}
+	if err := walletstatedb.SaveScheduledTransaction(scheduled); err != nil {
+		return nil, fmt.Errorf("failed to save scheduled transaction: %v", err)
+	}
+	if err := FreezeOutpoints(spent, true); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Scheduled transaction %s for %s", scheduled.TxID, DescribeLockTime(lockTime))
+	return scheduled, nil
+}
+
+// CancelScheduledTransaction drops a scheduled transaction that has not been
+// broadcast yet and releases its inputs.
+func CancelScheduledTransaction(txID string) error {
+	scheduled, err := walletstatedb.GetScheduledTransaction(txID)
+	if err != nil {
+		return err
+	}
+	if scheduled.Status != walletstatedb.ScheduledStatusScheduled {
+		return fmt.Errorf("transaction %s is %s and can no longer be cancelled", txID, scheduled.Status)
+	}
+
+	if err := walletstatedb.UpdateScheduledTransactionStatus(txID, walletstatedb.ScheduledStatusCancelled, ""); err != nil {
+		return fmt.Errorf("failed to cancel scheduled transaction: %v", err)
+	}
+	// It was counted against the spending limits when signed
+	if err := walletstatedb.DeleteSpend(txID); err != nil {
+		log.Printf("Failed to release spend of %s: %v", txID, err)
+	}
+
+	inputs, err := ParseOutpoints(scheduled.Inputs)
+	if err != nil {
+		return err
+	}
+	return FreezeOutpoints(inputs, false)
+}
+
+// BroadcastDueTransactions broadcasts every scheduled transaction whose
+// locktime the chain tip has reached and returns how many went out. Failed
+// broadcasts stay scheduled and are retried on the next call.
+func BroadcastDueTransactions(service *neutrino.ChainService) (int, error) {
+	scheduled, err := walletstatedb.GetScheduledTransactions(walletstatedb.ScheduledStatusScheduled)
+	if err != nil {
+		return 0, err
+	}
+	if len(scheduled) == 0 {
+		return 0, nil
+	}
+
+	bestBlock, err := service.BestBlock()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get best block: %v", err)
+	}
+
+	broadcast := 0
+	for _, st := range scheduled {
+		if !LockTimeReached(st.LockTime, bestBlock.Height, bestBlock.Timestamp) {
+			continue
+		}
+
+		tx := wire.NewMsgTx(wire.TxVersion)
+		if err := tx.Deserialize(bytes.NewReader(st.RawTx)); err != nil {
+			log.Printf("Failed to deserialize scheduled transaction %s: %v", st.TxID, err)
+			continue
+		}
+
+		log.Printf("Locktime %s reached, broadcasting scheduled transaction %s", DescribeLockTime(st.LockTime), st.TxID)
+		if _, _, err := broadcastAndVerifyTransaction(tx, service); err != nil {
+			log.Printf("Failed to broadcast scheduled transaction %s: %v", st.TxID, err)
+			if updateErr := walletstatedb.UpdateScheduledTransactionStatus(st.TxID, walletstatedb.ScheduledStatusScheduled, err.Error()); updateErr != nil {
+				log.Printf("Failed to record broadcast error: %v", updateErr)
+			}
+			continue
+		}
+
+		if _, err := walletstatedb.SaveTransactionToDB(tx); err != nil {
+			log.Printf("Failed to save broadcast transaction %s: %v", st.TxID, err)
+		}
+		if err := walletstatedb.UpdateScheduledTransactionStatus(st.TxID, walletstatedb.ScheduledStatusBroadcast, ""); err != nil {
+			log.Printf("Failed to mark %s as broadcast: %v", st.TxID, err)
+		}
+		broadcast++
+	}
+
+	return broadcast, nil
+}
@@ -1,12 +1,14 @@
 package transaction
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"sort"
 
+	"github.com/Maphikza/btc-wallet-btcsuite.git/internal/wallet/utils"
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/txscript"
@@ -14,17 +16,17 @@ import (
 	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/wallet"
 	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/spf13/viper"
 	"golang.org/x/exp/rand"
 )
 
-// Verify the signature of a transaction input
-func verifySignature(tx *wire.MsgTx, index int, scriptPubKey []byte, amount int64) (bool, error) {
+// verifySignatureWithFetcher verifies input index of tx against prevOut using
+// a fetcher that knows every spent output, as taproot sighashes require.
+func verifySignatureWithFetcher(tx *wire.MsgTx, index int, prevOut *wire.TxOut, prevOutputs txscript.PrevOutputFetcher) (bool, error) {
 	flags := txscript.StandardVerifyFlags
 
-	// Create the PrevOutputFetcher with the previous output's information
-	prevOutputs := txscript.NewCannedPrevOutputFetcher(scriptPubKey, amount)
-
-	engine, err := txscript.NewEngine(scriptPubKey, tx, index, flags, nil, nil, amount, prevOutputs)
+	sigHashes := txscript.NewTxSigHashes(tx, prevOutputs)
+	engine, err := txscript.NewEngine(prevOut.PkScript, tx, index, flags, nil, sigHashes, prevOut.Value, prevOutputs)
 	if err != nil {
 		return false, fmt.Errorf("failed to create script engine: %v", err)
 	}
@@ -35,6 +37,14 @@ func verifySignature(tx *wire.MsgTx, index int, scriptPubKey []byte, amount int6
 	return true, nil
 }
 
+func decodeScriptPubKey(scriptPubKey string) ([]byte, error) {
+	script, err := hex.DecodeString(scriptPubKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode scriptPubKey: %v", err)
+	}
+	return script, nil
+}
+
 // Helper function to check if an address is SegWit
 func isSegWitAddress(addr btcutil.Address) bool {
 	switch addr.(type) {
@@ -45,33 +55,31 @@ func isSegWitAddress(addr btcutil.Address) bool {
 	}
 }
 
-func selectAdditionalUTXOs(w *wallet.Wallet, amount btcutil.Amount) ([]*btcjson.ListUnspentResult, btcutil.Amount, error) {
-	utxos, err := w.ListUnspent(1, 9999999, "")
+// selectAdditionalUTXOs funds a shortfall of amount, which already covers the
+// fee of the transaction as built so far. Each extra input pays for its own
+// size at feeRate, as does a change output of changeVBytes when the caller
+// adds one that amount does not account for.
+func selectAdditionalUTXOs(w *wallet.Wallet, amount btcutil.Amount, feeRate int64, changeVBytes int) ([]*btcjson.ListUnspentResult, btcutil.Amount, error) {
+	utxos, err := listSpendableUTXOs(w)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to list unspent outputs: %v", err)
+		return nil, 0, err
 	}
 
-	var selectedUTXOs []*btcjson.ListUnspentResult
-	var totalSelected btcutil.Amount
-
-	// Sort UTXOs by amount in descending order
-	sort.Slice(utxos, func(i, j int) bool {
-		return utxos[i].Amount > utxos[j].Amount
-	})
-
-	for _, utxo := range utxos {
-		if totalSelected >= amount {
-			break
-		}
-		selectedUTXOs = append(selectedUTXOs, utxo)
-		totalSelected += btcutil.Amount(utxo.Amount * btcutil.SatoshiPerBitcoin)
+	selector, err := NewCoinSelector(viper.GetString("coin_selection"))
+	if err != nil {
+		return nil, 0, err
 	}
-
-	if totalSelected < amount {
-		return nil, 0, fmt.Errorf("insufficient funds to cover additional fee")
+	selection, err := selector.Select(utxos, SelectionParams{
+		Target:       amount,
+		FeeRate:      feeRate,
+		ChangeVBytes: changeVBytes,
+		DustLimit:    DustLimit(),
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("insufficient funds to cover additional fee: %v", err)
 	}
 
-	return selectedUTXOs, totalSelected, nil
+	return selection.Inputs, selection.Total, nil
 }
 
 func fetchUTXO(w *wallet.Wallet, outpoint *wire.OutPoint) (*btcjson.ListUnspentResult, error) {
@@ -136,10 +144,15 @@ func VerifyUTXO(txID string, vout uint32) error {
 }
 
 func findUnusedChangeAddress(w *wallet.Wallet) (btcutil.Address, error) {
+	if multisig.Active() != nil {
+		return findUnusedMultisigChangeAddress(w)
+	}
+
 	var maxAddressesToCheck uint32
+	scope := utils.AddressKeyScope()
 
 	err := walletdb.View(w.Database(), func(tx walletdb.ReadTx) error {
-		scopedMgr, err := w.Manager.FetchScopedKeyManager(waddrmgr.KeyScopeBIP0084)
+		scopedMgr, err := w.Manager.FetchScopedKeyManager(scope)
 		if err != nil {
 			return fmt.Errorf("failed to fetch scoped key manager: %v", err)
 		}
@@ -170,7 +183,7 @@ func findUnusedChangeAddress(w *wallet.Wallet) (btcutil.Address, error) {
 	for i := uint32(0); i < maxAddressesToCheck; i++ {
 		var addr btcutil.Address
 		err = walletdb.View(w.Database(), func(tx walletdb.ReadTx) error {
-			scopedMgr, err := w.Manager.FetchScopedKeyManager(waddrmgr.KeyScopeBIP0084)
+			scopedMgr, err := w.Manager.FetchScopedKeyManager(scope)
 			if err != nil {
 				return fmt.Errorf("failed to fetch scoped key manager: %v", err)
 			}
@@ -204,7 +217,7 @@ func findUnusedChangeAddress(w *wallet.Wallet) (btcutil.Address, error) {
 	}
 
 	// If no unused address found, create a new one
-	newAddr, err := w.NewChangeAddress(0, waddrmgr.KeyScopeBIP0084)
+	newAddr, err := w.NewChangeAddress(0, scope)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate new change address: %v", err)
 	}
@@ -219,3 +232,131 @@ func getChangeAddress(w *wallet.Wallet) (btcutil.Address, error) {
 	}
 	return changeAddr, nil
 }
+
+// changeOutputIndex returns the index of the last output of tx paying to one
+// of the wallet's internal (change) addresses, or -1 if there is none.
+func changeOutputIndex(w *wallet.Wallet, tx *wire.MsgTx) int {
+	index := -1
+	for i, txOut := range tx.TxOut {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript, w.ChainParams())
+		if err != nil || len(addrs) == 0 {
+			continue
+		}
+		info, err := w.AddressInfo(addrs[0])
+		if err != nil {
+			continue
+		}
+		if info.Internal() || isMultisigChange(addrs[0]) {
+			index = i
+		}
+	}
+	return index
+}
+
+// signTransactionInputs signs every input of tx with the wallet key for the
+// matching UTXO in utxos, which must be in input order, and verifies each
+// signature against its script. The spending policy is checked first and the
+// spend recorded once signed. P2TR inputs get a BIP86 key-path Schnorr
+// signature, which commits to every spent output; P2SH inputs are spent as
+// nested P2WPKH and anything else that is not segwit as legacy P2PKH.
+func signTransactionInputs(w *wallet.Wallet, tx *wire.MsgTx, utxos []*btcjson.ListUnspentResult) error {
+	if len(utxos) != len(tx.TxIn) {
+		return fmt.Errorf("have %d UTXOs for %d inputs", len(utxos), len(tx.TxIn))
+	}
+
+	prevOutputs := txscript.NewMultiPrevOutFetcher(nil)
+	prevOuts := make([]*wire.TxOut, len(utxos))
+	for i, utxo := range utxos {
+		scriptPubKey, err := decodeScriptPubKey(utxo.ScriptPubKey)
+		if err != nil {
+			return err
+		}
+		prevOuts[i] = wire.NewTxOut(int64(UTXOAmount(utxo)), scriptPubKey)
+		prevOutputs.AddPrevOut(tx.TxIn[i].PreviousOutPoint, prevOuts[i])
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, prevOutputs)
+
+	spendingMutex.Lock()
+	defer spendingMutex.Unlock()
+	spend, err := checkSpendingPolicy(w, tx, prevOuts, nil)
+	if err != nil {
+		return err
+	}
+
+	for i, utxo := range utxos {
+		utxoAddr, err := btcutil.DecodeAddress(utxo.Address, w.ChainParams())
+		if err != nil {
+			log.Printf("Failed to decode UTXO address: %v", err)
+			return fmt.Errorf("failed to decode UTXO address: %v", err)
+		}
+		privKey, err := w.PrivKeyForAddress(utxoAddr)
+		if err != nil {
+			log.Printf("Failed to get private key for address: %v", err)
+			return fmt.Errorf("failed to get private key for address: %v", err)
+		}
+		prevOut := prevOuts[i]
+
+		switch {
+		case txscript.IsPayToTaproot(prevOut.PkScript):
+			// BIP86 key-path spend: the key is tweaked with an empty script root
+			sig, err := txscript.RawTxInTaprootSignature(tx, sigHashes, i, prevOut.Value, prevOut.PkScript, nil, txscript.SigHashDefault, privKey)
+			if err != nil {
+				log.Printf("Failed to create taproot signature for input %d: %v", i, err)
+				return fmt.Errorf("failed to create taproot signature for input %d: %v", i, err)
+			}
+			tx.TxIn[i].Witness = wire.TxWitness{sig}
+
+		case txscript.IsPayToScriptHash(prevOut.PkScript):
+			// Nested segwit (BIP49): sign the P2WPKH program the script hash
+			// commits to and reveal it in the signature script
+			pubKeyHash := btcutil.Hash160(privKey.PubKey().SerializeCompressed())
+			witnessProgram, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(pubKeyHash).Script()
+			if err != nil {
+				return fmt.Errorf("failed to build witness program for input %d: %v", i, err)
+			}
+			witnessScript, err := txscript.WitnessSignature(tx, sigHashes, i, prevOut.Value, witnessProgram, txscript.SigHashAll, privKey, true)
+			if err != nil {
+				log.Printf("Failed to create witness script for input %d: %v", i, err)
+				return fmt.Errorf("failed to create witness script for input %d: %v", i, err)
+			}
+			sigScript, err := txscript.NewScriptBuilder().AddData(witnessProgram).Script()
+			if err != nil {
+				return fmt.Errorf("failed to build signature script for input %d: %v", i, err)
+			}
+			tx.TxIn[i].Witness = witnessScript
+			tx.TxIn[i].SignatureScript = sigScript
+
+		case isSegWitAddress(utxoAddr):
+			// Create the witness script for SegWit inputs
+			witnessScript, err := txscript.WitnessSignature(tx, sigHashes, i, prevOut.Value, prevOut.PkScript, txscript.SigHashAll, privKey, true)
+			if err != nil {
+				log.Printf("Failed to create witness script for input %d: %v", i, err)
+				return fmt.Errorf("failed to create witness script for input %d: %v", i, err)
+			}
+			tx.TxIn[i].Witness = witnessScript
+
+		default:
+			// Create the signature script for non-SegWit inputs
+			sigScript, err := txscript.SignatureScript(tx, i, prevOut.PkScript, txscript.SigHashAll, privKey, true)
+			if err != nil {
+				log.Printf("Failed to create signature script for input %d: %v", i, err)
+				return fmt.Errorf("failed to create signature script for input %d: %v", i, err)
+			}
+			tx.TxIn[i].SignatureScript = sigScript
+		}
+
+		// Verify the signature for each input
+		valid, err := verifySignatureWithFetcher(tx, i, prevOut, prevOutputs)
+		if err != nil {
+			log.Printf("Failed to verify signature for input %d: %v", i, err)
+			return fmt.Errorf("failed to verify signature for input %d: %v", i, err)
+		}
+		if !valid {
+			log.Printf("Signature verification failed for input %d", i)
+			return fmt.Errorf("signature verification failed for input %d", i)
+		}
+		log.Printf("Signature verification succeeded for input %d", i)
+	}
+
+	return recordSpend(spend, tx)
+}
@@ -0,0 +1,56 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Maphikza/btc-wallet-btcsuite.git/lib/multisig"
+	"github.com/btcsuite/btcwallet/wallet"
+)
+
+// ErrWatchOnly is returned by every operation that needs private keys when
+// the wallet was created from an extended public key.
+var ErrWatchOnly = errors.New("watch-only wallet holds no private keys: create an unsigned PSBT and sign it on the machine that holds the seed")
+
+// ErrWalletLocked is returned by signing operations when the wallet runs with
+// unlock sessions and none is open.
+var ErrWalletLocked = errors.New("wallet locked: unlock it for a signing session first")
+
+// IsWatchOnly reports whether w holds only public keys.
+func IsWatchOnly(w *wallet.Wallet) bool {
+	return w.Manager.WatchOnly()
+}
+
+// CheckUnlocked reports ErrWalletLocked when w can sign only inside an unlock
+// session, because no passphrase is held, and none is open. Multisig wallets
+// are locked while their signing key is not loaded. Other watch-only wallets
+// never sign and always pass.
+func CheckUnlocked(w *wallet.Wallet, privPass []byte) error {
+	if multisig.Active() != nil {
+		if multisig.HasSigningKey() && multisig.Signer() == nil {
+			return ErrWalletLocked
+		}
+		return nil
+	}
+	if privPass == nil && !IsWatchOnly(w) && w.Locked() {
+		return ErrWalletLocked
+	}
+	return nil
+}
+
+// unlockWallet unlocks w for signing. Watch-only wallets are refused before
+// the passphrase is tried so callers get ErrWatchOnly rather than a decryption
+// failure. Without a passphrase the wallet must already be unlocked by a
+// session.
+func unlockWallet(w *wallet.Wallet, privPass []byte) error {
+	if IsWatchOnly(w) {
+		return ErrWatchOnly
+	}
+	if privPass == nil {
+		return CheckUnlocked(w, privPass)
+	}
+	if err := w.Unlock(privPass, nil); err != nil {
+		return fmt.Errorf("failed to unlock wallet: %v", err)
+	}
+	return nil
+}